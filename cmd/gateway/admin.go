@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/middleware"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// buildAdminRouter serves the admin API, /metrics and optionally pprof on
+// the admin listener. Like the public router it is rebuilt on reload.
+func buildAdminRouter(cfg *config.Config, deps *dependencies) *gin.Engine {
+	router := newRouteEngine(cfg, deps.logger)
+	router.Use(middleware.Recovery(deps.logger))
+	router.Use(middleware.RequestLogger(deps.logger))
+	router.Use(middleware.RequestID())
+
+	router.NoRoute(func(c *gin.Context) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Route not found")
+	})
+
+	router.GET("/health", deps.healthHandler.Health)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	var auth []gin.HandlerFunc
+	switch cfg.Admin.Auth {
+	case "token":
+		auth = []gin.HandlerFunc{middleware.AdminToken(cfg.Admin.Token)}
+	case "none":
+		auth = []gin.HandlerFunc{middleware.LocalAdmin()}
+	default:
+		auth = []gin.HandlerFunc{newJWTAuth(cfg, deps), middleware.RoleAuth("admin")}
+	}
+
+	admin := router.Group("/api/v1/admin", middleware.BodyLimit(cfg.Server.MaxBodySize))
+	admin.Use(auth...)
+	registerAdminRoutes(admin, deps)
+
+	if cfg.Admin.Pprof {
+		debug := router.Group("/debug/pprof", auth...)
+		debug.Any("/*profile", func(c *gin.Context) {
+			switch c.Param("profile") {
+			case "/cmdline":
+				pprof.Cmdline(c.Writer, c.Request)
+			case "/profile":
+				pprof.Profile(c.Writer, c.Request)
+			case "/symbol":
+				pprof.Symbol(c.Writer, c.Request)
+			case "/trace":
+				pprof.Trace(c.Writer, c.Request)
+			default:
+				pprof.Index(c.Writer, c.Request)
+			}
+		})
+	}
+
+	return router
+}
+
+// startAdminServer serves the admin router on its own address. There is
+// no write timeout, pprof profiles take as long as they were asked to.
+func startAdminServer(cfg config.AdminConfig, handler http.Handler, log *logger.Logger) *http.Server {
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, _, err := buildTLS(cfg.TLS, 0)
+		if err != nil {
+			log.Fatal("Failed to configure admin TLS", "error", err)
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	go func() {
+		var err error
+		log.Info("Admin listener started", "addr", cfg.Addr, "tls", cfg.TLS.Enabled, "auth", cfg.Auth)
+		if cfg.TLS.Enabled {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("Admin listener failed", "error", err)
+		}
+	}()
+	return server
+}
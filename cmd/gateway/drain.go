@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+)
+
+// drain fails readiness, gives load balancers DrainDelay to stop routing to
+// this instance and then waits for in-flight proxied requests
+func drain(drainer *service.Drainer, cfg config.ServerConfig, log *logger.Logger) {
+	drainer.Begin()
+	log.Info("Draining", "in_flight", drainer.InFlight(), "delay", cfg.DrainDelay.String())
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+
+	if err := drainer.Wait(ctx); err != nil {
+		log.Warnw("Drain timed out with requests still in flight", "in_flight", drainer.InFlight())
+		return
+	}
+	log.Info("Drain complete")
+}
+
+// listen binds the gateway port, optionally with SO_REUSEPORT so that a new
+// process can bind the same port before this one exits
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"api-gateway/pkg/utils"
+)
+
+// runKeygen implements `gateway keygen`: it prints a new PEM encoded
+// private key for JWT_PRIVATE_KEY_FILE and returns the exit code
+func runKeygen(args []string) int {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	algorithm := flags.String("alg", utils.AlgRS256, "signing algorithm, RS256 or EdDSA")
+	flags.Parse(args)
+
+	key, err := utils.GeneratePrivateKeyPEM(*algorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+		return 1
+	}
+	fmt.Print(key)
+	return 0
+}
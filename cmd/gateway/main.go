@@ -2,128 +2,560 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"api-gateway/internal/accesslog"
+	"api-gateway/internal/alerting"
+	"api-gateway/internal/analytics"
 	"api-gateway/internal/circuit"
 	"api-gateway/internal/config"
+	"api-gateway/internal/events"
 	"api-gateway/internal/handler"
-	"api-gateway/internal/middleware"
+	"api-gateway/internal/mailer"
+	"api-gateway/internal/messaging"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/ratelimit"
 	"api-gateway/internal/service"
+	"api-gateway/internal/spiffe"
+	"api-gateway/internal/tracing"
+	"api-gateway/internal/transform"
 	"api-gateway/pkg/logger"
 	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pires/go-proxyproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "keygen":
+			os.Exit(runKeygen(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		}
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
 
-	log := logger.NewLogger(cfg.Logging.Level)
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("Invalid config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Lint(); err != nil && cfg.Server.StrictConfig {
+		fmt.Printf("Config has ignored settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	log := logger.New(cfg.Logging.LoggerOptions())
 	defer log.Sync()
 
+	if err := cfg.Lint(); err != nil {
+		for _, problem := range unjoin(err) {
+			log.Warnw("Config setting ignored", "problem", problem.Error())
+		}
+	}
+
 	log.Info("Starting API Gateway")
 
-	mongoClient, err := storage.NewMongoClient(cfg.MongoDB)
+	st, mongoClient, closeStorage, err := dialStorage(cfg)
 	if err != nil {
-		log.Fatal("MongoDB connection failed", "error", err)
+		log.Fatal("Storage setup failed", "backend", cfg.Storage.Backend, "error", err)
 	}
-	defer mongoClient.Close()
+	defer closeStorage()
 
-	redisClient, err := storage.NewRedisClient(cfg.Redis)
+	redisClient := storage.DialRedis(cfg.Redis)
+	defer redisClient.Close()
+	pingRedis := func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }
+
+	// Dependencies started alongside the gateway may take a while to come up
+	startupDeadline := time.Now().Add(cfg.Startup.RetryTimeout)
+	var storageErr, redisErr error
+	var awaiting sync.WaitGroup
+	awaiting.Add(2)
+	go func() {
+		defer awaiting.Done()
+		storageErr = awaitDependency(context.Background(), "storage", st.Ping, cfg.Startup, startupDeadline, log)
+	}()
+	go func() {
+		defer awaiting.Done()
+		redisErr = awaitDependency(context.Background(), "redis", pingRedis, cfg.Startup, startupDeadline, log)
+	}()
+	awaiting.Wait()
+	if storageErr != nil && !cfg.Startup.ServeDegraded {
+		log.Fatal("Storage connection failed", "backend", cfg.Storage.Backend, "error", storageErr)
+	}
+	if redisErr != nil && !cfg.Startup.ServeDegraded {
+		log.Fatal("Redis connection failed", "error", redisErr)
+	}
+
+	switch {
+	case storageErr != nil:
+		log.Warnw("Starting without storage, retrying in the background", "backend", cfg.Storage.Backend, "error", storageErr)
+	case autoMigrate(cfg):
+		if err := migrateStorage(st, log); err != nil {
+			log.Fatal("Migration failed", "backend", cfg.Storage.Backend, "error", err)
+		}
+	}
+	if redisErr != nil {
+		log.Warnw("Starting without Redis, retrying in the background", "error", redisErr)
+	}
+	if storageErr == nil && redisErr == nil {
+		log.Info("Database connections established")
+	}
+
+	notifier, err := events.New(cfg.Events, redisClient, log)
 	if err != nil {
-		log.Fatal("Redis connection failed", "error", err)
+		log.Fatal("Event notifications setup failed", "error", err)
 	}
-	defer redisClient.Close()
 
-	log.Info("Database connections established")
+	registry := service.NewRegistry(cfg.Services, notifier)
+	loadBalancer := service.NewLoadBalancer(cfg.Server.Zone)
+	breakerManager := circuit.NewBreakerManager(cfg.CircuitBreaker, notifier, redisClient, log)
 
-	registry := service.NewRegistry(cfg.Services)
-	loadBalancer := service.NewLoadBalancer()
-	breakerManager := circuit.NewBreakerManager(cfg.CircuitBreaker)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatal("Tracing setup failed", "error", err)
+	}
 
-	authHandler := handler.NewAuthHandler(mongoClient, cfg, log)
-	proxyHandler := handler.NewProxyHandler(registry, loadBalancer, breakerManager, log)
-	healthHandler := handler.NewHealthHandler(redisClient, mongoClient)
+	go breakerManager.Start(ctx, time.Minute)
+
+	// Upstreams with a SPIFFE ID can't be reached without an SVID
+	var svids *spiffe.Source
+	if cfg.SPIFFE.Enabled {
+		svids = spiffe.NewSource(cfg.SPIFFE.SocketPath, log)
+		go svids.Start(ctx)
+
+		fetchCtx, cancelFetch := context.WithTimeout(ctx, cfg.SPIFFE.FetchTimeout)
+		err := svids.WaitReady(fetchCtx)
+		cancelFetch()
+		if err != nil {
+			log.Fatal("SPIFFE SVID fetch failed", "socket", cfg.SPIFFE.SocketPath, "error", err)
+		}
+	}
+
+	discovery := service.NewDiscovery(registry, cfg.Discovery, log)
+	discovery.Start(ctx, cfg.Services)
+
+	if cfg.HealthCheck.Enabled {
+		healthChecker := service.NewHealthChecker(registry, cfg.HealthCheck, svids, log)
+		go healthChecker.Start(ctx)
+	}
+
+	revocation := service.NewTokenRevocation(redisClient, cfg.JWT.Expiry)
+	sessions := service.NewSessionStore(redisClient, cfg.Session)
+	// API keys, signing clients and tenants are only kept in Mongo
+	var apiKeys *service.APIKeyStore
+	var signingClients *service.SigningClientStore
+	var tenants *service.TenantStore
+	if mongoClient != nil {
+		apiKeys = service.NewAPIKeyStore(mongoClient)
+		signingClients = service.NewSigningClientStore(mongoClient, redisClient)
+		tenants = service.NewTenantStore(mongoClient, log)
+	}
+	if cfg.Tenancy.Enabled {
+		indexCtx, cancelIndex := context.WithTimeout(ctx, 10*time.Second)
+		if err := tenants.EnsureIndexes(indexCtx); err != nil {
+			log.Warnw("Failed to create tenant indexes", "error", err)
+		}
+		cancelIndex()
+		go tenants.Start(ctx, cfg.Tenancy.RefreshInterval)
+	}
+
+	drainer := service.NewDrainer()
+
+	shedder := service.NewLoadShedder(cfg.LoadShedding)
+	go shedder.Start(ctx, time.Second)
+
+	outliers := service.NewOutlierDetector(registry, cfg.Outliers, log)
+	if cfg.Outliers.Enabled {
+		go outliers.Start(ctx)
+	}
+
+	collector := metrics.NewCollector(prometheus.DefaultGatherer, time.Minute)
+	go collector.Start(ctx, 10*time.Second)
+
+	transforms := transform.NewStore()
+	if err := transforms.Replace(transformDefinitions(cfg.Routes)); err != nil {
+		log.Fatal("Invalid transformation rules", "error", err)
+	}
+	bodies := service.NewBodyLogger(cfg.BodyLog)
+	bodies.SetRoutes(cfg.Routes)
+	splits := service.NewTrafficSplits()
+	if err := splits.Replace(cfg.Routes); err != nil {
+		log.Fatal("Invalid traffic split", "error", err)
+	}
+	schedules := service.NewSchedules(cfg.Schedules)
+	go schedules.Start(ctx, 10*time.Second)
+	experiments := service.NewExperiments()
+	if err := experiments.Replace(cfg.Experiments); err != nil {
+		log.Fatal("Invalid experiment", "error", err)
+	}
+
+	audit := service.NewAuditLog(st, cfg.Audit, log)
+	go expireStorage(ctx, st, audit.Retention(), storageExpiryInterval, log)
+
+	quotas := service.NewQuotas(redisClient, mongoClient, cfg.Quota.Rules, log)
+	go quotas.Start(ctx, cfg.Quota.FlushInterval)
+
+	ipFilter, err := service.NewIPFilter(redisClient, cfg.AccessControl.GeoIPDatabase, log)
+	if err != nil {
+		log.Fatal("IP access control setup failed", "error", err)
+	}
+	defer ipFilter.Close()
+	if err := ipFilter.SetRules(cfg.AccessControl.Global, cfg.Routes); err != nil {
+		log.Fatal("Invalid IP access rules", "error", err)
+	}
+	go ipFilter.Start(ctx, 10*time.Second)
+
+	rateLimiter := ratelimit.NewLimiter(redisClient, notifier, log)
+	go rateLimiter.Start(ctx, 10*time.Second)
+
+	roles := service.NewRoleStore(st)
+	signingKey, err := cfg.JWT.SigningKey()
+	if err != nil {
+		log.Fatal("JWT signing key setup failed", "error", err)
+	}
+	signingKeys := service.NewSigningKeys(mongoClient, signingKey, cfg.JWT.TokenPolicy(), cfg.JWT.Expiry, log)
+	keysCtx, cancelKeys := context.WithTimeout(ctx, 10*time.Second)
+	if err := signingKeys.Refresh(keysCtx); err != nil {
+		log.Warnw("Failed to load JWT signing keys, signing with JWT_SECRET", "error", err)
+	}
+	cancelKeys()
+	go signingKeys.Start(ctx, cfg.JWT.KeyRefreshInterval)
+	authHandler := handler.NewAuthHandler(st, revocation, sessions, signingKeys, roles, audit, mailer.New(cfg.Email.SMTP, log), cfg, log)
+	oidcProviders := service.NewOIDCProviders(cfg.OIDC)
+
+	var accessLog *accesslog.Logger
+	if cfg.AccessLog.Enabled {
+		accessLog, err = accesslog.Open(cfg.AccessLog)
+		if err != nil {
+			log.Fatal("Access log setup failed", "error", err)
+		}
+	}
+
+	var recorder *analytics.Recorder
+	var analyticsStore *analytics.Store
+	if cfg.Analytics.Enabled {
+		recorder, analyticsStore, err = analytics.Open(cfg.Analytics, mongoClient, log)
+		if err != nil {
+			log.Fatal("Analytics setup failed", "error", err)
+		}
+		if analyticsStore != nil {
+			indexCtx, cancelIndex := context.WithTimeout(ctx, 10*time.Second)
+			if err := analyticsStore.EnsureIndexes(indexCtx); err != nil {
+				log.Warnw("Failed to create analytics indexes", "error", err)
+			}
+			cancelIndex()
+		}
+	}
+
+	var meter *service.Meter
+	if cfg.Metering.Enabled {
+		meter = service.NewMeter(mongoClient, cfg.Metering, log)
+		indexCtx, cancelIndex := context.WithTimeout(ctx, 10*time.Second)
+		if err := meter.EnsureIndexes(indexCtx); err != nil {
+			log.Warnw("Failed to create metering indexes", "error", err)
+		}
+		cancelIndex()
+		go meter.Start(ctx)
+	}
+
+	alerts := alerting.NewEvaluator(cfg.Alerting, collector, breakerManager, log)
+	go alerts.Start(ctx, cfg.Alerting.Interval)
+
+	publisher, err := messaging.NewPublisher(cfg.Messaging, log)
+	if err != nil {
+		log.Fatal("Message broker setup failed", "error", err)
+	}
+
+	proxyHandler := handler.NewProxyHandler(registry, loadBalancer, breakerManager, cfg.Upstream, svids, cfg.Bulkhead, cfg.SSE, shedder, outliers, transforms, splits, st, audit, log)
+	restoreServices := func() {
+		restoreCtx, cancelRestore := context.WithTimeout(ctx, 10*time.Second)
+		defer cancelRestore()
+		if err := proxyHandler.RestoreServices(restoreCtx); err != nil {
+			log.Warnw("Failed to restore registered services", "error", err)
+		}
+	}
+	if storageErr == nil {
+		restoreServices()
+	} else {
+		// Finish what startup skipped once storage is reachable
+		go func() {
+			if awaitDependency(ctx, "storage", st.Ping, cfg.Startup, time.Time{}, log) != nil {
+				return
+			}
+			log.Infow("Storage connection established", "backend", cfg.Storage.Backend)
+			if autoMigrate(cfg) {
+				if err := migrateStorage(st, log); err != nil {
+					log.Errorw("Migration failed", "backend", cfg.Storage.Backend, "error", err)
+					return
+				}
+			}
+			restoreServices()
+		}()
+	}
+	if redisErr != nil {
+		go func() {
+			if awaitDependency(ctx, "redis", pingRedis, cfg.Startup, time.Time{}, log) == nil {
+				log.Info("Redis connection established")
+			}
+		}()
+	}
+
+	deps := &dependencies{
+		logger:         log,
+		events:         notifier,
+		rateLimiter:    rateLimiter,
+		revocation:     revocation,
+		sessions:       sessions,
+		signingKeys:    signingKeys,
+		apiKeys:        apiKeys,
+		signingClients: signingClients,
+		authHandler:    authHandler,
+		oidcHandler:    handler.NewOIDCHandler(authHandler, oidcProviders, redisClient, log),
+		roleHandler:    handler.NewRoleHandler(roles, st, revocation, audit, log),
+		apiKeyHandler:  handler.NewAPIKeyHandler(apiKeys, audit, log),
+		tenants:        tenants,
+		tenantHandler:  handler.NewTenantHandler(tenants, audit, log),
+		signingHandler: handler.NewSigningClientHandler(signingClients, audit, log),
+		jwtKeyHandler:  handler.NewJWTKeyHandler(signingKeys, audit, log),
+		configHandler:  handler.NewConfigHandler(cfg),
+		rateLimits:     handler.NewRateLimitHandler(rateLimiter, cfg.RateLimit, audit, log),
+		proxyHandler:   proxyHandler,
+		healthHandler:  handler.NewHealthHandler(redisClient, st, drainer, registry, breakerManager, cfg.Readiness),
+		bodies:         bodies,
+		bodyLogHandler: handler.NewBodyLogHandler(bodies, audit, log),
+		drainHandler:   handler.NewDrainHandler(drainer, log),
+		scheduleAPI:    handler.NewScheduleHandler(schedules),
+		experimentAPI:  handler.NewExperimentHandler(experiments, audit, log),
+		statsHandler:   handler.NewStatsHandler(collector, registry, breakerManager, shedder, drainer, log),
+		breakers:       handler.NewCircuitBreakerHandler(breakerManager, log),
+		outlierHandler: handler.NewOutlierHandler(outliers, log),
+		auditHandler:   handler.NewAuditHandler(audit, log),
+		quotaHandler:   handler.NewQuotaHandler(quotas, audit, log),
+		docsHandler:    handler.NewDocsHandler(service.NewOpenAPISpecs(registry, log), cfg.Docs),
+		quotas:         quotas,
+		ipFilter:       ipFilter,
+		ipAccess:       handler.NewIPAccessHandler(ipFilter, audit, log),
+		transforms:     transforms,
+		splits:         splits,
+		drainer:        drainer,
+		shedder:        shedder,
+		inFlight:       service.NewClientInFlight(),
+		schedules:      schedules,
+		experiments:    experiments,
+		externalAuthz:  service.NewExternalAuthz(cfg.ExternalAuthz),
+		accessLog:      accessLog,
+		analytics:      recorder,
+		meter:          meter,
+		meteringAPI:    handler.NewMeteringHandler(meter, log),
+		reports:        handler.NewAnalyticsHandler(analyticsStore, log),
+		alerts:         alerts,
+		alertHandler:   handler.NewAlertHandler(alerts, log),
+		publisher:      publisher,
+		idempotency:    service.NewIdempotencyStore(redisClient),
+	}
 
 	if cfg.Server.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	utils.RegisterFieldNames()
 
-	router := gin.New()
-	router.Use(middleware.Recovery(log))
-	router.Use(middleware.RequestLogger(log))
-	router.Use(middleware.RequestID())
-	router.Use(middleware.CORS(cfg.CORS))
-	router.Use(middleware.SecurityHeaders())
+	router := &swappableHandler{}
+	router.Store(buildRouter(cfg, deps))
 
-	router.GET("/health", healthHandler.Health)
-	router.GET("/ready", healthHandler.Readiness)
+	var adminRouter *swappableHandler
+	if cfg.Admin.Addr != "" {
+		adminRouter = &swappableHandler{}
+		adminRouter.Store(buildAdminRouter(cfg, deps))
+	}
 
-	auth := router.Group("/api/v1/auth")
-	{
-		auth.POST("/register", authHandler.Register)
-		auth.POST("/login", authHandler.Login)
-		auth.POST("/refresh", authHandler.RefreshToken)
+	reload := &reloader{
+		ctx:       ctx,
+		current:   cfg,
+		registry:  registry,
+		discovery: discovery,
+		handler:   router,
+		admin:     adminRouter,
+		deps:      deps,
 	}
+	config.Watch(ctx, reload.Apply, func(err error) {
+		log.Errorw("Configuration reload rejected, keeping previous config", "error", err)
+	})
+	jwtConfig := cfg.JWT
+	cfg.WatchSecrets(ctx, func(changed map[string]string) {
+		rotate := false
+		for name, value := range changed {
+			switch name {
+			case "JWT_SECRET":
+				jwtConfig.Secret = value
+				rotate = true
+			case "JWT_PRIVATE_KEY":
+				jwtConfig.PrivateKey = value
+				rotate = true
+			default:
+				log.Warnw("Secret changed, restart to apply it", "secret", name)
+			}
+		}
+		if !rotate {
+			return
+		}
+		key, err := jwtConfig.SigningKey()
+		if err != nil {
+			log.Errorw("Refreshed JWT signing key is unusable, keeping the current one", "error", err)
+			return
+		}
+		if signingKeys.Rotate(key) {
+			log.Infow("JWT signing key rotated", "kid", key.ID)
+		}
+	}, func(err error) {
+		log.Warnw("Secrets refresh failed, keeping current secrets", "error", err)
+	})
 
-	api := router.Group("/api/v1")
-	api.Use(middleware.RateLimiter(redisClient, cfg.RateLimit))
-	api.Use(middleware.JWTAuth(cfg.JWT.Secret))
-	{
-		api.GET("/profile", authHandler.GetProfile)
-		
-		// Specific proxy routes for each service (instead of wildcard)
-		api.Any("/users/*path", proxyHandler.ProxyRequest)
-		api.Any("/products/*path", proxyHandler.ProxyRequest)
-		api.Any("/orders/*path", proxyHandler.ProxyRequest)
+	h2Server := &http2.Server{
+		MaxConcurrentStreams: cfg.Server.MaxConcurrentStreams,
+		IdleTimeout:          time.Duration(cfg.Timeouts.Idle) * time.Second,
 	}
 
-	admin := router.Group("/api/v1/admin")
-	admin.Use(middleware.RateLimiter(redisClient, cfg.RateLimit))
-	admin.Use(middleware.JWTAuth(cfg.JWT.Secret))
-	admin.Use(middleware.RoleAuth("admin"))
-	{
-		admin.GET("/services", proxyHandler.ListServices)
-		admin.POST("/services", proxyHandler.RegisterService)
-		admin.DELETE("/services/:name", proxyHandler.UnregisterService)
+	// gRPC clients speak HTTP/2, which needs h2c on the plaintext listener
+	var rootHandler http.Handler = router
+	if !cfg.Server.TLS.Enabled && (cfg.Server.H2C || hasGRPCServices(cfg.Services)) {
+		rootHandler = h2c.NewHandler(router, h2Server)
 	}
 
 	server := &http.Server{
 		Addr:           fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:        router,
+		Handler:        rootHandler,
 		ReadTimeout:    time.Duration(cfg.Timeouts.Read) * time.Second,
 		WriteTimeout:   time.Duration(cfg.Timeouts.Write) * time.Second,
 		IdleTimeout:    time.Duration(cfg.Timeouts.Idle) * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	var httpServer *http.Server
+	if cfg.Server.TLS.Enabled {
+		tlsConfig, httpHandler, err := buildTLS(cfg.Server.TLS, cfg.Server.Port)
+		if err != nil {
+			log.Fatal("Failed to configure TLS", "error", err)
+		}
+		server.TLSConfig = tlsConfig
+		if cfg.Server.HTTP2 {
+			if err := http2.ConfigureServer(server, h2Server); err != nil {
+				log.Fatal("Failed to configure HTTP/2", "error", err)
+			}
+		} else {
+			tlsConfig.NextProtos = withoutProto(tlsConfig.NextProtos, "h2")
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+
+		httpServer = &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Server.TLS.HTTPPort),
+			Handler:           httpHandler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Info("HTTP listener started", "port", cfg.Server.TLS.HTTPPort)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("HTTP listener failed", "error", err)
+			}
+		}()
+	}
+
+	listener, err := listen(server.Addr, cfg.Server.ReusePort)
+	if err != nil {
+		log.Fatal("Failed to listen", "error", err)
+	}
+	if cfg.Server.ProxyProtocol {
+		// PROXY headers from untrusted peers are ignored, not honoured
+		policy, err := proxyproto.LaxWhiteListPolicy(cfg.Server.TrustedProxies)
+		if err != nil {
+			log.Fatal("Invalid trusted proxies", "error", err)
+		}
+		listener = &proxyproto.Listener{Listener: listener, Policy: policy}
+	}
+
 	go func() {
-		log.Info("Server started", "port", cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLS.Enabled {
+			log.Info("Server started", "port", cfg.Server.Port, "tls", true)
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			log.Info("Server started", "port", cfg.Server.Port)
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal("Server failed", "error", err)
 		}
 	}()
 
+	var adminServer *http.Server
+	if adminRouter != nil {
+		adminServer = startAdminServer(cfg.Admin, adminRouter, log)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Info("Shutting down...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	drain(drainer, cfg.Server, log)
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatal("Shutdown failed", "error", err)
 	}
+	if httpServer != nil {
+		httpServer.Shutdown(shutdownCtx)
+	}
+	if adminServer != nil {
+		adminServer.Shutdown(shutdownCtx)
+	}
+
+	// Requests have finished, so no more messages can be accepted
+	if err := publisher.Close(); err != nil {
+		log.Errorw("Failed to close message brokers", "error", err)
+	}
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Errorw("Failed to close analytics", "error", err)
+		}
+	}
+
+	notifier.Close()
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Errorw("Failed to flush traces", "error", err)
+	}
+	if accessLog != nil {
+		if err := accessLog.Close(); err != nil {
+			log.Errorw("Failed to close access log", "error", err)
+		}
+	}
 
 	log.Info("Gateway stopped")
 }
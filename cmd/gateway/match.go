@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeVariant is one of several routes sharing a path. Each gets its own
+// engine so its middleware chain runs exactly as if it owned the path.
+type routeVariant struct {
+	route    config.RouteConfig
+	path     *regexp.Regexp
+	handlers []gin.HandlerFunc
+	engine   *gin.Engine
+}
+
+type parentContextKey struct{}
+
+// newRouteEngine creates an engine with the settings every router needs.
+// Only trusted proxies may report the client address; everyone else is
+// identified by the connection, so X-Forwarded-For can't be spoofed to
+// dodge rate limits. Validation has already checked the list.
+func newRouteEngine(cfg *config.Config, log *logger.Logger) *gin.Engine {
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Errorw("Invalid trusted proxies", "error", err)
+	}
+	engine.RemoteIPHeaders = []string{cfg.Server.RealIPHeader}
+	return engine
+}
+
+func usesMatch(variants []*routeVariant) bool {
+	for _, v := range variants {
+		if v.route.Match != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchRoutes hands a request to the first route whose conditions it
+// meets. Routes matching on host come first, exact hosts before wildcards,
+// then routes with more header and query conditions; ties keep config
+// order, so a route without conditions is the fallback.
+func dispatchRoutes(variants []*routeVariant) gin.HandlerFunc {
+	sorted := append([]*routeVariant(nil), variants...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		hi, ci := matchRank(sorted[i].route.Match)
+		hj, cj := matchRank(sorted[j].route.Match)
+		if hi != hj {
+			return hi > hj
+		}
+		return ci > cj
+	})
+
+	return func(c *gin.Context) {
+		for _, v := range sorted {
+			if !v.accepts(c.Request) {
+				continue
+			}
+			req := c.Request.WithContext(context.WithValue(c.Request.Context(), parentContextKey{}, c))
+			v.engine.ServeHTTP(c.Writer, req)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusNotFound, "Route not found")
+	}
+}
+
+// inheritContext carries the values set by global middleware into a route
+// engine and hands back what the route set, e.g. for the access log
+func inheritContext(c *gin.Context) {
+	parent, ok := c.Request.Context().Value(parentContextKey{}).(*gin.Context)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	for key, value := range parent.Keys {
+		c.Set(key, value)
+	}
+	c.Next()
+	for key, value := range c.Keys {
+		parent.Set(key, value)
+	}
+}
+
+// matchRank orders routes by specificity: 2 for exact hosts only, 1 when a
+// wildcard host is listed, 0 without hosts; then the number of path,
+// header and query conditions
+func matchRank(m *config.RouteMatch) (int, int) {
+	if m == nil {
+		return 0, 0
+	}
+	host := 0
+	if len(m.Hosts) > 0 {
+		host = 2
+		for _, h := range m.Hosts {
+			if strings.HasPrefix(h, "*.") {
+				host = 1
+			}
+		}
+	}
+	conditions := len(m.Headers) + len(m.Query)
+	if m.Path != "" {
+		conditions++
+	}
+	return host, conditions
+}
+
+func acceptsMethod(methods []string, method string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *routeVariant) accepts(r *http.Request) bool {
+	if !acceptsMethod(v.route.Methods, r.Method) {
+		return false
+	}
+	m := v.route.Match
+	if m == nil {
+		return true
+	}
+	if v.path != nil && !v.path.MatchString(r.URL.Path) {
+		return false
+	}
+	if len(m.Hosts) > 0 && !matchHost(m.Hosts, r.Host) {
+		return false
+	}
+	for name, want := range m.Headers {
+		if !matchValue(r.Header.Values(name), want) {
+			return false
+		}
+	}
+	query := r.URL.Query()
+	for name, want := range m.Query {
+		if !matchValue(queryValues(query, name), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryValues looks a parameter up ignoring case, as config keys are
+// lowercased when loaded
+func queryValues(query url.Values, name string) []string {
+	var values []string
+	for key, v := range query {
+		if strings.EqualFold(key, name) {
+			values = append(values, v...)
+		}
+	}
+	return values
+}
+
+func matchHost(patterns []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports whether any of values equals want; "*" only requires
+// a non-empty value
+func matchValue(values []string, want string) bool {
+	for _, v := range values {
+		if v == want || (want == "*" && v != "") {
+			return true
+		}
+	}
+	return false
+}
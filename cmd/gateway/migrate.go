@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/logger"
+)
+
+// migrationTimeout bounds a migration run; index builds on large
+// collections take a while
+const migrationTimeout = 10 * time.Minute
+
+// runMigrate implements `gateway migrate`: it applies pending migrations
+// of the storage backend, or only lists them with -status, and returns the
+// exit code
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	statusOnly := flags.Bool("status", false, "list migrations and whether they were applied, without applying any")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		return 1
+	}
+
+	st, _, closeStorage, err := openStorage(cfg)
+	if err != nil {
+		fmt.Printf("Storage connection failed (%s): %v\n", cfg.Storage.Backend, err)
+		return 1
+	}
+	defer closeStorage()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	runner := st.Migrations(logger.NewLogger(cfg.Logging.Level))
+	if !*statusOnly {
+		count, err := runner.Run(ctx)
+		fmt.Printf("Applied %d migration(s)\n", count)
+		if err != nil {
+			fmt.Printf("Migration failed: %v\n", err)
+			return 1
+		}
+	}
+
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		fmt.Printf("Failed to read migrations: %v\n", err)
+		return 1
+	}
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%4d  %-28s  %s\n", status.ID, applied, status.Description)
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/service"
+)
+
+// reloader applies a freshly loaded config to the running gateway. Only
+// routing, rate limits, CORS and services are hot-swappable; listener,
+// timeout and storage settings still need a restart.
+type reloader struct {
+	ctx       context.Context
+	current   *config.Config
+	registry  *service.Registry
+	discovery *service.Discovery
+	handler   *swappableHandler
+	admin     *swappableHandler
+	deps      *dependencies
+}
+
+func (r *reloader) Apply(cfg *config.Config) (err error) {
+	// gin panics on conflicting routes; treat that as an invalid config and
+	// keep serving with the previous router
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("failed to build routes: %v", rec)
+		}
+	}()
+
+	signingKey, err := cfg.JWT.SigningKey()
+	if err != nil {
+		return err
+	}
+
+	router := buildRouter(cfg, r.deps)
+	if err := r.deps.transforms.Replace(transformDefinitions(cfg.Routes)); err != nil {
+		return err
+	}
+	if err := r.deps.splits.Replace(cfg.Routes); err != nil {
+		return err
+	}
+	if err := r.deps.experiments.Replace(cfg.Experiments); err != nil {
+		return err
+	}
+
+	if err := r.deps.ipFilter.SetRules(cfg.AccessControl.Global, cfg.Routes); err != nil {
+		return err
+	}
+	r.deps.quotas.SetRules(cfg.Quota.Rules)
+	r.deps.schedules.SetSchedules(cfg.Schedules)
+	r.deps.bodies.SetRoutes(cfg.Routes)
+	r.syncServices(cfg.Services)
+	r.discovery.Start(r.ctx, cfg.Services)
+	r.handler.Store(router)
+	if r.admin != nil {
+		r.admin.Store(buildAdminRouter(cfg, r.deps))
+	}
+	r.current = cfg
+	r.deps.configHandler.SetConfig(cfg)
+	r.deps.rateLimits.SetConfig(cfg.RateLimit)
+	r.deps.alerts.SetRules(cfg.Alerting.Rules)
+
+	// Secrets and key files are read again with the config
+	if r.deps.signingKeys.Rotate(signingKey) {
+		r.deps.logger.Infow("JWT signing key rotated", "kid", signingKey.ID)
+	}
+	r.deps.signingKeys.SetPolicy(cfg.JWT.TokenPolicy())
+
+	r.deps.logger.Infow("Configuration reloaded",
+		"routes", len(cfg.Routes),
+		"services", len(cfg.Services),
+	)
+	r.deps.events.Emit(events.ConfigReloaded, map[string]interface{}{
+		"routes":   len(cfg.Routes),
+		"services": len(cfg.Services),
+	})
+	return nil
+}
+
+// syncServices registers services from the new config and removes services
+// that were dropped from it. Services added through the admin API are kept.
+func (r *reloader) syncServices(services []config.ServiceConfig) {
+	next := make(map[string]bool, len(services))
+	for _, svc := range services {
+		next[svc.Name] = true
+		r.registry.Register(svc)
+	}
+
+	for _, svc := range r.current.Services {
+		if !next[svc.Name] {
+			r.registry.Unregister(svc.Name)
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"api-gateway/internal/accesslog"
+	"api-gateway/internal/alerting"
+	"api-gateway/internal/analytics"
+	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/handler"
+	"api-gateway/internal/messaging"
+	"api-gateway/internal/middleware"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/schema"
+	"api-gateway/internal/service"
+	"api-gateway/internal/transform"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// dependencies are long-lived components shared by every router generation
+type dependencies struct {
+	logger         *logger.Logger
+	events         *events.Notifier
+	rateLimiter    *ratelimit.Limiter
+	revocation     *service.TokenRevocation
+	sessions       *service.SessionStore
+	signingKeys    *service.SigningKeys
+	apiKeys        *service.APIKeyStore
+	signingClients *service.SigningClientStore
+	authHandler    *handler.AuthHandler
+	apiKeyHandler  *handler.APIKeyHandler
+	signingHandler *handler.SigningClientHandler
+	jwtKeyHandler  *handler.JWTKeyHandler
+	configHandler  *handler.ConfigHandler
+	tenants        *service.TenantStore
+	tenantHandler  *handler.TenantHandler
+	oidcHandler    *handler.OIDCHandler
+	roleHandler    *handler.RoleHandler
+	proxyHandler   *handler.ProxyHandler
+	healthHandler  *handler.HealthHandler
+	drainHandler   *handler.DrainHandler
+	scheduleAPI    *handler.ScheduleHandler
+	experimentAPI  *handler.ExperimentHandler
+	statsHandler   *handler.StatsHandler
+	breakers       *handler.CircuitBreakerHandler
+	outlierHandler *handler.OutlierHandler
+	auditHandler   *handler.AuditHandler
+	quotaHandler   *handler.QuotaHandler
+	rateLimits     *handler.RateLimitHandler
+	docsHandler    *handler.DocsHandler
+	quotas         *service.Quotas
+	ipFilter       *service.IPFilter
+	ipAccess       *handler.IPAccessHandler
+	transforms     *transform.Store
+	splits         *service.TrafficSplits
+	bodies         *service.BodyLogger
+	bodyLogHandler *handler.BodyLogHandler
+	drainer        *service.Drainer
+	shedder        *service.LoadShedder
+	inFlight       *service.ClientInFlight
+	schedules      *service.Schedules
+	experiments    *service.Experiments
+	externalAuthz  *service.ExternalAuthz
+	accessLog      *accesslog.Logger
+	analytics      *analytics.Recorder
+	reports        *handler.AnalyticsHandler
+	meter          *service.Meter
+	meteringAPI    *handler.MeteringHandler
+	alerts         *alerting.Evaluator
+	alertHandler   *handler.AlertHandler
+	publisher      *messaging.Publisher
+	idempotency    *service.IdempotencyStore
+}
+
+// buildRouter assembles the complete middleware stack and route table for a
+// config. It is called at startup and again on every config reload.
+func buildRouter(cfg *config.Config, deps *dependencies) *gin.Engine {
+	// The error format is process wide; the latest config wins on reload
+	utils.SetProblemJSON(cfg.Errors.ProblemJSON)
+	errorTemplates, err := cfg.Errors.ErrorTemplates()
+	if err != nil {
+		deps.logger.Errorw("Invalid error templates", "error", err)
+	}
+	utils.SetErrorTemplates(errorTemplates)
+
+	router := newRouteEngine(cfg, deps.logger)
+	router.Use(middleware.Recovery(deps.logger))
+	router.Use(middleware.Metrics())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.RequestLogger(deps.logger))
+	if deps.accessLog != nil {
+		router.Use(middleware.AccessLog(deps.accessLog))
+	}
+	router.Use(middleware.RequestID())
+	router.Use(middleware.IPAccess(deps.ipFilter, service.GlobalScope))
+	router.Use(middleware.ClientInFlight(deps.inFlight, middleware.KeyByIP, cfg.ClientInFlight.MaxPerIP))
+	cors := cfg.CORS
+	if cfg.UsesCSRF() {
+		// Browsers must be allowed to send the CSRF header cross-origin
+		cors.AllowedHeaders = append(append([]string(nil), cors.AllowedHeaders...), cfg.CSRF.Header)
+	}
+	router.Use(middleware.CORS(cors))
+	router.Use(middleware.SecurityHeaders(cfg.SecurityHeaders))
+	router.Use(middleware.Compression(cfg.Compression))
+
+	router.NoRoute(func(c *gin.Context) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Route not found")
+	})
+
+	router.GET("/health", deps.healthHandler.Health)
+	router.GET("/ready", deps.healthHandler.Readiness)
+	router.GET("/health/services", deps.healthHandler.Services)
+	router.GET("/.well-known/jwks.json", deps.jwtKeyHandler.PublicJWKS)
+
+	bodyLimit := middleware.BodyLimit(cfg.Server.MaxBodySize)
+
+	auth := router.Group("/api/v1/auth")
+	auth.Use(bodyLimit)
+	if cfg.CSRF.Enabled {
+		auth.Use(middleware.CSRF(cfg.CSRF))
+		auth.GET("/csrf", deps.authHandler.CSRFToken)
+	}
+	{
+		auth.POST("/register", deps.authHandler.Register)
+		auth.POST("/login", deps.authHandler.Login)
+		auth.POST("/refresh", deps.authHandler.RefreshToken)
+		auth.POST("/logout", deps.authHandler.Logout)
+		auth.POST("/verify", deps.authHandler.VerifyEmail)
+		auth.POST("/forgot-password", deps.authHandler.ForgotPassword)
+		auth.POST("/reset-password", deps.authHandler.ResetPassword)
+		auth.GET("/oidc/:provider/login", deps.oidcHandler.Login)
+		auth.GET("/oidc/:provider/callback", deps.oidcHandler.Callback)
+	}
+
+	// Tenants are resolved after authentication, which may bind the caller
+	// to one, and before rate limits and quotas, which may key by tenant
+	resolveTenant := func(c *gin.Context) { c.Next() }
+	if cfg.Tenancy.Enabled {
+		resolveTenant = middleware.ResolveTenant(deps.tenants, cfg.Tenancy)
+	}
+
+	publicChain := []gin.HandlerFunc{
+		resolveTenant,
+		middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules),
+	}
+	jwtAuth := newJWTAuth(cfg, deps)
+
+	// API keys are only available with Mongo storage
+	authenticate := jwtAuth
+	if deps.apiKeys != nil {
+		authenticate = middleware.Authenticate(jwtAuth, middleware.APIKeyAuth(deps.apiKeys, cfg.APIKeys.Header), cfg.APIKeys.Header)
+	}
+
+	// Authenticate first so per-user limits can see the claims
+	userInFlight := middleware.ClientInFlight(deps.inFlight, middleware.KeyByUser, cfg.ClientInFlight.MaxPerUser)
+	protectedChain := []gin.HandlerFunc{
+		authenticate,
+		userInFlight,
+		resolveTenant,
+		middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules),
+		middleware.Quota(deps.quotas),
+	}
+	signedChain := []gin.HandlerFunc{
+		middleware.HMACAuth(deps.signingClients, cfg.HMAC),
+		userInFlight,
+		resolveTenant,
+		middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules),
+		middleware.Quota(deps.quotas),
+	}
+
+	api := router.Group("/api/v1")
+	api.Use(bodyLimit, jwtAuth, userInFlight, middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules))
+	{
+		api.GET("/profile", deps.authHandler.GetProfile)
+		api.PUT("/profile", deps.authHandler.UpdateProfile)
+		api.DELETE("/profile", deps.authHandler.DeleteProfile)
+		api.POST("/profile/change-password", deps.authHandler.ChangePassword)
+		api.POST("/auth/token", deps.authHandler.ScopedToken)
+		api.GET("/auth/session", deps.authHandler.Session)
+	}
+
+	// A separate admin listener serves the admin API with its own router
+	if cfg.Admin.Addr == "" {
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+		admin := router.Group("/api/v1/admin")
+		admin.Use(bodyLimit, jwtAuth, middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules))
+		admin.Use(middleware.RoleAuth("admin"))
+		registerAdminRoutes(admin, deps)
+	}
+
+	// Everything registered so far is the gateway's own API
+	docs := router.Group("/api/v1/docs")
+	docs.Use(middleware.RateLimiter(deps.rateLimiter, cfg.RateLimit, deps.schedules))
+	{
+		docs.GET("/openapi.json", deps.docsHandler.OpenAPI(router.Routes(), cfg.Routes))
+		if cfg.Docs.SwaggerUI {
+			docs.GET("", deps.docsHandler.SwaggerUI)
+		}
+	}
+
+	// Proxy routes declared in config
+	registerRoutes(router, cfg, deps, publicChain, protectedChain, signedChain)
+
+	return router
+}
+
+// claimsValidators are checked against every bearer token and session
+// after the claim rules of the config. Deployments building their own gateway add checks
+// of their custom claims here.
+var claimsValidators []middleware.ClaimsValidator
+
+// newJWTAuth builds the JWT middleware for a router, accepting session
+// cookies too when sessions are enabled. It is rebuilt with the router so
+// reloads pick up issuer and claim rule changes.
+func newJWTAuth(cfg *config.Config, deps *dependencies) gin.HandlerFunc {
+	var externalTokens *service.ExternalTokens
+	if len(cfg.JWT.TrustedIssuers) > 0 {
+		externalTokens = service.NewExternalTokens(cfg.JWT.TrustedIssuers, cfg.JWT.Leeway)
+	}
+	validators := claimsValidators
+	if len(cfg.JWT.ClaimRules) > 0 {
+		validators = append([]middleware.ClaimsValidator{middleware.ClaimRules(cfg.JWT.ClaimRules)}, claimsValidators...)
+	}
+	jwtAuth := middleware.JWTAuth(deps.signingKeys, deps.revocation, externalTokens, validators...)
+	if cfg.Session.Enabled {
+		return middleware.SessionAuth(deps.sessions, deps.revocation, cfg.Session, cfg.CSRF.Header, jwtAuth, validators...)
+	}
+	return jwtAuth
+}
+
+// registerAdminRoutes adds the admin API to a group that has already
+// authenticated the caller as an admin
+func registerAdminRoutes(admin *gin.RouterGroup, deps *dependencies) {
+	admin.GET("/services", deps.proxyHandler.ListServices)
+	admin.POST("/services", middleware.RejectWhileDraining(deps.drainer), deps.proxyHandler.RegisterService)
+	admin.DELETE("/services/:name", deps.proxyHandler.UnregisterService)
+	admin.POST("/users/:id/revoke-tokens", deps.authHandler.RevokeUserTokens)
+	admin.PUT("/users/:id/role", deps.roleHandler.AssignRole)
+
+	admin.GET("/roles", deps.roleHandler.ListRoles)
+	admin.POST("/roles", deps.roleHandler.SaveRole)
+	admin.PUT("/roles/:name", deps.roleHandler.SaveRole)
+	admin.DELETE("/roles/:name", deps.roleHandler.DeleteRole)
+
+	admin.GET("/circuit-breakers", deps.breakers.List)
+	admin.POST("/circuit-breakers/:service/open", deps.breakers.ForceOpen)
+	admin.POST("/circuit-breakers/:service/close", deps.breakers.ForceClose)
+	admin.POST("/circuit-breakers/:service/reset", deps.breakers.Reset)
+
+	admin.GET("/outliers", deps.outlierHandler.List)
+	admin.POST("/outliers/:service/readmit", deps.outlierHandler.Readmit)
+
+	admin.GET("/audit", deps.auditHandler.Query)
+
+	admin.GET("/quotas/report", deps.quotaHandler.Report)
+	admin.GET("/quotas/:subject", deps.quotaHandler.Usage)
+	admin.DELETE("/quotas/:subject/:rule", deps.quotaHandler.Reset)
+
+	admin.GET("/rate-limits", deps.rateLimits.Buckets)
+	admin.DELETE("/rate-limits", deps.rateLimits.Reset)
+	admin.GET("/rate-limits/exemptions", deps.rateLimits.Exemptions)
+	admin.POST("/rate-limits/exemptions", deps.rateLimits.Exempt)
+	admin.DELETE("/rate-limits/exemptions", deps.rateLimits.RemoveExemption)
+
+	admin.GET("/analytics/consumers", deps.reports.Consumers)
+	admin.GET("/analytics/routes", deps.reports.Routes)
+	admin.GET("/analytics/traffic", deps.reports.Traffic)
+
+	admin.GET("/metering/export", deps.meteringAPI.Export)
+
+	admin.GET("/alerts", deps.alertHandler.List)
+
+	admin.GET("/stats", deps.statsHandler.Stats)
+	admin.GET("/config", deps.configHandler.Get)
+
+	admin.GET("/drain", deps.drainHandler.Status)
+	admin.POST("/drain", deps.drainHandler.Drain)
+
+	admin.GET("/transforms", deps.proxyHandler.ListTransforms)
+	admin.PUT("/transforms", deps.proxyHandler.SetTransforms)
+
+	admin.GET("/ip-access", deps.ipAccess.List)
+	admin.POST("/ip-access", deps.ipAccess.Add)
+	admin.DELETE("/ip-access", deps.ipAccess.Remove)
+
+	admin.GET("/body-logging", deps.bodyLogHandler.Settings)
+	admin.PUT("/body-logging", deps.bodyLogHandler.Update)
+
+	admin.GET("/splits", deps.proxyHandler.ListSplits)
+	admin.GET("/schedules", deps.scheduleAPI.List)
+	admin.GET("/experiments", deps.experimentAPI.List)
+	admin.PUT("/experiments/:name", deps.experimentAPI.Put)
+	admin.DELETE("/experiments/:name", deps.experimentAPI.Delete)
+	admin.PUT("/splits", deps.proxyHandler.SetSplitWeights)
+
+	// These are only kept in Mongo, so other storage backends go without
+	if deps.apiKeys != nil {
+		admin.GET("/api-keys", deps.apiKeyHandler.ListKeys)
+		admin.POST("/api-keys", deps.apiKeyHandler.CreateKey)
+		admin.POST("/api-keys/:id/rotate", deps.apiKeyHandler.RotateKey)
+		admin.DELETE("/api-keys/:id", deps.apiKeyHandler.RevokeKey)
+	}
+
+	if deps.tenants != nil {
+		admin.GET("/tenants", deps.tenantHandler.ListTenants)
+		admin.POST("/tenants", deps.tenantHandler.CreateTenant)
+		admin.GET("/tenants/:id", deps.tenantHandler.GetTenant)
+		admin.PUT("/tenants/:id", deps.tenantHandler.UpdateTenant)
+		admin.DELETE("/tenants/:id", deps.tenantHandler.DeleteTenant)
+	}
+
+	if deps.signingClients != nil {
+		admin.GET("/signing-clients", deps.signingHandler.ListClients)
+		admin.POST("/signing-clients", deps.signingHandler.CreateClient)
+		admin.DELETE("/signing-clients/:id", deps.signingHandler.RevokeClient)
+	}
+
+	admin.GET("/jwt-keys", deps.jwtKeyHandler.List)
+	admin.GET("/jwt-keys/jwks", deps.jwtKeyHandler.JWKS)
+	admin.POST("/jwt-keys/rotate", deps.jwtKeyHandler.Rotate)
+	admin.DELETE("/jwt-keys/:kid", deps.jwtKeyHandler.Delete)
+}
+
+// registerRoutes builds the proxy routes declared in config. Authenticated
+// routes get the protected middleware chain, routes taking signed requests
+// the signed one and the rest only the public one.
+func registerRoutes(router *gin.Engine, cfg *config.Config, deps *dependencies, public, protected, signed []gin.HandlerFunc) {
+	var bases []string
+	variants := make(map[string][]*routeVariant)
+
+	for _, route := range cfg.Routes {
+		chain := public
+		switch {
+		case route.AuthRequired:
+			chain = protected
+		case route.HMACAuth:
+			chain = signed
+		}
+
+		limit := cfg.Server.MaxBodySize
+		if route.MaxBodySize > 0 {
+			limit = route.MaxBodySize
+		}
+
+		budget := cfg.Timeouts.Request
+		if route.Timeout > 0 {
+			budget = route.Timeout
+		}
+
+		// Route scoped IP rules may also be added at runtime, so every route
+		// checks them
+		handlers := append([]gin.HandlerFunc{
+			middleware.Scheduled(deps.schedules, route.Path),
+			middleware.TrackInFlight(deps.drainer),
+			middleware.IPAccess(deps.ipFilter, route.Path),
+			middleware.ClientCert(route.ClientCert),
+			middleware.BodyLimit(limit),
+			middleware.Timeout(budget),
+			middleware.BodyLog(deps.bodies, route.Path, deps.logger),
+		}, chain...)
+		// Shedding follows the chain, which may give the caller a priority,
+		// and so do experiments, which assign callers by user ID
+		handlers = append(handlers,
+			middleware.LoadShed(deps.shedder, route.Priority, cfg.LoadShedding.RolePriorities),
+			middleware.Experiment(deps.experiments, route.Path),
+		)
+		if route.BrowserFacing {
+			handlers = append(handlers, middleware.CSRF(cfg.CSRF))
+		}
+		handlers = append(handlers, middleware.Identity(cfg.Identity, deps.signingKeys, deps.logger))
+		if deps.meter != nil {
+			handlers = append(handlers, middleware.Metering(deps.meter, route.BillingUnits))
+		}
+		if deps.analytics != nil {
+			handlers = append([]gin.HandlerFunc{middleware.Analytics(deps.analytics, route.Path, route.Service)}, handlers...)
+		}
+		if policy := cfg.ResponseHeaders.Merge(route.ResponseHeaders); !policy.Empty() {
+			handlers = append(handlers, middleware.ResponseHeaders(policy))
+		}
+		if cfg.Tenancy.Enabled {
+			handlers = append(handlers, middleware.TenantRouteAccess(route.Path))
+		}
+		if len(route.Scopes) > 0 {
+			handlers = append(handlers, middleware.RequireScope(route.Scopes...))
+		}
+		if len(route.Permissions) > 0 {
+			handlers = append(handlers, middleware.RequirePermission(route.Permissions...))
+		}
+		if route.ExternalAuthz {
+			handlers = append(handlers, middleware.ExternalAuthz(deps.externalAuthz, route.Path, deps.logger))
+		}
+		if route.Body != nil {
+			var bodySchema *schema.Schema
+			if route.Body.SchemaFile != "" {
+				// Validation has already loaded the schema once
+				var err error
+				if bodySchema, err = schema.Load(route.Body.SchemaFile); err != nil {
+					deps.logger.Errorw("Invalid body schema", "route", route.Path, "error", err)
+				}
+			}
+			handlers = append(handlers, middleware.ValidateBody(route.Body.ContentTypes, bodySchema))
+		}
+		if route.Upload != nil {
+			handlers = append(handlers, middleware.Upload(*route.Upload))
+		}
+		if route.Idempotency {
+			handlers = append(handlers, middleware.Idempotency(deps.idempotency, cfg.Idempotency, route.Path, deps.logger))
+		}
+		if route.Dedup {
+			handlers = append(handlers, middleware.Dedup(deps.idempotency, cfg.Dedup, route.DedupWindow, route.Path, deps.logger))
+		}
+		switch {
+		case route.Static != nil:
+			handlers = append(handlers, handler.StaticResponse(*route.Static))
+		case route.Composite != nil:
+			handlers = append(handlers, deps.proxyHandler.Composite(route))
+		case route.Publish != nil:
+			handlers = append(handlers, handler.Publish(deps.publisher, route, deps.logger))
+		default:
+			handlers = append(handlers, deps.proxyHandler.Route(route))
+		}
+
+		base := strings.TrimSuffix(route.Path, "/")
+		if _, ok := variants[base]; !ok {
+			bases = append(bases, base)
+		}
+		// Validation has already compiled the pattern
+		pattern, _ := route.Match.PathPattern()
+		variants[base] = append(variants[base], &routeVariant{route: route, path: pattern, handlers: handlers})
+	}
+
+	// Paths whose routes all match on the path alone go straight into the
+	// router; the others are dispatched by their match conditions
+	for _, base := range bases {
+		if !usesMatch(variants[base]) {
+			for _, v := range variants[base] {
+				handleRoute(router, base, v.route.Methods, v.handlers)
+			}
+			continue
+		}
+		for _, v := range variants[base] {
+			v.engine = newRouteEngine(cfg, deps.logger)
+			handleRoute(v.engine, base, v.route.Methods, append([]gin.HandlerFunc{inheritContext}, v.handlers...))
+		}
+		handleRoute(router, base, nil, []gin.HandlerFunc{dispatchRoutes(variants[base])})
+	}
+}
+
+// handleRoute registers handlers for a route path and everything below it
+func handleRoute(router gin.IRoutes, base string, methods []string, handlers []gin.HandlerFunc) {
+	for _, path := range []string{base, base + "/*proxyPath"} {
+		if path == "" {
+			continue
+		}
+		if len(methods) == 0 {
+			router.Any(path, handlers...)
+			continue
+		}
+		for _, method := range methods {
+			router.Handle(strings.ToUpper(method), path, handlers...)
+		}
+	}
+}
+
+func hasGRPCServices(services []config.ServiceConfig) bool {
+	for _, svc := range services {
+		if svc.Protocol == service.ProtocolGRPC {
+			return true
+		}
+	}
+	return false
+}
+
+// swappableHandler lets a reload replace the active router without
+// restarting the listener. In-flight requests finish on the old router.
+type swappableHandler struct {
+	current atomic.Pointer[gin.Engine]
+}
+
+func (h *swappableHandler) Store(router *gin.Engine) {
+	h.current.Store(router)
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().ServeHTTP(w, r)
+}
+
+// transformDefinitions collects the per-route rules declared in config
+func transformDefinitions(routes []config.RouteConfig) map[string]transform.Definition {
+	defs := make(map[string]transform.Definition)
+	for _, route := range routes {
+		if route.Transforms == nil {
+			continue
+		}
+		defs[route.Path] = transform.Definition{
+			Request:  route.Transforms.Request,
+			Response: route.Transforms.Response,
+		}
+	}
+	return defs
+}
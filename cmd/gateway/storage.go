@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+)
+
+// storageExpiryInterval is how often expired tokens and audit events are
+// removed from backends that don't expire them themselves
+const storageExpiryInterval = time.Hour
+
+// openStorage connects to the configured storage backend and checks that
+// it answers. The Mongo client is nil unless the mongo backend is used,
+// which turns the features kept only in Mongo off. closeStorage
+// disconnects.
+func openStorage(cfg *config.Config) (st store.Store, mongoClient *storage.MongoClient, closeStorage func(), err error) {
+	st, mongoClient, closeStorage, err = dialStorage(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := st.Ping(ctx); err != nil {
+		closeStorage()
+		return nil, nil, nil, err
+	}
+	return st, mongoClient, closeStorage, nil
+}
+
+// dialStorage is openStorage without checking that the backend is
+// reachable
+func dialStorage(cfg *config.Config) (st store.Store, mongoClient *storage.MongoClient, closeStorage func(), err error) {
+	if cfg.Storage.Backend == "postgres" {
+		postgresClient, err := storage.DialPostgres(cfg.Postgres)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return store.NewPostgresStore(postgresClient), nil, func() { postgresClient.Close() }, nil
+	}
+
+	mongoClient, err = storage.DialMongo(cfg.MongoDB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return store.NewMongoStore(mongoClient), mongoClient, func() { mongoClient.Close() }, nil
+}
+
+// awaitDependency pings a dependency until it answers, backing off
+// exponentially between attempts as startup configures, and returns the
+// last error once deadline passed. A zero deadline retries until ctx is
+// cancelled.
+func awaitDependency(ctx context.Context, name string, ping func(ctx context.Context) error, startup config.StartupConfig, deadline time.Time, log *logger.Logger) error {
+	backoff := startup.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		wait := backoff
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return err
+			}
+			wait = min(wait, remaining)
+		}
+		log.Warnw("Dependency unavailable, retrying", "dependency", name, "attempt", attempt, "retry_in", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, startup.MaxBackoff)
+	}
+}
+
+// migrateStorage applies the backend's pending migrations
+func migrateStorage(st store.Store, log *logger.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+	_, err := st.Migrations(log).Run(ctx)
+	return err
+}
+
+// autoMigrate reports whether the backend's migrations run at startup
+func autoMigrate(cfg *config.Config) bool {
+	if cfg.Storage.Backend == "postgres" {
+		return cfg.Postgres.AutoMigrate
+	}
+	return cfg.MongoDB.AutoMigrate
+}
+
+// expireStorage runs the store's expiry now and then every interval until
+// ctx is cancelled
+func expireStorage(ctx context.Context, st store.Store, auditRetention time.Duration, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expireCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if err := st.Expire(expireCtx, auditRetention); err != nil {
+			log.Warnw("Failed to expire stored records", "backend", st.Backend(), "error", err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+
+	"api-gateway/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// buildTLS returns the listener TLS config and the handler for the plain
+// HTTP listener, which answers ACME challenges and redirects to HTTPS
+func buildTLS(cfg config.TLSConfig, httpsPort int) (*tls.Config, http.Handler, error) {
+	minVersion, err := cfg.Version()
+	if err != nil {
+		return nil, nil, err
+	}
+	suites, err := cfg.CipherSuiteIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+	clientCAs, err := cfg.ClientCAs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	// Client certificates are checked when presented; the routes needing
+	// one reject requests without
+	if clientCAs != nil {
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	var fallback http.Handler = http.NotFoundHandler()
+	if cfg.RedirectHTTP {
+		fallback = httpsRedirect(httpsPort)
+	}
+
+	if len(cfg.ACMEDomains) == 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return tlsConfig, fallback, nil
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+		Email:      cfg.ACMEEmail,
+	}
+	if cfg.ACMEDirectory != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectory}
+	}
+
+	// Certificates are obtained on the first handshake for a domain and
+	// renewed by the manager before they expire
+	tlsConfig.GetCertificate = manager.GetCertificate
+	tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+
+	return tlsConfig, manager.HTTPHandler(fallback), nil
+}
+
+func withoutProto(protos []string, drop string) []string {
+	kept := make([]string, 0, len(protos))
+	for _, proto := range protos {
+		if proto != drop {
+			kept = append(kept, proto)
+		}
+	}
+	return kept
+}
+
+func httpsRedirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
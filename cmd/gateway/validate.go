@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/storage"
+
+	"github.com/spf13/viper"
+)
+
+// runValidate implements `gateway validate`: it loads the config the way
+// the gateway would, reports every problem found and returns the exit
+// code, so CI can reject a config before it is deployed
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	connect := flags.Bool("connect", false, "also check that the storage backend and Redis are reachable")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		return 1
+	}
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		fmt.Printf("Config file: %s\n", file)
+	} else {
+		fmt.Println("Config file: none, using environment variables and defaults")
+	}
+
+	problems := 0
+	report := func(title string, err error) {
+		if err == nil {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, e := range unjoin(err) {
+			fmt.Printf("  - %v\n", e)
+			problems++
+		}
+	}
+	report("Invalid settings", cfg.Validate())
+	report("Ignored settings", cfg.Lint())
+
+	if *connect {
+		var errs []error
+		if _, _, closeStorage, err := openStorage(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Storage.Backend, err))
+		} else {
+			closeStorage()
+		}
+		if redisClient, err := storage.NewRedisClient(cfg.Redis); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		} else {
+			redisClient.Close()
+		}
+		report("Unreachable dependencies", errors.Join(errs...))
+	}
+
+	if problems > 0 {
+		fmt.Printf("\n%d problem(s) found\n", problems)
+		return 1
+	}
+	fmt.Printf("Config OK: %d services, %d routes\n", len(cfg.Services), len(cfg.Routes))
+	return 0
+}
+
+// unjoin splits an error built with errors.Join back into its parts
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,208 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// Formats supported for access log lines
+const (
+	FormatJSON     = "json"
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+)
+
+// Entry is one completed request
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"request_id,omitempty"`
+	ClientIP  string        `json:"client_ip"`
+	User      string        `json:"user,omitempty"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Query     string        `json:"query,omitempty"`
+	Route     string        `json:"route,omitempty"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int           `json:"bytes"`
+	Latency   time.Duration `json:"-"`
+	LatencyMS float64       `json:"latency_ms"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+
+	// Experiment and Variant tag requests assigned to an A/B experiment
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+// Sink receives formatted access log lines
+type Sink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// Logger formats entries and fans them out to its sinks. Entries can be
+// sampled per route; server errors are always logged.
+type Logger struct {
+	format      string
+	sinks       []Sink
+	defaultRate float64
+	routeRates  map[string]float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func New(format string, sinks []Sink, defaultRate float64, routeRates map[string]float64) (*Logger, error) {
+	switch format {
+	case "", FormatJSON:
+		format = FormatJSON
+	case FormatCommon, FormatCombined:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+
+	return &Logger{
+		format:      format,
+		sinks:       sinks,
+		defaultRate: defaultRate,
+		routeRates:  routeRates,
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Log writes the entry to every sink unless it is sampled out
+func (l *Logger) Log(e Entry) {
+	if !l.sampled(e) {
+		return
+	}
+
+	line := l.formatEntry(e)
+	for _, sink := range l.sinks {
+		sink.Write(line)
+	}
+}
+
+func (l *Logger) sampled(e Entry) bool {
+	if e.Status >= 500 {
+		return true
+	}
+
+	rate, ok := l.routeRates[e.Route]
+	if !ok {
+		rate = l.defaultRate
+	}
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rng.Float64() < rate
+}
+
+func (l *Logger) formatEntry(e Entry) []byte {
+	switch l.format {
+	case FormatCommon:
+		return []byte(commonLine(e) + "\n")
+	case FormatCombined:
+		return []byte(fmt.Sprintf("%s %q %q\n", commonLine(e), dash(e.Referer), dash(e.UserAgent)))
+	default:
+		e.LatencyMS = float64(e.Latency.Microseconds()) / 1000
+		b, _ := json.Marshal(e)
+		return append(b, '\n')
+	}
+}
+
+// commonLine renders the NCSA common log format
+func commonLine(e Entry) string {
+	uri := e.Path
+	if e.Query != "" {
+		uri += "?" + e.Query
+	}
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.Itoa(e.Bytes)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		e.ClientIP,
+		dash(strings.ReplaceAll(e.User, " ", "_")),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, uri, e.Proto,
+		e.Status, bytes,
+	)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (l *Logger) Close() error {
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Open builds the access logger and its sinks from config
+func Open(cfg config.AccessLogConfig) (*Logger, error) {
+	var sinks []Sink
+	fail := func(err error) (*Logger, error) {
+		for _, s := range sinks {
+			s.Close()
+		}
+		return nil, err
+	}
+
+	for _, out := range cfg.Outputs {
+		switch out {
+		case "stdout":
+			sinks = append(sinks, NewWriterSink(os.Stdout))
+		case "file":
+			sink, err := NewFileSink(cfg.FilePath, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+			if err != nil {
+				return fail(fmt.Errorf("open access log file: %w", err))
+			}
+			sinks = append(sinks, sink)
+		case "syslog":
+			sink, err := NewSyslogSink(cfg.SyslogAddr, cfg.SyslogTag)
+			if err != nil {
+				return fail(fmt.Errorf("connect to syslog: %w", err))
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sinks = append(sinks, NewKafkaSink(cfg.KafkaURL, cfg.KafkaTopic))
+		default:
+			return fail(fmt.Errorf("unknown access log output %q", out))
+		}
+	}
+
+	routeRates := make(map[string]float64, len(cfg.Sampling))
+	for _, s := range cfg.Sampling {
+		routeRates[s.Path] = s.Rate
+	}
+
+	l, err := New(cfg.Format, sinks, cfg.SampleRate, routeRates)
+	if err != nil {
+		return fail(err)
+	}
+	return l, nil
+}
@@ -0,0 +1,130 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kafkaBatchSize     = 500
+	kafkaFlushInterval = time.Second
+	kafkaQueueSize     = 10000
+)
+
+// KafkaSink publishes lines to a Kafka topic through a Kafka REST proxy.
+// Lines are queued and sent in batches; when the queue is full new lines
+// are dropped rather than blocking requests.
+type KafkaSink struct {
+	endpoint string
+	client   *http.Client
+	queue    chan []byte
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewKafkaSink(restURL, topic string) *KafkaSink {
+	s := &KafkaSink{
+		endpoint: strings.TrimSuffix(restURL, "/") + "/topics/" + topic,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		queue:    make(chan []byte, kafkaQueueSize),
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *KafkaSink) Write(line []byte) error {
+	select {
+	case s.queue <- line:
+		return nil
+	default:
+		return fmt.Errorf("kafka access log queue full")
+	}
+}
+
+func (s *KafkaSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(kafkaFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, kafkaBatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.publish(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case line := <-s.queue:
+			batch = append(batch, line)
+			if len(batch) >= kafkaBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.queue:
+					batch = append(batch, line)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+type kafkaRecord struct {
+	Value json.RawMessage `json:"value"`
+}
+
+func (s *KafkaSink) publish(lines [][]byte) error {
+	records := make([]kafkaRecord, 0, len(lines))
+	for _, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		value := json.RawMessage(line)
+		if !json.Valid(line) {
+			value, _ = json.Marshal(string(line))
+		}
+		records = append(records, kafkaRecord{Value: value})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close flushes queued lines and stops the sender
+func (s *KafkaSink) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !windows && !plan9
+
+package accesslog
+
+import (
+	"log/syslog"
+	"strings"
+)
+
+// SyslogSink sends each line to syslog at info level. An empty addr uses
+// the local syslog daemon; otherwise addr is network://host:port.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(addr, tag string) (*SyslogSink, error) {
+	network, raddr := "", ""
+	if addr != "" {
+		network, raddr = "udp", addr
+		if scheme, rest, ok := strings.Cut(addr, "://"); ok {
+			network, raddr = scheme, rest
+		}
+	}
+
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) Write(line []byte) error {
+	return s.writer.Info(strings.TrimSuffix(string(line), "\n"))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
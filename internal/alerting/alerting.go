@@ -0,0 +1,262 @@
+package alerting
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"api-gateway/internal/circuit"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+)
+
+// Metrics a rule can watch
+const (
+	MetricErrorRate   = "error_rate"
+	MetricP99Latency  = "p99_latency"
+	MetricBreakerOpen = "breaker_open"
+)
+
+// Alert states
+const (
+	StatePending = "pending"
+	StateFiring  = "firing"
+)
+
+// Alert is a rule whose condition holds for a service. It is pending
+// until the condition held for the rule's For, then firing until it no
+// longer holds.
+type Alert struct {
+	Rule      string     `json:"rule"`
+	Service   string     `json:"service"`
+	Metric    string     `json:"metric"`
+	Severity  string     `json:"severity"`
+	Threshold float64    `json:"threshold"`
+	Value     float64    `json:"value"`
+	State     string     `json:"state"`
+	Since     time.Time  `json:"since"`
+	FiredAt   *time.Time `json:"fired_at,omitempty"`
+}
+
+// Channel delivers alert notifications, e.g. to Slack
+type Channel interface {
+	Name() string
+	Notify(ctx context.Context, alert Alert, resolved bool) error
+}
+
+// Evaluator checks the alerting rules against the gateway's metrics. Every
+// instance evaluates its own traffic; PagerDuty deduplicates the alerts of
+// several instances by rule and service.
+type Evaluator struct {
+	collector *metrics.Collector
+	breakers  *circuit.BreakerManager
+	channels  []Channel
+	source    string
+	logger    *logger.Logger
+
+	mu          sync.Mutex
+	rules       []config.AlertRule
+	alerts      map[string]*Alert
+	breakerOpen map[string]time.Time
+}
+
+func NewEvaluator(cfg config.AlertingConfig, collector *metrics.Collector, breakers *circuit.BreakerManager, log *logger.Logger) *Evaluator {
+	source, _ := os.Hostname()
+	e := &Evaluator{
+		collector:   collector,
+		breakers:    breakers,
+		source:      source,
+		logger:      log,
+		rules:       cfg.Rules,
+		alerts:      make(map[string]*Alert),
+		breakerOpen: make(map[string]time.Time),
+	}
+	if cfg.SlackWebhookURL != "" {
+		e.channels = append(e.channels, NewSlack(cfg.SlackWebhookURL))
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		e.channels = append(e.channels, NewPagerDuty(cfg.PagerDutyURL, cfg.PagerDutyRoutingKey, source))
+	}
+	return e
+}
+
+// SetRules replaces the rules on config reload. Firing alerts of removed
+// rules are resolved at the next evaluation.
+func (e *Evaluator) SetRules(rules []config.AlertRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Alerts lists the pending and firing alerts
+func (e *Evaluator) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Service < alerts[j].Service
+	})
+	return alerts
+}
+
+// Start evaluates the rules every interval until ctx is cancelled
+func (e *Evaluator) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx, time.Now())
+		}
+	}
+}
+
+type notification struct {
+	alert    Alert
+	rule     config.AlertRule
+	resolved bool
+}
+
+// Evaluate updates the alerts from the current metrics and sends the
+// notifications for alerts that fired or resolved
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) {
+	stats, err := e.collector.Stats()
+	if err != nil {
+		e.logger.Warnw("Failed to collect stats for alerting", "error", err)
+		return
+	}
+
+	e.mu.Lock()
+	values := e.values(stats, now)
+	var notifications []notification
+	seen := make(map[string]bool)
+	for _, rule := range e.rules {
+		for service, value := range values[rule.Metric] {
+			if rule.Service != "" && rule.Service != service || value <= rule.Threshold {
+				continue
+			}
+
+			key := rule.Name + "|" + service
+			seen[key] = true
+			alert, ok := e.alerts[key]
+			if !ok {
+				alert = &Alert{
+					Rule:      rule.Name,
+					Service:   service,
+					Metric:    rule.Metric,
+					Severity:  rule.Severity,
+					Threshold: rule.Threshold,
+					State:     StatePending,
+					Since:     now,
+				}
+				e.alerts[key] = alert
+			}
+			alert.Value = value
+			if alert.State == StatePending && now.Sub(alert.Since) >= rule.For {
+				firedAt := now
+				alert.State = StateFiring
+				alert.FiredAt = &firedAt
+				notifications = append(notifications, notification{alert: *alert, rule: rule})
+			}
+		}
+	}
+	for key, alert := range e.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(e.alerts, key)
+		if alert.State == StateFiring {
+			notifications = append(notifications, notification{alert: *alert, rule: e.rule(alert.Rule), resolved: true})
+		}
+	}
+	e.mu.Unlock()
+
+	for _, n := range notifications {
+		e.notify(ctx, n)
+	}
+}
+
+// values works out every metric per service; e.mu must be held
+func (e *Evaluator) values(stats *metrics.Stats, now time.Time) map[string]map[string]float64 {
+	values := map[string]map[string]float64{
+		MetricErrorRate:   {},
+		MetricP99Latency:  {},
+		MetricBreakerOpen: {},
+	}
+	for _, upstream := range stats.Upstreams {
+		if upstream.Requests <= 0 {
+			continue
+		}
+		values[MetricErrorRate][upstream.Service] = (upstream.Errors + upstream.ServerErrors) / upstream.Requests
+		values[MetricP99Latency][upstream.Service] = upstream.Latency.P99
+	}
+
+	// Breakers cycle between open and half-open while the service keeps
+	// failing, so the duration counts from when the breaker left closed
+	open := make(map[string]time.Time)
+	for _, status := range e.breakers.List() {
+		if status.State == "closed" {
+			continue
+		}
+		since, ok := e.breakerOpen[status.Service]
+		if !ok {
+			since = now
+		}
+		open[status.Service] = since
+		values[MetricBreakerOpen][status.Service] = now.Sub(since).Seconds()
+	}
+	e.breakerOpen = open
+	return values
+}
+
+// rule returns the named rule, or one carrying just the name if it was
+// removed; e.mu must be held
+func (e *Evaluator) rule(name string) config.AlertRule {
+	for _, rule := range e.rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return config.AlertRule{Name: name}
+}
+
+func (e *Evaluator) notify(ctx context.Context, n notification) {
+	for _, channel := range e.channels {
+		if len(n.rule.Channels) > 0 && !contains(n.rule.Channels, channel.Name()) {
+			continue
+		}
+
+		notifyCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := channel.Notify(notifyCtx, n.alert, n.resolved)
+		cancel()
+		if err != nil {
+			e.logger.Warnw("Failed to send alert",
+				"channel", channel.Name(), "rule", n.alert.Rule, "service", n.alert.Service, "resolved", n.resolved, "error", err)
+			continue
+		}
+		e.logger.Infow("Alert sent",
+			"channel", channel.Name(), "rule", n.alert.Rule, "service", n.alert.Service, "resolved", n.resolved)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Slack posts alerts to an incoming webhook
+type Slack struct {
+	url string
+}
+
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{url: webhookURL}
+}
+
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+func (s *Slack) Notify(ctx context.Context, alert Alert, resolved bool) error {
+	text := fmt.Sprintf(":rotating_light: *FIRING* %s on %s: %s is %s, above %s",
+		alert.Rule, alert.Service, alert.Metric, formatValue(alert.Metric, alert.Value), formatValue(alert.Metric, alert.Threshold))
+	if alert.Severity != "" {
+		text += " (" + alert.Severity + ")"
+	}
+	if resolved {
+		text = fmt.Sprintf(":white_check_mark: *RESOLVED* %s on %s", alert.Rule, alert.Service)
+	}
+	return postJSON(ctx, s.url, map[string]string{"text": text})
+}
+
+// PagerDuty sends alerts to the PagerDuty Events API v2. The dedup key is
+// the rule and service, so a resolve closes the incident its trigger
+// opened.
+type PagerDuty struct {
+	url        string
+	routingKey string
+	source     string
+}
+
+func NewPagerDuty(eventsURL, routingKey, source string) *PagerDuty {
+	return &PagerDuty{url: eventsURL, routingKey: routingKey, source: source}
+}
+
+func (p *PagerDuty) Name() string {
+	return "pagerduty"
+}
+
+func (p *PagerDuty) Notify(ctx context.Context, alert Alert, resolved bool) error {
+	event := map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "api-gateway/" + alert.Rule + "/" + alert.Service,
+	}
+	if resolved {
+		event["event_action"] = "resolve"
+		return postJSON(ctx, p.url, event)
+	}
+
+	severity := alert.Severity
+	if severity == "" {
+		severity = "error"
+	}
+	event["payload"] = map[string]interface{}{
+		"summary": fmt.Sprintf("%s on %s: %s is %s, above %s",
+			alert.Rule, alert.Service, alert.Metric, formatValue(alert.Metric, alert.Value), formatValue(alert.Metric, alert.Threshold)),
+		"source":    p.source,
+		"severity":  severity,
+		"component": alert.Service,
+		"group":     "api-gateway",
+		"custom_details": map[string]interface{}{
+			"metric":    alert.Metric,
+			"value":     alert.Value,
+			"threshold": alert.Threshold,
+			"since":     alert.Since,
+		},
+	}
+	return postJSON(ctx, p.url, event)
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// formatValue renders a metric value with its unit
+func formatValue(metric string, value float64) string {
+	switch metric {
+	case MetricErrorRate:
+		return strconv.FormatFloat(value*100, 'f', 1, 64) + "%"
+	case MetricP99Latency:
+		return strconv.FormatFloat(value, 'f', 0, 64) + "ms"
+	case MetricBreakerOpen:
+		return (time.Duration(value) * time.Second).String()
+	default:
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	}
+}
@@ -0,0 +1,172 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"api-gateway/internal/accesslog"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+)
+
+// Record holds the facts about one proxied request the reports are built
+// from
+type Record struct {
+	Time          time.Time `bson:"time" json:"time"`
+	Consumer      string    `bson:"consumer" json:"consumer"`
+	Tenant        string    `bson:"tenant,omitempty" json:"tenant,omitempty"`
+	ClientIP      string    `bson:"client_ip" json:"client_ip"`
+	Method        string    `bson:"method" json:"method"`
+	Route         string    `bson:"route" json:"route"`
+	Service       string    `bson:"service" json:"service"`
+	Status        int       `bson:"status" json:"status"`
+	RequestBytes  int64     `bson:"request_bytes" json:"request_bytes"`
+	ResponseBytes int64     `bson:"response_bytes" json:"response_bytes"`
+	LatencyMS     float64   `bson:"latency_ms" json:"latency_ms"`
+	Experiment    string    `bson:"experiment,omitempty" json:"experiment,omitempty"`
+	Variant       string    `bson:"variant,omitempty" json:"variant,omitempty"`
+}
+
+// Sink stores batches of records
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// Recorder queues records and hands them to its sink in batches, so
+// requests never wait for the sink. When the queue is full new records
+// are dropped.
+type Recorder struct {
+	sink          Sink
+	batchSize     int
+	flushInterval time.Duration
+	logger        *logger.Logger
+
+	queue chan Record
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewRecorder(sink Sink, cfg config.AnalyticsConfig, log *logger.Logger) *Recorder {
+	r := &Recorder{
+		sink:          sink,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		logger:        log,
+		queue:         make(chan Record, cfg.QueueSize),
+		done:          make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Record queues a record without blocking
+func (r *Recorder) Record(record Record) {
+	select {
+	case r.queue <- record:
+	default:
+		metrics.AnalyticsRecords.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (r *Recorder) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, r.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			r.write(batch)
+			batch = make([]Record, 0, r.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case record := <-r.queue:
+			batch = append(batch, record)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.done:
+			for {
+				select {
+				case record := <-r.queue:
+					batch = append(batch, record)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(batch []Record) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.sink.Write(ctx, batch); err != nil {
+		metrics.AnalyticsRecords.WithLabelValues("failed").Add(float64(len(batch)))
+		r.logger.Warnw("Failed to write analytics records", "records", len(batch), "error", err)
+		return
+	}
+	metrics.AnalyticsRecords.WithLabelValues("written").Add(float64(len(batch)))
+}
+
+// Close writes the queued records and closes the sink
+func (r *Recorder) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return r.sink.Close()
+}
+
+// Open builds the recorder for cfg's sink. The store, which answers the
+// report queries, is nil unless the sink is mongo.
+func Open(cfg config.AnalyticsConfig, mongoClient *storage.MongoClient, log *logger.Logger) (*Recorder, *Store, error) {
+	switch cfg.Sink {
+	case "mongo":
+		store := NewStore(mongoClient, cfg.Retention)
+		return NewRecorder(store, cfg, log), store, nil
+	case "kafka":
+		sink := &lineSink{sink: accesslog.NewKafkaSink(cfg.KafkaURL, cfg.KafkaTopic)}
+		return NewRecorder(sink, cfg, log), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown analytics sink %q", cfg.Sink)
+	}
+}
+
+// lineSink writes records as JSON lines to an access log sink
+type lineSink struct {
+	sink accesslog.Sink
+}
+
+func (s *lineSink) Write(ctx context.Context, records []Record) error {
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := s.sink.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *lineSink) Close() error {
+	return s.sink.Close()
+}
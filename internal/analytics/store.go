@@ -0,0 +1,258 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway/pkg/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultReportLimit   = 20
+	defaultReportPeriod  = 24 * time.Hour
+	defaultTrafficWindow = time.Hour
+	maxTrafficWindows    = 1000
+)
+
+var ErrTooManyWindows = errors.New("the time range holds too many intervals")
+
+// Query narrows a report to a time range and optionally to one consumer,
+// route, service or experiment variant. The range defaults to the last 24
+// hours.
+type Query struct {
+	From       time.Time     `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time     `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Consumer   string        `form:"consumer"`
+	Route      string        `form:"route"`
+	Service    string        `form:"service"`
+	Experiment string        `form:"experiment"`
+	Variant    string        `form:"variant"`
+	Limit      int64         `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Interval   time.Duration `form:"interval"`
+}
+
+// ConsumerUsage is a consumer's traffic in a report. Errors counts server
+// errors; Bytes is request and response bodies together.
+type ConsumerUsage struct {
+	Consumer     string  `bson:"consumer" json:"consumer"`
+	Requests     int64   `bson:"requests" json:"requests"`
+	Errors       int64   `bson:"errors" json:"errors"`
+	Bytes        int64   `bson:"bytes" json:"bytes"`
+	AvgLatencyMS float64 `bson:"avg_latency_ms" json:"avg_latency_ms"`
+}
+
+// RouteErrors is a route's error rate in a report: the share of its
+// requests that ended in a server error
+type RouteErrors struct {
+	Route        string  `bson:"route" json:"route"`
+	Service      string  `bson:"service" json:"service"`
+	Requests     int64   `bson:"requests" json:"requests"`
+	ClientErrors int64   `bson:"client_errors" json:"client_errors"`
+	ServerErrors int64   `bson:"server_errors" json:"server_errors"`
+	ErrorRate    float64 `bson:"error_rate" json:"error_rate"`
+}
+
+// TrafficWindow is the traffic of one interval starting at Time
+type TrafficWindow struct {
+	Time         time.Time `bson:"time" json:"time"`
+	Requests     int64     `bson:"requests" json:"requests"`
+	Errors       int64     `bson:"errors" json:"errors"`
+	Bytes        int64     `bson:"bytes" json:"bytes"`
+	AvgLatencyMS float64   `bson:"avg_latency_ms" json:"avg_latency_ms"`
+}
+
+// Store keeps records in Mongo and builds the reports from them. Records
+// expire through a TTL index once the retention has passed.
+type Store struct {
+	collection *mongo.Collection
+	retention  time.Duration
+}
+
+func NewStore(mongoClient *storage.MongoClient, retention time.Duration) *Store {
+	return &Store{
+		collection: mongoClient.Database.Collection("analytics"),
+		retention:  retention,
+	}
+}
+
+// EnsureIndexes creates the report and retention indexes. Changing the
+// retention updates the existing TTL index in place.
+func (s *Store) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "consumer", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "route", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "service", Value: 1}, {Key: "time", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.retention <= 0 {
+		return nil
+	}
+	ttl := int32(s.retention.Seconds())
+	_, err = s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "time", Value: 1}},
+		Options: options.Index().SetName("time_ttl").SetExpireAfterSeconds(ttl),
+	})
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.HasErrorCode(85) {
+		// IndexOptionsConflict: the retention changed, update the TTL in place
+		err = s.collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: s.collection.Name()},
+			{Key: "index", Value: bson.D{{Key: "name", Value: "time_ttl"}, {Key: "expireAfterSeconds", Value: ttl}}},
+		}).Err()
+	}
+	return err
+}
+
+func (s *Store) Write(ctx context.Context, records []Record) error {
+	docs := make([]interface{}, len(records))
+	for i, record := range records {
+		docs[i] = record
+	}
+	_, err := s.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// TopConsumers returns the consumers with the most requests
+func (s *Store) TopConsumers(ctx context.Context, q Query) ([]ConsumerUsage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: q.filter()}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$consumer",
+			"requests":       bson.M{"$sum": 1},
+			"errors":         bson.M{"$sum": statusCount(500, 600)},
+			"bytes":          bson.M{"$sum": bson.M{"$add": bson.A{"$request_bytes", "$response_bytes"}}},
+			"avg_latency_ms": bson.M{"$avg": "$latency_ms"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "requests", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: q.limit()}},
+		{{Key: "$addFields", Value: bson.M{"consumer": "$_id"}}},
+	}
+
+	usage := make([]ConsumerUsage, 0)
+	return usage, s.aggregate(ctx, pipeline, &usage)
+}
+
+// RouteErrorRates returns the routes with the highest error rates
+func (s *Store) RouteErrorRates(ctx context.Context, q Query) ([]RouteErrors, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: q.filter()}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"route": "$route", "service": "$service"},
+			"requests":      bson.M{"$sum": 1},
+			"client_errors": bson.M{"$sum": statusCount(400, 500)},
+			"server_errors": bson.M{"$sum": statusCount(500, 600)},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"route":      "$_id.route",
+			"service":    "$_id.service",
+			"error_rate": bson.M{"$divide": bson.A{"$server_errors", "$requests"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "error_rate", Value: -1}, {Key: "requests", Value: -1}}}},
+		{{Key: "$limit", Value: q.limit()}},
+	}
+
+	routes := make([]RouteErrors, 0)
+	return routes, s.aggregate(ctx, pipeline, &routes)
+}
+
+// Traffic returns the traffic per interval, oldest first. Intervals
+// without requests are left out.
+func (s *Store) Traffic(ctx context.Context, q Query) ([]TrafficWindow, error) {
+	interval := q.Interval
+	if interval <= 0 {
+		interval = defaultTrafficWindow
+	}
+	from, to := q.period()
+	if to.Sub(from)/interval > maxTrafficWindows {
+		return nil, ErrTooManyWindows
+	}
+
+	millis := bson.M{"$toLong": "$time"}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: q.filter()}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$toDate": bson.M{"$subtract": bson.A{
+				millis, bson.M{"$mod": bson.A{millis, interval.Milliseconds()}},
+			}}},
+			"requests":       bson.M{"$sum": 1},
+			"errors":         bson.M{"$sum": statusCount(500, 600)},
+			"bytes":          bson.M{"$sum": bson.M{"$add": bson.A{"$request_bytes", "$response_bytes"}}},
+			"avg_latency_ms": bson.M{"$avg": "$latency_ms"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$addFields", Value: bson.M{"time": "$_id"}}},
+	}
+
+	windows := make([]TrafficWindow, 0)
+	return windows, s.aggregate(ctx, pipeline, &windows)
+}
+
+func (s *Store) aggregate(ctx context.Context, pipeline mongo.Pipeline, results interface{}) error {
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func (q Query) period() (time.Time, time.Time) {
+	to := q.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := q.From
+	if from.IsZero() {
+		from = to.Add(-defaultReportPeriod)
+	}
+	return from, to
+}
+
+func (q Query) filter() bson.M {
+	from, to := q.period()
+	filter := bson.M{"time": bson.M{"$gte": from, "$lt": to}}
+	if q.Consumer != "" {
+		filter["consumer"] = q.Consumer
+	}
+	if q.Route != "" {
+		filter["route"] = q.Route
+	}
+	if q.Service != "" {
+		filter["service"] = q.Service
+	}
+	if q.Experiment != "" {
+		filter["experiment"] = q.Experiment
+	}
+	if q.Variant != "" {
+		filter["variant"] = q.Variant
+	}
+	return filter
+}
+
+func (q Query) limit() int64 {
+	if q.Limit <= 0 {
+		return defaultReportLimit
+	}
+	return q.Limit
+}
+
+// statusCount counts the records whose status is in [from, to)
+func statusCount(from, to int) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$gte": bson.A{"$status", from}},
+			bson.M{"$lt": bson.A{"$status", to}},
+		}},
+		1, 0,
+	}}
+}
@@ -1,28 +1,91 @@
 package circuit
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
 
 	"github.com/sony/gobreaker"
 )
 
+// Manual overrides set through the admin API
+const (
+	ForceNone   = ""
+	ForceOpen   = "open"
+	ForceClosed = "closed"
+)
+
+var ErrBreakerNotFound = errors.New("circuit breaker not found")
+
+// Breaker wraps a gobreaker.CircuitBreaker with a manual override and the
+// time of its last state transition
+type Breaker struct {
+	name   string
+	shared *sharedBreakers
+
+	mu             sync.RWMutex
+	cb             *gobreaker.CircuitBreaker
+	forced         string
+	lastTransition time.Time
+
+	// openUntil holds a shared breaker open after a trip on any replica
+	openUntil time.Time
+	// sharedFailures is the shared failure count last seen
+	sharedFailures atomic.Int64
+}
+
+// BreakerStatus is a point-in-time view of a breaker for operators
+type BreakerStatus struct {
+	Service        string     `json:"service"`
+	State          string     `json:"state"`
+	Forced         string     `json:"forced,omitempty"`
+	Counts         Counts     `json:"counts"`
+	LastTransition time.Time  `json:"last_transition"`
+	OpenUntil      *time.Time `json:"open_until,omitempty"`
+}
+
+type Counts struct {
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"total_successes"`
+	TotalFailures        uint32 `json:"total_failures"`
+	ConsecutiveSuccesses uint32 `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32 `json:"consecutive_failures"`
+}
+
 type BreakerManager struct {
-	breakers map[string]*gobreaker.CircuitBreaker
+	breakers map[string]*Breaker
 	config   config.CircuitBreakerConfig
+	events   *events.Notifier
+	shared   *sharedBreakers
 	mu       sync.RWMutex
 }
 
-func NewBreakerManager(cfg config.CircuitBreakerConfig) *BreakerManager {
-	return &BreakerManager{
-		breakers: make(map[string]*gobreaker.CircuitBreaker),
+// NewBreakerManager creates the breakers' manager. With cfg.Shared the
+// breakers follow those of the other replicas once Start runs.
+func NewBreakerManager(cfg config.CircuitBreakerConfig, notifier *events.Notifier, redisClient *storage.RedisClient, log *logger.Logger) *BreakerManager {
+	bm := &BreakerManager{
+		breakers: make(map[string]*Breaker),
 		config:   cfg,
+		events:   notifier,
 	}
+	if cfg.Shared {
+		bm.shared = newSharedBreakers(bm, redisClient, log)
+	}
+	return bm
 }
 
-func (bm *BreakerManager) GetBreaker(serviceName string) *gobreaker.CircuitBreaker {
+func (bm *BreakerManager) GetBreaker(serviceName string) *Breaker {
 	bm.mu.RLock()
 	breaker, exists := bm.breakers[serviceName]
 	bm.mu.RUnlock()
@@ -40,19 +103,193 @@ func (bm *BreakerManager) GetBreaker(serviceName string) *gobreaker.CircuitBreak
 		return breaker
 	}
 
-	breaker = gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:        serviceName,
-		MaxRequests: 3,
-		Interval:    time.Minute,
-		Timeout:     bm.config.Timeout,
+	breaker = &Breaker{name: serviceName, shared: bm.shared, lastTransition: time.Now()}
+	breaker.cb = bm.newCircuitBreaker(breaker)
+
+	bm.breakers[serviceName] = breaker
+	metrics.CircuitBreakerState.WithLabelValues(serviceName).Set(float64(gobreaker.StateClosed))
+	return breaker
+}
+
+func (bm *BreakerManager) newCircuitBreaker(b *Breaker) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:         b.name,
+		MaxRequests:  3,
+		Interval:     time.Minute,
+		Timeout:      bm.config.Timeout,
+		IsSuccessful: isSuccessful,
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			return counts.ConsecutiveFailures >= uint32(bm.config.Threshold)
 		},
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			// Log state changes
+			b.mu.Lock()
+			b.lastTransition = time.Now()
+			b.mu.Unlock()
+			metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+
+			data := map[string]interface{}{"service": name, "from": from.String()}
+			switch to {
+			case gobreaker.StateOpen:
+				bm.events.Emit(events.BreakerOpened, data)
+			case gobreaker.StateClosed:
+				bm.events.Emit(events.BreakerClosed, data)
+			}
+			if bm.shared != nil {
+				bm.shared.stateChanged(b, to)
+			}
 		},
 	})
+}
 
-	bm.breakers[serviceName] = breaker
-	return breaker
+// isSuccessful keeps client faults, such as an oversized request body, an
+// upload breaking the route's policy or a client hanging up, from counting
+// against the upstream
+func isSuccessful(err error) bool {
+	var tooLarge *http.MaxBytesError
+	var rejected *utils.APIError
+	return err == nil || errors.As(err, &tooLarge) || errors.As(err, &rejected) || errors.Is(err, context.Canceled)
+}
+
+// List returns the status of every breaker, sorted by service
+func (bm *BreakerManager) List() []BreakerStatus {
+	bm.mu.RLock()
+	statuses := make([]BreakerStatus, 0, len(bm.breakers))
+	for _, breaker := range bm.breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	bm.mu.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Service < statuses[j].Service
+	})
+	return statuses
+}
+
+// Status returns the status of one service's breaker
+func (bm *BreakerManager) Status(serviceName string) (BreakerStatus, error) {
+	breaker, err := bm.lookup(serviceName)
+	if err != nil {
+		return BreakerStatus{}, err
+	}
+	return breaker.Status(), nil
+}
+
+// Force pins a breaker open or closed until it is reset. ForceNone clears
+// the override.
+func (bm *BreakerManager) Force(serviceName, mode string) error {
+	breaker, err := bm.lookup(serviceName)
+	if err != nil {
+		return err
+	}
+
+	breaker.mu.Lock()
+	breaker.forced = mode
+	breaker.lastTransition = time.Now()
+	openUntil := breaker.openUntil
+	breaker.mu.Unlock()
+
+	metrics.CircuitBreakerState.WithLabelValues(serviceName).Set(float64(breaker.State()))
+	if bm.shared != nil {
+		bm.shared.publish(breakerUpdate{Service: serviceName, Forced: mode, OpenUntil: openUntil})
+	}
+	return nil
+}
+
+// Reset clears any override and replaces the breaker with a fresh closed
+// one, discarding its counts
+func (bm *BreakerManager) Reset(serviceName string) error {
+	breaker, err := bm.lookup(serviceName)
+	if err != nil {
+		return err
+	}
+
+	cb := bm.newCircuitBreaker(breaker)
+
+	breaker.mu.Lock()
+	breaker.cb = cb
+	breaker.forced = ForceNone
+	breaker.openUntil = time.Time{}
+	breaker.lastTransition = time.Now()
+	breaker.mu.Unlock()
+	breaker.sharedFailures.Store(0)
+
+	metrics.CircuitBreakerState.WithLabelValues(serviceName).Set(float64(gobreaker.StateClosed))
+	if bm.shared != nil {
+		bm.shared.publish(breakerUpdate{Service: serviceName, Reset: true})
+	}
+	return nil
+}
+
+// lookup returns an existing breaker. Breakers are created lazily on the
+// first request, so a configured service may not have one yet.
+func (bm *BreakerManager) lookup(serviceName string) (*Breaker, error) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	breaker, ok := bm.breakers[serviceName]
+	if !ok {
+		return nil, ErrBreakerNotFound
+	}
+	return breaker, nil
+}
+
+// Execute runs fn through the breaker. A forced-open breaker rejects every
+// call; a forced-closed one lets every call through without counting it.
+// A shared breaker tripped on any replica rejects calls until it expires.
+func (b *Breaker) Execute(fn func() (interface{}, error)) (interface{}, error) {
+	b.mu.RLock()
+	cb, forced, openUntil := b.cb, b.forced, b.openUntil
+	b.mu.RUnlock()
+
+	switch forced {
+	case ForceOpen:
+		return nil, gobreaker.ErrOpenState
+	case ForceClosed:
+		return fn()
+	}
+	if time.Now().Before(openUntil) {
+		return nil, gobreaker.ErrOpenState
+	}
+
+	result, err := cb.Execute(fn)
+	if b.shared != nil {
+		b.shared.record(b, err)
+	}
+	return result, err
+}
+
+func (b *Breaker) State() gobreaker.State {
+	b.mu.RLock()
+	cb, forced, openUntil := b.cb, b.forced, b.openUntil
+	b.mu.RUnlock()
+
+	switch forced {
+	case ForceOpen:
+		return gobreaker.StateOpen
+	case ForceClosed:
+		return gobreaker.StateClosed
+	}
+	if time.Now().Before(openUntil) {
+		return gobreaker.StateOpen
+	}
+	return cb.State()
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	state := b.State()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := BreakerStatus{
+		Service:        b.name,
+		State:          state.String(),
+		Forced:         b.forced,
+		Counts:         Counts(b.cb.Counts()),
+		LastTransition: b.lastTransition,
+	}
+	if openUntil := b.openUntil; time.Now().Before(openUntil) {
+		status.OpenUntil = &openUntil
+	}
+	return status
 }
@@ -0,0 +1,278 @@
+package circuit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+)
+
+// Redis keys of shared breakers
+const (
+	sharedChannel     = "circuit:updates"
+	sharedStateKey    = "circuit:breakers"
+	sharedFailuresKey = "circuit:failures:"
+)
+
+// sharedFailuresTTL mirrors the interval gobreaker clears its counts after
+const sharedFailuresTTL = time.Minute
+
+// sharedBreakers keeps the breakers of every replica in step through
+// Redis. Trips, closes and overrides are published on sharedChannel and
+// kept in sharedStateKey while a breaker is open or forced, so replicas
+// starting later pick them up.
+type sharedBreakers struct {
+	manager *BreakerManager
+	redis   *storage.RedisClient
+	logger  *logger.Logger
+	config  config.CircuitBreakerConfig
+
+	// origin tells this replica's updates apart from the others'
+	origin string
+}
+
+// breakerUpdate is a breaker's shared state. OpenUntil is zero unless the
+// breaker was tripped.
+type breakerUpdate struct {
+	Origin    string    `json:"origin"`
+	Service   string    `json:"service"`
+	Forced    string    `json:"forced,omitempty"`
+	OpenUntil time.Time `json:"open_until"`
+	Reset     bool      `json:"reset,omitempty"`
+}
+
+func newSharedBreakers(bm *BreakerManager, redisClient *storage.RedisClient, log *logger.Logger) *sharedBreakers {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &sharedBreakers{
+		manager: bm,
+		redis:   redisClient,
+		logger:  log,
+		config:  bm.config,
+		origin:  hex.EncodeToString(id),
+	}
+}
+
+// Start applies the state other replicas stored, then follows their
+// updates until ctx is cancelled. The stored state is reloaded every
+// interval to catch up on updates missed while Redis was unreachable.
+func (bm *BreakerManager) Start(ctx context.Context, interval time.Duration) {
+	s := bm.shared
+	if s == nil {
+		return
+	}
+
+	if err := s.load(ctx); err != nil {
+		s.logger.Warnw("Failed to load shared circuit breaker state", "error", err)
+	}
+
+	sub := s.redis.Subscribe(ctx, sharedChannel)
+	defer sub.Close()
+	messages := sub.Channel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var update breakerUpdate
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				s.logger.Warnw("Ignoring malformed circuit breaker update", "error", err)
+				continue
+			}
+			s.apply(update)
+		case <-ticker.C:
+			if err := s.load(ctx); err != nil {
+				s.logger.Warnw("Failed to refresh shared circuit breaker state", "error", err)
+			}
+		}
+	}
+}
+
+// load applies the stored state of open and forced breakers
+func (s *sharedBreakers) load(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stored, err := s.redis.HGetAll(ctx, sharedStateKey).Result()
+	if err != nil {
+		return err
+	}
+	for _, value := range stored {
+		var update breakerUpdate
+		if err := json.Unmarshal([]byte(value), &update); err != nil {
+			continue
+		}
+		s.apply(update)
+	}
+	return nil
+}
+
+// apply takes over another replica's update
+func (s *sharedBreakers) apply(update breakerUpdate) {
+	if update.Origin == s.origin {
+		return
+	}
+
+	b := s.manager.GetBreaker(update.Service)
+	var cb *gobreaker.CircuitBreaker
+	if update.Reset {
+		cb = s.manager.newCircuitBreaker(b)
+	}
+
+	b.mu.Lock()
+	if b.forced == update.Forced && b.openUntil.Equal(update.OpenUntil) && !update.Reset {
+		b.mu.Unlock()
+		return
+	}
+	if cb != nil {
+		b.cb = cb
+	}
+	b.forced = update.Forced
+	b.openUntil = update.OpenUntil
+	b.lastTransition = time.Now()
+	b.mu.Unlock()
+
+	s.trackState(b)
+}
+
+// trackState updates the state metric now and once a shared trip expires
+func (s *sharedBreakers) trackState(b *Breaker) {
+	metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(b.State()))
+
+	b.mu.RLock()
+	remaining := time.Until(b.openUntil)
+	b.mu.RUnlock()
+	if remaining > 0 {
+		time.AfterFunc(remaining, func() {
+			metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(b.State()))
+		})
+	}
+}
+
+// publish stores and announces an update of this replica's breaker. It
+// runs in the background; a failure leaves the other replicas on their
+// own counts.
+func (s *sharedBreakers) publish(update breakerUpdate) {
+	update.Origin = s.origin
+	body, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if update.Forced == ForceNone && update.OpenUntil.IsZero() {
+				pipe.HDel(ctx, sharedStateKey, update.Service)
+			} else {
+				pipe.HSet(ctx, sharedStateKey, update.Service, body)
+			}
+			if update.Reset || !update.OpenUntil.IsZero() {
+				pipe.Del(ctx, sharedFailuresKey+update.Service)
+			}
+			pipe.Publish(ctx, sharedChannel, body)
+			return nil
+		})
+		if err != nil {
+			s.logger.Warnw("Failed to share circuit breaker state", "service", update.Service, "error", err)
+		}
+	}()
+}
+
+// stateChanged shares a transition of this replica's own breaker.
+// Half-open is local; every replica probes on its own.
+func (s *sharedBreakers) stateChanged(b *Breaker, to gobreaker.State) {
+	switch to {
+	case gobreaker.StateOpen:
+		s.publish(breakerUpdate{Service: b.name, OpenUntil: time.Now().Add(s.config.Timeout)})
+	case gobreaker.StateClosed:
+		b.mu.Lock()
+		b.openUntil = time.Time{}
+		b.mu.Unlock()
+		s.publish(breakerUpdate{Service: b.name})
+	}
+}
+
+// record counts a request's outcome across replicas. The replica whose
+// failure reaches the threshold trips the breaker on all of them; a
+// success clears the failures it has seen counted.
+func (s *sharedBreakers) record(b *Breaker, err error) {
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return
+	}
+
+	if isSuccessful(err) {
+		if b.sharedFailures.Swap(0) == 0 {
+			return
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			s.redis.Del(ctx, sharedFailuresKey+b.name)
+		}()
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		key := sharedFailuresKey + b.name
+		var count *redis.IntCmd
+		_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			count = pipe.Incr(ctx, key)
+			pipe.Expire(ctx, key, sharedFailuresTTL)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		b.sharedFailures.Store(count.Val())
+		if count.Val() == int64(s.config.Threshold) {
+			s.trip(b)
+		}
+	}()
+}
+
+// trip opens the breaker on every replica for the breaker timeout, unless
+// it is open or forced already
+func (s *sharedBreakers) trip(b *Breaker) {
+	if b.State() == gobreaker.StateOpen {
+		return
+	}
+	openUntil := time.Now().Add(s.config.Timeout)
+
+	b.mu.Lock()
+	if b.forced != ForceNone || b.openUntil.After(time.Now()) {
+		b.mu.Unlock()
+		return
+	}
+	b.openUntil = openUntil
+	b.lastTransition = time.Now()
+	b.mu.Unlock()
+	b.sharedFailures.Store(0)
+
+	s.trackState(b)
+	s.manager.events.Emit(events.BreakerOpened, map[string]interface{}{"service": b.name, "from": gobreaker.StateClosed.String()})
+	s.publish(breakerUpdate{Service: b.name, OpenUntil: openUntil})
+}
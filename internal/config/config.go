@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,30 +15,362 @@ import (
 
 type Config struct {
 	Server         ServerConfig
+	Admin          AdminConfig
 	JWT            JWTConfig
+	APIKeys        APIKeyConfig
+	HMAC           HMACConfig
+	Identity       IdentityConfig
+	Idempotency    IdempotencyConfig
+	Dedup          DedupConfig
+	Tenancy        TenancyConfig
+	Startup        StartupConfig
+	Storage        StorageConfig
 	MongoDB        MongoDBConfig
+	Postgres       PostgresConfig
 	Redis          RedisConfig
 	RateLimit      RateLimitConfig
+	Quota          QuotaConfig
 	CircuitBreaker CircuitBreakerConfig
+	HealthCheck    HealthCheckConfig
+	Readiness      ReadinessConfig
+	Outliers       OutlierDetectionConfig
+	Discovery      DiscoveryConfig
+	OIDC           OIDCConfig
+	Retry          RetryConfig
+	Upstream       UpstreamConfig
+	SPIFFE         SPIFFEConfig
+	Bulkhead       BulkheadConfig
+	LoadShedding   LoadSheddingConfig
+	ClientInFlight ClientInFlightConfig
+	SSE            SSEConfig
+	ExternalAuthz  ExternalAuthzConfig
 	Timeouts       TimeoutsConfig
 	CORS           CORSConfig
+	AccessControl  AccessControlConfig
+	Compression    CompressionConfig
+	Errors         ErrorsConfig
 	Logging        LoggingConfig
-	Services       []ServiceConfig
+	AccessLog      AccessLogConfig
+	BodyLog        BodyLogConfig
+	Messaging      MessagingConfig
+	Audit          AuditConfig
+	Analytics      AnalyticsConfig
+	Metering       MeteringConfig
+	Events         EventsConfig
+	Alerting       AlertingConfig
+	Email          EmailConfig
+	Session        SessionConfig
+	CSRF           CSRFConfig
+	Tracing        TracingConfig
+	Docs           DocsConfig
+	Secrets        SecretsConfig
+
+	// SecurityHeaders are set on every response. Config entries override
+	// the defaults; an empty value drops a default header.
+	SecurityHeaders map[string]string
+
+	// ResponseHeaders is applied to the responses of every proxied route,
+	// before the route's own policy
+	ResponseHeaders HeaderPolicy
+	Services        []ServiceConfig
+	Routes          []RouteConfig
+
+	// Schedules apply policies during recurring time windows
+	Schedules []ScheduleConfig
+
+	// Experiments split the consumers of routes between variants
+	Experiments []ExperimentConfig
+
+	// problems are the settings LoadConfig ignored, reported by Lint
+	problems []error
+
+	// secrets are the values fetched from the secrets provider
+	secrets map[string]string
 }
 
 type ServerConfig struct {
 	Port        int
 	Environment string
+	TLS         TLSConfig
+
+	// DrainDelay keeps serving after /ready starts failing so load
+	// balancers notice; DrainTimeout bounds the wait for in-flight requests
+	DrainDelay   time.Duration
+	DrainTimeout time.Duration
+
+	// MaxBodySize caps request bodies in bytes; routes may override it.
+	// Zero disables the limit.
+	MaxBodySize int64
+
+	// ReusePort binds with SO_REUSEPORT so a new binary can take over the
+	// port while the old one drains
+	ReusePort bool
+
+	// TrustedProxies are the addresses or CIDR ranges allowed to report the
+	// client address in RealIPHeader or a PROXY protocol header. Requests
+	// from anywhere else are attributed to their connection's address.
+	TrustedProxies []string
+	RealIPHeader   string
+	ProxyProtocol  bool
+
+	// StrictConfig refuses to start when Lint finds problems instead of
+	// logging them
+	StrictConfig bool
+
+	// Zone is the availability zone the gateway runs in. Zone-aware
+	// services prefer their instances in the same zone.
+	Zone string
+
+	// HTTP2 offers HTTP/2 over TLS via ALPN. H2C accepts cleartext HTTP/2
+	// on a plain listener, which is always on when gRPC services exist.
+	HTTP2                bool
+	H2C                  bool
+	MaxConcurrentStreams uint32
+}
+
+// TLSConfig enables HTTPS on the gateway listener, either from a static
+// certificate or with certificates obtained from an ACME CA
+type TLSConfig struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
+
+	ACMEDomains   []string
+	ACMEEmail     string
+	ACMECacheDir  string
+	ACMEDirectory string
+
+	// HTTPPort serves ACME HTTP-01 challenges and, when RedirectHTTP is
+	// set, redirects plain HTTP requests to HTTPS
+	HTTPPort     int
+	RedirectHTTP bool
+
+	MinVersion   string
+	CipherSuites []string
+
+	// ClientCAFile verifies the client certificates presented to the
+	// listener against these CAs. Certificates are optional during the
+	// handshake; routes with client_cert require them.
+	ClientCAFile string
+}
+
+// AdminConfig moves the admin API, /metrics and pprof to a listener of
+// their own, e.g. on 127.0.0.1 or an internal interface. Without Addr they
+// share the public listener and pprof is off. Auth is jwt (admin role
+// tokens, as on the public listener), token (Token as a bearer token) or
+// none, which is only allowed on a loopback address.
+type AdminConfig struct {
+	Addr  string
+	TLS   TLSConfig
+	Auth  string
+	Token string
+	Pprof bool
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret        string
+	Expiry        time.Duration
+	RefreshExpiry time.Duration
+
+	// Algorithm is HS256, signing with Secret, or RS256 or EdDSA, signing
+	// with the PEM encoded PrivateKey or the key in PrivateKeyFile, whose
+	// public half backends can verify tokens with
+	Algorithm      string
+	PrivateKey     string
+	PrivateKeyFile string
+
+	// KeyRefreshInterval is how often signing keys created through the
+	// admin API are reloaded, picking up rotations made on other instances
+	KeyRefreshInterval time.Duration
+
+	// TrustedIssuers are external identity providers whose RS/ES signed
+	// tokens are accepted alongside the gateway's own
+	TrustedIssuers []TrustedIssuerConfig
+
+	// Issuer and Audience are set on the gateway's own tokens and required
+	// of them. Leeway tolerates clock skew when checking exp and nbf, also
+	// for trusted issuers.
+	Issuer   string
+	Audience []string
+	Leeway   time.Duration
+
+	// ClaimRules are checked against every bearer token accepted
+	ClaimRules []ClaimRule
+}
+
+// ClaimRule requires a claim of every accepted token, unless Optional,
+// which only checks tokens carrying it. With Values the claim must be one
+// of them, and with Header it must equal that request header when the
+// request sends it, binding e.g. X-Tenant-ID or X-Device-ID to the token.
+// Array claims match if any element does.
+type ClaimRule struct {
+	Claim    string   `yaml:"claim" mapstructure:"claim"`
+	Values   []string `yaml:"values" mapstructure:"values"`
+	Header   string   `yaml:"header" mapstructure:"header"`
+	Optional bool     `yaml:"optional" mapstructure:"optional"`
+}
+
+// TrustedIssuerConfig describes an external token issuer. JWKSURL defaults
+// to the jwks_uri from the issuer's OpenID configuration. Tokens must carry
+// one of Audiences when any are set.
+type TrustedIssuerConfig struct {
+	Issuer    string   `yaml:"issuer" mapstructure:"issuer"`
+	JWKSURL   string   `yaml:"jwks_url" mapstructure:"jwks_url"`
+	Audiences []string `yaml:"audiences" mapstructure:"audiences"`
+
+	// Claim names mapped onto the gateway's user fields
+	UsernameClaim string            `yaml:"username_claim" mapstructure:"username_claim"`
+	RoleClaim     string            `yaml:"role_claim" mapstructure:"role_claim"`
+	RoleMap       map[string]string `yaml:"role_map" mapstructure:"role_map"`
+	DefaultRole   string            `yaml:"default_role" mapstructure:"default_role"`
+	TenantClaim   string            `yaml:"tenant_claim" mapstructure:"tenant_claim"`
+}
+
+type APIKeyConfig struct {
+	Header string
+}
+
+// TenancyConfig controls how requests are attributed to tenants: by the
+// caller's credentials, by host (a subdomain of BaseDomain or a tenant
+// domain) or by Header. With Required, proxied requests without a tenant
+// are rejected.
+type TenancyConfig struct {
+	Enabled         bool
+	Header          string
+	BaseDomain      string
+	Required        bool
+	RefreshInterval time.Duration
+}
+
+// IdentityConfig controls how the authenticated caller is passed to
+// upstream services. Clients can never set the identity headers
+// themselves; when Headers is set the gateway fills them in from the
+// verified credentials. InternalToken additionally forwards a JWT signed
+// with the gateway's key, valid for InternalTokenExpiry, which upstreams
+// can verify instead of trusting the network.
+type IdentityConfig struct {
+	Headers             bool
+	InternalToken       bool
+	InternalTokenHeader string
+	InternalTokenExpiry time.Duration
+}
+
+// HMACConfig names the headers signed requests carry. Timestamps may be
+// off by at most ClockSkew and each nonce is accepted only once.
+type HMACConfig struct {
+	ClientHeader    string
+	SignatureHeader string
+	TimestampHeader string
+	NonceHeader     string
+	ClockSkew       time.Duration
+}
+
+// IdempotencyConfig controls replay of responses for routes with
+// idempotency enabled. Responses to requests carrying Header are kept for
+// TTL if they are at most MaxSize bytes; a duplicate arriving while the
+// first is still running is rejected for up to LockTimeout.
+type IdempotencyConfig struct {
+	Header      string
+	TTL         time.Duration
+	LockTimeout time.Duration
+	MaxSize     int
+}
+
+// DedupConfig controls routes with dedup, which answer repeats of a
+// request arriving within Window with the first response. Duplicates of a
+// request still running wait for it for up to LockTimeout; responses over
+// MaxSize bytes aren't kept.
+type DedupConfig struct {
+	Window      time.Duration
+	LockTimeout time.Duration
+	MaxSize     int
+}
+
+// OIDCConfig lists the external identity providers users can log in with.
+// RedirectBaseURL is the public gateway URL the callbacks are built from.
+type OIDCConfig struct {
+	RedirectBaseURL string
+	Providers       []OIDCProviderConfig
+}
+
+// OIDCProviderConfig configures one provider. Type is google, github or
+// oidc; generic OIDC providers discover their endpoints from Issuer.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name" mapstructure:"name"`
+	Type         string   `yaml:"type" mapstructure:"type"`
+	ClientID     string   `yaml:"client_id" mapstructure:"client_id"`
+	ClientSecret string   `yaml:"client_secret" mapstructure:"client_secret"`
+	Issuer       string   `yaml:"issuer" mapstructure:"issuer"`
+	Scopes       []string `yaml:"scopes" mapstructure:"scopes"`
+
+	// RoleClaim names the IdP claim mapped through RoleMap to a gateway
+	// role; users without a mapped value get DefaultRole
+	DefaultRole string            `yaml:"default_role" mapstructure:"default_role"`
+	RoleClaim   string            `yaml:"role_claim" mapstructure:"role_claim"`
+	RoleMap     map[string]string `yaml:"role_map" mapstructure:"role_map"`
+}
+
+// StorageConfig selects the database that keeps users, registered
+// services and audit events: "mongo" or "postgres". API keys, signing
+// clients, rotated JWT keys, tenants, quota reports, metering and
+// analytics are only kept in Mongo, so they need the mongo backend.
+type StorageConfig struct {
+	Backend string
+}
+
+// StartupConfig controls waiting for the storage backend and Redis at
+// startup. Connecting is retried with exponential backoff, from
+// InitialBackoff up to MaxBackoff, for RetryTimeout; zero tries once. A
+// dependency still down then stops the gateway, unless ServeDegraded
+// starts it anyway and keeps retrying in the background.
+type StartupConfig struct {
+	RetryTimeout   time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	ServeDegraded  bool
+}
+
+// UsesMongo reports whether the gateway connects to MongoDB
+func (c *Config) UsesMongo() bool {
+	return c.Storage.Backend == "mongo"
 }
 
 type MongoDBConfig struct {
 	URI      string
 	Database string
+
+	// MaxPoolSize and MinPoolSize bound the connections kept per server.
+	// These and the options below override the same options in URI; zero
+	// and empty values keep them.
+	MaxPoolSize            int
+	MinPoolSize            int
+	ServerSelectionTimeout time.Duration
+	RetryWrites            bool
+
+	// ReadPreference is primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest. Reads from secondaries may be stale.
+	ReadPreference string
+	// WriteConcern is "majority" or the number of members that must
+	// acknowledge a write
+	WriteConcern string
+
+	// TLS verifies the server against the CA certificates in TLSCAFile, or
+	// the system roots without one
+	TLS       bool
+	TLSCAFile string
+
+	// AutoMigrate applies pending schema migrations at startup; without it
+	// they are applied with `gateway migrate`
+	AutoMigrate bool
+}
+
+type PostgresConfig struct {
+	URL      string
+	MaxConns int32
+
+	// AutoMigrate applies pending schema migrations at startup; without it
+	// they are applied with `gateway migrate`
+	AutoMigrate bool
 }
 
 type RedisConfig struct {
@@ -44,19 +380,147 @@ type RedisConfig struct {
 }
 
 type RateLimitConfig struct {
-	Requests int
-	Window   time.Duration
+	Requests   int
+	Window     time.Duration
+	KeyBy      []string
+	Dimensions []RateLimitDimension
+
+	// Algorithm is the default for dimensions that don't set one:
+	// token_bucket, sliding_log, sliding_window, fixed_window or concurrency
+	Algorithm string
+
+	// FailureMode applies while Redis is unreachable: local (in-process
+	// buckets), open (allow all) or closed (reject all)
+	FailureMode string
+
+	// LeaseFraction lets each gateway take up to that share of a token
+	// bucket's limit from Redis at once and spend it in process for
+	// LeaseTTL. Busy keys then rarely call Redis; a key may admit up to
+	// LeaseFraction of its limit fewer requests per gateway, never more.
+	// Zero calls Redis for every request.
+	LeaseFraction float64
+	LeaseTTL      time.Duration
+}
+
+// RateLimitDimension is an independently enforced limit keyed by one or
+// more of: ip, user, api_key, route
+type RateLimitDimension struct {
+	Name     string        `yaml:"name" mapstructure:"name"`
+	KeyBy    []string      `yaml:"key_by" mapstructure:"key_by"`
+	Requests int           `yaml:"requests" mapstructure:"requests"`
+	Window   time.Duration `yaml:"window" mapstructure:"window"`
+
+	// Algorithm overrides the global default. With concurrency, Requests is
+	// the maximum in flight and Window how long a slot outlives a crashed
+	// gateway.
+	Algorithm string `yaml:"algorithm" mapstructure:"algorithm"`
+}
+
+// EffectiveDimensions returns the enforced dimensions: the configured ones,
+// or a single "default" dimension built from the top-level settings. Each
+// has its algorithm filled in.
+func (r RateLimitConfig) EffectiveDimensions() []RateLimitDimension {
+	dimensions := r.Dimensions
+	if len(dimensions) == 0 {
+		dimensions = []RateLimitDimension{{
+			Name:     "default",
+			KeyBy:    r.KeyBy,
+			Requests: r.Requests,
+			Window:   r.Window,
+		}}
+	}
+
+	effective := make([]RateLimitDimension, len(dimensions))
+	for i, dim := range dimensions {
+		if dim.Algorithm == "" {
+			dim.Algorithm = r.Algorithm
+		}
+		effective[i] = dim
+	}
+	return effective
+}
+
+// QuotaConfig holds long-period usage limits such as 100k requests per
+// month per API key. Counters are flushed to Mongo every FlushInterval.
+type QuotaConfig struct {
+	FlushInterval time.Duration
+	Rules         []QuotaRule
+}
+
+// QuotaRule limits each api_key or user to Limit requests per daily or
+// monthly period. API keys can override the limit per rule.
+type QuotaRule struct {
+	Name   string `yaml:"name" mapstructure:"name"`
+	KeyBy  string `yaml:"key_by" mapstructure:"key_by"`
+	Period string `yaml:"period" mapstructure:"period"`
+	Limit  int64  `yaml:"limit" mapstructure:"limit"`
 }
 
 type CircuitBreakerConfig struct {
 	Threshold int
 	Timeout   time.Duration
+
+	// Shared keeps breakers in step across gateway replicas through Redis:
+	// failures on any replica count towards Threshold, and trips, closes
+	// and admin overrides apply on every replica. Without it each replica's
+	// breakers only see its own requests.
+	Shared bool
+}
+
+type HealthCheckConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// ReadinessConfig lists the dependencies /ready requires: redis, database
+// and services (every active backend service up). A required dependency
+// that is down makes the gateway unready; any other only degrades it.
+// Each check is given Timeout.
+type ReadinessConfig struct {
+	Required []string
+	Timeout  time.Duration
+}
+
+// OutlierDetectionConfig takes single misbehaving instances out of load
+// balancing. Every Interval, instances that served at least MinRequests
+// are ejected if their error share reached ErrorRate or their mean latency
+// is LatencyFactor times their peers' median (0 disables it); reaching
+// ConsecutiveErrors ejects at once. Ejections last BaseEjectionTime times
+// the number of recent ejections, up to MaxEjectionTime, and then the
+// instance gets its share of traffic back over RecoveryWindow. At most
+// MaxEjectionPercent of a service's instances are ejected at a time.
+type OutlierDetectionConfig struct {
+	Enabled            bool
+	Interval           time.Duration
+	MinRequests        int
+	ErrorRate          float64
+	ConsecutiveErrors  int
+	LatencyFactor      float64
+	BaseEjectionTime   time.Duration
+	MaxEjectionTime    time.Duration
+	MaxEjectionPercent int
+	RecoveryWindow     time.Duration
+}
+
+// RetryConfig controls retries of proxied requests. Only requests with a
+// retryable method are retried, on transport errors or retryable statuses.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`
+	StatusCodes    []int         `yaml:"status_codes" mapstructure:"status_codes"`
+	Methods        []string      `yaml:"methods" mapstructure:"methods"`
 }
 
 type TimeoutsConfig struct {
 	Read  int
 	Write int
 	Idle  int
+
+	// Request is the default budget for proxied requests, including
+	// retries; routes may override it. Zero means no budget.
+	Request time.Duration
 }
 
 type CORSConfig struct {
@@ -65,14 +529,817 @@ type CORSConfig struct {
 	AllowedHeaders []string
 }
 
+// LoggingConfig controls the application log. With Async records are
+// written by a background goroutine from a buffer of BufferSize records;
+// Overflow (block, drop or sample) decides what happens to debug and info
+// records while it is full. Sampling thins out repeated records by level.
 type LoggingConfig struct {
-	Level string
+	Level      string
+	Async      bool
+	BufferSize int
+	Overflow   string
+	Sampling   []LogSampling
+}
+
+// LogSampling keeps the first Initial records per second of a level with
+// the same message, then every Thereafter-th, or none if it is zero
+type LogSampling struct {
+	Level      string `yaml:"level" mapstructure:"level"`
+	Initial    int    `yaml:"initial" mapstructure:"initial"`
+	Thereafter int    `yaml:"thereafter" mapstructure:"thereafter"`
+}
+
+// AccessLogConfig controls the access log, written separately from the
+// application log. Outputs is any of: stdout, file, syslog, kafka.
+type AccessLogConfig struct {
+	Enabled    bool
+	Format     string
+	Outputs    []string
+	FilePath   string
+	MaxSizeMB  int
+	MaxBackups int
+	SyslogAddr string
+	SyslogTag  string
+	KafkaURL   string
+	KafkaTopic string
+	SampleRate float64
+	Sampling   []AccessLogSampling
+}
+
+// AccessLogSampling overrides the sample rate for one route pattern
+type AccessLogSampling struct {
+	Path string  `yaml:"path" mapstructure:"path"`
+	Rate float64 `yaml:"rate" mapstructure:"rate"`
+}
+
+// BodyLogConfig controls capture of request and response bodies for
+// debugging. At most MaxSize bytes of each body are logged, for a
+// SampleRate share of requests, after redacting RedactFields and
+// RedactHeaders. Routes may switch capture on or off with log_bodies.
+type BodyLogConfig struct {
+	Enabled       bool
+	MaxSize       int
+	SampleRate    float64
+	RedactFields  []string
+	RedactHeaders []string
+}
+
+// MessagingConfig holds the brokers publish routes send to. Accepted
+// messages wait in a buffer of BufferSize for one of Workers to publish
+// them, each attempt bounded by PublishTimeout and retried after
+// RetryBackoff, doubling.
+type MessagingConfig struct {
+	Brokers        []BrokerConfig
+	BufferSize     int
+	Workers        int
+	PublishTimeout time.Duration
+	RetryBackoff   time.Duration
+}
+
+// BrokerConfig is a message broker. Type is kafka, with URL pointing at a
+// Kafka REST proxy, rabbitmq, with URL pointing at the management API, or
+// nats, with a nats://host:port or tls://host:port URL. VHost and Exchange
+// apply to RabbitMQ and default to "/" and the default exchange. The TLS
+// files apply to NATS: TLSCAFile verifies the server and TLSCertFile and
+// TLSKeyFile present a client certificate.
+type BrokerConfig struct {
+	Name        string `yaml:"name" mapstructure:"name"`
+	Type        string `yaml:"type" mapstructure:"type"`
+	URL         string `yaml:"url" mapstructure:"url"`
+	Username    string `yaml:"username" mapstructure:"username"`
+	Password    string `yaml:"password" mapstructure:"password"`
+	VHost       string `yaml:"vhost" mapstructure:"vhost"`
+	Exchange    string `yaml:"exchange" mapstructure:"exchange"`
+	TLSCAFile   string `yaml:"tls_ca_file" mapstructure:"tls_ca_file"`
+	TLSCertFile string `yaml:"tls_cert_file" mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file" mapstructure:"tls_key_file"`
+}
+
+// Broker returns the broker with the given name
+func (m MessagingConfig) Broker(name string) (BrokerConfig, bool) {
+	for _, broker := range m.Brokers {
+		if broker.Name == name {
+			return broker, true
+		}
+	}
+	return BrokerConfig{}, false
+}
+
+// AuditConfig controls the audit trail of auth and admin operations.
+// Events older than Retention are deleted; zero keeps them forever.
+type AuditConfig struct {
+	Enabled   bool
+	Retention time.Duration
+}
+
+// AnalyticsConfig controls the per-request usage records behind the
+// analytics reports. Records are queued and written in batches of
+// BatchSize at least every FlushInterval; when QueueSize records are
+// waiting new ones are dropped. Sink is mongo, which the admin reports
+// query, or kafka.
+type AnalyticsConfig struct {
+	Enabled       bool
+	Sink          string
+	KafkaURL      string
+	KafkaTopic    string
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	Retention     time.Duration
+}
+
+// MeteringConfig controls hourly billable usage per API key and tenant.
+// Counts are flushed to Mongo every FlushInterval. An hour is closed
+// CloseDelay after it ended, once every instance flushed it, and posted to
+// WebhookURL if set, signed with WebhookSecret.
+type MeteringConfig struct {
+	Enabled       bool
+	FlushInterval time.Duration
+	CloseDelay    time.Duration
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// EventsConfig selects where gateway events, such as a circuit breaker
+// opening, are sent: to Webhooks, to the Redis pub/sub RedisChannel, or
+// both. Webhook deliveries are retried MaxAttempts times, backing off from
+// RetryBackoff. Events about the same subject, e.g. one rate limit bucket,
+// are sent at most once per Throttle.
+type EventsConfig struct {
+	RedisChannel string
+	Webhooks     []EventWebhook
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	QueueSize    int
+	Throttle     time.Duration
+}
+
+// EventWebhook receives the events named in Events, or all when empty.
+// Payloads are signed with Secret.
+type EventWebhook struct {
+	URL    string   `yaml:"url" mapstructure:"url"`
+	Secret string   `yaml:"secret" mapstructure:"secret"`
+	Events []string `yaml:"events" mapstructure:"events"`
+}
+
+// AlertingConfig evaluates Rules against the gateway's own metrics every
+// Interval and notifies Slack and/or PagerDuty when one fires or
+// resolves
+type AlertingConfig struct {
+	Interval            time.Duration
+	SlackWebhookURL     string
+	PagerDutyRoutingKey string
+	PagerDutyURL        string
+	Rules               []AlertRule
+}
+
+// AlertRule fires when Metric of a service stays above Threshold for For.
+// Metric is error_rate (share of upstream calls failing or answering 5xx,
+// 0 to 1), p99_latency (upstream, in milliseconds) or breaker_open
+// (seconds the breaker has not been closed). An empty Service applies the
+// rule to every service. Channels is any of slack and pagerduty; empty
+// notifies every configured channel.
+type AlertRule struct {
+	Name      string        `yaml:"name" mapstructure:"name"`
+	Metric    string        `yaml:"metric" mapstructure:"metric"`
+	Service   string        `yaml:"service" mapstructure:"service"`
+	Threshold float64       `yaml:"threshold" mapstructure:"threshold"`
+	For       time.Duration `yaml:"for" mapstructure:"for"`
+	Severity  string        `yaml:"severity" mapstructure:"severity"`
+	Channels  []string      `yaml:"channels" mapstructure:"channels"`
+}
+
+// AccessControlConfig holds the IP rules applied to every request and the
+// GeoIP database (MaxMind .mmdb) used for country rules
+type AccessControlConfig struct {
+	Global        AccessRules
+	GeoIPDatabase string
+}
+
+// AccessRules restrict who may send requests. Allow and Deny take IP
+// addresses or CIDR ranges, the country lists ISO 3166 codes. Deny wins;
+// once any allow list is set, other clients are rejected.
+type AccessRules struct {
+	Allow          []string `yaml:"allow" mapstructure:"allow"`
+	Deny           []string `yaml:"deny" mapstructure:"deny"`
+	AllowCountries []string `yaml:"allow_countries" mapstructure:"allow_countries"`
+	DenyCountries  []string `yaml:"deny_countries" mapstructure:"deny_countries"`
+}
+
+func (r AccessRules) hasCountries() bool {
+	return len(r.AllowCountries) > 0 || len(r.DenyCountries) > 0
+}
+
+// CompressionConfig controls gzip/brotli compression of responses.
+// ContentTypes lists media types to compress; "type/*" matches a whole
+// family.
+type CompressionConfig struct {
+	Enabled      bool
+	MinSize      int
+	ContentTypes []string
+}
+
+// ErrorsConfig selects the error body format. ProblemJSON switches from
+// the gateway's response envelope to RFC 7807 application/problem+json;
+// Templates replace it for the statuses they match.
+type ErrorsConfig struct {
+	ProblemJSON bool
+	Templates   []ErrorTemplate
+}
+
+// ErrorTemplate is the error body for a status such as 404, or a class
+// such as 5xx. HTML, inline or read from HTMLFile, goes to clients
+// preferring text/html and JSON to the rest. Both may use {{variables}}:
+// status, status_text, code, message, request_id, service, method and
+// path.
+type ErrorTemplate struct {
+	Status   string `yaml:"status" mapstructure:"status"`
+	JSON     string `yaml:"json" mapstructure:"json"`
+	HTML     string `yaml:"html" mapstructure:"html"`
+	HTMLFile string `yaml:"html_file" mapstructure:"html_file"`
+}
+
+// DocsConfig controls the aggregated API documentation. The OpenAPI
+// document is always served; SwaggerUI adds an interactive viewer.
+type DocsConfig struct {
+	Title     string
+	SwaggerUI bool
+}
+
+// SessionConfig enables cookie sessions for browser clients: logins asking
+// for mode "session" get an HttpOnly cookie backed by a Redis session
+// instead of tokens. Unsafe requests authenticated by the cookie must send
+// the session's CSRF token in the CSRF header. SameSite is strict, lax or
+// none.
+type SessionConfig struct {
+	Enabled      bool
+	CookieName   string
+	CookieDomain string
+	CookiePath   string
+	Secure       bool
+	SameSite     string
+	TTL          time.Duration
+	IdleTimeout  time.Duration
+}
+
+// CSRFConfig protects browser-facing routes against cross-site requests.
+// Session requests must echo the session's token in Header; other unsafe
+// requests without a bearer token, API key or signature must echo the
+// value of the CookieName cookie the gateway hands out. Enabled applies
+// the check to the gateway's own API and admin routes; proxy routes opt
+// in with browser_facing.
+type CSRFConfig struct {
+	Enabled    bool
+	CookieName string
+	Header     string
+	Secure     bool
+}
+
+// UsesCSRF reports whether any request may need a CSRF token
+func (c *Config) UsesCSRF() bool {
+	if c.Session.Enabled || c.CSRF.Enabled {
+		return true
+	}
+	for _, route := range c.Routes {
+		if route.BrowserFacing {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailConfig controls email verification and password reset. The links
+// sent to users are VerifyURL or ResetURL with a token query parameter.
+type EmailConfig struct {
+	RequireVerification bool
+	VerificationTTL     time.Duration
+	ResetTTL            time.Duration
+	VerifyURL           string
+	ResetURL            string
+	SMTP                SMTPConfig
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
 }
 
 type ServiceConfig struct {
-	Name      string   `yaml:"name"`
-	URLs      []string `yaml:"urls"`
-	HealthURL string   `yaml:"health_url"`
+	Name      string   `yaml:"name" mapstructure:"name"`
+	URLs      []string `yaml:"urls" mapstructure:"urls"`
+	HealthURL string   `yaml:"health_url" mapstructure:"health_url"`
+	Protocol  string   `yaml:"protocol" mapstructure:"protocol"`
+
+	// OpenAPIURL is the path (or absolute URL) of the service's OpenAPI 3
+	// spec, merged into the gateway's /api/v1/docs/openapi.json
+	OpenAPIURL string `yaml:"openapi_url" mapstructure:"openapi_url"`
+
+	// LoadBalancing is round_robin (default) or consistent_hash. HashKey
+	// selects the affinity key: cookie:<name>, header:<name>, jwt_sub or
+	// client_ip.
+	LoadBalancing string `yaml:"load_balancing" mapstructure:"load_balancing"`
+	HashKey       string `yaml:"hash_key" mapstructure:"hash_key"`
+
+	// Discovery replaces the static URLs with instances from a registry
+	Discovery *ServiceDiscoveryConfig `yaml:"discovery" mapstructure:"discovery"`
+
+	// Upstream overrides the global connection pool settings
+	Upstream *UpstreamConfig `yaml:"upstream" mapstructure:"upstream"`
+
+	// Bulkhead overrides the global concurrency limit
+	Bulkhead *BulkheadConfig `yaml:"bulkhead" mapstructure:"bulkhead"`
+
+	// SlowStart ramps up the traffic of instances added to the running
+	// service over this window instead of sending them a full share at once
+	SlowStart time.Duration `yaml:"slow_start" mapstructure:"slow_start"`
+
+	// Zones labels instance URLs with the zone they run in. ZoneAware
+	// keeps traffic on instances in the gateway's zone and fails over to
+	// the other zones only while none of those is available.
+	Zones     map[string][]string `yaml:"zones" mapstructure:"zones"`
+	ZoneAware bool                `yaml:"zone_aware" mapstructure:"zone_aware"`
+}
+
+// LoadSheddingConfig sets the overload thresholds. Shedding starts once
+// CPU use (a share of all cores), the goroutine count or p99 upstream
+// latency passes its threshold and grows as they climb further.
+type LoadSheddingConfig struct {
+	Enabled          bool
+	CPUThreshold     float64
+	MaxGoroutines    int
+	LatencyThreshold time.Duration
+	RetryAfter       time.Duration
+
+	// MaxInFlight caps the proxied requests served at once. Beyond it up
+	// to MaxQueue requests wait at most QueueTimeout for a slot, highest
+	// priority first. Zero disables the queue.
+	MaxInFlight  int
+	MaxQueue     int
+	QueueTimeout time.Duration
+
+	// RolePriorities gives the users of a role a priority, e.g. paid: high
+	RolePriorities map[string]string `yaml:"role_priorities" mapstructure:"role_priorities"`
+}
+
+// ClientInFlightConfig caps the requests one client may have in flight
+// on each gateway at once, by IP and by authenticated user. Unlike rate
+// limits it counts open requests, so slow or long-streaming clients are
+// held back too. Zero disables a limit.
+type ClientInFlightConfig struct {
+	MaxPerIP   int
+	MaxPerUser int
+}
+
+// ScheduleConfig applies a policy during a recurring window: from each
+// time Cron fires, in Timezone (UTC by default), for Duration. While
+// schedules overlap, the first one listed wins for a given dimension or
+// route.
+type ScheduleConfig struct {
+	Name     string        `yaml:"name" mapstructure:"name"`
+	Cron     string        `yaml:"cron" mapstructure:"cron"`
+	Duration time.Duration `yaml:"duration" mapstructure:"duration"`
+	Timezone string        `yaml:"timezone" mapstructure:"timezone"`
+
+	RateLimits []ScheduledRateLimit `yaml:"rate_limits" mapstructure:"rate_limits"`
+	Services   []ScheduledService   `yaml:"services" mapstructure:"services"`
+
+	// Maintenance answers proxied routes with 503 for the whole window
+	Maintenance bool `yaml:"maintenance" mapstructure:"maintenance"`
+}
+
+// ScheduledRateLimit replaces the requests of a rate limit dimension
+type ScheduledRateLimit struct {
+	Dimension string `yaml:"dimension" mapstructure:"dimension" json:"dimension"`
+	Requests  int    `yaml:"requests" mapstructure:"requests" json:"requests"`
+}
+
+// ScheduledService sends a route, by path, to another service
+type ScheduledService struct {
+	Route   string `yaml:"route" mapstructure:"route" json:"route"`
+	Service string `yaml:"service" mapstructure:"service" json:"service"`
+}
+
+// ExperimentConfig splits the consumers of Routes between Variants by
+// weight. A consumer's variant follows from a hash of their user ID and
+// the experiment name, so it stays the same while the weights do.
+type ExperimentConfig struct {
+	Name     string              `yaml:"name" mapstructure:"name" json:"name"`
+	Routes   []string            `yaml:"routes" mapstructure:"routes" json:"routes"`
+	Variants []ExperimentVariant `yaml:"variants" mapstructure:"variants" json:"variants"`
+}
+
+// ExperimentVariant sends its share of consumers to Service
+type ExperimentVariant struct {
+	Name    string `yaml:"name" mapstructure:"name" json:"name" binding:"required"`
+	Service string `yaml:"service" mapstructure:"service" json:"service" binding:"required"`
+	Weight  int    `yaml:"weight" mapstructure:"weight" json:"weight" binding:"min=0"`
+}
+
+// ExternalAuthzConfig points routes with external_authz at a decision
+// point: a webhook or an OPA server. FailureMode is open or closed and
+// CacheTTL caches decisions for identical requests (zero disables it).
+type ExternalAuthzConfig struct {
+	Provider    string
+	URL         string
+	Timeout     time.Duration
+	FailureMode string
+	CacheTTL    time.Duration
+
+	// Headers are the request headers passed to the decision point
+	Headers []string
+}
+
+// SSEConfig tunes server-sent event pass-through. A HeartbeatInterval of
+// zero disables heartbeats.
+type SSEConfig struct {
+	HeartbeatInterval time.Duration
+}
+
+// BulkheadConfig caps the requests proxied to one service at a time so a
+// slow service can't tie up the whole gateway. Up to MaxQueue requests
+// wait at most QueueTimeout for a slot; the rest are rejected. A
+// MaxConcurrent of zero disables the limit.
+type BulkheadConfig struct {
+	MaxConcurrent int           `yaml:"max_concurrent" mapstructure:"max_concurrent"`
+	MaxQueue      int           `yaml:"max_queue" mapstructure:"max_queue"`
+	QueueTimeout  time.Duration `yaml:"queue_timeout" mapstructure:"queue_timeout"`
+}
+
+// UpstreamConfig tunes the connection pool used to reach a service.
+// Each service gets its own pool.
+type UpstreamConfig struct {
+	MaxIdleConns          int           `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host" mapstructure:"max_conns_per_host"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`
+	DialTimeout           time.Duration `yaml:"dial_timeout" mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout" mapstructure:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" mapstructure:"response_header_timeout"`
+
+	// HTTP2 negotiates HTTP/2 with TLS upstreams via ALPN. Unset inherits
+	// the global setting.
+	HTTP2 *bool `yaml:"http2" mapstructure:"http2"`
+
+	// H2C speaks cleartext HTTP/2 to http:// instances, which must
+	// support it with prior knowledge
+	H2C bool `yaml:"h2c" mapstructure:"h2c"`
+
+	// SPIFFEID makes https:// instances mTLS peers: the gateway presents
+	// its SVID and the instances must present an SVID for this ID, or for
+	// any workload of a bare trust domain such as spiffe://example.org
+	SPIFFEID string `yaml:"spiffe_id" mapstructure:"spiffe_id"`
+}
+
+// SPIFFEConfig fetches the gateway's X.509 SVID from the SPIFFE Workload
+// API at SocketPath, e.g. a SPIRE agent, for upstreams with a spiffe_id.
+// Startup waits up to FetchTimeout for the first SVID.
+type SPIFFEConfig struct {
+	Enabled      bool
+	SocketPath   string
+	FetchTimeout time.Duration
+}
+
+// ServiceDiscoveryConfig selects where a service's instances come from.
+// Name is the service name in Consul (defaults to the gateway service name)
+// and Prefix the etcd key prefix whose values are instance URLs. The dns
+// provider resolves the hostnames of the service's URLs.
+type ServiceDiscoveryConfig struct {
+	Provider string `yaml:"provider" mapstructure:"provider"`
+	Name     string `yaml:"name" mapstructure:"name"`
+	Prefix   string `yaml:"prefix" mapstructure:"prefix"`
+	Scheme   string `yaml:"scheme" mapstructure:"scheme"`
+	Tag      string `yaml:"tag" mapstructure:"tag"`
+
+	// URLs are the service's configured URLs, filled in for the watcher
+	URLs []string `yaml:"-" mapstructure:"-"`
+}
+
+// RouteMatch lists the conditions a request must meet besides the path
+// prefix. Path is a regular expression the whole request path must match.
+// Hosts may start with "*." to match any subdomain. Header and query
+// values must equal the given value, or be present for "*".
+type RouteMatch struct {
+	Path    string            `yaml:"path" mapstructure:"path"`
+	Hosts   []string          `yaml:"hosts" mapstructure:"hosts"`
+	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
+	Query   map[string]string `yaml:"query" mapstructure:"query"`
+}
+
+// PathPattern compiles Path anchored at both ends. It returns nil when the
+// route doesn't match on path.
+func (m *RouteMatch) PathPattern() (*regexp.Regexp, error) {
+	if m == nil || m.Path == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + m.Path + ")$")
+}
+
+// HeaderPolicy rewrites response headers on the way out: Remove drops
+// headers (e.g. Server), Set replaces them and Add appends values.
+type HeaderPolicy struct {
+	Remove []string          `yaml:"remove" mapstructure:"remove"`
+	Set    map[string]string `yaml:"set" mapstructure:"set"`
+	Add    map[string]string `yaml:"add" mapstructure:"add"`
+}
+
+// Empty reports whether the policy changes nothing
+func (p HeaderPolicy) Empty() bool {
+	return len(p.Remove) == 0 && len(p.Set) == 0 && len(p.Add) == 0
+}
+
+// Merge returns the policy with route applied on top: headers removed by
+// either are removed and route values win over p for Set and Add
+func (p HeaderPolicy) Merge(route *HeaderPolicy) HeaderPolicy {
+	if route == nil {
+		return p
+	}
+
+	merged := HeaderPolicy{
+		Remove: append(append([]string(nil), p.Remove...), route.Remove...),
+		Set:    make(map[string]string, len(p.Set)+len(route.Set)),
+		Add:    make(map[string]string, len(p.Add)+len(route.Add)),
+	}
+	for _, src := range []HeaderPolicy{p, *route} {
+		for name, value := range src.Set {
+			merged.Set[name] = value
+		}
+		for name, value := range src.Add {
+			merged.Add[name] = value
+		}
+	}
+	return merged
+}
+
+func defaultSecurityHeaders() map[string]string {
+	return map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"X-XSS-Protection":          "1; mode=block",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'self'",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Permissions-Policy":        "geolocation=(), microphone=(), camera=()",
+	}
+}
+
+// PublishConfig sends the route's JSON request bodies to Topic on Broker
+// (the routing key for RabbitMQ, the subject for NATS) instead of
+// proxying them. Publishing is attempted MaxAttempts times; messages that
+// still fail go to the DeadLetter topic on the same broker, if set.
+type PublishConfig struct {
+	Broker      string `yaml:"broker" mapstructure:"broker"`
+	Topic       string `yaml:"topic" mapstructure:"topic"`
+	DeadLetter  string `yaml:"dead_letter" mapstructure:"dead_letter"`
+	MaxAttempts int    `yaml:"max_attempts" mapstructure:"max_attempts"`
+}
+
+// StaticResponseConfig is a response declared in config, e.g. to stub a
+// service that doesn't exist yet. Headers and Body may use the same
+// {{variables}} as transformations, plus path.<param> for path parameters.
+// Latency delays the response to mimic a real backend.
+type StaticResponseConfig struct {
+	Status  int               `yaml:"status" mapstructure:"status"`
+	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
+	Body    string            `yaml:"body" mapstructure:"body"`
+	Latency time.Duration     `yaml:"latency" mapstructure:"latency"`
+}
+
+// CompositeConfig fans a request out to several backend calls in parallel
+// and merges their JSON responses into one object. Timeout bounds all calls
+// together.
+type CompositeConfig struct {
+	Calls   []CompositeCall `yaml:"calls" mapstructure:"calls"`
+	Timeout time.Duration   `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// CompositeCall is a GET to Path on Service; Path may use the same
+// {{variables}} as static responses. Select picks a dotted field out of the
+// response and Into places it at a dotted field of the merged object, which
+// defaults to Name; "." merges the fields of an object response at the top.
+// A failed call fails the whole request unless it is Optional.
+type CompositeCall struct {
+	Name     string `yaml:"name" mapstructure:"name"`
+	Service  string `yaml:"service" mapstructure:"service"`
+	Path     string `yaml:"path" mapstructure:"path"`
+	Select   string `yaml:"select" mapstructure:"select"`
+	Into     string `yaml:"into" mapstructure:"into"`
+	Optional bool   `yaml:"optional" mapstructure:"optional"`
+}
+
+type DiscoveryConfig struct {
+	ConsulAddr    string
+	ConsulToken   string
+	EtcdEndpoints []string
+
+	// DNSInterval is how often hostnames of dns services are re-resolved
+	DNSInterval time.Duration
+}
+
+type RouteConfig struct {
+	Path         string   `yaml:"path" mapstructure:"path"`
+	Methods      []string `yaml:"methods" mapstructure:"methods"`
+	Service      string   `yaml:"service" mapstructure:"service"`
+	StripPrefix  bool     `yaml:"strip_prefix" mapstructure:"strip_prefix"`
+	AuthRequired bool     `yaml:"auth_required" mapstructure:"auth_required"`
+
+	// Match narrows the route to requests with these hosts, headers or
+	// query parameters, so several routes can share a path
+	Match *RouteMatch `yaml:"match" mapstructure:"match"`
+
+	// Rewrite builds the upstream path from the groups captured by
+	// match.path, e.g. /items/$1 or /items/${id}
+	Rewrite string `yaml:"rewrite" mapstructure:"rewrite"`
+
+	// Retry overrides the global retry policy for this route
+	Retry *RetryConfig `yaml:"retry" mapstructure:"retry"`
+
+	// Permissions must all be granted to the caller and scoped tokens must
+	// carry all Scopes; both require auth_required
+	Permissions []string `yaml:"permissions" mapstructure:"permissions"`
+	Scopes      []string `yaml:"scopes" mapstructure:"scopes"`
+
+	// MaxBodySize overrides the global request body limit in bytes
+	MaxBodySize int64 `yaml:"max_body_size" mapstructure:"max_body_size"`
+
+	// Transforms are ordered rules applied to the request before it is
+	// forwarded and to the response before it is returned
+	Transforms *TransformConfig `yaml:"transforms" mapstructure:"transforms"`
+
+	// Split divides traffic between several services by weight and makes
+	// Service optional
+	Split *TrafficSplitConfig `yaml:"split" mapstructure:"split"`
+
+	// ResponseHeaders rewrites the route's response headers on top of the
+	// global policy
+	ResponseHeaders *HeaderPolicy `yaml:"response_headers" mapstructure:"response_headers"`
+
+	// Static answers requests with a fixed response instead of proxying
+	// them, which makes Service optional
+	Static *StaticResponseConfig `yaml:"static" mapstructure:"static"`
+
+	// Composite answers requests by merging several backend calls instead
+	// of proxying them, which makes Service optional
+	Composite *CompositeConfig `yaml:"composite" mapstructure:"composite"`
+
+	// Idempotency replays the stored response for POST, PUT and PATCH
+	// requests repeating an Idempotency-Key instead of proxying them again
+	Idempotency bool `yaml:"idempotency" mapstructure:"idempotency"`
+
+	// Dedup answers POST, PUT, PATCH and DELETE requests repeating the
+	// method, URL and body of one from the same caller within DedupWindow
+	// (DEDUP_WINDOW if unset) with its response instead of proxying them
+	Dedup       bool          `yaml:"dedup" mapstructure:"dedup"`
+	DedupWindow time.Duration `yaml:"dedup_window" mapstructure:"dedup_window"`
+
+	// Publish hands request bodies to a message broker and answers 202
+	// instead of proxying them, which makes Service optional
+	Publish *PublishConfig `yaml:"publish" mapstructure:"publish"`
+
+	// Mirror copies a share of the route's requests to a shadow service
+	Mirror *MirrorConfig `yaml:"mirror" mapstructure:"mirror"`
+
+	// Decompress decodes gzip, br and deflate upstream responses so the
+	// gateway handles the plain payload
+	Decompress bool `yaml:"decompress" mapstructure:"decompress"`
+
+	// Access adds IP rules on top of the global ones
+	Access *AccessRules `yaml:"access" mapstructure:"access"`
+
+	// Timeout overrides the global request budget for this route
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+
+	// HMACAuth requires requests signed by a registered signing client
+	// instead of a JWT or API key
+	HMACAuth bool `yaml:"hmac_auth" mapstructure:"hmac_auth"`
+
+	// ExternalAuthz asks the external decision point about every request
+	ExternalAuthz bool `yaml:"external_authz" mapstructure:"external_authz"`
+
+	// LogBodies switches body capture on or off for this route; unset
+	// follows the global setting
+	LogBodies *bool `yaml:"log_bodies" mapstructure:"log_bodies"`
+
+	// Priority decides what is shed first under overload: low, normal
+	// (default), high or critical, which is never shed
+	Priority string `yaml:"priority" mapstructure:"priority"`
+
+	// BillingUnits is what one request costs in metering; 0 counts 1
+	BillingUnits int `yaml:"billing_units" mapstructure:"billing_units"`
+
+	// Body restricts the content types of request bodies and validates
+	// JSON bodies against a schema before they are proxied
+	Body *BodyValidationConfig `yaml:"body" mapstructure:"body"`
+
+	// Upload restricts multipart uploads, checked as they stream through
+	Upload *UploadConfig `yaml:"upload" mapstructure:"upload"`
+
+	// ResponseRewrite replaces the service's own URLs in responses with
+	// the gateway's
+	ResponseRewrite *ResponseRewriteConfig `yaml:"response_rewrite" mapstructure:"response_rewrite"`
+
+	// BrowserFacing requires a CSRF token on POST, PUT, PATCH and DELETE
+	// requests not carrying a bearer token, API key or signature
+	BrowserFacing bool `yaml:"browser_facing" mapstructure:"browser_facing"`
+
+	// ClientCert requires a client certificate verified against the CAs
+	// of TLS_CLIENT_CA_FILE, e.g. for partner APIs
+	ClientCert *ClientCertConfig `yaml:"client_cert" mapstructure:"client_cert"`
+}
+
+// ClientCertConfig requires a verified client certificate when Required
+// is set. Subjects lists the accepted subject common names or full
+// distinguished names and SANs the accepted DNS, URI, email or IP subject
+// alternative names; a certificate matching either list is accepted, and
+// with neither list any verified certificate is.
+type ClientCertConfig struct {
+	Required bool     `yaml:"required" mapstructure:"required"`
+	Subjects []string `yaml:"subjects" mapstructure:"subjects"`
+	SANs     []string `yaml:"sans" mapstructure:"sans"`
+}
+
+// ResponseRewriteConfig maps the service's instance URLs, and the base
+// URLs in From, onto PublicURL, adding back a stripped prefix. Location,
+// Content-Location and Link headers are always rewritten; Body also
+// rewrites JSON and HTML bodies, decompressing them if needed. An empty
+// PublicURL is taken from the request's host.
+type ResponseRewriteConfig struct {
+	PublicURL string   `yaml:"public_url" mapstructure:"public_url"`
+	From      []string `yaml:"from" mapstructure:"from"`
+	Body      bool     `yaml:"body" mapstructure:"body"`
+}
+
+// UploadConfig limits multipart uploads. MaxFileSize applies to each file
+// part in bytes, MaxParts to all parts, and AllowedTypes to the media
+// type sniffed from each file's content, e.g. image/* or application/pdf.
+// Zero values don't restrict.
+type UploadConfig struct {
+	MaxFileSize  int64    `yaml:"max_file_size" mapstructure:"max_file_size"`
+	MaxParts     int      `yaml:"max_parts" mapstructure:"max_parts"`
+	AllowedTypes []string `yaml:"allowed_types" mapstructure:"allowed_types"`
+}
+
+// BodyValidationConfig lists the media types a route accepts, e.g.
+// application/json or image/*, and a JSON schema file for JSON bodies. The
+// schema lives in its own file because config keys lose their case. With a
+// schema and no content types only JSON is accepted.
+type BodyValidationConfig struct {
+	ContentTypes []string `yaml:"content_types" mapstructure:"content_types"`
+	SchemaFile   string   `yaml:"schema_file" mapstructure:"schema_file"`
+}
+
+// MirrorConfig sends a copy of sampled requests to Service. Rate is the
+// share of requests mirrored, between 0 and 1. Mirrored requests are fire
+// and forget: responses are discarded and never delay the client.
+type MirrorConfig struct {
+	Service string  `yaml:"service" mapstructure:"service"`
+	Rate    float64 `yaml:"rate" mapstructure:"rate"`
+}
+
+// TrafficSplitConfig weights a route's backends. Header and Cookie name a
+// request header or cookie whose value, if it is one of the backend
+// services, routes the request there regardless of weight. HashKey takes
+// the same values as a service's hash_key and keeps each client on one
+// backend instead of choosing at random per request.
+type TrafficSplitConfig struct {
+	Backends []SplitBackend `yaml:"backends" mapstructure:"backends"`
+	Header   string         `yaml:"header" mapstructure:"header"`
+	Cookie   string         `yaml:"cookie" mapstructure:"cookie"`
+	HashKey  string         `yaml:"hash_key" mapstructure:"hash_key"`
+}
+
+type SplitBackend struct {
+	Service string `yaml:"service" mapstructure:"service" json:"service"`
+	Weight  int    `yaml:"weight" mapstructure:"weight" json:"weight"`
+}
+
+type TransformConfig struct {
+	Request  []string `yaml:"request" mapstructure:"request"`
+	Response []string `yaml:"response" mapstructure:"response"`
+}
+
+// defaultRoutes mirrors the routes that were hard-coded before routing became configurable
+func defaultRoutes() []RouteConfig {
+	routes := make([]RouteConfig, 0, 3)
+	for _, name := range []string{"users", "products", "orders"} {
+		routes = append(routes, RouteConfig{
+			Path:         "/api/v1/" + name,
+			Service:      name,
+			StripPrefix:  true,
+			AuthRequired: true,
+		})
+	}
+	return routes
 }
 
 func LoadConfig() (*Config, error) {
@@ -84,23 +1351,169 @@ func LoadConfig() (*Config, error) {
 	viper.AddConfigPath(".")
 
 	// Read config file (optional)
-	viper.ReadInConfig()
+	envErrors = nil
+	fileErr := viper.ReadInConfig()
 
 	// Environment variables take precedence
 	viper.AutomaticEnv()
 
+	// Secrets stand in for the environment variables they're named after,
+	// so they're fetched before anything else is read
+	secretsMu.Lock()
+	secretValues = nil
+	secretsMu.Unlock()
+	secretsConfig := SecretsConfig{
+		Provider:           getEnv("SECRETS_PROVIDER", ""),
+		RefreshInterval:    getEnvAsDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute),
+		Dir:                getEnv("SECRETS_DIR", "/run/secrets"),
+		VaultAddr:          getEnv("VAULT_ADDR", "http://localhost:8200"),
+		VaultToken:         getEnv("VAULT_TOKEN", ""),
+		VaultPath:          getEnv("VAULT_SECRET_PATH", "secret/data/api-gateway"),
+		AWSRegion:          getEnv("AWS_REGION", ""),
+		AWSSecretID:        getEnv("AWS_SECRET_ID", ""),
+		AWSAccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		AWSSessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+		AWSEndpoint:        getEnv("AWS_SECRETS_ENDPOINT", ""),
+	}
+	secrets, err := loadSecrets(secretsConfig)
+	if err != nil {
+		return nil, err
+	}
+	secretsMu.Lock()
+	secretValues = secrets
+	secretsMu.Unlock()
+
 	config := &Config{
+		Secrets: secretsConfig,
+		secrets: secrets,
+
 		Server: ServerConfig{
-			Port:        getEnvAsInt("PORT", 8080),
-			Environment: getEnv("ENVIRONMENT", "development"),
+			Port:         getEnvAsInt("PORT", 8080),
+			Environment:  getEnv("ENVIRONMENT", "development"),
+			DrainDelay:   getEnvAsDuration("DRAIN_DELAY", 0),
+			DrainTimeout: getEnvAsDuration("DRAIN_TIMEOUT", 30*time.Second),
+			ReusePort:    getEnvAsBool("REUSE_PORT", false),
+			MaxBodySize:  int64(getEnvAsInt("MAX_BODY_SIZE", 10<<20)),
+
+			TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES", nil),
+			RealIPHeader:   getEnv("REAL_IP_HEADER", "X-Forwarded-For"),
+			ProxyProtocol:  getEnvAsBool("PROXY_PROTOCOL", false),
+			Zone:           getEnv("GATEWAY_ZONE", ""),
+			StrictConfig:   getEnvAsBool("STRICT_CONFIG", false),
+
+			HTTP2:                getEnvAsBool("HTTP2_ENABLED", true),
+			H2C:                  getEnvAsBool("H2C_ENABLED", false),
+			MaxConcurrentStreams: uint32(getEnvAsInt("HTTP2_MAX_CONCURRENT_STREAMS", 250)),
+			TLS: TLSConfig{
+				Enabled:       getEnvAsBool("TLS_ENABLED", false),
+				CertFile:      getEnv("TLS_CERT_FILE", ""),
+				KeyFile:       getEnv("TLS_KEY_FILE", ""),
+				ACMEDomains:   getEnvAsSlice("TLS_ACME_DOMAINS", nil),
+				ACMEEmail:     getEnv("TLS_ACME_EMAIL", ""),
+				ACMECacheDir:  getEnv("TLS_ACME_CACHE_DIR", "certs"),
+				ACMEDirectory: getEnv("TLS_ACME_DIRECTORY", ""),
+				HTTPPort:      getEnvAsInt("TLS_HTTP_PORT", 80),
+				RedirectHTTP:  getEnvAsBool("TLS_REDIRECT_HTTP", true),
+				MinVersion:    getEnv("TLS_MIN_VERSION", "1.2"),
+				CipherSuites:  getEnvAsSlice("TLS_CIPHER_SUITES", nil),
+				ClientCAFile:  getEnv("TLS_CLIENT_CA_FILE", ""),
+			},
+		},
+		Admin: AdminConfig{
+			Addr: getEnv("ADMIN_ADDR", ""),
+			TLS: TLSConfig{
+				Enabled:      getEnvAsBool("ADMIN_TLS_ENABLED", false),
+				CertFile:     getEnv("ADMIN_TLS_CERT_FILE", ""),
+				KeyFile:      getEnv("ADMIN_TLS_KEY_FILE", ""),
+				MinVersion:   getEnv("ADMIN_TLS_MIN_VERSION", "1.2"),
+				CipherSuites: getEnvAsSlice("ADMIN_TLS_CIPHER_SUITES", nil),
+			},
+			Auth:  getEnv("ADMIN_AUTH", "jwt"),
+			Token: getEnv("ADMIN_TOKEN", ""),
+			Pprof: getEnvAsBool("ADMIN_PPROF_ENABLED", false),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-			Expiry: parseDuration(getEnv("JWT_EXPIRY", "24h")),
+			Secret:        getEnv("JWT_SECRET", defaultJWTSecret),
+			Expiry:        getEnvAsDuration("JWT_EXPIRY", 24*time.Hour),
+			RefreshExpiry: getEnvAsDuration("JWT_REFRESH_EXPIRY", 720*time.Hour),
+
+			Algorithm:      getEnv("JWT_ALGORITHM", "HS256"),
+			PrivateKey:     getEnv("JWT_PRIVATE_KEY", ""),
+			PrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+
+			KeyRefreshInterval: getEnvAsDuration("JWT_KEY_REFRESH_INTERVAL", 30*time.Second),
+
+			Issuer:   getEnv("JWT_ISSUER", ""),
+			Audience: getEnvAsSlice("JWT_AUDIENCE", nil),
+			Leeway:   getEnvAsDuration("JWT_LEEWAY", 0),
+		},
+		APIKeys: APIKeyConfig{
+			Header: getEnv("API_KEY_HEADER", "X-API-Key"),
+		},
+		Tenancy: TenancyConfig{
+			Enabled:         getEnvAsBool("TENANCY_ENABLED", false),
+			Header:          getEnv("TENANT_HEADER", "X-Tenant-ID"),
+			BaseDomain:      getEnv("TENANT_BASE_DOMAIN", ""),
+			Required:        getEnvAsBool("TENANT_REQUIRED", false),
+			RefreshInterval: getEnvAsDuration("TENANT_REFRESH_INTERVAL", 30*time.Second),
+		},
+		Identity: IdentityConfig{
+			Headers:             getEnvAsBool("IDENTITY_HEADERS_ENABLED", true),
+			InternalToken:       getEnvAsBool("INTERNAL_TOKEN_ENABLED", false),
+			InternalTokenHeader: getEnv("INTERNAL_TOKEN_HEADER", "X-Internal-Token"),
+			InternalTokenExpiry: getEnvAsDuration("INTERNAL_TOKEN_EXPIRY", time.Minute),
+		},
+		HMAC: HMACConfig{
+			ClientHeader:    getEnv("HMAC_CLIENT_HEADER", "X-Client-ID"),
+			SignatureHeader: getEnv("HMAC_SIGNATURE_HEADER", "X-Signature"),
+			TimestampHeader: getEnv("HMAC_TIMESTAMP_HEADER", "X-Timestamp"),
+			NonceHeader:     getEnv("HMAC_NONCE_HEADER", "X-Nonce"),
+			ClockSkew:       getEnvAsDuration("HMAC_CLOCK_SKEW", 5*time.Minute),
+		},
+		Idempotency: IdempotencyConfig{
+			Header:      getEnv("IDEMPOTENCY_HEADER", "Idempotency-Key"),
+			TTL:         getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			LockTimeout: getEnvAsDuration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+			MaxSize:     getEnvAsInt("IDEMPOTENCY_MAX_SIZE", 1<<20),
+		},
+		Dedup: DedupConfig{
+			Window:      getEnvAsDuration("DEDUP_WINDOW", 10*time.Second),
+			LockTimeout: getEnvAsDuration("DEDUP_LOCK_TIMEOUT", 30*time.Second),
+			MaxSize:     getEnvAsInt("DEDUP_MAX_SIZE", 1<<20),
+		},
+		OIDC: OIDCConfig{
+			RedirectBaseURL: getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080"),
+		},
+		Startup: StartupConfig{
+			RetryTimeout:   getEnvAsDuration("STARTUP_RETRY_TIMEOUT", time.Minute),
+			InitialBackoff: getEnvAsDuration("STARTUP_INITIAL_BACKOFF", 500*time.Millisecond),
+			MaxBackoff:     getEnvAsDuration("STARTUP_MAX_BACKOFF", 10*time.Second),
+			ServeDegraded:  getEnvAsBool("STARTUP_SERVE_DEGRADED", false),
+		},
+		Storage: StorageConfig{
+			Backend: getEnv("STORAGE_BACKEND", "mongo"),
 		},
 		MongoDB: MongoDBConfig{
 			URI:      getEnv("MONGO_URI", "mongodb://localhost:27017"),
 			Database: getEnv("MONGO_DATABASE", "api_gateway"),
+
+			MaxPoolSize:            getEnvAsInt("MONGO_MAX_POOL_SIZE", 100),
+			MinPoolSize:            getEnvAsInt("MONGO_MIN_POOL_SIZE", 0),
+			ServerSelectionTimeout: getEnvAsDuration("MONGO_SERVER_SELECTION_TIMEOUT", 30*time.Second),
+			RetryWrites:            getEnvAsBool("MONGO_RETRY_WRITES", true),
+			ReadPreference:         getEnv("MONGO_READ_PREFERENCE", ""),
+			WriteConcern:           getEnv("MONGO_WRITE_CONCERN", ""),
+			TLS:                    getEnvAsBool("MONGO_TLS_ENABLED", false),
+			TLSCAFile:              getEnv("MONGO_TLS_CA_FILE", ""),
+
+			AutoMigrate: getEnvAsBool("MONGO_AUTO_MIGRATE", true),
+		},
+		Postgres: PostgresConfig{
+			URL:      getEnv("POSTGRES_URL", ""),
+			MaxConns: int32(getEnvAsInt("POSTGRES_MAX_CONNS", 10)),
+
+			AutoMigrate: getEnvAsBool("POSTGRES_AUTO_MIGRATE", true),
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
@@ -108,17 +1521,109 @@ func LoadConfig() (*Config, error) {
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
 		RateLimit: RateLimitConfig{
-			Requests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
-			Window:   time.Duration(getEnvAsInt("RATE_LIMIT_WINDOW", 60)) * time.Second,
+			Requests:    getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
+			Window:      time.Duration(getEnvAsInt("RATE_LIMIT_WINDOW", 60)) * time.Second,
+			KeyBy:       getEnvAsSlice("RATE_LIMIT_KEY_BY", []string{"ip"}),
+			Algorithm:   getEnv("RATE_LIMIT_ALGORITHM", "token_bucket"),
+			FailureMode: getEnv("RATE_LIMIT_FAILURE_MODE", "local"),
+
+			LeaseFraction: getEnvAsFloat("RATE_LIMIT_LEASE_FRACTION", 0),
+			LeaseTTL:      getEnvAsDuration("RATE_LIMIT_LEASE_TTL", 200*time.Millisecond),
+		},
+		Quota: QuotaConfig{
+			FlushInterval: getEnvAsDuration("QUOTA_FLUSH_INTERVAL", time.Minute),
 		},
 		CircuitBreaker: CircuitBreakerConfig{
 			Threshold: getEnvAsInt("CIRCUIT_BREAKER_THRESHOLD", 5),
 			Timeout:   time.Duration(getEnvAsInt("CIRCUIT_BREAKER_TIMEOUT", 30)) * time.Second,
+			Shared:    getEnvAsBool("CIRCUIT_BREAKER_SHARED", false),
+		},
+		HealthCheck: HealthCheckConfig{
+			Enabled:  getEnvAsBool("HEALTH_CHECK_ENABLED", true),
+			Interval: time.Duration(getEnvAsInt("HEALTH_CHECK_INTERVAL", 10)) * time.Second,
+			Timeout:  time.Duration(getEnvAsInt("HEALTH_CHECK_TIMEOUT", 2)) * time.Second,
+		},
+		Readiness: ReadinessConfig{
+			Required: getEnvAsSlice("READINESS_REQUIRED", []string{"redis", "database"}),
+			Timeout:  getEnvAsDuration("READINESS_TIMEOUT", 2*time.Second),
+		},
+		Outliers: OutlierDetectionConfig{
+			Enabled:            getEnvAsBool("OUTLIER_DETECTION_ENABLED", false),
+			Interval:           getEnvAsDuration("OUTLIER_INTERVAL", 10*time.Second),
+			MinRequests:        getEnvAsInt("OUTLIER_MIN_REQUESTS", 20),
+			ErrorRate:          getEnvAsFloat("OUTLIER_ERROR_RATE", 0.5),
+			ConsecutiveErrors:  getEnvAsInt("OUTLIER_CONSECUTIVE_ERRORS", 5),
+			LatencyFactor:      getEnvAsFloat("OUTLIER_LATENCY_FACTOR", 3),
+			BaseEjectionTime:   getEnvAsDuration("OUTLIER_BASE_EJECTION_TIME", 30*time.Second),
+			MaxEjectionTime:    getEnvAsDuration("OUTLIER_MAX_EJECTION_TIME", 5*time.Minute),
+			MaxEjectionPercent: getEnvAsInt("OUTLIER_MAX_EJECTION_PERCENT", 50),
+			RecoveryWindow:     getEnvAsDuration("OUTLIER_RECOVERY_WINDOW", time.Minute),
+		},
+		Discovery: DiscoveryConfig{
+			ConsulAddr:    getEnv("CONSUL_ADDR", "http://localhost:8500"),
+			ConsulToken:   getEnv("CONSUL_TOKEN", ""),
+			EtcdEndpoints: getEnvAsSlice("ETCD_ENDPOINTS", []string{"http://localhost:2379"}),
+			DNSInterval:   getEnvAsDuration("DNS_RESOLVE_INTERVAL", 30*time.Second),
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    getEnvAsInt("RETRY_MAX_ATTEMPTS", 3),
+			InitialBackoff: getEnvAsDuration("RETRY_INITIAL_BACKOFF", 100*time.Millisecond),
+			MaxBackoff:     getEnvAsDuration("RETRY_MAX_BACKOFF", 2*time.Second),
+			StatusCodes:    getEnvAsIntSlice("RETRY_STATUS_CODES", []int{502, 503, 504}),
+			Methods:        getEnvAsSlice("RETRY_METHODS", []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}),
+		},
+		Upstream: UpstreamConfig{
+			MaxIdleConns:          getEnvAsInt("UPSTREAM_MAX_IDLE_CONNS", 100),
+			MaxIdleConnsPerHost:   getEnvAsInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 32),
+			MaxConnsPerHost:       getEnvAsInt("UPSTREAM_MAX_CONNS_PER_HOST", 0),
+			IdleConnTimeout:       getEnvAsDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second),
+			DialTimeout:           getEnvAsDuration("UPSTREAM_DIAL_TIMEOUT", 10*time.Second),
+			TLSHandshakeTimeout:   getEnvAsDuration("UPSTREAM_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+			ResponseHeaderTimeout: getEnvAsDuration("UPSTREAM_RESPONSE_HEADER_TIMEOUT", 30*time.Second),
+			HTTP2:                 boolPtr(getEnvAsBool("UPSTREAM_HTTP2", true)),
+			H2C:                   getEnvAsBool("UPSTREAM_H2C", false),
+		},
+		SPIFFE: SPIFFEConfig{
+			Enabled:      getEnvAsBool("SPIFFE_ENABLED", false),
+			SocketPath:   getEnv("SPIFFE_ENDPOINT_SOCKET", "unix:///tmp/spire-agent/public/api.sock"),
+			FetchTimeout: getEnvAsDuration("SPIFFE_FETCH_TIMEOUT", 30*time.Second),
+		},
+		Bulkhead: BulkheadConfig{
+			MaxConcurrent: getEnvAsInt("BULKHEAD_MAX_CONCURRENT", 0),
+			MaxQueue:      getEnvAsInt("BULKHEAD_MAX_QUEUE", 0),
+			QueueTimeout:  getEnvAsDuration("BULKHEAD_QUEUE_TIMEOUT", time.Second),
+		},
+		LoadShedding: LoadSheddingConfig{
+			Enabled:          getEnvAsBool("LOAD_SHEDDING_ENABLED", false),
+			CPUThreshold:     getEnvAsFloat("LOAD_SHEDDING_CPU_THRESHOLD", 0.8),
+			MaxGoroutines:    getEnvAsInt("LOAD_SHEDDING_MAX_GOROUTINES", 10000),
+			LatencyThreshold: getEnvAsDuration("LOAD_SHEDDING_LATENCY_THRESHOLD", 2*time.Second),
+			RetryAfter:       getEnvAsDuration("LOAD_SHEDDING_RETRY_AFTER", 5*time.Second),
+			MaxInFlight:      getEnvAsInt("LOAD_SHEDDING_MAX_IN_FLIGHT", 0),
+			MaxQueue:         getEnvAsInt("LOAD_SHEDDING_MAX_QUEUE", 1000),
+			QueueTimeout:     getEnvAsDuration("LOAD_SHEDDING_QUEUE_TIMEOUT", time.Second),
+		},
+		ClientInFlight: ClientInFlightConfig{
+			MaxPerIP:   getEnvAsInt("CLIENT_MAX_IN_FLIGHT_PER_IP", 0),
+			MaxPerUser: getEnvAsInt("CLIENT_MAX_IN_FLIGHT_PER_USER", 0),
+		},
+		ExternalAuthz: ExternalAuthzConfig{
+			Provider:    getEnv("EXT_AUTHZ_PROVIDER", "webhook"),
+			URL:         getEnv("EXT_AUTHZ_URL", ""),
+			Timeout:     getEnvAsDuration("EXT_AUTHZ_TIMEOUT", 2*time.Second),
+			FailureMode: getEnv("EXT_AUTHZ_FAILURE_MODE", "closed"),
+			CacheTTL:    getEnvAsDuration("EXT_AUTHZ_CACHE_TTL", 0),
+			Headers:     getEnvAsSlice("EXT_AUTHZ_HEADERS", nil),
+		},
+		SSE: SSEConfig{
+			HeartbeatInterval: getEnvAsDuration("SSE_HEARTBEAT_INTERVAL", 15*time.Second),
 		},
 		Timeouts: TimeoutsConfig{
 			Read:  getEnvAsInt("READ_TIMEOUT", 15),
 			Write: getEnvAsInt("WRITE_TIMEOUT", 15),
 			Idle:  getEnvAsInt("IDLE_TIMEOUT", 60),
+
+			Request: getEnvAsDuration("REQUEST_TIMEOUT", 0),
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: []string{getEnv("CORS_ALLOWED_ORIGINS", "*")},
@@ -126,37 +1631,400 @@ func LoadConfig() (*Config, error) {
 			AllowedHeaders: []string{"Content-Type", "Authorization"},
 		},
 		Logging: LoggingConfig{
-			Level: getEnv("LOG_LEVEL", "info"),
+			Level:      getEnv("LOG_LEVEL", "info"),
+			Async:      getEnvAsBool("LOG_ASYNC", false),
+			BufferSize: getEnvAsInt("LOG_BUFFER_SIZE", 8192),
+			Overflow:   getEnv("LOG_OVERFLOW", "drop"),
+		},
+		AccessLog: AccessLogConfig{
+			Enabled:    getEnvAsBool("ACCESS_LOG_ENABLED", false),
+			Format:     getEnv("ACCESS_LOG_FORMAT", "json"),
+			Outputs:    getEnvAsSlice("ACCESS_LOG_OUTPUTS", []string{"stdout"}),
+			FilePath:   getEnv("ACCESS_LOG_FILE", "access.log"),
+			MaxSizeMB:  getEnvAsInt("ACCESS_LOG_MAX_SIZE_MB", 100),
+			MaxBackups: getEnvAsInt("ACCESS_LOG_MAX_BACKUPS", 5),
+			SyslogAddr: getEnv("ACCESS_LOG_SYSLOG_ADDR", ""),
+			SyslogTag:  getEnv("ACCESS_LOG_SYSLOG_TAG", "api-gateway"),
+			KafkaURL:   getEnv("ACCESS_LOG_KAFKA_URL", ""),
+			KafkaTopic: getEnv("ACCESS_LOG_KAFKA_TOPIC", "access-logs"),
+			SampleRate: getEnvAsFloat("ACCESS_LOG_SAMPLE_RATE", 1.0),
+		},
+		BodyLog: BodyLogConfig{
+			Enabled:    getEnvAsBool("BODY_LOG_ENABLED", false),
+			MaxSize:    getEnvAsInt("BODY_LOG_MAX_SIZE", 4096),
+			SampleRate: getEnvAsFloat("BODY_LOG_SAMPLE_RATE", 1.0),
+			RedactFields: getEnvAsSlice("BODY_LOG_REDACT_FIELDS", []string{
+				"password", "new_password", "secret", "token", "access_token", "refresh_token",
+				"card_number", "cvv", "cvc",
+			}),
+			RedactHeaders: getEnvAsSlice("BODY_LOG_REDACT_HEADERS", []string{
+				"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-API-Key", "X-Signature",
+			}),
+		},
+		Messaging: MessagingConfig{
+			BufferSize:     getEnvAsInt("MESSAGING_BUFFER_SIZE", 10000),
+			Workers:        getEnvAsInt("MESSAGING_WORKERS", 4),
+			PublishTimeout: getEnvAsDuration("MESSAGING_PUBLISH_TIMEOUT", 5*time.Second),
+			RetryBackoff:   getEnvAsDuration("MESSAGING_RETRY_BACKOFF", 200*time.Millisecond),
+		},
+		Session: SessionConfig{
+			Enabled:      getEnvAsBool("SESSION_ENABLED", false),
+			CookieName:   getEnv("SESSION_COOKIE_NAME", "gateway_session"),
+			CookieDomain: getEnv("SESSION_COOKIE_DOMAIN", ""),
+			CookiePath:   getEnv("SESSION_COOKIE_PATH", "/"),
+			Secure:       getEnvAsBool("SESSION_COOKIE_SECURE", true),
+			SameSite:     getEnv("SESSION_COOKIE_SAMESITE", "lax"),
+			TTL:          getEnvAsDuration("SESSION_TTL", 24*time.Hour),
+			IdleTimeout:  getEnvAsDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute),
+		},
+		CSRF: CSRFConfig{
+			Enabled:    getEnvAsBool("CSRF_ENABLED", false),
+			CookieName: getEnv("CSRF_COOKIE_NAME", "csrf_token"),
+			Header:     getEnv("CSRF_HEADER", "X-CSRF-Token"),
+			Secure:     getEnvAsBool("CSRF_COOKIE_SECURE", true),
+		},
+		Email: EmailConfig{
+			RequireVerification: getEnvAsBool("EMAIL_REQUIRE_VERIFICATION", false),
+			VerificationTTL:     getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			ResetTTL:            getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+			VerifyURL:           getEnv("EMAIL_VERIFY_URL", "http://localhost:8080/verify-email"),
+			ResetURL:            getEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+			SMTP: SMTPConfig{
+				Host:     getEnv("SMTP_HOST", ""),
+				Port:     getEnvAsInt("SMTP_PORT", 587),
+				Username: getEnv("SMTP_USERNAME", ""),
+				Password: getEnv("SMTP_PASSWORD", ""),
+				From:     getEnv("SMTP_FROM", "no-reply@localhost"),
+			},
+		},
+		Audit: AuditConfig{
+			Enabled:   getEnvAsBool("AUDIT_ENABLED", true),
+			Retention: getEnvAsDuration("AUDIT_RETENTION", 90*24*time.Hour),
+		},
+		Analytics: AnalyticsConfig{
+			Enabled:       getEnvAsBool("ANALYTICS_ENABLED", false),
+			Sink:          getEnv("ANALYTICS_SINK", "mongo"),
+			KafkaURL:      getEnv("ANALYTICS_KAFKA_URL", ""),
+			KafkaTopic:    getEnv("ANALYTICS_KAFKA_TOPIC", "gateway-analytics"),
+			BatchSize:     getEnvAsInt("ANALYTICS_BATCH_SIZE", 500),
+			FlushInterval: getEnvAsDuration("ANALYTICS_FLUSH_INTERVAL", 5*time.Second),
+			QueueSize:     getEnvAsInt("ANALYTICS_QUEUE_SIZE", 10000),
+			Retention:     getEnvAsDuration("ANALYTICS_RETENTION", 90*24*time.Hour),
 		},
+		Metering: MeteringConfig{
+			Enabled:       getEnvAsBool("METERING_ENABLED", false),
+			FlushInterval: getEnvAsDuration("METERING_FLUSH_INTERVAL", 30*time.Second),
+			CloseDelay:    getEnvAsDuration("METERING_CLOSE_DELAY", 5*time.Minute),
+			WebhookURL:    getEnv("METERING_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("METERING_WEBHOOK_SECRET", ""),
+		},
+		Events: EventsConfig{
+			RedisChannel: getEnv("EVENTS_REDIS_CHANNEL", ""),
+			MaxAttempts:  getEnvAsInt("EVENTS_MAX_ATTEMPTS", 5),
+			RetryBackoff: getEnvAsDuration("EVENTS_RETRY_BACKOFF", time.Second),
+			QueueSize:    getEnvAsInt("EVENTS_QUEUE_SIZE", 1000),
+			Throttle:     getEnvAsDuration("EVENTS_THROTTLE", time.Minute),
+		},
+		Alerting: AlertingConfig{
+			Interval:            getEnvAsDuration("ALERTING_INTERVAL", 30*time.Second),
+			SlackWebhookURL:     getEnv("ALERTING_SLACK_WEBHOOK_URL", ""),
+			PagerDutyRoutingKey: getEnv("ALERTING_PAGERDUTY_ROUTING_KEY", ""),
+			PagerDutyURL:        getEnv("ALERTING_PAGERDUTY_URL", "https://events.pagerduty.com/v2/enqueue"),
+		},
+		AccessControl: AccessControlConfig{
+			Global: AccessRules{
+				Allow:          getEnvAsSlice("IP_ALLOWLIST", nil),
+				Deny:           getEnvAsSlice("IP_DENYLIST", nil),
+				AllowCountries: getEnvAsSlice("GEOIP_ALLOW_COUNTRIES", nil),
+				DenyCountries:  getEnvAsSlice("GEOIP_DENY_COUNTRIES", nil),
+			},
+			GeoIPDatabase: getEnv("GEOIP_DATABASE", ""),
+		},
+		Compression: CompressionConfig{
+			Enabled: getEnvAsBool("COMPRESSION_ENABLED", false),
+			MinSize: getEnvAsInt("COMPRESSION_MIN_SIZE", 1024),
+			ContentTypes: getEnvAsSlice("COMPRESSION_CONTENT_TYPES", []string{
+				"text/*", "application/json", "application/problem+json", "application/javascript", "application/xml", "image/svg+xml",
+			}),
+		},
+		Errors: ErrorsConfig{
+			ProblemJSON: getEnvAsBool("ERRORS_PROBLEM_JSON", false),
+		},
+		Docs: DocsConfig{
+			Title:     getEnv("DOCS_TITLE", "API Gateway"),
+			SwaggerUI: getEnvAsBool("DOCS_SWAGGER_UI", false),
+		},
+		Tracing: TracingConfig{
+			Enabled:     getEnvAsBool("TRACING_ENABLED", false),
+			Endpoint:    getEnv("TRACING_ENDPOINT", "localhost:4318"),
+			Insecure:    getEnvAsBool("TRACING_INSECURE", true),
+			ServiceName: getEnv("TRACING_SERVICE_NAME", "api-gateway"),
+			SampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+	}
+
+	// List and map sections come from the config file only. A section that
+	// doesn't decode fails the load rather than being silently dropped.
+	var decodeErrs []error
+	unmarshal := func(key string, out interface{}) {
+		if err := viper.UnmarshalKey(key, out); err != nil {
+			decodeErrs = append(decodeErrs, fmt.Errorf("config file: %s: %w", key, err))
+		}
 	}
 
 	// Load services from config file if available
-	if err := viper.UnmarshalKey("services", &config.Services); err == nil {
+	unmarshal("services", &config.Services)
+	if len(config.Services) > 0 {
 		fmt.Println("Loaded services from config file")
 	}
 
+	// Load external token issuers from config file
+	unmarshal("jwt.trusted_issuers", &config.JWT.TrustedIssuers)
+	unmarshal("jwt.claim_rules", &config.JWT.ClaimRules)
+
+	// Load identity providers from config file
+	unmarshal("oidc.providers", &config.OIDC.Providers)
+
+	// Load additional rate limit dimensions from config file
+	unmarshal("rate_limit.dimensions", &config.RateLimit.Dimensions)
+
+	// Load quota rules from config file
+	unmarshal("quota.rules", &config.Quota.Rules)
+
+	// Load event webhooks from config file; EVENTS_WEBHOOK_URL adds one
+	// receiving every event
+	unmarshal("events.webhooks", &config.Events.Webhooks)
+	if url := getEnv("EVENTS_WEBHOOK_URL", ""); url != "" {
+		config.Events.Webhooks = append(config.Events.Webhooks, EventWebhook{
+			URL:    url,
+			Secret: getEnv("EVENTS_WEBHOOK_SECRET", ""),
+		})
+	}
+
+	// Load alerting rules from config file
+	unmarshal("alerting.rules", &config.Alerting.Rules)
+
+	// Load per-route access log sampling from config file
+	unmarshal("access_log.sampling", &config.AccessLog.Sampling)
+	unmarshal("logging.sampling", &config.Logging.Sampling)
+
+	// Load header overrides from config file. Keys come back lowercased, so
+	// they are canonicalized before overriding the defaults.
+	config.SecurityHeaders = defaultSecurityHeaders()
+	var securityHeaders map[string]string
+	unmarshal("security_headers", &securityHeaders)
+	for name, value := range securityHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if value == "" {
+			delete(config.SecurityHeaders, name)
+			continue
+		}
+		config.SecurityHeaders[name] = value
+	}
+	unmarshal("response_headers", &config.ResponseHeaders)
+	unmarshal("brokers", &config.Messaging.Brokers)
+	unmarshal("load_shedding.role_priorities", &config.LoadShedding.RolePriorities)
+	unmarshal("schedules", &config.Schedules)
+	unmarshal("experiments", &config.Experiments)
+	unmarshal("errors.templates", &config.Errors.Templates)
+
+	// Load routes from config file, falling back to the built-in defaults
+	unmarshal("routes", &config.Routes)
+	if len(config.Routes) == 0 {
+		config.Routes = defaultRoutes()
+	}
+
+	if err := errors.Join(decodeErrs...); err != nil {
+		return nil, err
+	}
+
+	// Routes inherit unset retry settings from the global policy
+	for i := range config.Services {
+		config.Services[i].Upstream = mergeUpstream(config.Upstream, config.Services[i].Upstream)
+		config.Services[i].Bulkhead = mergeBulkhead(config.Bulkhead, config.Services[i].Bulkhead)
+	}
+	for i := range config.Routes {
+		config.Routes[i].Retry = mergeRetry(config.Retry, config.Routes[i].Retry)
+	}
+
+	config.problems = append(lintConfigFile(fileErr), envErrors...)
 	return config, nil
 }
 
+func mergeUpstream(global UpstreamConfig, svc *UpstreamConfig) *UpstreamConfig {
+	merged := global
+	if svc == nil {
+		return &merged
+	}
+
+	if svc.MaxIdleConns > 0 {
+		merged.MaxIdleConns = svc.MaxIdleConns
+	}
+	if svc.MaxIdleConnsPerHost > 0 {
+		merged.MaxIdleConnsPerHost = svc.MaxIdleConnsPerHost
+	}
+	if svc.MaxConnsPerHost > 0 {
+		merged.MaxConnsPerHost = svc.MaxConnsPerHost
+	}
+	if svc.IdleConnTimeout > 0 {
+		merged.IdleConnTimeout = svc.IdleConnTimeout
+	}
+	if svc.DialTimeout > 0 {
+		merged.DialTimeout = svc.DialTimeout
+	}
+	if svc.TLSHandshakeTimeout > 0 {
+		merged.TLSHandshakeTimeout = svc.TLSHandshakeTimeout
+	}
+	if svc.ResponseHeaderTimeout > 0 {
+		merged.ResponseHeaderTimeout = svc.ResponseHeaderTimeout
+	}
+	if svc.HTTP2 != nil {
+		merged.HTTP2 = svc.HTTP2
+	}
+	if svc.H2C {
+		merged.H2C = true
+	}
+	if svc.SPIFFEID != "" {
+		merged.SPIFFEID = svc.SPIFFEID
+	}
+	return &merged
+}
+
+func mergeBulkhead(global BulkheadConfig, svc *BulkheadConfig) *BulkheadConfig {
+	merged := global
+	if svc == nil {
+		return &merged
+	}
+
+	if svc.MaxConcurrent > 0 {
+		merged.MaxConcurrent = svc.MaxConcurrent
+	}
+	if svc.MaxQueue > 0 {
+		merged.MaxQueue = svc.MaxQueue
+	}
+	if svc.QueueTimeout > 0 {
+		merged.QueueTimeout = svc.QueueTimeout
+	}
+	return &merged
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func mergeRetry(global RetryConfig, route *RetryConfig) *RetryConfig {
+	merged := global
+	if route == nil {
+		return &merged
+	}
+
+	if route.MaxAttempts > 0 {
+		merged.MaxAttempts = route.MaxAttempts
+	}
+	if route.InitialBackoff > 0 {
+		merged.InitialBackoff = route.InitialBackoff
+	}
+	if route.MaxBackoff > 0 {
+		merged.MaxBackoff = route.MaxBackoff
+	}
+	if len(route.StatusCodes) > 0 {
+		merged.StatusCodes = route.StatusCodes
+	}
+	if len(route.Methods) > 0 {
+		merged.Methods = route.Methods
+	}
+	return &merged
+}
+
+// lookupEnv returns the value of an environment variable, or of the
+// secret standing in for it
+func lookupEnv(key string) string {
+	if value, ok := lookupSecret(key); ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookupEnv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
 func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
+	valueStr := lookupEnv(key)
 	if value, err := strconv.Atoi(valueStr); err == nil {
 		return value
 	}
+	if valueStr != "" {
+		invalidEnv(key, valueStr, "integer")
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := lookupEnv(key)
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	if valueStr != "" {
+		invalidEnv(key, valueStr, "boolean")
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	valueStr := lookupEnv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	values := make([]string, 0)
+	for _, v := range strings.Split(valueStr, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := lookupEnv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	if valueStr != "" {
+		invalidEnv(key, valueStr, "number")
+	}
 	return defaultValue
 }
 
-func parseDuration(s string) time.Duration {
-	d, err := time.ParseDuration(s)
+func getEnvAsIntSlice(key string, defaultValue []int) []int {
+	values := make([]int, 0)
+	for _, v := range getEnvAsSlice(key, nil) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalidEnv(key, lookupEnv(key), "list of integers")
+			return defaultValue
+		}
+		values = append(values, n)
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := lookupEnv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
 	if err != nil {
-		return 24 * time.Hour
+		invalidEnv(key, valueStr, "duration")
+		return defaultValue
 	}
-	return d
+	return value
 }
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+const masked = "********"
+
+// secretFields are the suffixes of field names holding credentials
+var secretFields = []string{"Password", "Secret", "Token", "PrivateKey", "SecretAccessKey", "RoutingKey", "SlackWebhookURL"}
+
+// secretHeaders are header names whose configured values are masked
+var secretHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// FileUsed returns the config file LoadConfig read, if any
+func FileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
+// Dump returns the config as the gateway resolved it, keyed like the
+// config file, with credentials masked: passwords, secrets, tokens and
+// private keys, the passwords in URLs and credential header values.
+func (c *Config) Dump() map[string]interface{} {
+	return dumpValue(reflect.ValueOf(*c), "").(map[string]interface{})
+}
+
+func dumpValue(v reflect.Value, field string) interface{} {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return dumpValue(v.Elem(), field)
+	case reflect.Struct:
+		out := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			out[dumpKey(f)] = dumpValue(v.Field(i), f.Name)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []interface{}{}
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = dumpValue(v.Index(i), field)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			value := dumpValue(iter.Value(), field)
+			if s, ok := value.(string); ok && s != "" && secretHeaders[strings.ToLower(key)] {
+				value = masked
+			}
+			out[key] = value
+		}
+		return out
+	case reflect.String:
+		return maskString(v.String(), field)
+	default:
+		return v.Interface()
+	}
+}
+
+func maskString(value, field string) string {
+	if value == "" {
+		return value
+	}
+	for _, suffix := range secretFields {
+		if strings.HasSuffix(field, suffix) {
+			return masked
+		}
+	}
+	if strings.Contains(value, "@") {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "xxxxx")
+				return strings.Replace(u.String(), "xxxxx", masked, 1)
+			}
+		}
+	}
+	return value
+}
+
+// dumpKey is the field's mapstructure key or its snake_case name
+func dumpKey(f reflect.StructField) string {
+	if tag, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ","); tag != "" && tag != "-" {
+		return tag
+	}
+
+	runes := []rune(f.Name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
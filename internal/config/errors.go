@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"api-gateway/pkg/utils"
+)
+
+// ErrorTemplates returns the templates by status, reading HTML files
+func (e ErrorsConfig) ErrorTemplates() (map[string]utils.ErrorTemplate, error) {
+	templates := make(map[string]utils.ErrorTemplate, len(e.Templates))
+	for _, t := range e.Templates {
+		html := t.HTML
+		if t.HTMLFile != "" {
+			data, err := os.ReadFile(t.HTMLFile)
+			if err != nil {
+				return nil, err
+			}
+			html = string(data)
+		}
+		templates[strings.ToLower(t.Status)] = utils.ErrorTemplate{JSON: t.JSON, HTML: html}
+	}
+	return templates, nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"api-gateway/pkg/utils"
+)
+
+// TokenPolicy returns the registered claims the gateway's tokens are
+// issued with and checked against
+func (j JWTConfig) TokenPolicy() utils.TokenPolicy {
+	return utils.TokenPolicy{Issuer: j.Issuer, Audience: j.Audience, Leeway: j.Leeway}
+}
+
+// SigningKey returns the configured key tokens are signed with. Its ID is
+// derived from the key, so instances sharing it name it alike.
+func (j JWTConfig) SigningKey() (utils.SigningKey, error) {
+	material := j.Secret
+	if j.Algorithm != "" && j.Algorithm != utils.AlgHS256 {
+		material = j.PrivateKey
+		if material == "" {
+			if j.PrivateKeyFile == "" {
+				return utils.SigningKey{}, errors.New("jwt.private_key or jwt.private_key_file must be set")
+			}
+			data, err := os.ReadFile(j.PrivateKeyFile)
+			if err != nil {
+				return utils.SigningKey{}, fmt.Errorf("jwt.private_key_file: %w", err)
+			}
+			material = string(data)
+		}
+	}
+
+	key, err := utils.NewSigningKey("", j.Algorithm, material)
+	if err != nil {
+		return utils.SigningKey{}, fmt.Errorf("jwt signing key: %w", err)
+	}
+	key.ID = "cfg-" + utils.KeyThumbprint(key)
+	return key, nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+const defaultJWTSecret = "your-secret-key-change-in-production"
+
+// envErrors collects the environment values LoadConfig couldn't parse and
+// replaced with their defaults
+var envErrors []error
+
+func invalidEnv(key, value, kind string) {
+	envErrors = append(envErrors, fmt.Errorf("%s: invalid %s %q, using the default", key, kind, value))
+}
+
+// Lint reports what LoadConfig ignored rather than rejected: a config file
+// that failed to parse, keys the gateway doesn't know, sections that
+// failed to decode and environment values that fell back to their
+// defaults. None of these stop the gateway, but each means a setting
+// isn't applied.
+func (c *Config) Lint() error {
+	return errors.Join(c.problems...)
+}
+
+// fileSections are the config file keys LoadConfig decodes, each with a
+// value of the type it decodes into
+func fileSections() map[string]interface{} {
+	return map[string]interface{}{
+		"services":                      &[]ServiceConfig{},
+		"routes":                        &[]RouteConfig{},
+		"jwt.trusted_issuers":           &[]TrustedIssuerConfig{},
+		"jwt.claim_rules":               &[]ClaimRule{},
+		"oidc.providers":                &[]OIDCProviderConfig{},
+		"rate_limit.dimensions":         &[]RateLimitDimension{},
+		"quota.rules":                   &[]QuotaRule{},
+		"events.webhooks":               &[]EventWebhook{},
+		"alerting.rules":                &[]AlertRule{},
+		"access_log.sampling":           &[]AccessLogSampling{},
+		"logging.sampling":              &[]LogSampling{},
+		"security_headers":              &map[string]string{},
+		"response_headers":              &HeaderPolicy{},
+		"brokers":                       &[]BrokerConfig{},
+		"load_shedding.role_priorities": &map[string]string{},
+		"schedules":                     &[]ScheduleConfig{},
+		"experiments":                   &[]ExperimentConfig{},
+		"errors.templates":              &[]ErrorTemplate{},
+	}
+}
+
+// lintConfigFile checks the config file viper has read; fileErr is the
+// error it returned
+func lintConfigFile(fileErr error) []error {
+	if fileErr != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(fileErr, &notFound) {
+			return nil
+		}
+		return []error{fmt.Errorf("config file: %w", fileErr)}
+	}
+
+	var errs []error
+	allKeys := viper.AllKeys()
+	sort.Strings(allKeys)
+	for _, key := range allKeys {
+		if !knownKey(key) {
+			errs = append(errs, fmt.Errorf("config file: unknown key %q", key))
+		}
+	}
+
+	sections := fileSections()
+	keys := make([]string, 0, len(sections))
+	for key := range sections {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !viper.IsSet(key) {
+			continue
+		}
+		err := viper.UnmarshalKey(key, sections[key], func(dc *mapstructure.DecoderConfig) {
+			dc.ErrorUnused = true
+		})
+		var decodeErr *mapstructure.Error
+		switch {
+		case errors.As(err, &decodeErr):
+			for _, msg := range decodeErr.Errors {
+				errs = append(errs, fmt.Errorf("config file: %s: %s", key, msg))
+			}
+		case err != nil:
+			errs = append(errs, fmt.Errorf("config file: %s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// knownKey reports whether a dotted config file key names a field of
+// Config. Field names match their snake_case keys; lists and maps accept
+// anything below them, the decoding of sections checks those.
+func knownKey(key string) bool {
+	segments := strings.Split(key, ".")
+	if segments[0] == "brokers" {
+		return true
+	}
+
+	t := reflect.TypeOf(Config{})
+	for _, segment := range segments {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Interface:
+			return true
+		case reflect.Struct:
+			field, ok := fieldForKey(t, segment)
+			if !ok {
+				return false
+			}
+			t = field.Type
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func fieldForKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	normalized := strings.ReplaceAll(key, "_", "")
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ","); tag != "" {
+			if tag == key {
+				return field, true
+			}
+			continue
+		}
+		if strings.ToLower(field.Name) == normalized {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
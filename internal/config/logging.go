@@ -0,0 +1,20 @@
+package config
+
+import "api-gateway/pkg/logger"
+
+// LoggerOptions returns the options the application logger is built with
+func (l LoggingConfig) LoggerOptions() logger.Options {
+	opts := logger.Options{
+		Level:      l.Level,
+		Async:      l.Async,
+		BufferSize: l.BufferSize,
+		Overflow:   l.Overflow,
+	}
+	if len(l.Sampling) > 0 {
+		opts.Sampling = make(map[string]logger.Sampling, len(l.Sampling))
+		for _, s := range l.Sampling {
+			opts.Sampling[s.Level] = logger.Sampling{Initial: s.Initial, Thereafter: s.Thereafter}
+		}
+	}
+	return opts
+}
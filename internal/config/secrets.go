@@ -0,0 +1,340 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretsConfig selects where secrets such as JWT_SECRET, MONGO_URI or
+// REDIS_PASSWORD come from. A value the provider returns takes the place
+// of the environment variable of the same name.
+type SecretsConfig struct {
+	// Provider is file, vault or aws; empty reads the environment only
+	Provider string
+
+	// RefreshInterval is how often secrets are fetched again; zero
+	// fetches them only when the config is loaded
+	RefreshInterval time.Duration
+
+	// Dir holds one file per secret, named after it (Docker and
+	// Kubernetes mounted secrets)
+	Dir string
+
+	// VaultPath is the path of a KV secret, e.g. secret/data/api-gateway
+	// for a KV v2 mount
+	VaultAddr  string
+	VaultToken string
+	VaultPath  string
+
+	// AWSSecretID names a Secrets Manager secret holding a JSON object
+	AWSRegion          string
+	AWSSecretID        string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	AWSEndpoint        string
+}
+
+// SecretsProvider fetches secrets, keyed by the environment variable they
+// replace
+type SecretsProvider interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// NewSecretsProvider returns the provider cfg selects, or nil when secrets
+// only come from the environment
+func NewSecretsProvider(cfg SecretsConfig) (SecretsProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "file":
+		return &fileSecrets{dir: cfg.Dir}, nil
+	case "vault":
+		return &vaultSecrets{
+			addr:   strings.TrimSuffix(cfg.VaultAddr, "/"),
+			token:  cfg.VaultToken,
+			path:   strings.Trim(cfg.VaultPath, "/"),
+			client: client,
+		}, nil
+	case "aws":
+		endpoint := cfg.AWSEndpoint
+		if endpoint == "" {
+			endpoint = "https://secretsmanager." + cfg.AWSRegion + ".amazonaws.com"
+		}
+		return &awsSecrets{config: cfg, endpoint: strings.TrimSuffix(endpoint, "/"), client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", cfg.Provider)
+	}
+}
+
+// secretName turns a file or key name such as jwt_secret or jwt-secret
+// into the environment variable it replaces
+func secretName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// secretValues are the secrets fetched by the last LoadConfig, consulted
+// by getEnv before the environment
+var (
+	secretsMu    sync.RWMutex
+	secretValues map[string]string
+)
+
+func lookupSecret(key string) (string, bool) {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	value, ok := secretValues[key]
+	return value, ok
+}
+
+// loadSecrets fetches the secrets for a LoadConfig
+func loadSecrets(cfg SecretsConfig) (map[string]string, error) {
+	provider, err := NewSecretsProvider(cfg)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	secrets, err := provider.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch secrets from %s: %w", cfg.Provider, err)
+	}
+	return secrets, nil
+}
+
+// WatchSecrets fetches the secrets again every RefreshInterval and calls
+// changed with those whose value differs from what the config was loaded
+// with. Settings read once at startup, such as connection strings, only
+// take effect on restart; changed decides what can be applied live.
+func (c *Config) WatchSecrets(ctx context.Context, changed func(map[string]string), onError func(error)) {
+	provider, err := NewSecretsProvider(c.Secrets)
+	if err != nil || provider == nil || c.Secrets.RefreshInterval <= 0 {
+		return
+	}
+
+	last := c.secrets
+	go func() {
+		ticker := time.NewTicker(c.Secrets.RefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			secrets, err := provider.Fetch(fetchCtx)
+			cancel()
+			if err != nil {
+				onError(err)
+				continue
+			}
+
+			diff := make(map[string]string)
+			for name, value := range secrets {
+				if last[name] != value {
+					diff[name] = value
+				}
+			}
+			last = secrets
+			if len(diff) > 0 {
+				changed(diff)
+			}
+		}
+	}()
+}
+
+// fileSecrets reads one secret per file in a directory
+type fileSecrets struct {
+	dir string
+}
+
+func (p *fileSecrets) Fetch(ctx context.Context) (map[string]string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		// Kubernetes mounts secrets through ..data symlinks
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(p.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		secrets[secretName(entry.Name())] = strings.TrimRight(string(data), "\r\n")
+	}
+	return secrets, nil
+}
+
+// vaultSecrets reads a KV secret through the Vault HTTP API
+type vaultSecrets struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+func (p *vaultSecrets) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	// KV v2 nests the values under data.data, next to their metadata
+	nested, v2 := body.Data["data"]
+	if _, ok := body.Data["metadata"]; !v2 || !ok {
+		return stringSecrets(body.Data)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(nested, &values); err != nil {
+		return nil, err
+	}
+	return stringSecrets(values)
+}
+
+// awsSecrets reads a Secrets Manager secret whose value is a JSON object
+type awsSecrets struct {
+	config   SecretsConfig
+	endpoint string
+	client   *http.Client
+}
+
+func (p *awsSecrets) Fetch(ctx context.Context) (map[string]string, error) {
+	body, _ := json.Marshal(map[string]string{"SecretId": p.config.AWSSecretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, body, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("secrets manager returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out.SecretString), &values); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object", p.config.AWSSecretID)
+	}
+	return stringSecrets(values)
+}
+
+// sign adds an AWS Signature Version 4 to req
+func (p *awsSecrets) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.config.AWSSessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.config.AWSSessionToken)
+	}
+
+	names := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if p.config.AWSSessionToken != "" {
+		names = append(names, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + p.config.AWSRegion + "/secretsmanager/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + p.config.AWSSecretAccessKey)
+	for _, part := range []string{date, p.config.AWSRegion, "secretsmanager", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.config.AWSAccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// stringSecrets keeps the string values of a secret object, keyed by the
+// environment variable they replace
+func stringSecrets(values map[string]json.RawMessage) (map[string]string, error) {
+	secrets := make(map[string]string, len(values))
+	for name, raw := range values {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("secret %s must be a string", name)
+		}
+		secrets[secretName(name)] = value
+	}
+	return secrets, nil
+}
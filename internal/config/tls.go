@@ -0,0 +1,97 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Version returns the configured minimum TLS version
+func (t TLSConfig) Version() (uint16, error) {
+	if t.MinVersion == "" {
+		return tls.VersionTLS12, nil
+	}
+	v, ok := tlsVersions[t.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q", t.MinVersion)
+	}
+	return v, nil
+}
+
+// CipherSuiteIDs resolves the configured cipher suite names. An empty list
+// keeps Go's defaults. Suites only apply up to TLS 1.2; TLS 1.3 suites are
+// not configurable.
+func (t TLSConfig) CipherSuiteIDs() ([]uint16, error) {
+	if len(t.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(t.CipherSuites))
+	for _, name := range t.CipherSuites {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ClientCAs loads the CA certificates client certificates are verified
+// against, or nil without ClientCAFile
+func (t TLSConfig) ClientCAs() (*x509.CertPool, error) {
+	if t.ClientCAFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(t.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in client CA file %s", t.ClientCAFile)
+	}
+	return pool, nil
+}
+
+// TLSConfig builds the client TLS settings for a broker, or nil when no TLS
+// files are configured. Without TLSCAFile the system roots are used.
+func (b BrokerConfig) TLSConfig() (*tls.Config, error) {
+	if b.TLSCAFile == "" && b.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if b.TLSCAFile != "" {
+		pem, err := os.ReadFile(b.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", b.TLSCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if b.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(b.TLSCertFile, b.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
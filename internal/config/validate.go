@@ -0,0 +1,1070 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/schedule"
+	"api-gateway/internal/schema"
+	"api-gateway/internal/spiffe"
+	"api-gateway/internal/transform"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+)
+
+// Validate checks the config for values that would break the gateway at
+// runtime. It is run at startup and before every hot reload.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %d is out of range", c.Server.Port))
+	}
+
+	if tlsCfg := c.Server.TLS; tlsCfg.Enabled {
+		static := tlsCfg.CertFile != "" || tlsCfg.KeyFile != ""
+		switch {
+		case static && len(tlsCfg.ACMEDomains) > 0:
+			errs = append(errs, errors.New("server.tls: use either cert_file/key_file or acme_domains, not both"))
+		case static && (tlsCfg.CertFile == "" || tlsCfg.KeyFile == ""):
+			errs = append(errs, errors.New("server.tls: cert_file and key_file must be set together"))
+		case !static && len(tlsCfg.ACMEDomains) == 0:
+			errs = append(errs, errors.New("server.tls: cert_file/key_file or acme_domains is required"))
+		}
+		if tlsCfg.HTTPPort <= 0 || tlsCfg.HTTPPort > 65535 || tlsCfg.HTTPPort == c.Server.Port {
+			errs = append(errs, fmt.Errorf("server.tls: http_port %d is invalid", tlsCfg.HTTPPort))
+		}
+		if _, err := tlsCfg.Version(); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls: %w", err))
+		}
+		if _, err := tlsCfg.CipherSuiteIDs(); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls: %w", err))
+		}
+		if _, err := tlsCfg.ClientCAs(); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls: client CAs: %w", err))
+		}
+	}
+
+	errs = append(errs, c.Admin.validate(c.Server.Port)...)
+
+	if c.SPIFFE.Enabled {
+		if !strings.HasPrefix(c.SPIFFE.SocketPath, "unix://") && !strings.HasPrefix(c.SPIFFE.SocketPath, "tcp://") {
+			errs = append(errs, fmt.Errorf("spiffe.socket_path %q must start with unix:// or tcp://", c.SPIFFE.SocketPath))
+		}
+		if c.SPIFFE.FetchTimeout <= 0 {
+			errs = append(errs, errors.New("spiffe.fetch_timeout must be positive"))
+		}
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if !validIPOrCIDR(proxy) {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: invalid address or CIDR range %q", proxy))
+		}
+	}
+	if _, err := c.JWT.SigningKey(); err != nil {
+		errs = append(errs, err)
+	}
+	hmacSigned := c.JWT.Algorithm == "" || c.JWT.Algorithm == utils.AlgHS256
+	if hmacSigned && c.Server.Environment == "production" && (c.JWT.Secret == "" || c.JWT.Secret == defaultJWTSecret) {
+		errs = append(errs, errors.New("jwt.secret must be set in production"))
+	}
+	if c.Identity.InternalToken && (c.Identity.InternalTokenHeader == "" || c.Identity.InternalTokenExpiry <= 0) {
+		errs = append(errs, errors.New("identity: the internal token needs a header and a positive expiry"))
+	}
+	if c.JWT.KeyRefreshInterval <= 0 {
+		errs = append(errs, errors.New("jwt.key_refresh_interval must be positive"))
+	}
+
+	switch s := c.Secrets; s.Provider {
+	case "", "file", "vault":
+	case "aws":
+		if s.AWSRegion == "" || s.AWSSecretID == "" || s.AWSAccessKeyID == "" || s.AWSSecretAccessKey == "" {
+			errs = append(errs, errors.New("secrets: aws requires AWS_REGION, AWS_SECRET_ID and access keys"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("secrets.provider: unknown provider %q", s.Provider))
+	}
+
+	if c.Server.ProxyProtocol && len(c.Server.TrustedProxies) == 0 {
+		errs = append(errs, errors.New("server.proxy_protocol requires trusted_proxies"))
+	}
+
+	if c.Server.MaxBodySize < 0 {
+		errs = append(errs, errors.New("server.max_body_size must not be negative"))
+	}
+
+	if c.Timeouts.Request < 0 {
+		errs = append(errs, errors.New("timeouts.request must not be negative"))
+	}
+
+	if b := c.Bulkhead; b.MaxConcurrent < 0 || b.MaxQueue < 0 || b.QueueTimeout < 0 {
+		errs = append(errs, errors.New("bulkhead: limits and queue_timeout must not be negative"))
+	}
+
+	if ls := c.LoadShedding; ls.Enabled {
+		if ls.CPUThreshold <= 0 || ls.CPUThreshold > 1 {
+			errs = append(errs, errors.New("load_shedding.cpu_threshold must be between 0 and 1"))
+		}
+		if ls.MaxGoroutines < 0 || ls.LatencyThreshold < 0 || ls.RetryAfter < 0 {
+			errs = append(errs, errors.New("load_shedding: thresholds must not be negative"))
+		}
+		if ls.MaxInFlight < 0 || ls.MaxQueue < 0 || ls.QueueTimeout < 0 {
+			errs = append(errs, errors.New("load_shedding: queue limits must not be negative"))
+		}
+		for role, priority := range ls.RolePriorities {
+			switch priority {
+			case "low", "normal", "high":
+			default:
+				errs = append(errs, fmt.Errorf("load_shedding.role_priorities[%s]: priority must be low, normal or high", role))
+			}
+		}
+	}
+
+	if ci := c.ClientInFlight; ci.MaxPerIP < 0 || ci.MaxPerUser < 0 {
+		errs = append(errs, errors.New("client_in_flight: limits must not be negative"))
+	}
+
+	usesExternalAuthz := false
+	for _, route := range c.Routes {
+		usesExternalAuthz = usesExternalAuthz || route.ExternalAuthz
+	}
+	if ea := c.ExternalAuthz; usesExternalAuthz {
+		if ea.Provider != "webhook" && ea.Provider != "opa" {
+			errs = append(errs, fmt.Errorf("external_authz.provider: unknown provider %q", ea.Provider))
+		}
+		if u, err := url.Parse(ea.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, errors.New("external_authz.url must be an absolute URL"))
+		}
+		if ea.FailureMode != "open" && ea.FailureMode != "closed" {
+			errs = append(errs, fmt.Errorf("external_authz.failure_mode: unknown mode %q", ea.FailureMode))
+		}
+		if ea.Timeout <= 0 || ea.CacheTTL < 0 {
+			errs = append(errs, errors.New("external_authz: timeout must be positive and cache_ttl not negative"))
+		}
+	}
+
+	if st := c.Startup; st.RetryTimeout < 0 || st.InitialBackoff <= 0 || st.MaxBackoff < st.InitialBackoff {
+		errs = append(errs, errors.New("startup: retry_timeout must not be negative, initial_backoff must be positive and max_backoff at least initial_backoff"))
+	}
+
+	switch c.Storage.Backend {
+	case "mongo":
+		errs = append(errs, c.MongoDB.validate()...)
+	case "postgres":
+		if c.Postgres.URL == "" {
+			errs = append(errs, errors.New("postgres.url is required by the postgres storage backend"))
+		}
+		if c.Postgres.MaxConns <= 0 {
+			errs = append(errs, errors.New("postgres.max_conns must be positive"))
+		}
+		usesHMAC := false
+		for _, route := range c.Routes {
+			usesHMAC = usesHMAC || route.HMACAuth
+		}
+		mongoOnly := []struct {
+			feature string
+			used    bool
+		}{
+			{"tenancy", c.Tenancy.Enabled},
+			{"metering", c.Metering.Enabled},
+			{"the mongo analytics sink", c.Analytics.Enabled && c.Analytics.Sink == "mongo"},
+			{"hmac_auth routes", usesHMAC},
+		}
+		for _, f := range mongoOnly {
+			if f.used {
+				errs = append(errs, fmt.Errorf("storage.backend: %s requires the mongo backend", f.feature))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend: unknown backend %q", c.Storage.Backend))
+	}
+
+	for _, dep := range c.Readiness.Required {
+		switch dep {
+		case "redis", "database", "services":
+		default:
+			errs = append(errs, fmt.Errorf("readiness.required: unknown dependency %q", dep))
+		}
+	}
+	if c.Readiness.Timeout <= 0 {
+		errs = append(errs, errors.New("readiness.timeout must be positive"))
+	}
+
+	if c.Tenancy.Enabled && c.Tenancy.RefreshInterval <= 0 {
+		errs = append(errs, errors.New("tenancy.refresh_interval must be positive"))
+	}
+
+	if c.HMAC.ClockSkew <= 0 {
+		errs = append(errs, errors.New("hmac.clock_skew must be positive"))
+	}
+
+	if bl := c.BodyLog; bl.MaxSize <= 0 || bl.SampleRate < 0 || bl.SampleRate > 1 {
+		errs = append(errs, errors.New("body_log: max_size must be positive and sample_rate between 0 and 1"))
+	}
+
+	if m := c.Messaging; m.BufferSize <= 0 || m.Workers <= 0 || m.PublishTimeout <= 0 || m.RetryBackoff < 0 {
+		errs = append(errs, errors.New("messaging: buffer_size, workers and publish_timeout must be positive"))
+	}
+	brokers := make(map[string]bool, len(c.Messaging.Brokers))
+	for i, broker := range c.Messaging.Brokers {
+		switch broker.Type {
+		case "kafka", "rabbitmq", "nats":
+		default:
+			errs = append(errs, fmt.Errorf("brokers[%d]: unknown type %q", i, broker.Type))
+		}
+		if broker.Name == "" || broker.URL == "" {
+			errs = append(errs, fmt.Errorf("brokers[%d]: name and url are required", i))
+		}
+		if (broker.TLSCertFile == "") != (broker.TLSKeyFile == "") {
+			errs = append(errs, fmt.Errorf("brokers[%d]: tls_cert_file and tls_key_file must be set together", i))
+		}
+		if broker.TLSCAFile != "" || broker.TLSCertFile != "" {
+			if _, err := broker.TLSConfig(); err != nil {
+				errs = append(errs, fmt.Errorf("brokers[%d]: %w", i, err))
+			}
+		}
+		if brokers[broker.Name] {
+			errs = append(errs, fmt.Errorf("brokers[%d]: duplicate broker %q", i, broker.Name))
+		}
+		brokers[broker.Name] = true
+	}
+
+	if i := c.Idempotency; i.Header == "" || i.TTL <= 0 || i.LockTimeout <= 0 || i.MaxSize <= 0 {
+		errs = append(errs, errors.New("idempotency: header, ttl, lock_timeout and max_size are required"))
+	}
+	statuses := make(map[string]bool)
+	for i, t := range c.Errors.Templates {
+		status := strings.ToLower(t.Status)
+		if !validErrorStatus(status) {
+			errs = append(errs, fmt.Errorf("errors.templates[%d]: status must be a 4xx or 5xx code, or 4xx or 5xx", i))
+		} else if statuses[status] {
+			errs = append(errs, fmt.Errorf("errors.templates[%d]: duplicate status %s", i, t.Status))
+		}
+		statuses[status] = true
+		if t.JSON == "" && t.HTML == "" && t.HTMLFile == "" {
+			errs = append(errs, fmt.Errorf("errors.templates[%d]: json, html or html_file is required", i))
+		}
+		if t.HTML != "" && t.HTMLFile != "" {
+			errs = append(errs, fmt.Errorf("errors.templates[%d]: html and html_file are mutually exclusive", i))
+		}
+		// Variables expand to escaped strings or the numeric status
+		if t.JSON != "" && !json.Valid([]byte(transform.Expand(t.JSON, func(string) string { return "0" }))) {
+			errs = append(errs, fmt.Errorf("errors.templates[%d]: json is not a valid JSON document", i))
+		}
+	}
+	if _, err := c.Errors.ErrorTemplates(); err != nil {
+		errs = append(errs, fmt.Errorf("errors.templates: %w", err))
+	}
+
+	if l := c.Logging; l.Async {
+		if l.BufferSize <= 0 {
+			errs = append(errs, errors.New("logging.buffer_size must be positive"))
+		}
+		switch l.Overflow {
+		case logger.OverflowBlock, logger.OverflowDrop, logger.OverflowSample:
+		default:
+			errs = append(errs, fmt.Errorf("logging.overflow %q must be block, drop or sample", l.Overflow))
+		}
+	}
+	sampledLevels := make(map[string]bool)
+	for i, s := range c.Logging.Sampling {
+		switch s.Level {
+		case "debug", "info", "warn", "error":
+		default:
+			errs = append(errs, fmt.Errorf("logging.sampling[%d]: level %q must be debug, info, warn or error", i, s.Level))
+		}
+		if sampledLevels[s.Level] {
+			errs = append(errs, fmt.Errorf("logging.sampling[%d]: duplicate level %q", i, s.Level))
+		}
+		sampledLevels[s.Level] = true
+		if s.Initial < 0 || s.Thereafter < 0 {
+			errs = append(errs, fmt.Errorf("logging.sampling[%d]: initial and thereafter must not be negative", i))
+		}
+	}
+
+	if d := c.Dedup; d.Window <= 0 || d.LockTimeout <= 0 || d.MaxSize <= 0 {
+		errs = append(errs, errors.New("dedup: window, lock_timeout and max_size must be positive"))
+	}
+
+	if o := c.Outliers; o.Enabled {
+		if o.Interval <= 0 || o.MinRequests <= 0 || o.BaseEjectionTime <= 0 || o.MaxEjectionTime < o.BaseEjectionTime {
+			errs = append(errs, errors.New("outliers: interval, min_requests and base_ejection_time must be positive and max_ejection_time at least base_ejection_time"))
+		}
+		if o.ErrorRate <= 0 || o.ErrorRate > 1 || o.ConsecutiveErrors < 0 || o.LatencyFactor < 0 || o.RecoveryWindow < 0 {
+			errs = append(errs, errors.New("outliers: error_rate must be in (0, 1] and consecutive_errors, latency_factor and recovery_window non-negative"))
+		}
+		if o.MaxEjectionPercent <= 0 || o.MaxEjectionPercent > 100 {
+			errs = append(errs, errors.New("outliers: max_ejection_percent must be between 1 and 100"))
+		}
+	}
+
+	if c.SSE.HeartbeatInterval < 0 {
+		errs = append(errs, errors.New("sse.heartbeat_interval must not be negative"))
+	}
+
+	if c.Compression.MinSize < 0 {
+		errs = append(errs, errors.New("compression.min_size must not be negative"))
+	}
+
+	if c.RateLimit.Requests <= 0 {
+		errs = append(errs, errors.New("rate_limit.requests must be positive"))
+	}
+	if c.RateLimit.Window <= 0 {
+		errs = append(errs, errors.New("rate_limit.window must be positive"))
+	}
+
+	switch c.RateLimit.FailureMode {
+	case "local", "open", "closed":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.failure_mode: unknown mode %q", c.RateLimit.FailureMode))
+	}
+	if rl := c.RateLimit; rl.LeaseFraction < 0 || rl.LeaseFraction > 1 || (rl.LeaseFraction > 0 && rl.LeaseTTL <= 0) {
+		errs = append(errs, errors.New("rate_limit: lease_fraction must be between 0 and 1 and lease_ttl positive"))
+	}
+
+	for i, issuer := range c.JWT.TrustedIssuers {
+		if issuer.Issuer == "" {
+			errs = append(errs, fmt.Errorf("jwt.trusted_issuers[%d]: issuer is required", i))
+		}
+	}
+	if c.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("jwt.leeway must not be negative"))
+	}
+	for _, audience := range c.JWT.Audience {
+		if audience == utils.InternalTokenAudience {
+			errs = append(errs, fmt.Errorf("jwt.audience: %q is reserved for internal tokens", audience))
+		}
+	}
+	for i, rule := range c.JWT.ClaimRules {
+		if rule.Claim == "" {
+			errs = append(errs, fmt.Errorf("jwt.claim_rules[%d]: claim is required", i))
+		}
+	}
+
+	seenProviders := make(map[string]bool)
+	for i, p := range c.OIDC.Providers {
+		if p.Name == "" || p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("oidc.providers[%d]: name and client_id are required", i))
+		}
+		if seenProviders[p.Name] {
+			errs = append(errs, fmt.Errorf("oidc.providers[%d]: duplicate provider %q", i, p.Name))
+		}
+		seenProviders[p.Name] = true
+
+		switch p.Type {
+		case "google", "github":
+		case "oidc":
+			if p.Issuer == "" {
+				errs = append(errs, fmt.Errorf("oidc.providers[%d]: issuer is required for oidc providers", i))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("oidc.providers[%d]: unknown type %q", i, p.Type))
+		}
+	}
+
+	if al := c.AccessLog; al.Enabled {
+		switch al.Format {
+		case "json", "common", "combined":
+		default:
+			errs = append(errs, fmt.Errorf("access_log.format: unknown format %q", al.Format))
+		}
+		for _, out := range al.Outputs {
+			switch out {
+			case "stdout", "file", "syslog":
+			case "kafka":
+				if al.KafkaURL == "" || al.KafkaTopic == "" {
+					errs = append(errs, errors.New("access_log: kafka output requires kafka_url and kafka_topic"))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("access_log.outputs: unknown output %q", out))
+			}
+		}
+		for i, s := range al.Sampling {
+			if s.Path == "" || s.Rate < 0 || s.Rate > 1 {
+				errs = append(errs, fmt.Errorf("access_log.sampling[%d]: path is required and rate must be between 0 and 1", i))
+			}
+		}
+	}
+
+	if an := c.Analytics; an.Enabled {
+		switch an.Sink {
+		case "mongo":
+		case "kafka":
+			if an.KafkaURL == "" || an.KafkaTopic == "" {
+				errs = append(errs, errors.New("analytics: kafka sink requires kafka_url and kafka_topic"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("analytics.sink: unknown sink %q", an.Sink))
+		}
+		if an.BatchSize <= 0 || an.QueueSize < an.BatchSize {
+			errs = append(errs, errors.New("analytics: batch_size must be positive and queue_size at least batch_size"))
+		}
+		if an.FlushInterval <= 0 {
+			errs = append(errs, errors.New("analytics.flush_interval must be positive"))
+		}
+	}
+
+	if m := c.Metering; m.Enabled {
+		if m.FlushInterval <= 0 || m.CloseDelay <= m.FlushInterval {
+			errs = append(errs, errors.New("metering: flush_interval must be positive and close_delay longer than it"))
+		}
+		if m.WebhookURL != "" && m.WebhookSecret == "" {
+			errs = append(errs, errors.New("metering: webhook_url requires webhook_secret"))
+		}
+	}
+
+	if ev := c.Events; ev.RedisChannel != "" || len(ev.Webhooks) > 0 {
+		if ev.MaxAttempts < 1 || ev.RetryBackoff <= 0 || ev.QueueSize <= 0 || ev.Throttle < 0 {
+			errs = append(errs, errors.New("events: max_attempts, retry_backoff and queue_size must be positive and throttle not negative"))
+		}
+		for i, w := range ev.Webhooks {
+			if u, err := url.Parse(w.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("events.webhooks[%d]: url must be absolute", i))
+			}
+			if w.Secret == "" {
+				errs = append(errs, fmt.Errorf("events.webhooks[%d]: secret is required to sign payloads", i))
+			}
+		}
+	}
+
+	if sc := c.Session; sc.Enabled {
+		if sc.CookieName == "" {
+			errs = append(errs, errors.New("session: cookie name is required"))
+		}
+		if sc.TTL <= 0 || sc.IdleTimeout < 0 {
+			errs = append(errs, errors.New("session: TTL must be positive and the idle timeout not negative"))
+		}
+		switch strings.ToLower(sc.SameSite) {
+		case "strict", "lax":
+		case "none":
+			if !sc.Secure {
+				errs = append(errs, errors.New("session: SameSite=None cookies must be Secure"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("session: unknown SameSite mode %q", sc.SameSite))
+		}
+	}
+
+	if c.UsesCSRF() && (c.CSRF.CookieName == "" || c.CSRF.Header == "") {
+		errs = append(errs, errors.New("csrf: cookie name and header are required"))
+	}
+
+	if c.Alerting.Interval <= 0 {
+		errs = append(errs, errors.New("alerting.interval must be positive"))
+	}
+	if al := c.Alerting; len(al.Rules) > 0 {
+		if al.SlackWebhookURL == "" && al.PagerDutyRoutingKey == "" {
+			errs = append(errs, errors.New("alerting: rules need a Slack webhook URL or a PagerDuty routing key"))
+		}
+		names := make(map[string]bool, len(al.Rules))
+		for i, rule := range al.Rules {
+			if rule.Name == "" || names[rule.Name] {
+				errs = append(errs, fmt.Errorf("alerting.rules[%d]: name is required and must be unique", i))
+			}
+			names[rule.Name] = true
+			switch rule.Metric {
+			case "error_rate", "p99_latency", "breaker_open":
+			default:
+				errs = append(errs, fmt.Errorf("alerting.rules[%d]: unknown metric %q", i, rule.Metric))
+			}
+			if rule.Threshold < 0 || rule.For < 0 {
+				errs = append(errs, fmt.Errorf("alerting.rules[%d]: threshold and for must not be negative", i))
+			}
+			switch rule.Severity {
+			case "", "critical", "error", "warning", "info":
+			default:
+				errs = append(errs, fmt.Errorf("alerting.rules[%d]: unknown severity %q", i, rule.Severity))
+			}
+			for _, channel := range rule.Channels {
+				switch {
+				case channel == "slack" && al.SlackWebhookURL != "":
+				case channel == "pagerduty" && al.PagerDutyRoutingKey != "":
+				default:
+					errs = append(errs, fmt.Errorf("alerting.rules[%d]: channel %q is unknown or not configured", i, channel))
+				}
+			}
+		}
+	}
+
+	validAlgorithms := map[string]bool{
+		"token_bucket": true, "sliding_log": true, "sliding_window": true, "fixed_window": true, "concurrency": true,
+	}
+	if !validAlgorithms[c.RateLimit.Algorithm] {
+		errs = append(errs, fmt.Errorf("rate_limit.algorithm: unknown algorithm %q", c.RateLimit.Algorithm))
+	}
+
+	validKeys := map[string]bool{"ip": true, "user": true, "api_key": true, "route": true, "tenant": true}
+	for _, key := range c.RateLimit.KeyBy {
+		if !validKeys[key] {
+			errs = append(errs, fmt.Errorf("rate_limit.key_by: unknown dimension %q", key))
+		}
+	}
+	for i, dim := range c.RateLimit.Dimensions {
+		if dim.Name == "" || dim.Requests <= 0 || dim.Window <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.dimensions[%d]: name, requests and window are required", i))
+		}
+		for _, key := range dim.KeyBy {
+			if !validKeys[key] {
+				errs = append(errs, fmt.Errorf("rate_limit.dimensions[%d]: unknown dimension %q", i, key))
+			}
+		}
+		if dim.Algorithm != "" && !validAlgorithms[dim.Algorithm] {
+			errs = append(errs, fmt.Errorf("rate_limit.dimensions[%d]: unknown algorithm %q", i, dim.Algorithm))
+		}
+	}
+
+	quotaRules := make(map[string]bool, len(c.Quota.Rules))
+	for i, rule := range c.Quota.Rules {
+		if rule.Name == "" || rule.Limit <= 0 {
+			errs = append(errs, fmt.Errorf("quota.rules[%d]: name and a positive limit are required", i))
+		}
+		if quotaRules[rule.Name] {
+			errs = append(errs, fmt.Errorf("quota.rules[%d]: duplicate rule %q", i, rule.Name))
+		}
+		quotaRules[rule.Name] = true
+		if rule.KeyBy != "api_key" && rule.KeyBy != "user" && rule.KeyBy != "tenant" {
+			errs = append(errs, fmt.Errorf("quota.rules[%d]: key_by must be api_key, user or tenant", i))
+		}
+		if rule.Period != "daily" && rule.Period != "monthly" {
+			errs = append(errs, fmt.Errorf("quota.rules[%d]: period must be daily or monthly", i))
+		}
+	}
+	if len(c.Quota.Rules) > 0 && c.Quota.FlushInterval <= 0 {
+		errs = append(errs, errors.New("quota.flush_interval must be positive"))
+	}
+
+	checkAccess := func(field string, rules AccessRules) {
+		for _, value := range append(append([]string(nil), rules.Allow...), rules.Deny...) {
+			if !validIPOrCIDR(value) {
+				errs = append(errs, fmt.Errorf("%s: invalid address or CIDR range %q", field, value))
+			}
+		}
+		for _, code := range append(append([]string(nil), rules.AllowCountries...), rules.DenyCountries...) {
+			if len(code) != 2 {
+				errs = append(errs, fmt.Errorf("%s: invalid country code %q", field, code))
+			}
+		}
+		if rules.hasCountries() && c.AccessControl.GeoIPDatabase == "" {
+			errs = append(errs, fmt.Errorf("%s: country rules need a geoip database", field))
+		}
+	}
+	checkAccess("access_control", c.AccessControl.Global)
+
+	services := make(map[string]bool, len(c.Services))
+	for i, svc := range c.Services {
+		if svc.Name == "" {
+			errs = append(errs, fmt.Errorf("services[%d]: name is required", i))
+			continue
+		}
+		if services[svc.Name] {
+			errs = append(errs, fmt.Errorf("services[%d]: duplicate service %q", i, svc.Name))
+		}
+		services[svc.Name] = true
+
+		if svc.Discovery != nil {
+			switch svc.Discovery.Provider {
+			case "consul":
+			case "dns":
+				if len(svc.URLs) == 0 {
+					errs = append(errs, fmt.Errorf("service %q: dns discovery requires urls", svc.Name))
+				}
+				if c.Discovery.DNSInterval <= 0 {
+					errs = append(errs, fmt.Errorf("service %q: DNS_RESOLVE_INTERVAL must be positive", svc.Name))
+				}
+			case "etcd":
+				if svc.Discovery.Prefix == "" {
+					errs = append(errs, fmt.Errorf("service %q: etcd discovery requires a prefix", svc.Name))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("service %q: unknown discovery provider %q", svc.Name, svc.Discovery.Provider))
+			}
+		} else if len(svc.URLs) == 0 {
+			errs = append(errs, fmt.Errorf("service %q: at least one url is required", svc.Name))
+		}
+		for _, raw := range svc.URLs {
+			if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("service %q: invalid url %q", svc.Name, raw))
+			}
+		}
+		if b := svc.Bulkhead; b != nil && (b.MaxConcurrent < 0 || b.MaxQueue < 0 || b.QueueTimeout < 0) {
+			errs = append(errs, fmt.Errorf("service %q: bulkhead limits and queue_timeout must not be negative", svc.Name))
+		}
+		if svc.SlowStart < 0 {
+			errs = append(errs, fmt.Errorf("service %q: slow_start must not be negative", svc.Name))
+		}
+		if up := svc.Upstream; up != nil && up.SPIFFEID != "" {
+			if !c.SPIFFE.Enabled {
+				errs = append(errs, fmt.Errorf("service %q: spiffe_id requires SPIFFE_ENABLED", svc.Name))
+			}
+			if _, _, err := spiffe.ParseID(up.SPIFFEID); err != nil {
+				errs = append(errs, fmt.Errorf("service %q: %w", svc.Name, err))
+			}
+			for _, raw := range svc.URLs {
+				if strings.HasPrefix(raw, "http://") {
+					errs = append(errs, fmt.Errorf("service %q: spiffe_id requires https urls, got %q", svc.Name, raw))
+				}
+			}
+		}
+		listed := make(map[string]bool, len(svc.URLs))
+		for _, u := range svc.URLs {
+			listed[u] = true
+		}
+		zoned := make(map[string]string)
+		for zone, urls := range svc.Zones {
+			for _, u := range urls {
+				if other, ok := zoned[u]; ok {
+					errs = append(errs, fmt.Errorf("service %q: url %q is in zones %q and %q", svc.Name, u, other, zone))
+				} else if svc.Discovery == nil && !listed[u] {
+					errs = append(errs, fmt.Errorf("service %q: zone %q lists unknown url %q", svc.Name, zone, u))
+				}
+				zoned[u] = zone
+			}
+		}
+		if svc.ZoneAware && len(svc.Zones) == 0 {
+			errs = append(errs, fmt.Errorf("service %q: zone_aware requires zones", svc.Name))
+		}
+		if svc.Protocol != "" && svc.Protocol != "http" && svc.Protocol != "grpc" {
+			errs = append(errs, fmt.Errorf("service %q: unknown protocol %q", svc.Name, svc.Protocol))
+		}
+		switch svc.LoadBalancing {
+		case "", "round_robin":
+		case "consistent_hash":
+			if !validHashKey(svc.HashKey) {
+				errs = append(errs, fmt.Errorf("service %q: invalid hash_key %q", svc.Name, svc.HashKey))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("service %q: unknown load_balancing %q", svc.Name, svc.LoadBalancing))
+		}
+	}
+
+	// Transformations and splits are kept per path, so only one of the
+	// routes sharing a path may declare them
+	transformPaths := make(map[string]bool)
+	splitPaths := make(map[string]bool)
+	for i, route := range c.Routes {
+		base := strings.TrimSuffix(route.Path, "/")
+		if route.Transforms != nil {
+			if transformPaths[base] {
+				errs = append(errs, fmt.Errorf("routes[%d]: another route on %q already declares transforms", i, route.Path))
+			}
+			transformPaths[base] = true
+		}
+		if route.Split != nil {
+			if splitPaths[base] {
+				errs = append(errs, fmt.Errorf("routes[%d]: another route on %q already declares a split", i, route.Path))
+			}
+			splitPaths[base] = true
+		}
+	}
+
+	// Routes sharing a path need different methods or match conditions,
+	// otherwise only the first of them is ever reached
+	for i, route := range c.Routes {
+		for j, other := range c.Routes[:i] {
+			if strings.TrimSuffix(other.Path, "/") == strings.TrimSuffix(route.Path, "/") &&
+				reflect.DeepEqual(other.Match, route.Match) && methodsOverlap(other.Methods, route.Methods) {
+				errs = append(errs, fmt.Errorf("routes[%d]: duplicates routes[%d] on %q", i, j, route.Path))
+				break
+			}
+		}
+	}
+
+	for i, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs, fmt.Errorf("routes[%d]: path %q must start with /", i, route.Path))
+		}
+		if route.Service == "" && route.Split == nil && route.Static == nil && route.Composite == nil && route.Publish == nil {
+			errs = append(errs, fmt.Errorf("routes[%d]: service is required", i))
+		}
+		if pub := route.Publish; pub != nil {
+			if route.Service != "" || route.Split != nil || route.Static != nil || route.Composite != nil {
+				errs = append(errs, fmt.Errorf("routes[%d]: publish can't be combined with service, split, static or composite", i))
+			}
+			if !brokers[pub.Broker] {
+				errs = append(errs, fmt.Errorf("routes[%d]: unknown broker %q", i, pub.Broker))
+			}
+			if pub.Topic == "" || pub.MaxAttempts < 0 {
+				errs = append(errs, fmt.Errorf("routes[%d]: publish needs a topic and non-negative max_attempts", i))
+			}
+		}
+		if comp := route.Composite; comp != nil {
+			if route.Service != "" || route.Split != nil || route.Static != nil {
+				errs = append(errs, fmt.Errorf("routes[%d]: composite can't be combined with service, split or static", i))
+			}
+			if len(comp.Calls) == 0 || comp.Timeout < 0 {
+				errs = append(errs, fmt.Errorf("routes[%d]: composite needs calls and a non-negative timeout", i))
+			}
+			names := make(map[string]bool, len(comp.Calls))
+			for _, call := range comp.Calls {
+				if call.Name == "" || call.Service == "" || !strings.HasPrefix(call.Path, "/") {
+					errs = append(errs, fmt.Errorf("routes[%d]: composite calls need a name, a service and a path starting with /", i))
+				}
+				if names[call.Name] {
+					errs = append(errs, fmt.Errorf("routes[%d]: duplicate composite call %q", i, call.Name))
+				}
+				names[call.Name] = true
+			}
+		}
+		if m := route.Match; m != nil {
+			if m.Path == "" && len(m.Hosts) == 0 && len(m.Headers) == 0 && len(m.Query) == 0 {
+				errs = append(errs, fmt.Errorf("routes[%d]: match needs a path, hosts, headers or query", i))
+			}
+			if _, err := m.PathPattern(); err != nil {
+				errs = append(errs, fmt.Errorf("routes[%d]: invalid match path: %w", i, err))
+			}
+			for _, host := range m.Hosts {
+				if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+					errs = append(errs, fmt.Errorf("routes[%d]: invalid match host %q", i, host))
+				}
+			}
+		}
+		if route.Rewrite != "" && (route.Match == nil || route.Match.Path == "") {
+			errs = append(errs, fmt.Errorf("routes[%d]: rewrite requires match.path", i))
+		}
+		if s := route.Static; s != nil && (s.Status != 0 && (s.Status < 100 || s.Status > 599) || s.Latency < 0) {
+			errs = append(errs, fmt.Errorf("routes[%d]: static needs a valid status and a non-negative latency", i))
+		}
+		if route.Split != nil {
+			total := 0
+			for _, b := range route.Split.Backends {
+				if b.Service == "" || b.Weight < 0 {
+					errs = append(errs, fmt.Errorf("routes[%d]: split backends need a service and a non-negative weight", i))
+				}
+				total += b.Weight
+			}
+			if total <= 0 {
+				errs = append(errs, fmt.Errorf("routes[%d]: split needs a backend with a positive weight", i))
+			}
+			if route.Split.HashKey != "" && !validHashKey(route.Split.HashKey) {
+				errs = append(errs, fmt.Errorf("routes[%d]: invalid split hash_key %q", i, route.Split.HashKey))
+			}
+		}
+		if route.BillingUnits < 0 {
+			errs = append(errs, fmt.Errorf("routes[%d]: billing_units must not be negative", i))
+		}
+		if (len(route.Permissions) > 0 || len(route.Scopes) > 0) && !route.AuthRequired && !route.HMACAuth {
+			errs = append(errs, fmt.Errorf("routes[%d]: permissions and scopes require auth_required or hmac_auth", i))
+		}
+		if route.HMACAuth && route.AuthRequired {
+			errs = append(errs, fmt.Errorf("routes[%d]: hmac_auth and auth_required are mutually exclusive", i))
+		}
+		if route.Mirror != nil && (route.Mirror.Service == "" || route.Mirror.Rate <= 0 || route.Mirror.Rate > 1) {
+			errs = append(errs, fmt.Errorf("routes[%d]: mirror needs a service and a rate between 0 and 1", i))
+		}
+		if cc := route.ClientCert; cc != nil {
+			if !cc.Required && (len(cc.Subjects) > 0 || len(cc.SANs) > 0) {
+				errs = append(errs, fmt.Errorf("routes[%d].client_cert: subjects and sans require required", i))
+			}
+			if cc.Required && (!c.Server.TLS.Enabled || c.Server.TLS.ClientCAFile == "") {
+				errs = append(errs, fmt.Errorf("routes[%d].client_cert: requires server.tls with client CAs", i))
+			}
+		}
+		if route.Access != nil {
+			checkAccess(fmt.Sprintf("routes[%d].access", i), *route.Access)
+		}
+		if route.MaxBodySize < 0 {
+			errs = append(errs, fmt.Errorf("routes[%d]: max_body_size must not be negative", i))
+		}
+		if route.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("routes[%d]: timeout must not be negative", i))
+		}
+		if route.DedupWindow < 0 || (route.DedupWindow > 0 && !route.Dedup) {
+			errs = append(errs, fmt.Errorf("routes[%d]: dedup_window must be positive and requires dedup", i))
+		}
+		switch route.Priority {
+		case "", "low", "normal", "high", "critical":
+		default:
+			errs = append(errs, fmt.Errorf("routes[%d]: unknown priority %q", i, route.Priority))
+		}
+		if route.Body != nil {
+			for _, contentType := range route.Body.ContentTypes {
+				if _, _, err := mime.ParseMediaType(contentType); err != nil || !strings.Contains(contentType, "/") {
+					errs = append(errs, fmt.Errorf("routes[%d].body: invalid content type %q", i, contentType))
+				}
+			}
+			if route.Body.SchemaFile != "" {
+				if _, err := schema.Load(route.Body.SchemaFile); err != nil {
+					errs = append(errs, fmt.Errorf("routes[%d].body: schema: %w", i, err))
+				}
+			}
+		}
+		if route.Upload != nil {
+			// Both hash the whole body, which would buffer the upload
+			if route.Idempotency || route.Dedup {
+				errs = append(errs, fmt.Errorf("routes[%d].upload: can't be combined with idempotency or dedup", i))
+			}
+			if route.Upload.MaxFileSize < 0 || route.Upload.MaxParts < 0 {
+				errs = append(errs, fmt.Errorf("routes[%d].upload: max_file_size and max_parts must not be negative", i))
+			}
+			for _, allowed := range route.Upload.AllowedTypes {
+				if _, _, err := mime.ParseMediaType(allowed); err != nil || !strings.Contains(allowed, "/") {
+					errs = append(errs, fmt.Errorf("routes[%d].upload: invalid allowed type %q", i, allowed))
+				}
+			}
+		}
+		if rw := route.ResponseRewrite; rw != nil {
+			for _, base := range append([]string{rw.PublicURL}, rw.From...) {
+				if base == "" {
+					continue
+				}
+				if u, err := url.Parse(base); err != nil || u.Scheme == "" || u.Host == "" {
+					errs = append(errs, fmt.Errorf("routes[%d].response_rewrite: %q is not an absolute URL", i, base))
+				}
+			}
+		}
+		if route.Transforms != nil {
+			if _, err := transform.Compile(route.Transforms.Request, route.Transforms.Response); err != nil {
+				errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+			}
+		}
+	}
+
+	errs = append(errs, c.validateSchedules()...)
+	errs = append(errs, c.validateExperiments()...)
+
+	return errors.Join(errs...)
+}
+
+func validIPOrCIDR(value string) bool {
+	if strings.Contains(value, "/") {
+		_, _, err := net.ParseCIDR(value)
+		return err == nil
+	}
+	return net.ParseIP(value) != nil
+}
+
+func validHashKey(key string) bool {
+	switch {
+	case key == "jwt_sub", key == "client_ip":
+		return true
+	case strings.HasPrefix(key, "cookie:"), strings.HasPrefix(key, "header:"):
+		return !strings.HasSuffix(key, ":")
+	default:
+		return false
+	}
+}
+
+// methodsOverlap reports whether two method lists share a method; an empty
+// list accepts every method
+func methodsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, m := range a {
+		for _, n := range b {
+			if strings.EqualFold(m, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a AdminConfig) validate(publicPort int) []error {
+	var errs []error
+	if a.Addr == "" {
+		if a.Auth != "jwt" || a.Pprof || a.TLS.Enabled {
+			errs = append(errs, errors.New("admin: auth, pprof and tls settings require admin.addr"))
+		}
+		return errs
+	}
+
+	host, port, err := net.SplitHostPort(a.Addr)
+	if err != nil {
+		return append(errs, fmt.Errorf("admin.addr: %w", err))
+	}
+	if port == strconv.Itoa(publicPort) {
+		errs = append(errs, fmt.Errorf("admin.addr: port %s is the public listener's", port))
+	}
+
+	switch a.Auth {
+	case "jwt":
+	case "token":
+		if len(a.Token) < 16 {
+			errs = append(errs, errors.New("admin.token must be at least 16 characters"))
+		}
+	case "none":
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			errs = append(errs, errors.New("admin.auth none is only allowed on a loopback address"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("admin.auth: unknown mode %q", a.Auth))
+	}
+
+	if a.TLS.Enabled {
+		if a.TLS.CertFile == "" || a.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("admin.tls: cert_file and key_file are required"))
+		}
+		if _, err := a.TLS.Version(); err != nil {
+			errs = append(errs, fmt.Errorf("admin.tls: %w", err))
+		}
+		if _, err := a.TLS.CipherSuiteIDs(); err != nil {
+			errs = append(errs, fmt.Errorf("admin.tls: %w", err))
+		}
+	}
+	return errs
+}
+
+func (m MongoDBConfig) validate() []error {
+	var errs []error
+	if m.MaxPoolSize < 0 || m.MinPoolSize < 0 {
+		errs = append(errs, errors.New("mongodb: max_pool_size and min_pool_size must not be negative"))
+	}
+	if m.MaxPoolSize > 0 && m.MinPoolSize > m.MaxPoolSize {
+		errs = append(errs, errors.New("mongodb.min_pool_size must not exceed max_pool_size"))
+	}
+	if m.ServerSelectionTimeout < 0 {
+		errs = append(errs, errors.New("mongodb.server_selection_timeout must not be negative"))
+	}
+
+	switch strings.ToLower(m.ReadPreference) {
+	case "", "primary", "primarypreferred", "secondary", "secondarypreferred", "nearest":
+	default:
+		errs = append(errs, fmt.Errorf("mongodb.read_preference: unknown mode %q", m.ReadPreference))
+	}
+	if m.WriteConcern != "" && m.WriteConcern != "majority" {
+		if n, err := strconv.Atoi(m.WriteConcern); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("mongodb.write_concern must be \"majority\" or a number of members, got %q", m.WriteConcern))
+		}
+	}
+	if m.TLSCAFile != "" && !m.TLS {
+		errs = append(errs, errors.New("mongodb.tls_ca_file requires mongodb.tls"))
+	}
+	return errs
+}
+
+func (c *Config) validateSchedules() []error {
+	var errs []error
+
+	dimensions := make(map[string]bool)
+	for _, dim := range c.RateLimit.EffectiveDimensions() {
+		dimensions[dim.Name] = true
+	}
+	routes := make(map[string]bool)
+	for _, route := range c.Routes {
+		routes[route.Path] = true
+	}
+
+	names := make(map[string]bool)
+	for i, s := range c.Schedules {
+		if s.Name == "" {
+			errs = append(errs, fmt.Errorf("schedules[%d]: name is required", i))
+		} else if names[s.Name] {
+			errs = append(errs, fmt.Errorf("schedules[%d]: duplicate name %q", i, s.Name))
+		}
+		names[s.Name] = true
+
+		if _, err := schedule.Parse(s.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("schedules[%d]: %w", i, err))
+		}
+		if s.Duration < time.Minute || s.Duration > schedule.MaxWindow {
+			errs = append(errs, fmt.Errorf("schedules[%d]: duration must be between 1m and %s", i, schedule.MaxWindow))
+		}
+		if s.Timezone != "" {
+			if _, err := time.LoadLocation(s.Timezone); err != nil {
+				errs = append(errs, fmt.Errorf("schedules[%d]: unknown timezone %q", i, s.Timezone))
+			}
+		}
+		if len(s.RateLimits) == 0 && len(s.Services) == 0 && !s.Maintenance {
+			errs = append(errs, fmt.Errorf("schedules[%d]: set rate_limits, services or maintenance", i))
+		}
+		for j, limit := range s.RateLimits {
+			if !dimensions[limit.Dimension] {
+				errs = append(errs, fmt.Errorf("schedules[%d].rate_limits[%d]: unknown dimension %q", i, j, limit.Dimension))
+			}
+			if limit.Requests <= 0 {
+				errs = append(errs, fmt.Errorf("schedules[%d].rate_limits[%d]: requests must be positive", i, j))
+			}
+		}
+		for j, override := range s.Services {
+			if !routes[override.Route] {
+				errs = append(errs, fmt.Errorf("schedules[%d].services[%d]: unknown route %q", i, j, override.Route))
+			}
+			if override.Service == "" {
+				errs = append(errs, fmt.Errorf("schedules[%d].services[%d]: service is required", i, j))
+			}
+		}
+	}
+	return errs
+}
+
+// Validate checks an experiment on its own; Config.Validate also checks
+// it against the routes and the other experiments
+func (e ExperimentConfig) Validate() error {
+	var errs []error
+	if e.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if len(e.Routes) == 0 {
+		errs = append(errs, errors.New("at least one route is required"))
+	}
+
+	total := 0
+	names := make(map[string]bool)
+	for _, v := range e.Variants {
+		if v.Name == "" || v.Service == "" {
+			errs = append(errs, errors.New("variants need a name and a service"))
+		}
+		if names[v.Name] {
+			errs = append(errs, fmt.Errorf("duplicate variant %q", v.Name))
+		}
+		names[v.Name] = true
+		if v.Weight < 0 {
+			errs = append(errs, fmt.Errorf("variant %q: weight must not be negative", v.Name))
+		}
+		total += v.Weight
+	}
+	if total <= 0 {
+		errs = append(errs, errors.New("at least one variant needs a positive weight"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateExperiments() []error {
+	var errs []error
+
+	routes := make(map[string]bool)
+	for _, route := range c.Routes {
+		routes[route.Path] = true
+	}
+
+	names := make(map[string]bool)
+	claimed := make(map[string]string)
+	for i, e := range c.Experiments {
+		if err := e.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("experiments[%d]: %w", i, err))
+		}
+		if e.Name != "" && names[e.Name] {
+			errs = append(errs, fmt.Errorf("experiments[%d]: duplicate name %q", i, e.Name))
+		}
+		names[e.Name] = true
+
+		for _, path := range e.Routes {
+			if !routes[path] {
+				errs = append(errs, fmt.Errorf("experiments[%d]: unknown route %q", i, path))
+			}
+			if other, ok := claimed[path]; ok {
+				errs = append(errs, fmt.Errorf("experiments[%d]: route %q is already in experiment %q", i, path, other))
+			}
+			claimed[path] = e.Name
+		}
+	}
+	return errs
+}
+
+// validErrorStatus reports whether status is a 4xx or 5xx code or class
+func validErrorStatus(status string) bool {
+	if status == "4xx" || status == "5xx" {
+		return true
+	}
+	code, err := strconv.Atoi(status)
+	return err == nil && code >= 400 && code <= 599
+}
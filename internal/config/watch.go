@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Watch reloads the configuration when the config file changes or the
+// process receives SIGHUP. A reloaded config is validated before apply is
+// called; on any error the running config is left untouched and onError is
+// invoked instead.
+func Watch(ctx context.Context, apply func(*Config) error, onError func(error)) {
+	var mu sync.Mutex
+	reload := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		cfg, err := LoadConfig()
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err == nil {
+			err = apply(cfg)
+		}
+		if err != nil {
+			onError(err)
+		}
+	}
+
+	viper.OnConfigChange(func(fsnotify.Event) {
+		reload()
+	})
+	viper.WatchConfig()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload()
+			}
+		}
+	}()
+}
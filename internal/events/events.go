@@ -0,0 +1,315 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+)
+
+// Event types
+const (
+	ServiceRegistered   = "service.registered"
+	ServiceUnregistered = "service.unregistered"
+	BreakerOpened       = "circuit_breaker.opened"
+	BreakerClosed       = "circuit_breaker.closed"
+	InstanceUnhealthy   = "instance.unhealthy"
+	InstanceHealthy     = "instance.healthy"
+	ConfigReloaded      = "config.reloaded"
+	RateLimitExceeded   = "rate_limit.exceeded"
+)
+
+// maxThrottledSubjects bounds the throttle state; older entries are pruned
+// once it is reached
+const maxThrottledSubjects = 10000
+
+var types = map[string]bool{
+	ServiceRegistered:   true,
+	ServiceUnregistered: true,
+	BreakerOpened:       true,
+	BreakerClosed:       true,
+	InstanceUnhealthy:   true,
+	InstanceHealthy:     true,
+	ConfigReloaded:      true,
+	RateLimitExceeded:   true,
+}
+
+// Webhook request headers. The signature is sha256= followed by the hex
+// HMAC-SHA256 of the body, keyed with the webhook's secret.
+const (
+	SignatureHeader = "X-Gateway-Signature"
+	EventHeader     = "X-Gateway-Event"
+	DeliveryHeader  = "X-Gateway-Delivery"
+)
+
+// Event is something operators may want to react to
+type Event struct {
+	ID   string                 `json:"id"`
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Notifier sends events to webhooks and a Redis pub/sub channel. Emit
+// never blocks: events are queued, and dropped when a queue is full. Each
+// webhook has its own queue, so a slow receiver only delays itself. A nil
+// Notifier drops every event.
+type Notifier struct {
+	redis        *storage.RedisClient
+	channel      string
+	webhooks     []*webhook
+	maxAttempts  int
+	retryBackoff time.Duration
+	throttle     time.Duration
+	client       *http.Client
+	logger       *logger.Logger
+
+	queue chan Event
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+type webhook struct {
+	config.EventWebhook
+	events map[string]bool
+	queue  chan Event
+}
+
+// New starts a notifier for cfg. It returns nil when no destination is
+// configured.
+func New(cfg config.EventsConfig, redisClient *storage.RedisClient, log *logger.Logger) (*Notifier, error) {
+	if cfg.RedisChannel == "" && len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+
+	n := &Notifier{
+		redis:        redisClient,
+		channel:      cfg.RedisChannel,
+		maxAttempts:  cfg.MaxAttempts,
+		retryBackoff: cfg.RetryBackoff,
+		throttle:     cfg.Throttle,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		logger:       log,
+		queue:        make(chan Event, cfg.QueueSize),
+		done:         make(chan struct{}),
+		sent:         make(map[string]time.Time),
+	}
+	for _, w := range cfg.Webhooks {
+		hook := &webhook{EventWebhook: w, events: make(map[string]bool), queue: make(chan Event, cfg.QueueSize)}
+		for _, t := range w.Events {
+			if !types[t] {
+				return nil, fmt.Errorf("webhook %s: unknown event %q", w.URL, t)
+			}
+			hook.events[t] = true
+		}
+		n.webhooks = append(n.webhooks, hook)
+	}
+
+	n.wg.Add(1 + len(n.webhooks))
+	go n.dispatch()
+	for _, hook := range n.webhooks {
+		go n.deliver(hook)
+	}
+	return n, nil
+}
+
+// Emit queues an event
+func (n *Notifier) Emit(eventType string, data map[string]interface{}) {
+	if n == nil {
+		return
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	event := Event{ID: hex.EncodeToString(id), Type: eventType, Time: time.Now().UTC(), Data: data}
+	select {
+	case n.queue <- event:
+	default:
+		metrics.EventDeliveries.WithLabelValues("queue", "dropped").Inc()
+	}
+}
+
+// EmitThrottled queues an event unless one of the same type about subject
+// was emitted within the throttle period
+func (n *Notifier) EmitThrottled(eventType, subject string, data map[string]interface{}) {
+	if n == nil {
+		return
+	}
+
+	key := eventType + "|" + subject
+	now := time.Now()
+	n.mu.Lock()
+	if last, ok := n.sent[key]; ok && now.Sub(last) < n.throttle {
+		n.mu.Unlock()
+		return
+	}
+	if len(n.sent) >= maxThrottledSubjects {
+		for k, last := range n.sent {
+			if now.Sub(last) >= n.throttle {
+				delete(n.sent, k)
+			}
+		}
+	}
+	n.sent[key] = now
+	n.mu.Unlock()
+
+	n.Emit(eventType, data)
+}
+
+// dispatch publishes queued events to Redis and hands them to the webhooks
+// subscribed to them
+func (n *Notifier) dispatch() {
+	defer n.wg.Done()
+	defer func() {
+		for _, hook := range n.webhooks {
+			close(hook.queue)
+		}
+	}()
+
+	for {
+		select {
+		case event := <-n.queue:
+			n.fanOut(event)
+		case <-n.done:
+			for {
+				select {
+				case event := <-n.queue:
+					n.fanOut(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (n *Notifier) fanOut(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.Warnw("Failed to encode event", "type", event.Type, "error", err)
+		return
+	}
+
+	if n.channel != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := n.redis.Publish(ctx, n.channel, body).Err()
+		cancel()
+		if err != nil {
+			metrics.EventDeliveries.WithLabelValues("redis", "failed").Inc()
+			n.logger.Warnw("Failed to publish event", "type", event.Type, "channel", n.channel, "error", err)
+		} else {
+			metrics.EventDeliveries.WithLabelValues("redis", "delivered").Inc()
+		}
+	}
+
+	for _, hook := range n.webhooks {
+		if len(hook.events) > 0 && !hook.events[event.Type] {
+			continue
+		}
+		select {
+		case hook.queue <- event:
+		default:
+			metrics.EventDeliveries.WithLabelValues("webhook", "dropped").Inc()
+		}
+	}
+}
+
+// deliver posts events to a webhook in order, retrying failed deliveries
+// with exponential backoff. Once the notifier is closed remaining events
+// get a single attempt.
+func (n *Notifier) deliver(hook *webhook) {
+	defer n.wg.Done()
+
+	for event := range hook.queue {
+		body, _ := json.Marshal(event)
+		backoff := n.retryBackoff
+		for attempt := 1; ; attempt++ {
+			retry, err := n.post(hook, event, body)
+			if err == nil {
+				metrics.EventDeliveries.WithLabelValues("webhook", "delivered").Inc()
+				break
+			}
+			if !retry || attempt >= n.maxAttempts || n.closed() {
+				metrics.EventDeliveries.WithLabelValues("webhook", "failed").Inc()
+				n.logger.Warnw("Failed to deliver event",
+					"type", event.Type, "id", event.ID, "url", hook.URL, "attempts", attempt, "error", err)
+				break
+			}
+
+			metrics.EventDeliveries.WithLabelValues("webhook", "retried").Inc()
+			select {
+			case <-time.After(backoff):
+			case <-n.done:
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// post sends one delivery attempt. It reports whether a failure is worth
+// retrying: network errors, 429 and server errors are.
+func (n *Notifier) post(hook *webhook, event Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+}
+
+func (n *Notifier) closed() bool {
+	select {
+	case <-n.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close sends the queued events and stops the notifier
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	close(n.done)
+	n.wg.Wait()
+}
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"api-gateway/internal/mailer"
+	"api-gateway/internal/models"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// VerifyEmail confirms the user's email address and activates accounts
+// that were waiting for verification
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := h.consumeUserToken(ctx, req.Token, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+
+	if err := h.users.VerifyEmail(ctx, userID); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to verify email", "user_id", userID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	event := auditEvent(c, models.AuditEmailVerified, userID.Hex())
+	event.ActorID = userID.Hex()
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
+}
+
+// ForgotPassword emails a password reset link. It responds the same way
+// whether or not the email belongs to an account so it can't be used to
+// discover registered addresses.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.users.FindUserByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.WithContext(c).Errorw("Failed to look up user", "error", err)
+	}
+	if err == nil && user.Active {
+		token, err := h.issueUserToken(ctx, user.ID, models.TokenPurposeResetPassword, h.config.Email.ResetTTL)
+		if err != nil {
+			h.logger.WithContext(c).Errorw("Failed to issue password reset token", "user_id", user.ID.Hex(), "error", err)
+		} else {
+			h.sendMail(mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %s.\n\n%s\n\nIf you didn't ask for a password reset you can ignore this email.\n",
+					user.Username, h.config.Email.ResetTTL, tokenLink(h.config.Email.ResetURL, token)),
+			})
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "If the email belongs to an account, a reset link has been sent", nil)
+}
+
+// ResetPassword sets a new password and signs the user out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := h.consumeUserToken(ctx, req.Token, models.TokenPurposeResetPassword)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to hash password", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	// The reset link reached the user's inbox, which also proves the address
+	password, verified := string(hashedPassword), true
+	err = h.users.UpdateUser(ctx, userID, store.UserUpdate{Password: &password, EmailVerified: &verified})
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to reset password", "user_id", userID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if err := h.revocation.RevokeUser(ctx, userID.Hex()); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to revoke access tokens", "user_id", userID.Hex(), "error", err)
+	}
+	if err := h.users.RevokeRefreshTokens(ctx, userID); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to revoke refresh tokens", "user_id", userID.Hex(), "error", err)
+	}
+
+	event := auditEvent(c, models.AuditPasswordReset, userID.Hex())
+	event.ActorID = userID.Hex()
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Password reset successfully", nil)
+}
+
+// sendVerificationEmail emails the user a link to confirm their address
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := h.issueUserToken(ctx, user.ID, models.TokenPurposeVerifyEmail, h.config.Email.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address using the link below. It expires in %s.\n\n%s\n",
+			user.Username, h.config.Email.VerificationTTL, tokenLink(h.config.Email.VerifyURL, token)),
+	})
+	return nil
+}
+
+// sendMail delivers in the background so slow mail servers don't hold up
+// the request or reveal whether an account exists
+func (h *AuthHandler) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := h.mailer.Send(ctx, msg); err != nil {
+			h.logger.Errorw("Failed to send email", "subject", msg.Subject, "error", err)
+		}
+	}()
+}
+
+func (h *AuthHandler) issueUserToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = h.users.CreateUserToken(ctx, &models.UserToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken atomically marks an unexpired token as used and returns
+// its owner
+func (h *AuthHandler) consumeUserToken(ctx context.Context, token, purpose string) (primitive.ObjectID, error) {
+	stored, err := h.users.ConsumeUserToken(ctx, utils.HashToken(token), purpose)
+	if err != nil {
+		return primitive.NilObjectID, errInvalidUserToken
+	}
+	return stored.UserID, nil
+}
+
+func tokenLink(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
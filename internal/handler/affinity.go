@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// affinityKey extracts the value consistent hashing pins requests by.
+// An empty key falls back to round-robin.
+func affinityKey(c *gin.Context, hashKey string) string {
+	switch {
+	case hashKey == "":
+		return ""
+	case hashKey == "jwt_sub":
+		return c.GetString("user_id")
+	case hashKey == "client_ip":
+		return c.ClientIP()
+	case strings.HasPrefix(hashKey, "header:"):
+		return c.GetHeader(strings.TrimPrefix(hashKey, "header:"))
+	case strings.HasPrefix(hashKey, "cookie:"):
+		value, _ := c.Cookie(strings.TrimPrefix(hashKey, "cookie:"))
+		return value
+	default:
+		return ""
+	}
+}
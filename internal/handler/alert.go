@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/alerting"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertHandler struct {
+	evaluator *alerting.Evaluator
+	logger    *logger.Logger
+}
+
+func NewAlertHandler(evaluator *alerting.Evaluator, log *logger.Logger) *AlertHandler {
+	return &AlertHandler{
+		evaluator: evaluator,
+		logger:    log,
+	}
+}
+
+// List returns the pending and firing alerts of this instance
+func (h *AlertHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Alerts retrieved successfully", h.evaluator.Alerts())
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/analytics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyticsHandler serves the usage reports. They are only available with
+// the mongo analytics sink.
+type AnalyticsHandler struct {
+	store  *analytics.Store
+	logger *logger.Logger
+}
+
+func NewAnalyticsHandler(store *analytics.Store, log *logger.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		store:  store,
+		logger: log,
+	}
+}
+
+// Consumers lists the consumers with the most requests
+func (h *AnalyticsHandler) Consumers(c *gin.Context) {
+	h.report(c, "Top consumers retrieved successfully", func(ctx context.Context, q analytics.Query) (interface{}, error) {
+		return h.store.TopConsumers(ctx, q)
+	})
+}
+
+// Routes lists the routes with the highest error rates
+func (h *AnalyticsHandler) Routes(c *gin.Context) {
+	h.report(c, "Route error rates retrieved successfully", func(ctx context.Context, q analytics.Query) (interface{}, error) {
+		return h.store.RouteErrorRates(ctx, q)
+	})
+}
+
+// Traffic lists the traffic per interval, e.g. ?interval=15m
+func (h *AnalyticsHandler) Traffic(c *gin.Context) {
+	h.report(c, "Traffic retrieved successfully", func(ctx context.Context, q analytics.Query) (interface{}, error) {
+		return h.store.Traffic(ctx, q)
+	})
+}
+
+func (h *AnalyticsHandler) report(c *gin.Context, message string, run func(context.Context, analytics.Query) (interface{}, error)) {
+	if h.store == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Analytics reports require ANALYTICS_ENABLED and the mongo sink")
+		return
+	}
+
+	var query analytics.Query
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := run(ctx, query)
+	if errors.Is(err, analytics.ErrTooManyWindows) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "The time range holds too many intervals, use a longer interval")
+		return
+	}
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to build analytics report", "path", c.FullPath(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build analytics report")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, message, result)
+}
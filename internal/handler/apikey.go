@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKeyHandler struct {
+	store  *service.APIKeyStore
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewAPIKeyHandler(store *service.APIKeyStore, audit *service.AuditLog, log *logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		store:  store,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, plaintext, err := h.store.Create(ctx, req)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to create API key", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("API key created", "key_id", key.ID.Hex(), "name", key.Name)
+
+	event := auditEvent(c, models.AuditAPIKeyCreated, key.ID.Hex())
+	event.Details = map[string]interface{}{"name": key.Name, "scopes": key.Scopes}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusCreated, "API key created successfully", gin.H{
+		"key":     plaintext,
+		"api_key": key,
+	})
+}
+
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	keys, err := h.store.List(ctx)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to list API keys", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, plaintext, err := h.store.Rotate(ctx, objID)
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to rotate API key", "key_id", objID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to rotate API key")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("API key rotated", "key_id", key.ID.Hex())
+	h.audit.Record(auditEvent(c, models.AuditAPIKeyRotated, key.ID.Hex()))
+
+	utils.SuccessResponse(c, http.StatusOK, "API key rotated successfully", gin.H{
+		"key":     plaintext,
+		"api_key": key,
+	})
+}
+
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.store.Revoke(ctx, objID); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to revoke API key", "key_id", objID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("API key revoked", "key_id", objID.Hex())
+	h.audit.Record(auditEvent(c, models.AuditAPIKeyRevoked, objID.Hex()))
+	utils.SuccessResponse(c, http.StatusOK, "API key revoked successfully", nil)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewAuditHandler(audit *service.AuditLog, log *logger.Logger) *AuditHandler {
+	return &AuditHandler{
+		audit:  audit,
+		logger: log,
+	}
+}
+
+// Query lists audit events filtered by user, action and time range
+func (h *AuditHandler) Query(c *gin.Context) {
+	var query models.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := h.audit.Query(ctx, query)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to query audit events", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to query audit events")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Audit events retrieved successfully", events)
+}
+
+// auditEvent starts an event for the current request, attributed to the
+// authenticated caller if there is one
+func auditEvent(c *gin.Context, action, target string) models.AuditEvent {
+	return models.AuditEvent{
+		Action:    action,
+		Success:   true,
+		ActorID:   c.GetString("user_id"),
+		Actor:     c.GetString("username"),
+		Target:    target,
+		ClientIP:  c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+}
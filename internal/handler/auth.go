@@ -2,32 +2,46 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"api-gateway/internal/config"
+	"api-gateway/internal/mailer"
 	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/internal/store"
 	"api-gateway/pkg/logger"
-	"api-gateway/pkg/storage"
 	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	mongo  *storage.MongoClient
-	config *config.Config
-	logger *logger.Logger
+	users      store.UserStore
+	revocation *service.TokenRevocation
+	sessions   *service.SessionStore
+	keys       *service.SigningKeys
+	roles      *service.RoleStore
+	audit      *service.AuditLog
+	mailer     mailer.Mailer
+	config     *config.Config
+	logger     *logger.Logger
 }
 
-func NewAuthHandler(mongo *storage.MongoClient, cfg *config.Config, log *logger.Logger) *AuthHandler {
+func NewAuthHandler(users store.UserStore, revocation *service.TokenRevocation, sessions *service.SessionStore, keys *service.SigningKeys, roles *service.RoleStore, audit *service.AuditLog, mail mailer.Mailer, cfg *config.Config, log *logger.Logger) *AuthHandler {
 	return &AuthHandler{
-		mongo:  mongo,
-		config: cfg,
-		logger: log,
+		users:      users,
+		revocation: revocation,
+		sessions:   sessions,
+		keys:       keys,
+		roles:      roles,
+		audit:      audit,
+		mailer:     mail,
+		config:     cfg,
+		logger:     log,
 	}
 }
 
@@ -39,20 +53,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	collection := h.mongo.Database.Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Check if user already exists
-	var existingUser models.User
-	err := collection.FindOne(ctx, bson.M{
-		"$or": []bson.M{
-			{"username": req.Username},
-			{"email": req.Email},
-		},
-	}).Decode(&existingUser)
-
-	if err == nil {
+	exists, err := h.users.UserExists(ctx, req.Username, req.Email, primitive.NilObjectID)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to look up user", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+	if exists {
 		utils.ErrorResponse(c, http.StatusConflict, "Username or email already exists")
 		return
 	}
@@ -60,7 +71,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		h.logger.Errorw("Failed to hash password", "error", err)
+		h.logger.WithContext(c).Errorw("Failed to hash password", "error", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
@@ -72,37 +83,69 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Email:     req.Email,
 		Password:  string(hashedPassword),
 		Role:      "user",
-		Active:    true,
+		Active:    !h.config.Email.RequireVerification,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	_, err = collection.InsertOne(ctx, user)
+	err = h.users.CreateUser(ctx, &user)
+	if errors.Is(err, store.ErrDuplicate) {
+		// Registered concurrently; the unique indexes caught it
+		utils.ErrorResponse(c, http.StatusConflict, "Username or email already exists")
+		return
+	}
 	if err != nil {
-		h.logger.Errorw("Failed to create user", "error", err)
+		h.logger.WithContext(c).Errorw("Failed to create user", "error", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
+	if err := h.sendVerificationEmail(ctx, &user); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to send verification email", "username", user.Username, "error", err)
+	}
+
+	event := auditEvent(c, models.AuditUserRegister, user.ID.Hex())
+	event.ActorID, event.Actor = user.ID.Hex(), user.Username
+	h.audit.Record(event)
+
+	userResponse := models.UserResponse{
+		ID:       user.ID.Hex(),
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}
+
+	// Accounts awaiting verification can't log in yet, so issue no tokens
+	if !user.Active {
+		h.logger.WithContext(c).Infow("User registered, awaiting email verification", "username", user.Username, "email", user.Email)
+		utils.SuccessResponse(c, http.StatusCreated, "User registered, check your email to verify your account", gin.H{
+			"user": userResponse,
+		})
+		return
+	}
+
 	// Generate JWT token
-	token, expiresAt, err := utils.GenerateToken(&user, h.config.JWT.Secret, h.config.JWT.Expiry)
+	token, expiresAt, err := h.issueAccessToken(ctx, &user, nil, h.config.JWT.Expiry)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := h.issueRefreshToken(ctx, user.ID, nil)
 	if err != nil {
-		h.logger.Errorw("Failed to generate token", "error", err)
+		h.logger.WithContext(c).Errorw("Failed to issue refresh token", "error", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	h.logger.Infow("User registered successfully", "username", user.Username, "email", user.Email)
+	h.logger.WithContext(c).Infow("User registered successfully", "username", user.Username, "email", user.Email)
 
 	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully", gin.H{
-		"token":      token,
-		"expires_at": expiresAt,
-		"user": models.UserResponse{
-			ID:       user.ID.Hex(),
-			Username: user.Username,
-			Email:    user.Email,
-			Role:     user.Role,
-		},
+		"token":         token,
+		"expires_at":    expiresAt,
+		"refresh_token": refreshToken,
+		"user":          userResponse,
 	})
 }
 
@@ -113,44 +156,90 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		utils.ValidationErrorResponse(c, err)
 		return
 	}
+	if req.Mode == "session" && !h.config.Session.Enabled {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Session login is not enabled")
+		return
+	}
 
-	collection := h.mongo.Database.Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	failed := func(reason string) {
+		event := auditEvent(c, models.AuditLoginFailed, "")
+		event.Success = false
+		event.Actor = req.Username
+		event.Details = map[string]interface{}{"reason": reason}
+		h.audit.Record(event)
+	}
+
 	// Find user
-	var user models.User
-	err := collection.FindOne(ctx, bson.M{"username": req.Username}).Decode(&user)
+	user, err := h.users.FindUserByUsername(ctx, req.Username)
 	if err != nil {
+		failed("unknown_user")
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	// Check if user is active
+	if !user.Active && !user.EmailVerified && h.config.Email.RequireVerification {
+		failed("email_unverified")
+		utils.ErrorResponse(c, http.StatusForbidden, "Email address has not been verified")
+		return
+	}
 	if !user.Active {
+		failed("inactive")
 		utils.ErrorResponse(c, http.StatusForbidden, "Account is inactive")
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		failed("invalid_password")
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
+	if req.Mode == "session" {
+		session, err := h.startSession(c, ctx, user, req.Scopes)
+		if err != nil {
+			h.logger.WithContext(c).Errorw("Failed to start session", "error", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start session")
+			return
+		}
+		h.loggedIn(c, user)
+		utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
+			"csrf_token": session.CSRFToken,
+			"expires_at": session.ExpiresAt,
+			"user": models.UserResponse{
+				ID:       user.ID.Hex(),
+				Username: user.Username,
+				Email:    user.Email,
+				Role:     user.Role,
+			},
+		})
+		return
+	}
+
 	// Generate JWT token
-	token, expiresAt, err := utils.GenerateToken(&user, h.config.JWT.Secret, h.config.JWT.Expiry)
+	token, expiresAt, err := h.issueAccessToken(ctx, user, req.Scopes, h.config.JWT.Expiry)
 	if err != nil {
-		h.logger.Errorw("Failed to generate token", "error", err)
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	h.logger.Infow("User logged in successfully", "username", user.Username)
+	refreshToken, err := h.issueRefreshToken(ctx, user.ID, req.Scopes)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to issue refresh token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
 
+	h.loggedIn(c, user)
 	utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
-		"token":      token,
-		"expires_at": expiresAt,
+		"token":         token,
+		"expires_at":    expiresAt,
+		"refresh_token": refreshToken,
 		"user": models.UserResponse{
 			ID:       user.ID.Hex(),
 			Username: user.Username,
@@ -160,31 +249,36 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
+// RefreshToken exchanges a refresh token for a new access token. Refresh
+// tokens are single use: each call revokes the presented token and issues a
+// new one. Presenting an already revoked token revokes every refresh token
+// of that user, since it indicates the token was stolen.
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	var req struct {
-		Token string `json:"token" binding:"required"`
-	}
+	var req models.RefreshRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationErrorResponse(c, err)
 		return
 	}
 
-	// Validate old token
-	claims, err := utils.ValidateToken(req.Token, h.config.JWT.Secret)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Atomically consume the token so concurrent refreshes can't both succeed
+	stored, err := h.users.ConsumeRefreshToken(ctx, utils.HashToken(req.RefreshToken))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+		h.revokeOnReuse(ctx, req.RefreshToken)
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
-	// Get user from database
-	collection := h.mongo.Database.Collection("users")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if time.Now().After(stored.ExpiresAt) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Refresh token expired")
+		return
+	}
 
-	objID, _ := primitive.ObjectIDFromHex(claims.UserID)
-	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	// Get user from database
+	user, err := h.users.FindUser(ctx, stored.UserID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
 		return
@@ -195,17 +289,157 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Generate new token
-	newToken, expiresAt, err := utils.GenerateToken(&user, h.config.JWT.Secret, h.config.JWT.Expiry)
+	// Generate new token pair
+	// Rotated tokens keep the scopes of the original login
+	newToken, expiresAt, err := h.issueAccessToken(ctx, user, stored.Scopes, h.config.JWT.Expiry)
 	if err != nil {
-		h.logger.Errorw("Failed to generate token", "error", err)
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := h.issueRefreshToken(ctx, user.ID, stored.Scopes)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to issue refresh token", "error", err)
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Token refreshed successfully", gin.H{
-		"token":      newToken,
+		"token":         newToken,
+		"expires_at":    expiresAt,
+		"refresh_token": refreshToken,
+	})
+}
+
+// Logout revokes the given refresh token. Already issued access tokens stay
+// valid until they expire. Requests with a session cookie end the session
+// instead.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if h.endSession(c, ctx) {
+		return
+	}
+
+	var req models.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := h.users.RevokeRefreshToken(ctx, utils.HashToken(req.RefreshToken)); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to revoke refresh token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to logout")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Logged out successfully", nil)
+}
+
+// issueAccessToken signs a JWT embedding the permissions of the user's role
+// and, if any, the scopes the token is restricted to
+func (h *AuthHandler) issueAccessToken(ctx context.Context, user *models.User, scopes []string, expiry time.Duration) (string, time.Time, error) {
+	permissions, err := h.roles.Permissions(ctx, user.Role)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	grant := utils.TokenGrant{Permissions: permissions, Scopes: scopes}
+	return utils.GenerateToken(user, grant, h.keys.Current(), h.keys.Policy(), expiry)
+}
+
+func (h *AuthHandler) issueRefreshToken(ctx context.Context, userID primitive.ObjectID, scopes []string) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = h.users.CreateRefreshToken(ctx, &models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(h.config.JWT.RefreshExpiry),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// revokeOnReuse revokes every refresh token of the owner when a previously
+// rotated token is presented again
+func (h *AuthHandler) revokeOnReuse(ctx context.Context, token string) {
+	stored, err := h.users.FindRefreshToken(ctx, utils.HashToken(token))
+	if err != nil {
+		return
+	}
+
+	if err := h.users.RevokeRefreshTokens(ctx, stored.UserID); err != nil {
+		h.logger.Errorw("Failed to revoke refresh tokens", "user_id", stored.UserID.Hex(), "error", err)
+		return
+	}
+
+	h.logger.Warnw("Refresh token reuse detected, revoked all sessions", "user_id", stored.UserID.Hex())
+}
+
+// ScopedToken mints a short-lived access token restricted to a subset of
+// scopes, e.g. a read-only token for a reporting job. A scoped caller can
+// only narrow its own scopes further.
+func (h *AuthHandler) ScopedToken(c *gin.Context) {
+	var req models.ScopedTokenRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	claims := c.MustGet("claims").(*utils.Claims)
+	if current := claims.Scopes(); len(current) > 0 {
+		for _, scope := range req.Scopes {
+			if !service.HasPermission(current, scope) {
+				utils.ErrorResponse(c, http.StatusForbidden, "Requested scope exceeds the current token: "+scope)
+				return
+			}
+		}
+	}
+
+	objID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "Scoped tokens can only be issued for gateway users")
+		return
+	}
+
+	expiry := h.config.JWT.Expiry
+	if requested := time.Duration(req.ExpiresIn) * time.Second; requested > 0 && requested < expiry {
+		expiry = requested
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.users.FindUser(ctx, objID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	token, expiresAt, err := h.issueAccessToken(ctx, user, req.Scopes, expiry)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Scoped token issued successfully", gin.H{
+		"token":      token,
 		"expires_at": expiresAt,
+		"scopes":     req.Scopes,
 	})
 }
 
@@ -218,12 +452,10 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	collection := h.mongo.Database.Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	user, err := h.users.FindUser(ctx, objID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
 		return
@@ -236,3 +468,26 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		Role:     user.Role,
 	})
 }
+
+// RevokeUserTokens invalidates every access and refresh token issued to a
+// user and ends their sessions
+func (h *AuthHandler) RevokeUserTokens(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.signOutUser(ctx, objID); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to revoke tokens", "user_id", objID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke tokens")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Revoked all tokens for user", "user_id", objID.Hex())
+	h.audit.Record(auditEvent(c, models.AuditTokensRevoked, objID.Hex()))
+	utils.SuccessResponse(c, http.StatusOK, "Tokens revoked successfully", nil)
+}
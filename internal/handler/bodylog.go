@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLogHandler switches request and response body capture at runtime
+type BodyLogHandler struct {
+	bodies *service.BodyLogger
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewBodyLogHandler(bodies *service.BodyLogger, audit *service.AuditLog, log *logger.Logger) *BodyLogHandler {
+	return &BodyLogHandler{
+		bodies: bodies,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *BodyLogHandler) Settings(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Body logging settings retrieved successfully", h.bodies.Settings())
+}
+
+// Update switches capture for one route, or globally when no route is
+// given. Route switches last until the next config reload.
+func (h *BodyLogHandler) Update(c *gin.Context) {
+	var req struct {
+		Route      string   `json:"route"`
+		Enabled    *bool    `json:"enabled" binding:"required"`
+		SampleRate *float64 `json:"sample_rate" binding:"omitempty,min=0,max=1"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	settings := h.bodies.Update(req.Route, *req.Enabled, req.SampleRate)
+
+	event := auditEvent(c, models.AuditBodyLogUpdated, req.Route)
+	event.Details = map[string]interface{}{"enabled": *req.Enabled, "sample_rate": settings.SampleRate}
+	h.audit.Record(event)
+
+	h.logger.WithContext(c).Infow("Body logging updated", "route", req.Route, "enabled", *req.Enabled, "sample_rate", settings.SampleRate)
+	utils.SuccessResponse(c, http.StatusOK, "Body logging updated successfully", settings)
+}
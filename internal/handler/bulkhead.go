@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+)
+
+var (
+	errBulkheadFull    = errors.New("bulkhead queue is full")
+	errBulkheadTimeout = errors.New("timed out waiting for a bulkhead slot")
+)
+
+// bulkheads keeps one concurrency limiter per service, rebuilt when the
+// service is re-registered with different limits
+type bulkheads struct {
+	defaults config.BulkheadConfig
+
+	mu       sync.Mutex
+	services map[string]*bulkhead
+}
+
+type bulkhead struct {
+	config config.BulkheadConfig
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func newBulkheads(defaults config.BulkheadConfig) *bulkheads {
+	return &bulkheads{
+		defaults: defaults,
+		services: make(map[string]*bulkhead),
+	}
+}
+
+// acquire takes a slot for svc, queueing if all are taken. The returned
+// release must be called once the request is done.
+func (b *bulkheads) acquire(ctx context.Context, svc *service.Service) (func(), error) {
+	bh := b.get(svc)
+	if bh == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case bh.slots <- struct{}{}:
+		return bh.holding(svc.Name), nil
+	default:
+	}
+
+	bh.mu.Lock()
+	if bh.waiting >= bh.config.MaxQueue {
+		bh.mu.Unlock()
+		metrics.BulkheadRejections.WithLabelValues(svc.Name, "queue_full").Inc()
+		return nil, errBulkheadFull
+	}
+	bh.waiting++
+	bh.mu.Unlock()
+
+	metrics.BulkheadQueued.WithLabelValues(svc.Name).Inc()
+	defer func() {
+		metrics.BulkheadQueued.WithLabelValues(svc.Name).Dec()
+		bh.mu.Lock()
+		bh.waiting--
+		bh.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(bh.config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case bh.slots <- struct{}{}:
+		return bh.holding(svc.Name), nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	metrics.BulkheadRejections.WithLabelValues(svc.Name, "queue_timeout").Inc()
+	return nil, errBulkheadTimeout
+}
+
+func (b *bulkheads) get(svc *service.Service) *bulkhead {
+	cfg := b.defaults
+	if svc.Bulkhead != nil {
+		cfg = *svc.Bulkhead
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cfg.MaxConcurrent <= 0 {
+		delete(b.services, svc.Name)
+		return nil
+	}
+	if bh, ok := b.services[svc.Name]; ok && bh.config == cfg {
+		return bh
+	}
+
+	// Requests holding slots in a replaced bulkhead release them there
+	bh := &bulkhead{config: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+	b.services[svc.Name] = bh
+	return bh
+}
+
+func (bh *bulkhead) holding(serviceName string) func() {
+	metrics.BulkheadActive.WithLabelValues(serviceName).Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-bh.slots
+			metrics.BulkheadActive.WithLabelValues(serviceName).Dec()
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"api-gateway/internal/circuit"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CircuitBreakerHandler struct {
+	breakers *circuit.BreakerManager
+	logger   *logger.Logger
+}
+
+func NewCircuitBreakerHandler(bm *circuit.BreakerManager, log *logger.Logger) *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{
+		breakers: bm,
+		logger:   log,
+	}
+}
+
+func (h *CircuitBreakerHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Circuit breakers retrieved successfully", h.breakers.List())
+}
+
+func (h *CircuitBreakerHandler) ForceOpen(c *gin.Context) {
+	h.force(c, circuit.ForceOpen)
+}
+
+func (h *CircuitBreakerHandler) ForceClose(c *gin.Context) {
+	h.force(c, circuit.ForceClosed)
+}
+
+func (h *CircuitBreakerHandler) Reset(c *gin.Context) {
+	service := c.Param("service")
+
+	if err := h.breakers.Reset(service); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Circuit breaker reset", "service", service, "by", c.GetString("user_id"))
+	utils.SuccessResponse(c, http.StatusOK, "Circuit breaker reset successfully", h.breakers.GetBreaker(service).Status())
+}
+
+func (h *CircuitBreakerHandler) force(c *gin.Context, mode string) {
+	service := c.Param("service")
+
+	if err := h.breakers.Force(service, mode); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Circuit breaker forced", "service", service, "state", mode, "by", c.GetString("user_id"))
+	utils.SuccessResponse(c, http.StatusOK, "Circuit breaker updated successfully", h.breakers.GetBreaker(service).Status())
+}
+
+func (h *CircuitBreakerHandler) respondError(c *gin.Context, err error) {
+	if errors.Is(err, circuit.ErrBreakerNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Circuit breaker not found")
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update circuit breaker")
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/internal/transform"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// maxCompositeResponseBytes caps how much of each backend response is read
+const maxCompositeResponseBytes = 10 << 20
+
+// CompositeErrorsHeader lists the optional calls left out of a response
+const CompositeErrorsHeader = "X-Composite-Errors"
+
+// compositeCall is a call prepared from the request before the fan-out, so
+// the goroutines don't touch the gin context
+type compositeCall struct {
+	config.CompositeCall
+	service string
+	path    string
+	query   string
+}
+
+type compositeResult struct {
+	value interface{}
+	err   error
+}
+
+// Composite returns a handler that sends the route's calls in parallel and
+// responds with their merged JSON
+func (p *ProxyHandler) Composite(route config.RouteConfig) gin.HandlerFunc {
+	comp := route.Composite
+
+	return func(c *gin.Context) {
+		// As with proxied requests the calls end with the route's deadline
+		// or when the client goes away
+		ctx := c.Request.Context()
+		if comp.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, comp.Timeout)
+			defer cancel()
+		}
+
+		lookup := templateLookup(c)
+		header := compositeHeader(c)
+		calls := make([]compositeCall, len(comp.Calls))
+		for i, call := range comp.Calls {
+			path, query, _ := strings.Cut(transform.Expand(call.Path, lookup), "?")
+			calls[i] = compositeCall{
+				CompositeCall: call,
+				service:       tenantService(c, call.Service),
+				path:          path,
+				query:         query,
+			}
+		}
+
+		results := make([]compositeResult, len(calls))
+		var wg sync.WaitGroup
+		for i, call := range calls {
+			wg.Add(1)
+			go func(i int, call compositeCall) {
+				defer wg.Done()
+				value, err := p.compositeFetch(ctx, call, header)
+				results[i] = compositeResult{value: value, err: err}
+			}(i, call)
+		}
+		wg.Wait()
+
+		merged := make(map[string]interface{})
+		var skipped []string
+		for i, call := range calls {
+			value, err := results[i].value, results[i].err
+			if err == nil && call.Select != "" {
+				var ok bool
+				if value, ok = selectField(value, call.Select); !ok {
+					err = fmt.Errorf("response has no field %q", call.Select)
+				}
+			}
+			if err == nil {
+				err = mergeField(merged, call.Into, call.Name, value)
+			}
+			if err == nil {
+				continue
+			}
+
+			p.logger.WithContext(c).Warnw("Composite call failed",
+				"route", route.Path,
+				"call", call.Name,
+				"service", call.service,
+				"error", err,
+			)
+			if !call.Optional {
+				if errors.Is(err, context.Canceled) {
+					c.Status(statusClientClosedRequest)
+					return
+				}
+				if errors.Is(err, context.DeadlineExceeded) {
+					utils.ErrorResponse(c, http.StatusGatewayTimeout, "Upstream request timed out")
+					return
+				}
+				utils.ErrorResponse(c, http.StatusBadGateway, "Upstream call "+call.Name+" failed")
+				return
+			}
+			skipped = append(skipped, call.Name)
+		}
+
+		if len(skipped) > 0 {
+			c.Header(CompositeErrorsHeader, strings.Join(skipped, ","))
+		}
+		c.JSON(http.StatusOK, merged)
+	}
+}
+
+// compositeHeader copies the client headers passed on to every call
+func compositeHeader(c *gin.Context) http.Header {
+	header := make(http.Header, len(c.Request.Header))
+	for key, values := range c.Request.Header {
+		// Encodings are left to the transport so responses arrive decoded
+		if isHopByHopHeader(key) || key == "Content-Length" || key == "Content-Type" || key == "Accept-Encoding" {
+			continue
+		}
+		header[key] = append([]string(nil), values...)
+	}
+
+	header.Set("Accept", "application/json")
+	header.Set("X-Forwarded-For", c.ClientIP())
+	header.Set("X-Forwarded-Proto", c.Request.Proto)
+	header.Set("X-Forwarded-Host", c.Request.Host)
+	if requestID := c.GetString("request_id"); requestID != "" {
+		header.Set("X-Request-ID", requestID)
+	}
+	otel.GetTextMapPropagator().Inject(c.Request.Context(), propagation.HeaderCarrier(header))
+	return header
+}
+
+// compositeFetch performs one call and decodes its JSON response
+func (p *ProxyHandler) compositeFetch(ctx context.Context, call compositeCall, header http.Header) (interface{}, error) {
+	svc, err := p.registry.Get(call.service)
+	if err != nil {
+		return nil, err
+	}
+	if svc.Protocol == service.ProtocolGRPC {
+		return nil, fmt.Errorf("service %s speaks gRPC", call.service)
+	}
+
+	release, err := p.bulkheads.acquire(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	targetURL, err := p.loadBalancer.Select(svc, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	fullURL, err := url.Parse(targetURL + call.path)
+	if err != nil {
+		return nil, err
+	}
+	fullURL.RawQuery = call.query
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+
+	resp, err := p.execute(svc, p.breakerManager.GetBreaker(call.service), targetURL, func() (*http.Response, error) {
+		return p.upstreams.client(svc).Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxCompositeResponseBytes))
+		return nil, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+
+	var value interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCompositeResponseBytes)).Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return value, nil
+}
+
+// selectField returns the value at a dotted path of a decoded JSON object
+func selectField(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// mergeField places value at the dotted path into, or name if into is
+// empty. "." merges the fields of an object value into merged itself.
+func mergeField(merged map[string]interface{}, into, name string, value interface{}) error {
+	if into == "." {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("only an object response can be merged at the top level")
+		}
+		for key, field := range obj {
+			merged[key] = field
+		}
+		return nil
+	}
+	if into == "" {
+		into = name
+	}
+
+	keys := strings.Split(into, ".")
+	obj := merged
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[keys[len(keys)-1]] = value
+	return nil
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigHandler shows the config the gateway is running with
+type ConfigHandler struct {
+	mu       sync.RWMutex
+	current  *config.Config
+	loadedAt time.Time
+}
+
+func NewConfigHandler(cfg *config.Config) *ConfigHandler {
+	h := &ConfigHandler{}
+	h.SetConfig(cfg)
+	return h
+}
+
+// SetConfig records the config a reload applied
+func (h *ConfigHandler) SetConfig(cfg *config.Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.current = cfg
+	h.loadedAt = time.Now()
+}
+
+// Get returns the effective config, after environment variables, the
+// config file and secrets were merged, with credentials masked
+func (h *ConfigHandler) Get(c *gin.Context) {
+	h.mu.RLock()
+	cfg, loadedAt := h.current, h.loadedAt
+	h.mu.RUnlock()
+
+	utils.SuccessResponse(c, http.StatusOK, "Configuration retrieved successfully", gin.H{
+		"config_file": config.FileUsed(),
+		"loaded_at":   loadedAt,
+		"config":      cfg.Dump(),
+	})
+}
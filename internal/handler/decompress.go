@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptedUpstreamEncodings is sent upstream on routes that decompress, so
+// services may still compress and the gateway decodes
+const acceptedUpstreamEncodings = "br, gzip, deflate"
+
+// decompressResponse replaces an encoded upstream body with its plain
+// payload so the gateway can work on it. Unknown encodings are left alone.
+// The compression middleware re-encodes the response for the client.
+func decompressResponse(resp *http.Response) error {
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(resp.Body)
+	case "br":
+		reader = brotli.NewReader(resp.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(resp.Body)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	// Ranges would be of the encoded body
+	resp.Header.Del("Accept-Ranges")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
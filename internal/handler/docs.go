@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/openapi"
+	"api-gateway/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIScript points Swagger UI at the merged document
+const swaggerUIScript = `window.ui = SwaggerUIBundle({ url: "/api/v1/docs/openapi.json", dom_id: "#swagger-ui" });`
+
+// swaggerUIPage loads Swagger UI from a CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>API Documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>` + swaggerUIScript + `</script>
+</body>
+</html>
+`
+
+// swaggerUIPolicy relaxes the gateway's default CSP just enough for the
+// CDN assets and the inline script, which is allowed by its hash
+var swaggerUIPolicy = func() string {
+	sum := sha256.Sum256([]byte(swaggerUIScript))
+	return "default-src 'self'; img-src 'self' data:; style-src 'self' https://unpkg.com; " +
+		"script-src https://unpkg.com 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+type DocsHandler struct {
+	specs  *service.OpenAPISpecs
+	config config.DocsConfig
+}
+
+func NewDocsHandler(specs *service.OpenAPISpecs, cfg config.DocsConfig) *DocsHandler {
+	return &DocsHandler{
+		specs:  specs,
+		config: cfg,
+	}
+}
+
+// OpenAPI returns a handler serving the merged document for a route table.
+// gateway holds the gateway's own endpoints, routes the proxied ones.
+func (h *DocsHandler) OpenAPI(gateway gin.RoutesInfo, routes []config.RouteConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		doc := openapi.Merge(h.config.Title, "1.0.0", gateway, routes, h.specs.All(ctx))
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+func (h *DocsHandler) SwaggerUI(c *gin.Context) {
+	c.Header("Content-Security-Policy", swaggerUIPolicy)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DrainHandler struct {
+	drainer *service.Drainer
+	logger  *logger.Logger
+}
+
+func NewDrainHandler(drainer *service.Drainer, log *logger.Logger) *DrainHandler {
+	return &DrainHandler{
+		drainer: drainer,
+		logger:  log,
+	}
+}
+
+// Drain puts the gateway into drain mode: /ready starts failing so load
+// balancers stop sending traffic while in-flight requests complete
+func (h *DrainHandler) Drain(c *gin.Context) {
+	if h.drainer.Begin() {
+		h.logger.WithContext(c).Infow("Drain mode enabled", "in_flight", h.drainer.InFlight())
+	}
+	h.Status(c)
+}
+
+func (h *DrainHandler) Status(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Drain status retrieved successfully", gin.H{
+		"draining":  h.drainer.Draining(),
+		"in_flight": h.drainer.InFlight(),
+	})
+}
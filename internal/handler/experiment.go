@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExperimentHandler struct {
+	experiments *service.Experiments
+	audit       *service.AuditLog
+	logger      *logger.Logger
+}
+
+func NewExperimentHandler(experiments *service.Experiments, audit *service.AuditLog, log *logger.Logger) *ExperimentHandler {
+	return &ExperimentHandler{
+		experiments: experiments,
+		audit:       audit,
+		logger:      log,
+	}
+}
+
+func (h *ExperimentHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Experiments retrieved successfully", h.experiments.List())
+}
+
+// Put starts or changes a runtime experiment. It lasts until it is
+// deleted or the gateway restarts.
+func (h *ExperimentHandler) Put(c *gin.Context) {
+	var req struct {
+		Routes   []string                   `json:"routes" binding:"required,min=1"`
+		Variants []config.ExperimentVariant `json:"variants" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	name := c.Param("name")
+	experiment, err := h.experiments.Put(config.ExperimentConfig{Name: name, Routes: req.Routes, Variants: req.Variants})
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrExperimentInConfig) {
+			status = http.StatusConflict
+		}
+		utils.ErrorResponse(c, status, err.Error())
+		return
+	}
+
+	event := auditEvent(c, models.AuditExperimentUpdated, name)
+	event.Details = map[string]interface{}{"routes": req.Routes, "variants": req.Variants}
+	h.audit.Record(event)
+
+	h.logger.WithContext(c).Infow("Experiment updated", "experiment", name, "routes", req.Routes)
+	utils.SuccessResponse(c, http.StatusOK, "Experiment updated successfully", experiment)
+}
+
+func (h *ExperimentHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.experiments.Delete(name); err != nil {
+		switch {
+		case errors.Is(err, service.ErrExperimentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Experiment not found")
+		case errors.Is(err, service.ErrExperimentInConfig):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete experiment")
+		}
+		return
+	}
+
+	h.audit.Record(auditEvent(c, models.AuditExperimentDeleted, name))
+
+	h.logger.WithContext(c).Infow("Experiment deleted", "experiment", name)
+	utils.SuccessResponse(c, http.StatusOK, "Experiment deleted successfully", nil)
+}
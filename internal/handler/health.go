@@ -2,24 +2,90 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"api-gateway/internal/circuit"
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/internal/store"
 	"api-gateway/pkg/storage"
 	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sony/gobreaker"
+)
+
+// Service health states reported by /health/services
+const (
+	serviceHealthy  = "healthy"
+	serviceDegraded = "degraded"
+	serviceDown     = "down"
+)
+
+// Gateway states reported by /ready
+const (
+	gatewayReady    = "ready"
+	gatewayDegraded = "degraded"
+	gatewayUnready  = "unready"
 )
 
 type HealthHandler struct {
-	redis *storage.RedisClient
-	mongo *storage.MongoClient
+	redis     *storage.RedisClient
+	store     store.Store
+	drainer   *service.Drainer
+	registry  *service.Registry
+	breakers  *circuit.BreakerManager
+	readiness config.ReadinessConfig
+
+	mu         sync.Mutex
+	lastErrors map[string]dependencyError
+}
+
+// dependencyHealth is a dependency's state in the /ready report. The last
+// error is kept after the dependency recovered.
+type dependencyHealth struct {
+	Status    string           `json:"status"`
+	Required  bool             `json:"required"`
+	LatencyMs float64          `json:"latency_ms"`
+	LastError *dependencyError `json:"last_error,omitempty"`
 }
 
-func NewHealthHandler(redis *storage.RedisClient, mongo *storage.MongoClient) *HealthHandler {
+type dependencyError struct {
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+type serviceHealth struct {
+	Name      string                   `json:"name"`
+	Status    string                   `json:"status"`
+	Active    bool                     `json:"active"`
+	Protocol  string                   `json:"protocol"`
+	Checked   bool                     `json:"health_checked"`
+	Instances []service.InstanceStatus `json:"instances"`
+	Breaker   serviceBreaker           `json:"circuit_breaker"`
+	LastError *service.UpstreamError   `json:"last_error,omitempty"`
+}
+
+type serviceBreaker struct {
+	State          string     `json:"state"`
+	Forced         string     `json:"forced,omitempty"`
+	LastTransition *time.Time `json:"last_transition,omitempty"`
+}
+
+func NewHealthHandler(redis *storage.RedisClient, st store.Store, drainer *service.Drainer, registry *service.Registry, breakers *circuit.BreakerManager, readiness config.ReadinessConfig) *HealthHandler {
 	return &HealthHandler{
-		redis: redis,
-		mongo: mongo,
+		redis:      redis,
+		store:      st,
+		drainer:    drainer,
+		registry:   registry,
+		breakers:   breakers,
+		readiness:  readiness,
+		lastErrors: make(map[string]dependencyError),
 	}
 }
 
@@ -31,23 +97,203 @@ func (h *HealthHandler) Health(c *gin.Context) {
 	})
 }
 
+// Readiness checks the gateway's dependencies. It responds with 503 when a
+// required one is down, and reports the gateway degraded when only others
+// are.
 func (h *HealthHandler) Readiness(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Check Redis
-	if err := h.redis.Ping(ctx).Err(); err != nil {
-		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Redis unavailable")
+	if h.drainer.Draining() {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Gateway is draining")
 		return
 	}
 
-	// Check MongoDB
-	if err := h.mongo.Ping(ctx, nil); err != nil {
-		utils.ErrorResponse(c, http.StatusServiceUnavailable, "MongoDB unavailable")
+	dependencies := h.checkDependencies()
+
+	status := gatewayReady
+	var unavailable []string
+	for name, dep := range dependencies {
+		if dep.Status != serviceDown {
+			continue
+		}
+		if dep.Required {
+			unavailable = append(unavailable, name)
+		} else {
+			status = gatewayDegraded
+		}
+	}
+
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		c.JSON(http.StatusServiceUnavailable, utils.Response{
+			Success: false,
+			Message: "Required dependencies unavailable: " + strings.Join(unavailable, ", "),
+			Data:    gin.H{"status": gatewayUnready, "dependencies": dependencies},
+		})
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Service is ready", gin.H{
-		"status": "ready",
+	message := "Service is ready"
+	if status == gatewayDegraded {
+		message = "Service is ready but degraded"
+	}
+	utils.SuccessResponse(c, http.StatusOK, message, gin.H{
+		"status":       status,
+		"dependencies": dependencies,
 	})
 }
+
+// checkDependencies runs the dependency checks concurrently, each bounded by
+// the readiness timeout
+func (h *HealthHandler) checkDependencies() map[string]dependencyHealth {
+	checks := map[string]func(ctx context.Context) error{
+		"redis": func(ctx context.Context) error {
+			return h.redis.Ping(ctx).Err()
+		},
+		"database": h.store.Ping,
+		"services": func(context.Context) error {
+			return h.servicesDown()
+		},
+	}
+
+	required := make(map[string]bool, len(h.readiness.Required))
+	for _, name := range h.readiness.Required {
+		required[name] = true
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	dependencies := make(map[string]dependencyHealth, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), h.readiness.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			dep := dependencyHealth{
+				Status:    serviceHealthy,
+				Required:  required[name],
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				LastError: h.recordDependencyError(name, err),
+			}
+			if err != nil {
+				dep.Status = serviceDown
+			}
+
+			mu.Lock()
+			dependencies[name] = dep
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return dependencies
+}
+
+// servicesDown fails when any active backend service is down
+func (h *HealthHandler) servicesDown() error {
+	var down []string
+	for _, svc := range h.registry.List() {
+		if svc.Active && describeService(svc, h.breakers).Status == serviceDown {
+			down = append(down, svc.Name)
+		}
+	}
+	if len(down) == 0 {
+		return nil
+	}
+	sort.Strings(down)
+	return fmt.Errorf("services down: %s", strings.Join(down, ", "))
+}
+
+// recordDependencyError remembers a failed check and returns the last
+// failure of the dependency, if any
+func (h *HealthHandler) recordDependencyError(name string, err error) *dependencyError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err != nil {
+		h.lastErrors[name] = dependencyError{Message: err.Error(), Time: time.Now()}
+	}
+	last, ok := h.lastErrors[name]
+	if !ok {
+		return nil
+	}
+	return &last
+}
+
+// Services reports the health of every registered backend. It responds
+// with 503 when any active service is down so uptime monitors can alert
+// on the status code alone.
+func (h *HealthHandler) Services(c *gin.Context) {
+	services := h.registry.List()
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	report := make([]serviceHealth, 0, len(services))
+	overall := serviceHealthy
+	for _, svc := range services {
+		health := describeService(svc, h.breakers)
+		report = append(report, health)
+
+		if !svc.Active {
+			continue
+		}
+		switch {
+		case health.Status == serviceDown:
+			overall = serviceDown
+		case health.Status == serviceDegraded && overall == serviceHealthy:
+			overall = serviceDegraded
+		}
+	}
+
+	data := gin.H{
+		"status":    overall,
+		"timestamp": time.Now().Unix(),
+		"services":  report,
+	}
+	if overall == serviceDown {
+		c.JSON(http.StatusServiceUnavailable, utils.Response{
+			Success: false,
+			Message: "One or more services are down",
+			Data:    data,
+		})
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Service health retrieved", data)
+}
+
+// describeService reports a service's instances, breaker and overall state
+func describeService(svc *service.Service, breakers *circuit.BreakerManager) serviceHealth {
+	health := serviceHealth{
+		Name:      svc.Name,
+		Active:    svc.Active,
+		Protocol:  svc.Protocol,
+		Checked:   svc.HealthURL != "",
+		Instances: svc.Instances(),
+		Breaker:   serviceBreaker{State: gobreaker.StateClosed.String()},
+		LastError: svc.LastError(),
+	}
+
+	// Breakers are created on a service's first request
+	if status, err := breakers.Status(svc.Name); err == nil {
+		lastTransition := status.LastTransition
+		health.Breaker = serviceBreaker{State: status.State, Forced: status.Forced, LastTransition: &lastTransition}
+	}
+
+	healthy := 0
+	for _, instance := range health.Instances {
+		if instance.Healthy {
+			healthy++
+		}
+	}
+
+	switch {
+	case healthy == 0 || health.Breaker.State == gobreaker.StateOpen.String():
+		health.Status = serviceDown
+	case healthy < len(health.Instances) || health.Breaker.State == gobreaker.StateHalfOpen.String():
+		health.Status = serviceDegraded
+	default:
+		health.Status = serviceHealthy
+	}
+	return health
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPAccessHandler manages IP allow and deny entries at runtime. Entries
+// from config are not listed and can't be changed here.
+type IPAccessHandler struct {
+	filter *service.IPFilter
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+type ipAccessEntryRequest struct {
+	Scope string `json:"scope" form:"scope"`
+	List  string `json:"list" form:"list" binding:"required,oneof=allow deny"`
+	Value string `json:"value" form:"value" binding:"required"`
+}
+
+func NewIPAccessHandler(filter *service.IPFilter, audit *service.AuditLog, log *logger.Logger) *IPAccessHandler {
+	return &IPAccessHandler{
+		filter: filter,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *IPAccessHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "IP access entries retrieved successfully", h.filter.Entries())
+}
+
+// Add puts an address, CIDR range or country code on a list. The scope is
+// a route path or empty for all requests.
+func (h *IPAccessHandler) Add(c *gin.Context) {
+	var req ipAccessEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	h.change(c, req, models.AuditIPAccessAdded, h.filter.AddEntry)
+}
+
+func (h *IPAccessHandler) Remove(c *gin.Context) {
+	var req ipAccessEntryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	h.change(c, req, models.AuditIPAccessRemoved, h.filter.RemoveEntry)
+}
+
+func (h *IPAccessHandler) change(c *gin.Context, req ipAccessEntryRequest, action string,
+	apply func(ctx context.Context, scope, list, value string) error) {
+	if req.Scope == "" {
+		req.Scope = service.GlobalScope
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := apply(ctx, req.Scope, req.List, req.Value); err != nil {
+		if errors.Is(err, service.ErrInvalidIPEntry) || errors.Is(err, service.ErrGeoIPDisabled) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to update IP access entries", "scope", req.Scope, "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to update IP access entries")
+		return
+	}
+
+	event := auditEvent(c, action, req.Scope)
+	event.Details = map[string]interface{}{"list": req.List, "value": req.Value}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "IP access entries updated successfully", h.filter.Entries()[req.Scope])
+}
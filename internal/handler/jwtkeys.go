@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWTKeyHandler struct {
+	keys   *service.SigningKeys
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewJWTKeyHandler(keys *service.SigningKeys, audit *service.AuditLog, log *logger.Logger) *JWTKeyHandler {
+	return &JWTKeyHandler{
+		keys:   keys,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *JWTKeyHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Signing keys retrieved successfully", h.keys.List())
+}
+
+// JWKS serves the keys still in use for downstream services verifying
+// gateway tokens themselves. They are HMAC secrets, hence admin only.
+func (h *JWTKeyHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// PublicJWKS serves the public keys of RS256 and EdDSA signing keys, so
+// backends can verify gateway tokens without sharing a secret. Pending
+// keys are included; scheduling rotations ahead of the cache lifetime
+// lets verifiers know a key before tokens carry its kid.
+func (h *JWTKeyHandler) PublicJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.PublicJWKS())
+}
+
+// Rotate creates the next signing key. Scheduling it ahead gives
+// downstream services time to fetch it before tokens carry its kid.
+func (h *JWTKeyHandler) Rotate(c *gin.Context) {
+	var req models.RotateJWTKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ValidationErrorResponse(c, err)
+			return
+		}
+	}
+
+	activatesAt := time.Now()
+	if req.ActivateAt != nil {
+		if req.ActivateAt.Before(activatesAt.Add(-time.Minute)) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "activate_at must not be in the past")
+			return
+		}
+		activatesAt = *req.ActivateAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, err := h.keys.Create(ctx, activatesAt)
+	if errors.Is(err, service.ErrSigningKeysNotStored) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Rotating signing keys requires the mongo storage backend")
+		return
+	}
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to create signing key", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create signing key")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Signing key created", "kid", key.ID, "activates_at", key.ActivatesAt)
+
+	event := auditEvent(c, models.AuditJWTKeyRotated, key.ID)
+	event.Details = map[string]interface{}{"activates_at": key.ActivatesAt}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusCreated, "Signing key created successfully", key)
+}
+
+// Delete removes a key before it expires, e.g. after it leaked. Tokens
+// signed with it are rejected at once.
+func (h *JWTKeyHandler) Delete(c *gin.Context) {
+	kid := c.Param("kid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.keys.Delete(ctx, kid); err != nil {
+		switch {
+		case errors.Is(err, service.ErrSigningKeyNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Signing key not found")
+		case errors.Is(err, service.ErrSigningKeyConfigured):
+			utils.ErrorResponse(c, http.StatusConflict, "Configured signing keys can only be replaced through JWT_SECRET")
+		case errors.Is(err, service.ErrSigningKeyInUse):
+			utils.ErrorResponse(c, http.StatusConflict, "The current signing key can't be deleted, rotate it first")
+		default:
+			h.logger.WithContext(c).Errorw("Failed to delete signing key", "kid", kid, "error", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete signing key")
+		}
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Signing key deleted", "kid", kid)
+	h.audit.Record(auditEvent(c, models.AuditJWTKeyDeleted, kid))
+	utils.SuccessResponse(c, http.StatusOK, "Signing key deleted successfully", nil)
+}
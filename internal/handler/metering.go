@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMeterExportRange bounds an export to about three months of hours
+const maxMeterExportRange = 93 * 24 * time.Hour
+
+type MeteringHandler struct {
+	meter  *service.Meter
+	logger *logger.Logger
+}
+
+func NewMeteringHandler(meter *service.Meter, log *logger.Logger) *MeteringHandler {
+	return &MeteringHandler{
+		meter:  meter,
+		logger: log,
+	}
+}
+
+// Export returns the hourly billable usage in a time range as JSON or,
+// with ?format=csv, as a CSV file for invoicing
+func (h *MeteringHandler) Export(c *gin.Context) {
+	if h.meter == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Metering is not enabled")
+		return
+	}
+
+	var query models.MeterExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if !query.From.Before(query.To) || query.To.Sub(query.From) > maxMeterExportRange {
+		utils.ErrorResponse(c, http.StatusBadRequest, "from must be before to and the range at most 93 days")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	usage, err := h.meter.Export(ctx, query)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to export metering usage", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export metering usage")
+		return
+	}
+
+	if query.Format != "csv" {
+		utils.SuccessResponse(c, http.StatusOK, "Metering usage exported successfully", usage)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="metering-`+query.From.UTC().Format("20060102T15")+`.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"hour", "api_key_id", "tenant_id", "requests", "units", "request_bytes", "response_bytes"})
+	for _, u := range usage {
+		w.Write([]string{
+			u.Hour.UTC().Format(time.RFC3339),
+			u.APIKeyID,
+			u.TenantID,
+			strconv.FormatInt(u.Requests, 10),
+			strconv.FormatInt(u.Units, 10),
+			strconv.FormatInt(u.RequestBytes, 10),
+			strconv.FormatInt(u.ResponseBytes, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.logger.WithContext(c).Warnw("Failed to write metering export", "error", err)
+	}
+}
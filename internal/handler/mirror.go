@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxMirrorBodyBytes caps how much of a request body is copied for the
+	// mirror. Requests with larger bodies are not mirrored.
+	maxMirrorBodyBytes = 1 << 20
+	// maxMirrorInflight bounds concurrent mirrored requests so a slow shadow
+	// service can't pile up goroutines; requests beyond it are dropped
+	maxMirrorInflight = 256
+	mirrorTimeout     = 30 * time.Second
+)
+
+var mirrorSlots = make(chan struct{}, maxMirrorInflight)
+
+// mirrorBody copies the request body as the primary upstream reads it, so
+// the primary path is never held up waiting for the mirror
+type mirrorBody struct {
+	io.ReadCloser
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	complete bool
+	overflow bool
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	if !b.overflow {
+		if b.buf.Len()+n > maxMirrorBodyBytes {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.complete = true
+	}
+	b.mu.Unlock()
+
+	return n, err
+}
+
+// bytes returns the body if it was read completely and fit the cap
+func (b *mirrorBody) bytes() ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.complete || b.overflow {
+		return nil, false
+	}
+	return append([]byte(nil), b.buf.Bytes()...), true
+}
+
+// prepareMirror samples the request for mirroring and starts copying its
+// body. The returned function sends the copy once the primary upstream has
+// responded; it is nil if the request isn't mirrored.
+func (p *ProxyHandler) prepareMirror(c *gin.Context, mirror *config.MirrorConfig) func(path string) {
+	if mirror == nil || rand.Float64() >= mirror.Rate {
+		return nil
+	}
+
+	var body *mirrorBody
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body = &mirrorBody{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+	}
+
+	return func(path string) {
+		var payload []byte
+		if body != nil {
+			var ok bool
+			if payload, ok = body.bytes(); !ok {
+				metrics.MirrorRequests.WithLabelValues(mirror.Service, "skipped").Inc()
+				return
+			}
+		}
+
+		// Everything is copied now because gin reuses the context once the
+		// handler returns
+		req := mirrorRequest{
+			method:    c.Request.Method,
+			path:      path,
+			query:     c.Request.URL.RawQuery,
+			header:    c.Request.Header.Clone(),
+			body:      payload,
+			clientIP:  c.ClientIP(),
+			host:      c.Request.Host,
+			requestID: c.GetString("request_id"),
+		}
+
+		select {
+		case mirrorSlots <- struct{}{}:
+		default:
+			metrics.MirrorRequests.WithLabelValues(mirror.Service, "dropped").Inc()
+			return
+		}
+		go func() {
+			defer func() { <-mirrorSlots }()
+			p.sendMirror(mirror.Service, req)
+		}()
+	}
+}
+
+type mirrorRequest struct {
+	method    string
+	path      string
+	query     string
+	header    http.Header
+	body      []byte
+	clientIP  string
+	host      string
+	requestID string
+}
+
+// sendMirror delivers a copy of the request to the shadow service and
+// discards the response
+func (p *ProxyHandler) sendMirror(serviceName string, m mirrorRequest) {
+	svc, err := p.registry.Get(serviceName)
+	if err != nil {
+		metrics.MirrorRequests.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	targetURL, err := p.loadBalancer.Select(svc, "", nil)
+	if err != nil {
+		metrics.MirrorRequests.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+
+	fullURL, err := url.Parse(targetURL + m.path)
+	if err != nil {
+		metrics.MirrorRequests.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	fullURL.RawQuery = m.query
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, m.method, fullURL.String(), bytes.NewReader(m.body))
+	if err != nil {
+		metrics.MirrorRequests.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	for key, values := range m.header {
+		if !isHopByHopHeader(key) {
+			req.Header[key] = values
+		}
+	}
+	req.Header.Set("X-Forwarded-For", m.clientIP)
+	req.Header.Set("X-Forwarded-Host", m.host)
+	req.Header.Set("X-Gateway-Mirror", "true")
+	if m.requestID != "" {
+		req.Header.Set("X-Request-ID", m.requestID)
+	}
+
+	resp, err := p.upstreams.client(svc).Do(req)
+	if err != nil {
+		p.logger.Debugw("Mirrored request failed", "service", serviceName, "error", err)
+		metrics.MirrorRequests.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	metrics.MirrorRequests.WithLabelValues(serviceName, "sent").Inc()
+}
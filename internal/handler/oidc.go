@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCHandler logs users in through external identity providers and issues
+// gateway tokens for them
+type OIDCHandler struct {
+	auth      *AuthHandler
+	providers *service.OIDCProviders
+	redis     *storage.RedisClient
+	logger    *logger.Logger
+}
+
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+}
+
+func NewOIDCHandler(auth *AuthHandler, providers *service.OIDCProviders, redis *storage.RedisClient, log *logger.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		auth:      auth,
+		providers: providers,
+		redis:     redis,
+		logger:    log,
+	}
+}
+
+func (h *OIDCHandler) Login(c *gin.Context) {
+	provider, err := h.providers.Get(c.Param("provider"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	state, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	verifier, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payload, _ := json.Marshal(oidcState{Provider: c.Param("provider"), Verifier: verifier})
+	if err := h.redis.Set(ctx, oidcStateKey(state), payload, oidcStateTTL).Err(); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to store OIDC state", "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to start login")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, verifier, h.redirectURI(c.Param("provider")))
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to build authorization URL", "provider", c.Param("provider"), "error", err)
+		utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	providerName := c.Param("provider")
+	provider, err := h.providers.Get(providerName)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	if errParam := c.Query("error"); errParam != "" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Login was not completed: "+errParam)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// States are single use so a captured callback URL can't be replayed
+	raw, err := h.redis.GetDel(ctx, oidcStateKey(c.Query("state"))).Bytes()
+	var state oidcState
+	if err != nil || json.Unmarshal(raw, &state) != nil || state.Provider != providerName {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+
+	accessToken, err := provider.Exchange(ctx, c.Query("code"), state.Verifier, h.redirectURI(providerName))
+	if err != nil {
+		h.logger.WithContext(c).Warnw("OIDC code exchange failed", "provider", providerName, "error", err)
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Failed to verify login with identity provider")
+		return
+	}
+
+	identity, err := provider.UserInfo(ctx, accessToken)
+	if err != nil {
+		h.logger.WithContext(c).Warnw("OIDC userinfo failed", "provider", providerName, "error", err)
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Failed to verify login with identity provider")
+		return
+	}
+
+	user, err := h.provisionUser(ctx, identity, provider.Role(identity))
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to provision user", "provider", providerName, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to provision user")
+		return
+	}
+
+	event := auditEvent(c, models.AuditLogin, user.ID.Hex())
+	event.ActorID, event.Actor = user.ID.Hex(), user.Username
+	event.Details = map[string]interface{}{"provider": providerName}
+
+	if !user.Active {
+		event.Action, event.Success = models.AuditLoginFailed, false
+		event.Details["reason"] = "inactive"
+		h.auth.audit.Record(event)
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is inactive")
+		return
+	}
+
+	token, expiresAt, err := h.auth.issueAccessToken(ctx, user, nil, h.auth.config.JWT.Expiry)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := h.auth.issueRefreshToken(ctx, user.ID, nil)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to issue refresh token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("User logged in via identity provider", "provider", providerName, "username", user.Username)
+	h.auth.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
+		"token":         token,
+		"expires_at":    expiresAt,
+		"refresh_token": refreshToken,
+		"user": models.UserResponse{
+			ID:       user.ID.Hex(),
+			Username: user.Username,
+			Email:    user.Email,
+			Role:     user.Role,
+		},
+	})
+}
+
+// provisionUser finds the account linked to the identity, links an existing
+// account with the same verified email, or creates a new one
+func (h *OIDCHandler) provisionUser(ctx context.Context, identity *service.Identity, role string) (*models.User, error) {
+	link := models.ExternalIdentity{Provider: identity.Provider, Subject: identity.Subject}
+
+	user, err := h.auth.users.FindUserByIdentity(ctx, link)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	// Only a verified email proves ownership of an existing account
+	if identity.Email != "" && identity.EmailVerified {
+		user, err = h.auth.users.LinkIdentity(ctx, identity.Email, link)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	username, err := h.uniqueUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	user = &models.User{
+		ID:            primitive.NewObjectID(),
+		Username:      username,
+		Email:         identity.Email,
+		Role:          role,
+		Active:        true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		EmailVerified: identity.EmailVerified,
+		Identities:    []models.ExternalIdentity{link},
+	}
+	if err := h.auth.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	h.logger.Infow("Provisioned user from identity provider", "provider", identity.Provider, "username", username)
+	return user, nil
+}
+
+func (h *OIDCHandler) uniqueUsername(ctx context.Context, identity *service.Identity) (string, error) {
+	base := identity.Username
+	if base == "" && identity.Email != "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	if base == "" {
+		base = identity.Provider + "-" + identity.Subject
+	}
+
+	candidate := base
+	for i := 1; i <= 20; i++ {
+		taken, err := h.auth.users.UserExists(ctx, candidate, "", primitive.NilObjectID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return identity.Provider + "-" + identity.Subject, nil
+}
+
+func (h *OIDCHandler) redirectURI(provider string) string {
+	base := strings.TrimSuffix(h.auth.config.OIDC.RedirectBaseURL, "/")
+	return base + "/api/v1/auth/oidc/" + provider + "/callback"
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
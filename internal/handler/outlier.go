@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OutlierHandler struct {
+	outliers *service.OutlierDetector
+	logger   *logger.Logger
+}
+
+func NewOutlierHandler(outliers *service.OutlierDetector, log *logger.Logger) *OutlierHandler {
+	return &OutlierHandler{
+		outliers: outliers,
+		logger:   log,
+	}
+}
+
+func (h *OutlierHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Outliers retrieved successfully", gin.H{
+		"ejected": h.outliers.Ejections(),
+		"events":  h.outliers.Events(),
+	})
+}
+
+// Readmit returns an ejected instance to load balancing at full weight
+func (h *OutlierHandler) Readmit(c *gin.Context) {
+	var req struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	svc := c.Param("service")
+	if err := h.outliers.Readmit(svc, req.URL); err != nil {
+		if errors.Is(err, service.ErrInstanceNotEjected) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Instance is not ejected")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusNotFound, "Service not found")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Outlier instance readmitted", "service", svc, "instance", req.URL, "by", c.GetString("user_id"))
+	utils.SuccessResponse(c, http.StatusOK, "Instance readmitted successfully", nil)
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UpdateProfile changes the caller's username or email. A new email has to
+// be verified again. Tokens keep the old values until they are refreshed.
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	var req models.UpdateProfileRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if req.Username == "" && req.Email == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Username or email is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(c, ctx)
+	if !ok {
+		return
+	}
+
+	var update store.UserUpdate
+	var username, email string
+	if req.Username != "" && req.Username != user.Username {
+		username = req.Username
+		update.Username = &username
+	}
+	emailChanged := req.Email != "" && req.Email != user.Email
+	if emailChanged {
+		email = req.Email
+		verified := false
+		update.Email, update.EmailVerified = &email, &verified
+	}
+	if username == "" && email == "" {
+		utils.SuccessResponse(c, http.StatusOK, "Profile is unchanged", profileResponse(user))
+		return
+	}
+
+	taken, err := h.users.UserExists(ctx, username, email, user.ID)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to look up user", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+	if taken {
+		utils.ErrorResponse(c, http.StatusConflict, "Username or email already exists")
+		return
+	}
+
+	if err := h.users.UpdateUser(ctx, user.ID, update); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			utils.ErrorResponse(c, http.StatusConflict, "Username or email already exists")
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to update profile", "user_id", user.ID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	event := auditEvent(c, models.AuditProfileUpdated, user.ID.Hex())
+	event.Details = map[string]interface{}{}
+	if req.Username != "" && req.Username != user.Username {
+		event.Details["username"] = map[string]string{"from": user.Username, "to": req.Username}
+		user.Username = req.Username
+	}
+	if emailChanged {
+		event.Details["email"] = map[string]string{"from": user.Email, "to": req.Email}
+		user.Email, user.EmailVerified = req.Email, false
+		if err := h.sendVerificationEmail(ctx, user); err != nil {
+			h.logger.WithContext(c).Errorw("Failed to send verification email", "username", user.Username, "error", err)
+		}
+	}
+	h.audit.Record(event)
+
+	h.logger.WithContext(c).Infow("Profile updated", "user_id", user.ID.Hex())
+	utils.SuccessResponse(c, http.StatusOK, "Profile updated successfully", profileResponse(user))
+}
+
+// ChangePassword sets a new password after checking the current one and
+// signs the user out everywhere else. The caller gets fresh credentials of
+// the kind it used: a new session cookie or new tokens.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(c, ctx)
+	if !ok {
+		return
+	}
+	if !h.checkPassword(c, user, req.CurrentPassword, models.AuditPasswordChanged) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to hash password", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	password := string(hashedPassword)
+	if err := h.users.UpdateUser(ctx, user.ID, store.UserUpdate{Password: &password}); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to change password", "user_id", user.ID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	if err := h.signOutUser(ctx, user.ID); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to sign out other sessions", "user_id", user.ID.Hex(), "error", err)
+	}
+	h.audit.Record(auditEvent(c, models.AuditPasswordChanged, user.ID.Hex()))
+
+	claims := c.MustGet("claims").(*utils.Claims)
+	if _, ok := c.Get("session"); ok {
+		session, err := h.startSession(c, ctx, user, claims.Scopes())
+		if err != nil {
+			h.logger.WithContext(c).Errorw("Failed to start session", "error", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Password changed, but failed to start a new session")
+			return
+		}
+		utils.SuccessResponse(c, http.StatusOK, "Password changed successfully", gin.H{
+			"csrf_token": session.CSRFToken,
+			"expires_at": session.ExpiresAt,
+		})
+		return
+	}
+
+	token, expiresAt, err := h.issueAccessToken(ctx, user, claims.Scopes(), h.config.JWT.Expiry)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to generate token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Password changed, but failed to generate token")
+		return
+	}
+	refreshToken, err := h.issueRefreshToken(ctx, user.ID, claims.Scopes())
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to issue refresh token", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Password changed, but failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Password changed successfully", gin.H{
+		"token":         token,
+		"expires_at":    expiresAt,
+		"refresh_token": refreshToken,
+	})
+}
+
+// DeleteProfile deactivates the caller's account after checking their
+// password and signs them out everywhere. The account is kept inactive so
+// its username and email aren't reused.
+func (h *AuthHandler) DeleteProfile(c *gin.Context) {
+	var req models.DeleteProfileRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(c, ctx)
+	if !ok {
+		return
+	}
+	if !h.checkPassword(c, user, req.Password, models.AuditUserDeleted) {
+		return
+	}
+
+	active, now := false, time.Now()
+	if err := h.users.UpdateUser(ctx, user.ID, store.UserUpdate{Active: &active, DeletedAt: &now}); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to delete account", "user_id", user.ID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	if err := h.signOutUser(ctx, user.ID); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to sign out deleted account", "user_id", user.ID.Hex(), "error", err)
+	}
+	if _, ok := c.Get("session"); ok {
+		h.setSessionCookie(c, "", -time.Second)
+	}
+
+	h.audit.Record(auditEvent(c, models.AuditUserDeleted, user.ID.Hex()))
+	h.logger.WithContext(c).Infow("Account deleted", "user_id", user.ID.Hex())
+	utils.SuccessResponse(c, http.StatusOK, "Account deleted successfully", nil)
+}
+
+// currentUser loads the authenticated user, answering the request itself
+// if that fails
+func (h *AuthHandler) currentUser(c *gin.Context, ctx context.Context) (*models.User, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "Profiles can only be managed by gateway users")
+		return nil, false
+	}
+
+	user, err := h.users.FindUser(ctx, objID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	if !user.Active {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is inactive")
+		return nil, false
+	}
+	return user, true
+}
+
+// checkPassword confirms a sensitive change with the user's password,
+// auditing a failed attempt under action
+func (h *AuthHandler) checkPassword(c *gin.Context, user *models.User, password, action string) bool {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		event := auditEvent(c, action, user.ID.Hex())
+		event.Success = false
+		event.Details = map[string]interface{}{"reason": "invalid_password"}
+		h.audit.Record(event)
+
+		utils.ErrorResponse(c, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+	return true
+}
+
+// signOutUser revokes every access and refresh token of a user and ends
+// their sessions
+func (h *AuthHandler) signOutUser(ctx context.Context, userID primitive.ObjectID) error {
+	if err := h.revocation.RevokeUser(ctx, userID.Hex()); err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+	if err := h.users.RevokeRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+	if err := h.sessions.DeleteUser(ctx, userID.Hex()); err != nil {
+		return fmt.Errorf("end sessions: %w", err)
+	}
+	return nil
+}
+
+func profileResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:       user.ID.Hex(),
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}
+}
@@ -1,25 +1,55 @@
 package handler
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"api-gateway/internal/circuit"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/models"
 	"api-gateway/internal/service"
+	"api-gateway/internal/spiffe"
+	"api-gateway/internal/store"
+	"api-gateway/internal/tracing"
+	"api-gateway/internal/transform"
 	"api-gateway/pkg/logger"
 	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// statusClientClosedRequest records requests whose client went away before
+// the upstream answered, as nginx does
+const statusClientClosedRequest = 499
+
 type ProxyHandler struct {
 	registry       *service.Registry
 	loadBalancer   *service.LoadBalancer
 	breakerManager *circuit.BreakerManager
+	upstreams      *upstreamPool
+	bulkheads      *bulkheads
+	sse            config.SSEConfig
+	shedder        *service.LoadShedder
+	outliers       *service.OutlierDetector
+	grpcClients    *grpcClients
+	transforms     *transform.Store
+	splits         *service.TrafficSplits
+	services       store.ServiceStore
+	audit          *service.AuditLog
 	logger         *logger.Logger
 }
 
@@ -27,31 +57,62 @@ func NewProxyHandler(
 	registry *service.Registry,
 	lb *service.LoadBalancer,
 	bm *circuit.BreakerManager,
+	upstream config.UpstreamConfig,
+	svids *spiffe.Source,
+	bulkhead config.BulkheadConfig,
+	sse config.SSEConfig,
+	shedder *service.LoadShedder,
+	outliers *service.OutlierDetector,
+	transforms *transform.Store,
+	splits *service.TrafficSplits,
+	services store.ServiceStore,
+	audit *service.AuditLog,
 	log *logger.Logger,
 ) *ProxyHandler {
 	return &ProxyHandler{
 		registry:       registry,
 		loadBalancer:   lb,
 		breakerManager: bm,
+		upstreams:      newUpstreamPool(upstream, svids),
+		bulkheads:      newBulkheads(bulkhead),
+		sse:            sse,
+		shedder:        shedder,
+		outliers:       outliers,
+		grpcClients:    newGRPCClients(svids),
+		transforms:     transforms,
+		splits:         splits,
+		services:       services,
+		audit:          audit,
 		logger:         log,
 	}
 }
 
-func (p *ProxyHandler) ProxyRequest(c *gin.Context) {
-	// Extract service name from path
-	path := c.Param("path")
-	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+// Route returns a handler that proxies requests matching the given route
+// to its target service.
+func (p *ProxyHandler) Route(route config.RouteConfig) gin.HandlerFunc {
+	// Validation has already compiled the pattern
+	pattern, _ := route.Match.PathPattern()
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if route.Rewrite != "" && pattern != nil {
+			path = pattern.ReplaceAllString(path, route.Rewrite)
+		} else if route.StripPrefix {
+			path = strings.TrimPrefix(path, strings.TrimSuffix(route.Path, "/"))
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
 
-	if len(parts) == 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request path")
-		return
+		p.proxy(c, route, path)
 	}
+}
 
-	serviceName := parts[0]
-	remainingPath := ""
-	if len(parts) > 1 {
-		remainingPath = "/" + parts[1]
-	}
+func (p *ProxyHandler) proxy(c *gin.Context, route config.RouteConfig, remainingPath string) {
+	serviceName := p.routeService(c, route.Path, route.Service)
+	retry := route.Retry
+	// Error templates may name the service
+	c.Set("service", serviceName)
 
 	// Get service from registry
 	svc, err := p.registry.Get(serviceName)
@@ -60,49 +121,213 @@ func (p *ProxyHandler) ProxyRequest(c *gin.Context) {
 		return
 	}
 
-	// Get target URL using load balancer
-	targetURL, err := p.loadBalancer.RoundRobin(svc)
+	// Execute request through circuit breaker
+	breaker := p.breakerManager.GetBreaker(serviceName)
+
+	// WebSocket upgrades bypass buffering and are tunneled directly
+	if isWebSocketRequest(c.Request) {
+		targetURL, err := p.nextInstance(c, svc, nil)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, "No available instances")
+			return
+		}
+		p.proxyWebSocket(c, breaker, svc, targetURL, remainingPath)
+		return
+	}
+
+	// Long-lived tunnels above are left out so they can't pin every slot
+	release, err := p.bulkheads.acquire(c.Request.Context(), svc)
+	if err != nil {
+		p.logger.WithContext(c).Warnw("Bulkhead rejected request", "service", serviceName, "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Service is at capacity")
+		return
+	}
+	defer release()
+
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), "proxy "+serviceName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gateway.service", serviceName)),
+	)
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	pipeline := p.transforms.Get(route.Path)
+	if pipeline != nil {
+		remainingPath = p.transformRequest(c, pipeline, remainingPath)
+	}
+
+	// The mirror is sent after the client has its response
+	if sendMirror := p.prepareMirror(c, route.Mirror); sendMirror != nil {
+		defer sendMirror(remainingPath)
+	}
+
+	// Bodies are rewritten in plain form. Byte ranges are of the encoded
+	// body, so for those the client's own Accept-Encoding goes upstream.
+	decompress := route.Decompress || route.ResponseRewrite != nil && route.ResponseRewrite.Body
+	if decompress && !isRangeRequest(c.Request) {
+		c.Request.Header.Set("Accept-Encoding", acceptedUpstreamEncodings)
+	}
+
+	rewind, retryable := prepareRetry(c, retry)
+	maxAttempts := 1
+	if retryable {
+		maxAttempts = retry.MaxAttempts
+	}
+
+	// Get target URL using load balancer, preferring untried instances
+	targetURL, err := p.nextInstance(c, svc, nil)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusServiceUnavailable, "No available instances")
 		return
 	}
 
-	// Execute request through circuit breaker
-	breaker := p.breakerManager.GetBreaker(serviceName)
-	result, err := breaker.Execute(func() (interface{}, error) {
-		return p.forwardRequest(c, targetURL, remainingPath)
-	})
+	var resp *http.Response
+	tried := make(map[string]bool)
+	for attempt := 1; ; attempt++ {
+		tried[targetURL] = true
+		span.SetAttributes(attribute.String("gateway.instance_url", targetURL))
+
+		rewind()
+		resp, err = p.forward(c, svc, breaker, targetURL, remainingPath)
 
+		if attempt >= maxAttempts || ctx.Err() != nil || !shouldRetry(retry, resp, err) {
+			break
+		}
+		// With no instance left to retry on, this attempt's response or
+		// error is the answer
+		next, lbErr := p.nextInstance(c, svc, tried)
+		if lbErr != nil {
+			break
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			resp = nil
+		}
+
+		metrics.UpstreamRetries.WithLabelValues(serviceName).Inc()
+		p.logger.WithContext(c).Warnw("Retrying upstream request",
+			"service", serviceName,
+			"instance", targetURL,
+			"attempt", attempt,
+			"error", err,
+		)
+		if !backoff(ctx, retry, attempt) {
+			err = ctx.Err()
+			break
+		}
+		targetURL = next
+	}
+
+	span.SetAttributes(
+		attribute.String("gateway.circuit_breaker.state", breaker.State().String()),
+		attribute.Int("gateway.attempts", len(tried)),
+	)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	// The upload policy rejected the body while it streamed upstream
+	var rejected *utils.APIError
+	if errors.As(err, &rejected) {
+		utils.WriteError(c, rejected)
+		return
+	}
 	if err != nil {
-		p.logger.Errorw("Circuit breaker error",
+		reason := upstreamErrorReason(err)
+		span.SetAttributes(attribute.String("gateway.circuit_breaker.outcome", reason))
+		if reason == "client_canceled" {
+			// Nobody is left to answer; the status is for logs and metrics
+			p.logger.WithContext(c).Infow("Client closed request", "service", serviceName)
+			c.Status(statusClientClosedRequest)
+			return
+		}
+		span.SetStatus(codes.Error, err.Error())
+
+		p.logger.WithContext(c).Errorw("Circuit breaker error",
 			"service", serviceName,
 			"error", err,
 		)
+		if reason == "timeout" {
+			utils.ErrorResponse(c, http.StatusGatewayTimeout, "Upstream request timed out")
+			return
+		}
 		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Service temporarily unavailable")
 		return
 	}
+	defer resp.Body.Close()
 
-	response := result.(*ProxyResponse)
-
-	// Copy headers
-	for key, values := range response.Headers {
-		for _, value := range values {
-			c.Header(key, value)
+	partial := isPartialResponse(resp)
+	if decompress && !partial {
+		if err := decompressResponse(resp); err != nil {
+			p.logger.WithContext(c).Errorw("Failed to decompress upstream response", "service", serviceName, "error", err)
+			utils.ErrorResponse(c, http.StatusBadGateway, "Invalid upstream response")
+			return
 		}
 	}
+	if route.ResponseRewrite != nil {
+		newURLRewriter(c, route, svc).rewriteResponse(resp, route.ResponseRewrite.Body && !partial)
+	}
+
+	span.SetAttributes(
+		attribute.String("gateway.circuit_breaker.outcome", "success"),
+		attribute.Int("http.response.status_code", resp.StatusCode),
+	)
+
+	if pipeline != nil {
+		pipeline.ApplyResponse(&transform.Context{Header: resp.Header, Lookup: templateLookup(c)})
+	}
 
-	// Send response
-	c.Data(response.StatusCode, response.ContentType, response.Body)
+	p.writeResponse(c, resp)
 }
 
-type ProxyResponse struct {
-	StatusCode  int
-	Headers     http.Header
-	Body        []byte
-	ContentType string
+// forward performs a single upstream attempt through the circuit breaker
+func (p *ProxyHandler) forward(c *gin.Context, svc *service.Service, breaker *circuit.Breaker, targetURL, path string) (*http.Response, error) {
+	return p.execute(svc, breaker, targetURL, func() (*http.Response, error) {
+		if svc.Protocol == service.ProtocolGRPC {
+			return p.forwardGRPC(c, svc, targetURL, path)
+		}
+		return p.forwardRequest(c, p.upstreams.client(svc), svc.Name, targetURL, path)
+	})
+}
+
+// execute sends an upstream request to instance through the circuit
+// breaker and records its outcome
+func (p *ProxyHandler) execute(svc *service.Service, breaker *circuit.Breaker, instance string, send func() (*http.Response, error)) (*http.Response, error) {
+	start := time.Now()
+	metrics.UpstreamInflight.WithLabelValues(svc.Name).Inc()
+	defer metrics.UpstreamInflight.WithLabelValues(svc.Name).Dec()
+
+	result, err := breaker.Execute(func() (interface{}, error) {
+		return send()
+	})
+	if err != nil {
+		reason := upstreamErrorReason(err)
+		// The service isn't to blame for a client going away
+		if reason == "client_canceled" {
+			metrics.UpstreamCancellations.WithLabelValues(svc.Name).Inc()
+			return nil, err
+		}
+		metrics.UpstreamErrors.WithLabelValues(svc.Name, reason).Inc()
+		var tooLarge *http.MaxBytesError
+		var rejected *utils.APIError
+		if reason != "circuit_open" && !errors.As(err, &tooLarge) && !errors.As(err, &rejected) {
+			svc.RecordError(err)
+			p.outliers.Observe(svc, instance, true, time.Since(start))
+		}
+		return nil, err
+	}
+
+	resp := result.(*http.Response)
+	p.outliers.Observe(svc, instance, resp.StatusCode >= http.StatusInternalServerError, time.Since(start))
+	p.shedder.Observe(time.Since(start))
+	metrics.UpstreamDuration.WithLabelValues(svc.Name).Observe(time.Since(start).Seconds())
+	metrics.UpstreamResponses.WithLabelValues(svc.Name, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
 }
 
-func (p *ProxyHandler) forwardRequest(c *gin.Context, targetURL, path string) (*ProxyResponse, error) {
+func (p *ProxyHandler) forwardRequest(c *gin.Context, client *http.Client, serviceName, targetURL, path string) (*http.Response, error) {
 	// Build target URL
 	fullURL, err := url.Parse(targetURL + path)
 	if err != nil {
@@ -112,17 +337,16 @@ func (p *ProxyHandler) forwardRequest(c *gin.Context, targetURL, path string) (*
 	// Copy query parameters
 	fullURL.RawQuery = c.Request.URL.RawQuery
 
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		return nil, err
-	}
+	// The request's context carries the route's deadline and ends when the
+	// client goes away, so abandoned requests stop upstream too
+	ctx := httptrace.WithClientTrace(c.Request.Context(), connectionTrace(serviceName))
 
-	// Create new request
-	req, err := http.NewRequest(c.Request.Method, fullURL.String(), bytes.NewReader(body))
+	// Create new request, streaming the client body straight through
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, fullURL.String(), c.Request.Body)
 	if err != nil {
 		return nil, err
 	}
+	req.ContentLength = c.Request.ContentLength
 
 	// Copy headers (exclude hop-by-hop headers)
 	for key, values := range c.Request.Header {
@@ -138,29 +362,82 @@ func (p *ProxyHandler) forwardRequest(c *gin.Context, targetURL, path string) (*
 	req.Header.Set("X-Forwarded-Proto", c.Request.Proto)
 	req.Header.Set("X-Forwarded-Host", c.Request.Host)
 
-	// Execute request
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// Correlate upstream logs with the gateway's
+	if requestID := c.GetString("request_id"); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	// Propagate the trace (traceparent and b3 headers)
+	otel.GetTextMapPropagator().Inject(c.Request.Context(), propagation.HeaderCarrier(req.Header))
+
+	// gRPC requires the "te: trailers" header, which is otherwise hop-by-hop
+	if isGRPCRequest(c.Request) {
+		req.Header.Set("Te", "trailers")
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	// The caller owns the response body and must close it
+	return client.Do(req)
+}
+
+// writeResponse streams the upstream response to the client, flushing after
+// every chunk so large downloads are not buffered.
+func (p *ProxyHandler) writeResponse(c *gin.Context, resp *http.Response) {
+	if isEventStream(resp) {
+		p.streamEvents(c, resp)
+		return
+	}
+
+	for key, values := range resp.Header {
+		if isHopByHopHeader(key) {
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			// A client going away ends the stream without the service at fault
+			if err != io.EOF && !errors.Is(err, context.Canceled) {
+				p.logger.WithContext(c).Warnw("Upstream response stream interrupted", "error", err)
+			}
+			if err != io.EOF {
+				return
+			}
+			break
+		}
+	}
+
+	// Trailers are only known once the body is drained (gRPC status lives here)
+	for key, values := range resp.Trailer {
+		for _, value := range values {
+			c.Writer.Header().Add(http.TrailerPrefix+key, value)
+		}
 	}
+}
 
-	return &ProxyResponse{
-		StatusCode:  resp.StatusCode,
-		Headers:     resp.Header,
-		Body:        respBody,
-		ContentType: resp.Header.Get("Content-Type"),
-	}, nil
+func upstreamErrorReason(err error) string {
+	switch {
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return "circuit_open"
+	case errors.Is(err, context.Canceled):
+		return "client_canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "transport"
+	}
 }
 
 func isHopByHopHeader(header string) bool {
@@ -190,9 +467,17 @@ func (p *ProxyHandler) ListServices(c *gin.Context) {
 
 func (p *ProxyHandler) RegisterService(c *gin.Context) {
 	var req struct {
-		Name      string   `json:"name" binding:"required"`
-		URLs      []string `json:"urls" binding:"required"`
-		HealthURL string   `json:"health_url"`
+		Name          string   `json:"name" binding:"required"`
+		URLs          []string `json:"urls" binding:"required"`
+		HealthURL     string   `json:"health_url"`
+		OpenAPIURL    string   `json:"openapi_url"`
+		Protocol      string   `json:"protocol" binding:"omitempty,oneof=http grpc"`
+		LoadBalancing string   `json:"load_balancing" binding:"omitempty,oneof=round_robin consistent_hash"`
+		HashKey       string   `json:"hash_key"`
+		SlowStart     string   `json:"slow_start"`
+
+		Zones     map[string][]string `json:"zones"`
+		ZoneAware bool                `json:"zone_aware"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -200,17 +485,91 @@ func (p *ProxyHandler) RegisterService(c *gin.Context) {
 		return
 	}
 
-	p.registry.Register(req.Name, req.URLs, req.HealthURL)
+	var slowStart time.Duration
+	if req.SlowStart != "" {
+		var err error
+		if slowStart, err = time.ParseDuration(req.SlowStart); err != nil || slowStart < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "slow_start must be a duration such as 30s")
+			return
+		}
+	}
+
+	svc := &models.RegisteredService{
+		Name:          req.Name,
+		URLs:          req.URLs,
+		HealthURL:     req.HealthURL,
+		OpenAPIURL:    req.OpenAPIURL,
+		Protocol:      req.Protocol,
+		LoadBalancing: req.LoadBalancing,
+		HashKey:       req.HashKey,
+		SlowStart:     slowStart,
+		Zones:         req.Zones,
+		ZoneAware:     req.ZoneAware,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := p.services.SaveService(ctx, svc); err != nil {
+		p.logger.WithContext(c).Errorw("Failed to save service", "service", req.Name, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to register service")
+		return
+	}
+	p.registry.Register(registeredServiceConfig(svc))
+
+	event := auditEvent(c, models.AuditServiceRegister, req.Name)
+	event.Details = map[string]interface{}{"urls": req.URLs}
+	p.audit.Record(event)
+
 	utils.SuccessResponse(c, http.StatusCreated, "Service registered successfully", nil)
 }
 
 func (p *ProxyHandler) UnregisterService(c *gin.Context) {
 	name := c.Param("name")
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Services from the config file aren't stored
+	if err := p.services.DeleteService(ctx, name); err != nil && !errors.Is(err, store.ErrNotFound) {
+		p.logger.WithContext(c).Errorw("Failed to delete service", "service", name, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to unregister service")
+		return
+	}
 	if err := p.registry.Unregister(name); err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
 		return
 	}
 
+	p.audit.Record(auditEvent(c, models.AuditServiceUnregister, name))
 	utils.SuccessResponse(c, http.StatusOK, "Service unregistered successfully", nil)
 }
+
+// RestoreServices registers the services stored by earlier calls to
+// RegisterService. It is called once at startup, after the services from
+// the config file, which stored services of the same name replace.
+func (p *ProxyHandler) RestoreServices(ctx context.Context) error {
+	services, err := p.services.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range services {
+		p.registry.Register(registeredServiceConfig(&services[i]))
+	}
+	return nil
+}
+
+func registeredServiceConfig(svc *models.RegisteredService) config.ServiceConfig {
+	return config.ServiceConfig{
+		Name:          svc.Name,
+		URLs:          svc.URLs,
+		HealthURL:     svc.HealthURL,
+		OpenAPIURL:    svc.OpenAPIURL,
+		Protocol:      svc.Protocol,
+		LoadBalancing: svc.LoadBalancing,
+		HashKey:       svc.HashKey,
+		SlowStart:     svc.SlowStart,
+		Zones:         svc.Zones,
+		ZoneAware:     svc.ZoneAware,
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/messaging"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorrelationIDHeader carries the ID of a published message
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// Publish returns a handler that accepts the request's JSON body for
+// publishing to the route's broker and answers 202 without waiting for it.
+// The request ID doubles as the message's correlation ID.
+func Publish(publisher *messaging.Publisher, route config.RouteConfig, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if !json.Valid(body) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Request body must be valid JSON")
+			return
+		}
+
+		id := c.GetString("request_id")
+		headers := map[string]string{
+			CorrelationIDHeader: id,
+			"X-Gateway-Route":   route.Path,
+		}
+		if userID := c.GetString("user_id"); userID != "" {
+			headers["X-User-ID"] = userID
+		}
+		if tenantID := c.GetString("tenant_id"); tenantID != "" {
+			headers["X-Tenant-ID"] = tenantID
+		}
+
+		msg := messaging.Message{ID: id, Body: body, Headers: headers}
+		if err := publisher.Enqueue(*route.Publish, msg); err != nil {
+			log.WithContext(c).Warnw("Message not accepted",
+				"broker", route.Publish.Broker,
+				"topic", route.Publish.Topic,
+				"error", err,
+			)
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, "Unable to accept message")
+			return
+		}
+
+		c.Header(CorrelationIDHeader, id)
+		utils.SuccessResponse(c, http.StatusAccepted, "Message accepted", gin.H{
+			"correlation_id": id,
+		})
+	}
+}
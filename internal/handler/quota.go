@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	quotas *service.Quotas
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewQuotaHandler(quotas *service.Quotas, audit *service.AuditLog, log *logger.Logger) *QuotaHandler {
+	return &QuotaHandler{
+		quotas: quotas,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+// Usage shows the current period usage of a subject (an API key ID or a
+// user ID) under every quota rule
+func (h *QuotaHandler) Usage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	usage, err := h.quotas.Usage(ctx, c.Param("subject"))
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to read quota usage", "subject", c.Param("subject"), "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to read quota usage")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Quota usage retrieved successfully", usage)
+}
+
+func (h *QuotaHandler) Reset(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subject, rule := c.Param("subject"), c.Param("rule")
+	if err := h.quotas.Reset(ctx, rule, subject); err != nil {
+		if errors.Is(err, service.ErrQuotaRuleNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Quota rule not found")
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to reset quota", "subject", subject, "rule", rule, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset quota")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Quota reset", "subject", subject, "rule", rule)
+
+	event := auditEvent(c, models.AuditQuotaReset, subject)
+	event.Details = map[string]interface{}{"rule": rule}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Quota reset successfully", nil)
+}
+
+// Report lists persisted usage per subject and period
+func (h *QuotaHandler) Report(c *gin.Context) {
+	var query models.QuotaReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	usage, err := h.quotas.Report(ctx, query)
+	if errors.Is(err, service.ErrQuotaReportUnavailable) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Quota reports require the mongo storage backend")
+		return
+	}
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to query quota usage", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to query quota usage")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Quota report retrieved successfully", usage)
+}
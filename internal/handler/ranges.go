@@ -0,0 +1,15 @@
+package handler
+
+import "net/http"
+
+// isRangeRequest reports whether the client asked for part of the body
+func isRangeRequest(r *http.Request) bool {
+	return r.Header.Get("Range") != ""
+}
+
+// isPartialResponse reports whether resp carries a byte range, or the
+// 416 answering an unsatisfiable one. Such bodies are passed through as
+// they are, since Content-Range counts the bytes the service sent.
+func isPartialResponse(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Content-Range") != ""
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/models"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitSubjects are the query parameters selecting buckets; they are
+// named after the key_by values of the dimensions
+var rateLimitSubjects = []string{"ip", "user", "api_key", "tenant", "route"}
+
+// RateLimitHandler inspects and resets rate limit buckets and manages
+// exemptions
+type RateLimitHandler struct {
+	limiter *ratelimit.Limiter
+	audit   *service.AuditLog
+	logger  *logger.Logger
+
+	mu  sync.RWMutex
+	cfg config.RateLimitConfig
+}
+
+type rateLimitExemptionRequest struct {
+	Type     string `json:"type" binding:"required,oneof=ip user api_key tenant"`
+	Value    string `json:"value" binding:"required"`
+	Duration string `json:"duration" binding:"required"`
+}
+
+func NewRateLimitHandler(limiter *ratelimit.Limiter, cfg config.RateLimitConfig, audit *service.AuditLog, log *logger.Logger) *RateLimitHandler {
+	return &RateLimitHandler{
+		limiter: limiter,
+		audit:   audit,
+		logger:  log,
+		cfg:     cfg,
+	}
+}
+
+// SetConfig records the rate limit settings a reload applied, so buckets
+// are reported against the limits in force
+func (h *RateLimitHandler) SetConfig(cfg config.RateLimitConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg = cfg
+}
+
+// Buckets lists the buckets of a subject, e.g. ?ip=10.0.0.1 or
+// ?user=42&dimension=per_user, with their remaining requests
+func (h *RateLimitHandler) Buckets(c *gin.Context) {
+	filter, ok := h.filter(c)
+	if !ok {
+		return
+	}
+
+	h.mu.RLock()
+	cfg := h.cfg
+	h.mu.RUnlock()
+
+	policies := make(map[string]ratelimit.Policy)
+	for _, dim := range cfg.EffectiveDimensions() {
+		policies[dim.Name] = ratelimit.Policy{Algorithm: dim.Algorithm, Limit: dim.Requests, Window: dim.Window}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	buckets, err := h.limiter.Buckets(ctx, filter, policies)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to read rate limit buckets", "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to read rate limit buckets")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Rate limit buckets retrieved successfully", gin.H{
+		"buckets":   buckets,
+		"truncated": len(buckets) == ratelimit.MaxBuckets,
+	})
+}
+
+// Reset deletes the buckets of a subject, giving it its full allowance
+// back on every dimension or the one selected
+func (h *RateLimitHandler) Reset(c *gin.Context) {
+	filter, ok := h.filter(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deleted, err := h.limiter.Reset(ctx, filter)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to reset rate limit buckets", "deleted", deleted, "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to reset rate limit buckets")
+		return
+	}
+
+	event := auditEvent(c, models.AuditRateLimitReset, c.Request.URL.RawQuery)
+	event.Details = map[string]interface{}{"dimension": filter.Dimension, "subject": filter.Subject, "deleted": deleted}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Rate limit buckets reset successfully", gin.H{"deleted": deleted})
+}
+
+// filter reads the bucket selection from the query. A subject is required
+// so a bare request can't wipe every bucket.
+func (h *RateLimitHandler) filter(c *gin.Context) (ratelimit.Filter, bool) {
+	filter := ratelimit.Filter{Dimension: c.Query("dimension"), Subject: make(map[string]string)}
+	for _, name := range rateLimitSubjects {
+		if value := c.Query(name); value != "" {
+			filter.Subject[name] = value
+		}
+	}
+	if len(filter.Subject) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "At least one of ip, user, api_key, tenant or route is required")
+		return filter, false
+	}
+	return filter, true
+}
+
+func (h *RateLimitHandler) Exemptions(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Rate limit exemptions retrieved successfully", h.limiter.Exemptions())
+}
+
+// Exempt lets an IP, user, API key or tenant bypass every rate limit for
+// a while, e.g. during a migration or a load test
+func (h *RateLimitHandler) Exempt(c *gin.Context) {
+	var req rateLimitExemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Duration must be a positive duration such as 30m")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subject := req.Type + "=" + req.Value
+	expiresAt := time.Now().Add(duration)
+	if err := h.limiter.Exempt(ctx, subject, expiresAt); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to exempt from rate limits", "subject", subject, "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to exempt from rate limits")
+		return
+	}
+
+	event := auditEvent(c, models.AuditRateLimitExempted, subject)
+	event.Details = map[string]interface{}{"expires_at": expiresAt}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusCreated, "Rate limit exemption created successfully", ratelimit.Exemption{
+		Subject:   subject,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// RemoveExemption ends an exemption selected by ?type=ip&value=10.0.0.1
+func (h *RateLimitHandler) RemoveExemption(c *gin.Context) {
+	subject := c.Query("type") + "=" + c.Query("value")
+	if c.Query("type") == "" || c.Query("value") == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "type and value are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	removed, err := h.limiter.RemoveExemption(ctx, subject)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to remove rate limit exemption", "subject", subject, "error", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Failed to remove rate limit exemption")
+		return
+	}
+	if !removed {
+		utils.ErrorResponse(c, http.StatusNotFound, "Rate limit exemption not found")
+		return
+	}
+
+	h.audit.Record(auditEvent(c, models.AuditRateLimitUnexempted, subject))
+	utils.SuccessResponse(c, http.StatusOK, "Rate limit exemption removed successfully", nil)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRetryBodyBytes caps how much of a request body is buffered so it can be
+// replayed. Larger bodies are streamed and the request is not retried.
+const maxRetryBodyBytes = 1 << 20
+
+// prepareRetry decides whether the request may be retried and, if it has a
+// body, buffers it so every attempt can resend it. It returns a function that
+// rewinds the body before each attempt.
+func prepareRetry(c *gin.Context, policy *config.RetryConfig) (func(), bool) {
+	if policy == nil || policy.MaxAttempts <= 1 || !retryableMethod(policy, c.Request.Method) {
+		return func() {}, false
+	}
+
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return func() {}, true
+	}
+	// Uploads are streamed from the first byte rather than held back for
+	// a replay they would rarely fit
+	if mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); strings.HasPrefix(mediaType, "multipart/") {
+		return func() {}, false
+	}
+
+	original := c.Request.Body
+	buf, err := io.ReadAll(io.LimitReader(original, maxRetryBodyBytes+1))
+	if err != nil || len(buf) > maxRetryBodyBytes {
+		// Too large to replay: stream the remainder after what was read
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), original), original}
+		return func() {}, false
+	}
+
+	original.Close()
+	return func() {
+		c.Request.Body = io.NopCloser(bytes.NewReader(buf))
+		c.Request.ContentLength = int64(len(buf))
+	}, true
+}
+
+func retryableMethod(policy *config.RetryConfig, method string) bool {
+	for _, m := range policy.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func shouldRetry(policy *config.RetryConfig, resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, code := range policy.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff sleeps using exponential backoff with full jitter. It returns false
+// if the context ends first.
+func backoff(ctx context.Context, policy *config.RetryConfig, attempt int) bool {
+	delay := policy.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// nextInstance asks the load balancer for an instance that hasn't been tried
+// yet, falling back to any instance once all of them have been attempted.
+func (p *ProxyHandler) nextInstance(c *gin.Context, svc *service.Service, tried map[string]bool) (string, error) {
+	return p.loadBalancer.Select(svc, affinityKey(c, svc.HashKey), tried)
+}
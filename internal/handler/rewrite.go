@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rewrittenHeaders carry URLs a client may follow
+var rewrittenHeaders = []string{"Location", "Content-Location", "Link"}
+
+// urlRewriter replaces the service's base URLs with the gateway's
+type urlRewriter struct {
+	from   [][]byte
+	to     [][]byte
+	prefix string
+}
+
+// newURLRewriter maps every instance URL of svc, and the route's extra
+// base URLs, onto the public URL. With strip_prefix the route's prefix is
+// added back, since the service never saw it.
+func newURLRewriter(c *gin.Context, route config.RouteConfig, svc *service.Service) *urlRewriter {
+	public := strings.TrimSuffix(route.ResponseRewrite.PublicURL, "/")
+	if public == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		public = scheme + "://" + c.Request.Host
+	}
+
+	var prefix string
+	if route.StripPrefix && route.Rewrite == "" {
+		prefix = strings.TrimSuffix(route.Path, "/")
+	}
+
+	bases := append(append([]string(nil), svc.InstanceURLs()...), route.ResponseRewrite.From...)
+	// Longer bases first, so a base isn't replaced by a shorter one it
+	// starts with
+	sort.Slice(bases, func(i, j int) bool { return len(bases[i]) > len(bases[j]) })
+
+	r := &urlRewriter{prefix: prefix}
+	for _, base := range bases {
+		base = strings.TrimSuffix(base, "/")
+		if base == "" {
+			continue
+		}
+		r.from = append(r.from, []byte(base))
+		r.to = append(r.to, []byte(public+prefix))
+	}
+	return r
+}
+
+// rewriteResponse rewrites the URL headers of resp and, if the route asks
+// for it, its JSON or HTML body
+func (r *urlRewriter) rewriteResponse(resp *http.Response, body bool) {
+	for _, name := range rewrittenHeaders {
+		values := resp.Header.Values(name)
+		for i, value := range values {
+			values[i] = r.header(name, value)
+		}
+	}
+
+	if !body || len(r.from) == 0 || !rewritableBody(resp) {
+		return
+	}
+	resp.Body = &rewriteReader{rewriter: r, source: resp.Body}
+	// Ranges of the service's body don't line up with the rewritten one
+	resp.Header.Del("Accept-Ranges")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+}
+
+func (r *urlRewriter) header(name, value string) string {
+	// Paths relative to the service's root lose the stripped prefix
+	if name != "Link" && r.prefix != "" && strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") {
+		return r.prefix + value
+	}
+	out, _ := r.replaceUpTo([]byte(value), len(value), nil)
+	return string(out)
+}
+
+// replaceUpTo rewrites data up to cut. A match starting before cut is
+// replaced in full even if it extends past cut. It returns the output and
+// how much of data was consumed.
+func (r *urlRewriter) replaceUpTo(data []byte, cut int, out []byte) ([]byte, int) {
+	i := 0
+	for i < cut {
+		match, at := -1, len(data)
+		for k, from := range r.from {
+			if j := indexBase(data, from, i); j >= 0 && j < at {
+				match, at = k, j
+			}
+		}
+		if match < 0 || at >= cut {
+			out = append(out, data[i:cut]...)
+			return out, cut
+		}
+		out = append(out, data[i:at]...)
+		out = append(out, r.to[match]...)
+		i = at + len(r.from[match])
+	}
+	return out, i
+}
+
+// indexBase finds base in data from start where it isn't followed by more
+// of a host or port, so http://orders:80 doesn't match http://orders:8080
+func indexBase(data, base []byte, start int) int {
+	for start <= len(data) {
+		j := bytes.Index(data[start:], base)
+		if j < 0 {
+			return -1
+		}
+		end := start + j + len(base)
+		if end == len(data) || !isHostByte(data[end]) {
+			return start + j
+		}
+		start += j + 1
+	}
+	return -1
+}
+
+func isHostByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '-' || b == ':'
+}
+
+func (r *urlRewriter) longest() int {
+	n := 0
+	for _, from := range r.from {
+		n = max(n, len(from))
+	}
+	return n
+}
+
+// rewriteReader rewrites a body as it streams. It holds back as many bytes
+// as the longest base, so a base split across reads is still matched and
+// the byte after it is known.
+type rewriteReader struct {
+	rewriter *urlRewriter
+	source   io.ReadCloser
+	buf      []byte
+	pending  []byte
+	out      []byte
+	eof      bool
+}
+
+func (b *rewriteReader) Read(p []byte) (int, error) {
+	if b.buf == nil {
+		b.buf = make([]byte, 32*1024)
+	}
+	for len(b.out) == 0 {
+		if b.eof {
+			return 0, io.EOF
+		}
+		n, err := b.source.Read(b.buf)
+		b.pending = append(b.pending, b.buf[:n]...)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		cut := len(b.pending) - b.rewriter.longest()
+		if err == io.EOF {
+			b.eof = true
+			cut = len(b.pending)
+		}
+		if cut <= 0 {
+			continue
+		}
+		var consumed int
+		b.out, consumed = b.rewriter.replaceUpTo(b.pending, cut, b.out)
+		b.pending = append(b.pending[:0], b.pending[consumed:]...)
+	}
+
+	n := copy(p, b.out)
+	b.out = b.out[n:]
+	return n, nil
+}
+
+func (b *rewriteReader) Close() error {
+	return b.source.Close()
+}
+
+// rewritableBody reports whether the body is plain JSON or HTML
+func rewritableBody(resp *http.Response) bool {
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RoleHandler struct {
+	roles      *service.RoleStore
+	users      store.UserStore
+	revocation *service.TokenRevocation
+	audit      *service.AuditLog
+	logger     *logger.Logger
+}
+
+func NewRoleHandler(roles *service.RoleStore, users store.UserStore, revocation *service.TokenRevocation, audit *service.AuditLog, log *logger.Logger) *RoleHandler {
+	return &RoleHandler{
+		roles:      roles,
+		users:      users,
+		revocation: revocation,
+		audit:      audit,
+		logger:     log,
+	}
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roles, err := h.roles.List(ctx)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to list roles", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list roles")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Roles retrieved successfully", roles)
+}
+
+// SaveRole creates a role or replaces its permissions. Tokens already
+// issued keep their permissions until they are refreshed.
+func (h *RoleHandler) SaveRole(c *gin.Context) {
+	var req models.RoleRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if name := c.Param("name"); name != "" && name != req.Name {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Role name does not match the URL")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	role, err := h.roles.Save(ctx, req)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to save role", "role", req.Name, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save role")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Role saved", "role", role.Name, "permissions", role.Permissions)
+
+	event := auditEvent(c, models.AuditRoleSaved, role.Name)
+	event.Details = map[string]interface{}{"permissions": role.Permissions}
+	h.audit.Record(event)
+	utils.SuccessResponse(c, http.StatusOK, "Role saved successfully", role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.roles.Delete(ctx, c.Param("name")); err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Role not found")
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to delete role", "role", c.Param("name"), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete role")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Role deleted", "role", c.Param("name"))
+	h.audit.Record(auditEvent(c, models.AuditRoleDeleted, c.Param("name")))
+	utils.SuccessResponse(c, http.StatusOK, "Role deleted successfully", nil)
+}
+
+// AssignRole changes a user's role and revokes their access tokens so the
+// new permissions apply from the next token refresh
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exists, err := h.roles.Exists(ctx, req.Role)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to look up role", "role", req.Role, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to assign role")
+		return
+	}
+	if !exists {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Role does not exist")
+		return
+	}
+
+	err = h.users.UpdateUser(ctx, objID, store.UserUpdate{Role: &req.Role})
+	if errors.Is(err, store.ErrNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to assign role", "user_id", objID.Hex(), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to assign role")
+		return
+	}
+
+	if err := h.revocation.RevokeUser(ctx, objID.Hex()); err != nil {
+		h.logger.WithContext(c).Errorw("Failed to revoke access tokens", "user_id", objID.Hex(), "error", err)
+	}
+
+	h.logger.WithContext(c).Infow("Role assigned", "user_id", objID.Hex(), "role", req.Role)
+
+	event := auditEvent(c, models.AuditRoleAssigned, objID.Hex())
+	event.Details = map[string]interface{}{"role": req.Role}
+	h.audit.Record(event)
+	utils.SuccessResponse(c, http.StatusOK, "Role assigned successfully", gin.H{
+		"user_id": objID.Hex(),
+		"role":    req.Role,
+	})
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	schedules *service.Schedules
+}
+
+func NewScheduleHandler(schedules *service.Schedules) *ScheduleHandler {
+	return &ScheduleHandler{schedules: schedules}
+}
+
+// List shows the configured schedules and whether their windows are open
+func (h *ScheduleHandler) List(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Schedules retrieved successfully", h.schedules.List())
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startSession creates a session for a login and puts its ID into an
+// HttpOnly cookie. Only the CSRF token is returned to the script.
+func (h *AuthHandler) startSession(c *gin.Context, ctx context.Context, user *models.User, scopes []string) (*service.Session, error) {
+	permissions, err := h.roles.Permissions(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	id, session, err := h.sessions.Create(ctx, utils.Claims{
+		UserID:      user.ID.Hex(),
+		Username:    user.Username,
+		Email:       user.Email,
+		Role:        user.Role,
+		TenantID:    user.TenantID,
+		Permissions: permissions,
+		Scope:       strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.setSessionCookie(c, id, h.config.Session.TTL)
+	if h.config.UsesCSRF() {
+		// The session's token replaces the double-submit token, so the
+		// client only ever sends one
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     h.config.CSRF.CookieName,
+			Value:    session.CSRFToken,
+			Path:     "/",
+			Secure:   h.config.CSRF.Secure,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return session, nil
+}
+
+// loggedIn logs and audits a successful login
+func (h *AuthHandler) loggedIn(c *gin.Context, user *models.User) {
+	h.logger.WithContext(c).Infow("User logged in successfully", "username", user.Username)
+
+	event := auditEvent(c, models.AuditLogin, user.ID.Hex())
+	event.ActorID, event.Actor = user.ID.Hex(), user.Username
+	h.audit.Record(event)
+}
+
+// Session returns the caller's session, so a reloaded page can pick up
+// its CSRF token again
+func (h *AuthHandler) Session(c *gin.Context) {
+	value, ok := c.Get("session")
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "Not authenticated by a session")
+		return
+	}
+	session := value.(*service.Session)
+
+	utils.SuccessResponse(c, http.StatusOK, "Session retrieved successfully", gin.H{
+		"csrf_token": session.CSRFToken,
+		"created_at": session.CreatedAt,
+		"expires_at": session.ExpiresAt,
+		"user": models.UserResponse{
+			ID:       session.Claims.UserID,
+			Username: session.Claims.Username,
+			Email:    session.Claims.Email,
+			Role:     session.Claims.Role,
+		},
+	})
+}
+
+// CSRFToken returns the token browser-facing requests must send in the CSRF
+// header, for clients that can't read the cookie
+func (h *AuthHandler) CSRFToken(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "CSRF token issued", gin.H{
+		"csrf_token": c.GetString("csrf_token"),
+		"header":     h.config.CSRF.Header,
+	})
+}
+
+// endSession logs out the session named by the request's cookie, if any,
+// and clears the cookie. It reports whether there was a cookie and so a
+// response was written.
+func (h *AuthHandler) endSession(c *gin.Context, ctx context.Context) bool {
+	if !h.config.Session.Enabled {
+		return false
+	}
+	id, err := c.Cookie(h.config.Session.CookieName)
+	if err != nil || id == "" {
+		return false
+	}
+
+	session, err := h.sessions.Get(ctx, id)
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		// Already ended; just drop the cookie
+	case err != nil:
+		h.logger.WithContext(c).Errorw("Failed to load session", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to logout")
+		return true
+	default:
+		token := c.GetHeader(h.config.CSRF.Header)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+			utils.ErrorResponse(c, http.StatusForbidden, "Missing or invalid CSRF token")
+			return true
+		}
+		if err := h.sessions.Delete(ctx, id); err != nil {
+			h.logger.WithContext(c).Errorw("Failed to end session", "error", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to logout")
+			return true
+		}
+	}
+
+	h.setSessionCookie(c, "", -time.Second)
+	utils.SuccessResponse(c, http.StatusOK, "Logged out successfully", nil)
+	return true
+}
+
+// setSessionCookie sets the session cookie; a negative maxAge deletes it
+func (h *AuthHandler) setSessionCookie(c *gin.Context, value string, maxAge time.Duration) {
+	cfg := h.config.Session
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cfg.SameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    value,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SigningClientHandler struct {
+	store  *service.SigningClientStore
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewSigningClientHandler(store *service.SigningClientStore, audit *service.AuditLog, log *logger.Logger) *SigningClientHandler {
+	return &SigningClientHandler{
+		store:  store,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *SigningClientHandler) CreateClient(c *gin.Context) {
+	var req models.CreateSigningClientRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, secret, err := h.store.Create(ctx, req)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to create signing client", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create signing client")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Signing client created", "client_id", client.ClientID, "name", client.Name)
+
+	event := auditEvent(c, models.AuditSigningClientCreated, client.ClientID)
+	event.Details = map[string]interface{}{"name": client.Name, "scopes": client.Scopes}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusCreated, "Signing client created successfully", gin.H{
+		"secret": secret,
+		"client": client,
+	})
+}
+
+func (h *SigningClientHandler) ListClients(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clients, err := h.store.List(ctx)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to list signing clients", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list signing clients")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Signing clients retrieved successfully", clients)
+}
+
+func (h *SigningClientHandler) RevokeClient(c *gin.Context) {
+	clientID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.store.Revoke(ctx, clientID); err != nil {
+		if errors.Is(err, service.ErrSigningClientNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Signing client not found")
+			return
+		}
+		h.logger.WithContext(c).Errorw("Failed to revoke signing client", "client_id", clientID, "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke signing client")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Signing client revoked", "client_id", clientID)
+	h.audit.Record(auditEvent(c, models.AuditSigningClientRevoked, clientID))
+	utils.SuccessResponse(c, http.StatusOK, "Signing client revoked successfully", nil)
+}
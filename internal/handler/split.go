@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeService picks the service a request goes to, applying the route's
+// traffic split if it has one and then the tenant's own services. A
+// service set by an open schedule window, or else by the caller's
+// experiment variant, replaces both the route's and its split.
+func (p *ProxyHandler) routeService(c *gin.Context, routePath, defaultService string) string {
+	if scheduled := c.GetString("scheduled_service"); scheduled != "" {
+		return tenantService(c, scheduled)
+	}
+	if variant := c.GetString("experiment_service"); variant != "" {
+		return tenantService(c, variant)
+	}
+	return tenantService(c, p.splitService(c, routePath, defaultService))
+}
+
+func (p *ProxyHandler) splitService(c *gin.Context, routePath, defaultService string) string {
+	split := p.splits.Get(routePath)
+	if split == nil {
+		return defaultService
+	}
+
+	var override string
+	if split.Header != "" {
+		override = c.GetHeader(split.Header)
+	}
+	if override == "" && split.Cookie != "" {
+		override, _ = c.Cookie(split.Cookie)
+	}
+
+	serviceName := split.Pick(override, affinityKey(c, split.HashKey))
+	metrics.SplitRequests.WithLabelValues(routePath, serviceName).Inc()
+	return serviceName
+}
+
+// tenantService maps a service to the deployment serving the request's
+// tenant, if it has its own
+func tenantService(c *gin.Context, serviceName string) string {
+	value, _ := c.Get("tenant")
+	if tenant, ok := value.(*models.Tenant); ok && tenant.Services[serviceName] != "" {
+		return tenant.Services[serviceName]
+	}
+	return serviceName
+}
+
+func (p *ProxyHandler) ListSplits(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Traffic splits retrieved successfully", p.splits.List())
+}
+
+// SetSplitWeights adjusts the weights of a route's split at runtime. The
+// change lasts until the next config reload.
+func (p *ProxyHandler) SetSplitWeights(c *gin.Context) {
+	var req struct {
+		Route   string         `json:"route" binding:"required"`
+		Weights map[string]int `json:"weights" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	split, err := p.splits.SetWeights(req.Route, req.Weights)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event := auditEvent(c, models.AuditSplitUpdated, req.Route)
+	event.Details = map[string]interface{}{"weights": req.Weights}
+	p.audit.Record(event)
+
+	p.logger.WithContext(c).Infow("Traffic split updated", "route", req.Route, "weights", req.Weights)
+	utils.SuccessResponse(c, http.StatusOK, "Traffic split updated successfully", split)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseHeartbeat is an SSE comment line, which clients ignore
+var sseHeartbeat = []byte(": heartbeat\n\n")
+
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// streamEvents relays a server-sent event stream. The server's write
+// timeout is lifted for the connection, every chunk is flushed as it
+// arrives, and a heartbeat comment is sent whenever the stream has been
+// idle for the heartbeat interval so intermediaries keep it open.
+func (p *ProxyHandler) streamEvents(c *gin.Context, resp *http.Response) {
+	// Not every writer supports deadlines; the stream still works until
+	// the server's write timeout
+	rc := http.NewResponseController(c.Writer)
+	rc.SetWriteDeadline(time.Time{})
+	rc.SetReadDeadline(time.Time{})
+
+	header := c.Writer.Header()
+	for key, values := range resp.Header {
+		if isHopByHopHeader(key) {
+			continue
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	header.Del("Content-Length")
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "no-cache")
+	}
+	// Stops nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- append([]byte(nil), buf[:n]...):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var heartbeat <-chan time.Time
+	if p.sse.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(p.sse.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	// Heartbeats only go out between events so they never split one
+	atBoundary := true
+	lastWrite := time.Now()
+	for {
+		select {
+		case chunk := <-chunks:
+			if _, err := c.Writer.Write(chunk); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			atBoundary = endsEvent(chunk)
+			lastWrite = time.Now()
+		case <-heartbeat:
+			if !atBoundary || time.Since(lastWrite) < p.sse.HeartbeatInterval {
+				continue
+			}
+			if _, err := c.Writer.Write(sseHeartbeat); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		case err := <-readErr:
+			if err != io.EOF {
+				p.logger.WithContext(c).Warnw("Upstream event stream interrupted", "error", err)
+			}
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// endsEvent reports whether a chunk finishes on a blank line, the end of
+// an event
+func endsEvent(chunk []byte) bool {
+	return bytes.HasSuffix(chunk, []byte("\n\n")) || bytes.HasSuffix(chunk, []byte("\r\n\r\n")) ||
+		bytes.HasSuffix(chunk, []byte("\r\r"))
+}
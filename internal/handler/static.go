@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/transform"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaticResponse answers every request with the response declared in
+// config, after the configured latency
+func StaticResponse(cfg config.StaticResponseConfig) gin.HandlerFunc {
+	status := cfg.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Latency > 0 {
+			timer := time.NewTimer(cfg.Latency)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		lookup := templateLookup(c)
+		for name, value := range cfg.Headers {
+			c.Header(name, transform.Expand(value, lookup))
+		}
+		body := transform.Expand(cfg.Body, lookup)
+
+		contentType := c.Writer.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+			if json.Valid([]byte(body)) {
+				contentType = "application/json; charset=utf-8"
+			}
+		}
+		c.Data(status, contentType, []byte(body))
+	}
+}
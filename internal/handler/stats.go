@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"api-gateway/internal/circuit"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsHandler serves live traffic stats for operators and dashboards
+type StatsHandler struct {
+	collector *metrics.Collector
+	registry  *service.Registry
+	breakers  *circuit.BreakerManager
+	shedder   *service.LoadShedder
+	drainer   *service.Drainer
+	logger    *logger.Logger
+}
+
+func NewStatsHandler(collector *metrics.Collector, registry *service.Registry, bm *circuit.BreakerManager, shedder *service.LoadShedder, drainer *service.Drainer, log *logger.Logger) *StatsHandler {
+	return &StatsHandler{
+		collector: collector,
+		registry:  registry,
+		breakers:  bm,
+		shedder:   shedder,
+		drainer:   drainer,
+		logger:    log,
+	}
+}
+
+func (h *StatsHandler) Stats(c *gin.Context) {
+	stats, err := h.collector.Stats()
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to collect stats", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to collect stats")
+		return
+	}
+
+	services := h.registry.List()
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	health := make([]serviceHealth, 0, len(services))
+	for _, svc := range services {
+		health = append(health, describeService(svc, h.breakers))
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Stats retrieved successfully", gin.H{
+		"since":              stats.Since,
+		"window_seconds":     stats.Window,
+		"in_flight":          h.drainer.InFlight(),
+		"draining":           h.drainer.Draining(),
+		"load_shed_pressure": h.shedder.Pressure(),
+		"routes":             stats.Routes,
+		"upstreams":          stats.Upstreams,
+		"rate_limit":         stats.RateLimit,
+		"services":           health,
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantHandler struct {
+	store  *service.TenantStore
+	audit  *service.AuditLog
+	logger *logger.Logger
+}
+
+func NewTenantHandler(store *service.TenantStore, audit *service.AuditLog, log *logger.Logger) *TenantHandler {
+	return &TenantHandler{
+		store:  store,
+		audit:  audit,
+		logger: log,
+	}
+}
+
+func (h *TenantHandler) ListTenants(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tenants, err := h.store.List(ctx)
+	if err != nil {
+		h.logger.WithContext(c).Errorw("Failed to list tenants", "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list tenants")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Tenants retrieved successfully", tenants)
+}
+
+func (h *TenantHandler) GetTenant(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tenant, err := h.store.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.storeError(c, err, "Failed to get tenant")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Tenant retrieved successfully", tenant)
+}
+
+func (h *TenantHandler) CreateTenant(c *gin.Context) {
+	var req models.CreateTenantRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tenant, err := h.store.Create(ctx, req)
+	if err != nil {
+		h.storeError(c, err, "Failed to create tenant")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Tenant created", "tenant_id", tenant.TenantID)
+
+	event := auditEvent(c, models.AuditTenantCreated, tenant.TenantID)
+	event.Details = map[string]interface{}{"name": tenant.Name, "domains": tenant.Domains}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusCreated, "Tenant created successfully", tenant)
+}
+
+func (h *TenantHandler) UpdateTenant(c *gin.Context) {
+	var req models.UpdateTenantRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tenant, err := h.store.Update(ctx, c.Param("id"), req)
+	if err != nil {
+		h.storeError(c, err, "Failed to update tenant")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Tenant updated", "tenant_id", tenant.TenantID)
+
+	event := auditEvent(c, models.AuditTenantUpdated, tenant.TenantID)
+	event.Details = map[string]interface{}{"active": tenant.Active, "domains": tenant.Domains}
+	h.audit.Record(event)
+
+	utils.SuccessResponse(c, http.StatusOK, "Tenant updated successfully", tenant)
+}
+
+func (h *TenantHandler) DeleteTenant(c *gin.Context) {
+	tenantID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.store.Delete(ctx, tenantID); err != nil {
+		h.storeError(c, err, "Failed to delete tenant")
+		return
+	}
+
+	h.logger.WithContext(c).Infow("Tenant deleted", "tenant_id", tenantID)
+	h.audit.Record(auditEvent(c, models.AuditTenantDeleted, tenantID))
+	utils.SuccessResponse(c, http.StatusOK, "Tenant deleted successfully", nil)
+}
+
+func (h *TenantHandler) storeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrTenantNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Tenant not found")
+	case errors.Is(err, service.ErrTenantExists):
+		utils.ErrorResponse(c, http.StatusConflict, "Tenant already exists")
+	case errors.Is(err, service.ErrInvalidTenantID):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.WithContext(c).Errorw(message, "tenant_id", c.Param("id"), "error", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, message)
+	}
+}
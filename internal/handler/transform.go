@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"api-gateway/internal/transform"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transformRequest runs request rules against the client request headers
+// and the upstream path, returning the rewritten path
+func (p *ProxyHandler) transformRequest(c *gin.Context, pipeline *transform.Pipeline, path string) string {
+	ctx := &transform.Context{
+		Header: c.Request.Header,
+		Path:   path,
+		Lookup: templateLookup(c),
+	}
+	pipeline.ApplyRequest(ctx)
+	return ctx.Path
+}
+
+// templateLookup resolves rule template variables from the request context
+func templateLookup(c *gin.Context) func(string) string {
+	return func(name string) string {
+		switch {
+		case name == "jwt.sub":
+			return c.GetString("user_id")
+		case strings.HasPrefix(name, "jwt."):
+			return c.GetString(strings.TrimPrefix(name, "jwt."))
+		case strings.HasPrefix(name, "header."):
+			return c.GetHeader(strings.TrimPrefix(name, "header."))
+		case strings.HasPrefix(name, "query."):
+			return c.Query(strings.TrimPrefix(name, "query."))
+		case strings.HasPrefix(name, "path."):
+			return strings.TrimPrefix(c.Param(strings.TrimPrefix(name, "path.")), "/")
+		case name == "request_id":
+			return c.GetString("request_id")
+		case name == "client_ip":
+			return c.ClientIP()
+		default:
+			return ""
+		}
+	}
+}
+
+func (p *ProxyHandler) ListTransforms(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Transformations retrieved successfully", p.transforms.List())
+}
+
+// SetTransforms replaces the rules of a route at runtime. The change lasts
+// until the next config reload.
+func (p *ProxyHandler) SetTransforms(c *gin.Context) {
+	var req struct {
+		Route    string   `json:"route" binding:"required"`
+		Request  []string `json:"request"`
+		Response []string `json:"response"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	def := transform.Definition{Request: req.Request, Response: req.Response}
+	if err := p.transforms.Set(req.Route, def); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.logger.WithContext(c).Infow("Transformations updated", "route", req.Route)
+	utils.SuccessResponse(c, http.StatusOK, "Transformations updated successfully", def)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/internal/spiffe"
+
+	"golang.org/x/net/http2"
+)
+
+// upstreamPool keeps one HTTP client per service so that keep-alive
+// connections are reused across requests and pools can be tuned per service
+type upstreamPool struct {
+	defaults config.UpstreamConfig
+	svids    *spiffe.Source
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+type pooledClient struct {
+	config config.UpstreamConfig
+	client *http.Client
+}
+
+func newUpstreamPool(defaults config.UpstreamConfig, svids *spiffe.Source) *upstreamPool {
+	return &upstreamPool{
+		defaults: defaults,
+		svids:    svids,
+		clients:  make(map[string]*pooledClient),
+	}
+}
+
+// client returns the service's client, rebuilding it when the service was
+// re-registered with different pool settings
+func (u *upstreamPool) client(svc *service.Service) *http.Client {
+	cfg := u.defaults
+	if svc.Upstream != nil {
+		cfg = *svc.Upstream
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if pc, ok := u.clients[svc.Name]; ok {
+		if sameUpstreamConfig(pc.config, cfg) {
+			return pc.client
+		}
+		// In-flight requests keep their connections; only idle ones go
+		pc.client.CloseIdleConnections()
+	}
+
+	pc := &pooledClient{config: cfg, client: &http.Client{Transport: newRoundTripper(cfg, u.svids)}}
+	u.clients[svc.Name] = pc
+	return pc.client
+}
+
+// tlsConfig returns the TLS config of connections to services with a
+// SPIFFE ID, and nil for the others
+func (u *upstreamPool) tlsConfig(svc *service.Service) *tls.Config {
+	if svc.Upstream == nil || svc.Upstream.SPIFFEID == "" || u.svids == nil {
+		return nil
+	}
+	return u.svids.ClientTLS(svc.Upstream.SPIFFEID)
+}
+
+// newRoundTripper returns the transport for a service, sending http://
+// requests over h2c when the service asks for it
+func newRoundTripper(cfg config.UpstreamConfig, svids *spiffe.Source) http.RoundTripper {
+	transport := newTransport(cfg, svids)
+	if !cfg.H2C {
+		return transport
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+	return &h2cTransport{
+		tls: transport,
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			// Pings detect connections that died without a close
+			ReadIdleTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// h2cTransport multiplexes plaintext requests over h2c connections and
+// leaves https ones to the regular transport
+type h2cTransport struct {
+	tls *http.Transport
+	h2c *http2.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.tls.RoundTrip(req)
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.tls.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
+
+func newTransport(cfg config.UpstreamConfig, svids *spiffe.Source) *http.Transport {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ForceAttemptHTTP2:     http2Enabled(cfg),
+	}
+	// Validation requires SPIFFE for services with a SPIFFE ID
+	if cfg.SPIFFEID != "" && svids != nil {
+		transport.TLSClientConfig = svids.ClientTLS(cfg.SPIFFEID)
+	}
+	return transport
+}
+
+func http2Enabled(cfg config.UpstreamConfig) bool {
+	return cfg.HTTP2 == nil || *cfg.HTTP2
+}
+
+func sameUpstreamConfig(a, b config.UpstreamConfig) bool {
+	if http2Enabled(a) != http2Enabled(b) {
+		return false
+	}
+	a.HTTP2, b.HTTP2 = nil, nil
+	return a == b
+}
+
+// connectionTrace records whether a request reused a pooled connection
+func connectionTrace(serviceName string) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.UpstreamConnections.WithLabelValues(serviceName, strconv.FormatBool(info.Reused)).Inc()
+		},
+	}
+}
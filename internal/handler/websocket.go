@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-gateway/internal/circuit"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isWebSocketRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyWebSocket performs the upgrade handshake against the selected instance
+// and then pipes frames in both directions until either side closes.
+func (p *ProxyHandler) proxyWebSocket(c *gin.Context, breaker *circuit.Breaker, svc *service.Service, targetURL, path string) {
+	target, err := url.Parse(targetURL + path)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, "Invalid upstream URL")
+		return
+	}
+	target.RawQuery = c.Request.URL.RawQuery
+
+	result, err := breaker.Execute(func() (interface{}, error) {
+		return dialUpstream(target, p.upstreams.tlsConfig(svc))
+	})
+	if err != nil {
+		p.logger.WithContext(c).Errorw("WebSocket upstream dial failed",
+			"service", svc.Name,
+			"error", err,
+		)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Service temporarily unavailable")
+		return
+	}
+	upstream := result.(net.Conn)
+	defer upstream.Close()
+
+	// Replay the handshake with the upstream path and forwarding headers
+	req := c.Request.Clone(c.Request.Context())
+	req.URL = &url.URL{Path: target.Path, RawQuery: target.RawQuery}
+	req.RequestURI = ""
+	req.Host = target.Host
+	req.Header.Set("X-Forwarded-For", c.ClientIP())
+	req.Header.Set("X-Forwarded-Proto", c.Request.Proto)
+	req.Header.Set("X-Forwarded-Host", c.Request.Host)
+
+	if err := req.Write(upstream); err != nil {
+		p.logger.WithContext(c).Errorw("WebSocket handshake write failed", "service", svc.Name, "error", err)
+		utils.ErrorResponse(c, http.StatusBadGateway, "Upstream handshake failed")
+		return
+	}
+
+	hijacker, ok := c.Writer.(http.Hijacker)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "WebSocket not supported")
+		return
+	}
+
+	client, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		p.logger.WithContext(c).Errorw("WebSocket hijack failed", "service", svc.Name, "error", err)
+		return
+	}
+	defer client.Close()
+
+	// Drain anything the client already sent past the handshake
+	if clientBuf.Reader.Buffered() > 0 {
+		buffered, _ := clientBuf.Reader.Peek(clientBuf.Reader.Buffered())
+		if _, err := upstream.Write(buffered); err != nil {
+			return
+		}
+	}
+
+	errc := make(chan error, 2)
+	go pipe(upstream, client, errc)
+	go pipe(client, upstream, errc)
+	<-errc
+}
+
+// dialUpstream connects to target, over TLS for https and wss. Without a
+// TLS config the server is verified by hostname.
+func dialUpstream(target *url.URL, tlsConfig *tls.Config) (net.Conn, error) {
+	host := target.Host
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	switch target.Scheme {
+	case "https", "wss":
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "443")
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: target.Hostname()}
+		}
+		return tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	default:
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "80")
+		}
+		return dialer.Dial("tcp", host)
+	}
+}
+
+func pipe(dst io.Writer, src io.Reader, errc chan<- error) {
+	_, err := io.Copy(dst, src)
+	errc <- err
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/logger"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers account emails such as verification and password reset
+// links
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns an SMTP mailer, or a mailer that only logs messages when no
+// SMTP host is configured
+func New(cfg config.SMTPConfig, log *logger.Logger) Mailer {
+	if cfg.Host == "" {
+		return &LogMailer{logger: log}
+	}
+	return NewSMTPMailer(cfg)
+}
+
+// LogMailer writes messages to the log instead of sending them. It is meant
+// for local development.
+type LogMailer struct {
+	logger *logger.Logger
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Infow("Email not sent, no SMTP host configured", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,44 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// Message is a request body handed to a broker. ID correlates it with the
+// request that produced it.
+type Message struct {
+	ID      string
+	Topic   string
+	Body    []byte
+	Headers map[string]string
+}
+
+// Producer publishes messages to a broker. Publish must be safe for
+// concurrent use.
+type Producer interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// NewProducer builds the producer for a broker's type
+func NewProducer(cfg config.BrokerConfig) (Producer, error) {
+	switch cfg.Type {
+	case "kafka":
+		return newKafkaProducer(cfg), nil
+	case "rabbitmq":
+		return newRabbitMQProducer(cfg), nil
+	case "nats":
+		return newNATSProducer(cfg)
+	default:
+		return nil, fmt.Errorf("unknown broker type %q", cfg.Type)
+	}
+}
+
+// httpClient is shared by the producers that talk to an HTTP API; each
+// publish is bounded by its context instead
+var httpClient = &http.Client{Timeout: time.Minute}
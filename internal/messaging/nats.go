@@ -0,0 +1,237 @@
+package messaging
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// natsDialTimeout bounds connecting when the publish context has no deadline
+const natsDialTimeout = 5 * time.Second
+
+// natsProducer speaks the NATS client protocol over one TCP connection,
+// reconnecting after any error. Every publish is followed by a PING so a
+// PONG confirms the server has processed it. The connection is upgraded to
+// TLS after the server's INFO line when the URL scheme is tls, TLS files are
+// configured or the server requires it.
+type natsProducer struct {
+	addr     string
+	username string
+	password string
+	tls      *tls.Config
+	forceTLS bool
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newNATSProducer(cfg config.BrokerConfig) (*natsProducer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	if (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return nil, fmt.Errorf("nats url must look like nats://host:port or tls://host:port")
+	}
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("nats tls: %w", err)
+	}
+
+	n := &natsProducer{
+		addr:     u.Host,
+		username: cfg.Username,
+		password: cfg.Password,
+		tls:      tlsConfig,
+		forceTLS: u.Scheme == "tls" || tlsConfig != nil,
+	}
+	if n.tls == nil {
+		n.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if n.tls.ServerName == "" {
+		n.tls.ServerName = u.Hostname()
+	}
+	if u.Port() == "" {
+		n.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if n.username == "" && u.User != nil {
+		n.username = u.User.Username()
+		n.password, _ = u.User.Password()
+	}
+	return n, nil
+}
+
+var natsHeaderValue = strings.NewReplacer("\r", " ", "\n", " ")
+
+// validNATSHeaderName reports whether name is an HTTP token, which is all a
+// NATS header block can carry without corrupting the frame
+func validNATSHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// validNATSSubject rejects subjects that would split the HPUB line
+func validNATSSubject(subject string) bool {
+	if subject == "" {
+		return false
+	}
+	for _, r := range subject {
+		if r <= ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *natsProducer) Publish(ctx context.Context, msg Message) error {
+	if !validNATSSubject(msg.Topic) {
+		return fmt.Errorf("invalid nats subject %q", msg.Topic)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	var header strings.Builder
+	header.WriteString("NATS/1.0\r\n")
+	header.WriteString("Nats-Msg-Id: " + natsHeaderValue.Replace(msg.ID) + "\r\n")
+	for name, value := range msg.Headers {
+		if !validNATSHeaderName(name) {
+			continue
+		}
+		header.WriteString(name + ": " + natsHeaderValue.Replace(value) + "\r\n")
+	}
+	header.WriteString("\r\n")
+
+	frame := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n",
+		msg.Topic, header.Len(), header.Len()+len(msg.Body), header.String(), msg.Body)
+	if err := n.roundTrip(ctx, frame); err != nil {
+		n.closeConn()
+		return err
+	}
+	return nil
+}
+
+// connect dials the server and authenticates. The server greets with an
+// INFO line before it accepts CONNECT.
+func (n *natsProducer) connect(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, natsDialTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(dialCtx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	n.conn = conn
+	n.reader = bufio.NewReader(conn)
+
+	n.setDeadline(ctx)
+	line, err := n.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		n.closeConn()
+		return fmt.Errorf("unexpected nats greeting: %q (%v)", strings.TrimSpace(line), err)
+	}
+
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	secure := n.forceTLS || info.TLSRequired
+	if secure {
+		tlsConn := tls.Client(conn, n.tls)
+		if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+			n.closeConn()
+			return fmt.Errorf("nats tls handshake: %w", err)
+		}
+		n.conn = tlsConn
+		n.reader = bufio.NewReader(tlsConn)
+	}
+
+	options, _ := json.Marshal(map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": secure,
+		"headers":      true,
+		"name":         "api-gateway",
+		"lang":         "go",
+		"user":         n.username,
+		"pass":         n.password,
+	})
+	if err := n.roundTrip(ctx, "CONNECT "+string(options)+"\r\nPING\r\n"); err != nil {
+		n.closeConn()
+		return err
+	}
+	return nil
+}
+
+// roundTrip writes frame, which must end in a PING, and waits for the PONG
+func (n *natsProducer) roundTrip(ctx context.Context, frame string) error {
+	n.setDeadline(ctx)
+	if _, err := n.conn.Write([]byte(frame)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+	}
+}
+
+func (n *natsProducer) setDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsDialTimeout)
+	}
+	n.conn.SetDeadline(deadline)
+}
+
+func (n *natsProducer) closeConn() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.reader = nil, nil
+	}
+}
+
+func (n *natsProducer) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closeConn()
+	return nil
+}
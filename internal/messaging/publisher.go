@@ -0,0 +1,174 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+)
+
+var (
+	ErrBufferFull    = errors.New("message buffer is full")
+	ErrUnknownBroker = errors.New("unknown broker")
+	ErrClosed        = errors.New("publisher is closed")
+)
+
+// Publisher buffers accepted messages and publishes them in the
+// background, retrying failures and dead-lettering what still fails.
+// Brokers are set up once at startup.
+type Publisher struct {
+	config    config.MessagingConfig
+	producers map[string]Producer
+	logger    *logger.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan delivery
+	wg     sync.WaitGroup
+}
+
+type delivery struct {
+	broker  string
+	publish config.PublishConfig
+	msg     Message
+}
+
+func NewPublisher(cfg config.MessagingConfig, log *logger.Logger) (*Publisher, error) {
+	p := &Publisher{
+		config:    cfg,
+		producers: make(map[string]Producer, len(cfg.Brokers)),
+		logger:    log,
+		queue:     make(chan delivery, cfg.BufferSize),
+	}
+	for _, broker := range cfg.Brokers {
+		producer, err := NewProducer(broker)
+		if err != nil {
+			return nil, fmt.Errorf("broker %s: %w", broker.Name, err)
+		}
+		p.producers[broker.Name] = producer
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p, nil
+}
+
+// Enqueue accepts msg for publishing to publish.Topic on publish.Broker.
+// It fails without blocking when the buffer is full.
+func (p *Publisher) Enqueue(publish config.PublishConfig, msg Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+	if p.producers[publish.Broker] == nil {
+		return ErrUnknownBroker
+	}
+
+	msg.Topic = publish.Topic
+	select {
+	case p.queue <- delivery{broker: publish.Broker, publish: publish, msg: msg}:
+		return nil
+	default:
+		metrics.MessagesPublished.WithLabelValues(publish.Broker, publish.Topic, "rejected").Inc()
+		return ErrBufferFull
+	}
+}
+
+func (p *Publisher) run() {
+	defer p.wg.Done()
+	for d := range p.queue {
+		p.deliver(d)
+	}
+}
+
+func (p *Publisher) deliver(d delivery) {
+	producer := p.producers[d.broker]
+	attempts := d.publish.MaxAttempts
+	if attempts == 0 {
+		attempts = 3
+	}
+
+	var err error
+	backoff := p.config.RetryBackoff
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = p.publish(producer, d.msg); err == nil {
+			metrics.MessagesPublished.WithLabelValues(d.broker, d.msg.Topic, "published").Inc()
+			return
+		}
+		if attempt < attempts {
+			metrics.MessagesPublished.WithLabelValues(d.broker, d.msg.Topic, "retried").Inc()
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	if d.publish.DeadLetter != "" {
+		dead := d.msg
+		dead.Topic = d.publish.DeadLetter
+		dead.Headers = make(map[string]string, len(d.msg.Headers)+2)
+		for name, value := range d.msg.Headers {
+			dead.Headers[name] = value
+		}
+		dead.Headers["X-Original-Topic"] = d.msg.Topic
+		dead.Headers["X-Publish-Error"] = err.Error()
+
+		deadErr := p.publish(producer, dead)
+		if deadErr == nil {
+			metrics.MessagesPublished.WithLabelValues(d.broker, d.msg.Topic, "dead_lettered").Inc()
+			p.logger.Warnw("Message dead-lettered",
+				"broker", d.broker,
+				"topic", d.msg.Topic,
+				"dead_letter", dead.Topic,
+				"correlation_id", d.msg.ID,
+				"error", err,
+			)
+			return
+		}
+		err = fmt.Errorf("%w; dead-letter: %v", err, deadErr)
+	}
+
+	metrics.MessagesPublished.WithLabelValues(d.broker, d.msg.Topic, "dropped").Inc()
+	p.logger.Errorw("Message dropped",
+		"broker", d.broker,
+		"topic", d.msg.Topic,
+		"correlation_id", d.msg.ID,
+		"attempts", attempts,
+		"error", err,
+	)
+}
+
+func (p *Publisher) publish(producer Producer, msg Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+	return producer.Publish(ctx, msg)
+}
+
+// Close stops accepting messages, publishes the buffered ones and closes
+// the producers
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	var errs []error
+	for _, producer := range p.producers {
+		errs = append(errs, producer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"api-gateway/internal/config"
+)
+
+// rabbitMQProducer publishes through the RabbitMQ management HTTP API.
+// Messages are persistent and carry the message ID as correlation_id.
+type rabbitMQProducer struct {
+	endpoint string
+	username string
+	password string
+}
+
+func newRabbitMQProducer(cfg config.BrokerConfig) *rabbitMQProducer {
+	vhost, exchange := cfg.VHost, cfg.Exchange
+	if vhost == "" {
+		vhost = "/"
+	}
+	if exchange == "" {
+		exchange = "amq.default"
+	}
+
+	return &rabbitMQProducer{
+		endpoint: strings.TrimSuffix(cfg.URL, "/") + "/api/exchanges/" +
+			url.PathEscape(vhost) + "/" + url.PathEscape(exchange) + "/publish",
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+}
+
+func (r *rabbitMQProducer) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"properties": map[string]interface{}{
+			"correlation_id": msg.ID,
+			"message_id":     msg.ID,
+			"content_type":   "application/json",
+			"delivery_mode":  2,
+			"headers":        msg.Headers,
+		},
+		"routing_key":      msg.Topic,
+		"payload":          string(msg.Body),
+		"payload_encoding": "string",
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(r.username, r.password)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rabbitmq management api returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Routed bool `json:"routed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid rabbitmq response: %w", err)
+	}
+	if !result.Routed {
+		return errors.New("message was not routed to any queue")
+	}
+	return nil
+}
+
+func (r *rabbitMQProducer) Close() error {
+	return nil
+}
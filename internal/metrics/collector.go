@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Collector turns the gateway's Prometheus metrics into live stats. It
+// keeps snapshots of the counters over a sliding window and reports the
+// difference between the oldest one and the current values, so rates and
+// percentiles reflect recent traffic rather than totals since startup.
+type Collector struct {
+	gatherer prometheus.Gatherer
+	window   time.Duration
+
+	mu        sync.Mutex
+	snapshots []snapshot
+}
+
+type snapshot struct {
+	at       time.Time
+	families map[string]*dto.MetricFamily
+}
+
+// Latency percentiles in milliseconds, estimated from histogram buckets
+type Latency struct {
+	P50 float64 `json:"p50_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+type RouteStats struct {
+	Route             string  `json:"route"`
+	Requests          float64 `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	ErrorRate         float64 `json:"error_rate"`
+	Latency           Latency `json:"latency"`
+}
+
+type UpstreamStats struct {
+	Service           string  `json:"service"`
+	Requests          float64 `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Errors            float64 `json:"errors"`
+	ServerErrors      float64 `json:"server_errors"`
+	Latency           Latency `json:"latency"`
+}
+
+type RateLimitStats struct {
+	Route      string  `json:"route"`
+	Rejections float64 `json:"rejections"`
+}
+
+type Stats struct {
+	Since     time.Time        `json:"since"`
+	Window    float64          `json:"window_seconds"`
+	Routes    []RouteStats     `json:"routes"`
+	Upstreams []UpstreamStats  `json:"upstreams"`
+	RateLimit []RateLimitStats `json:"rate_limit"`
+}
+
+func NewCollector(gatherer prometheus.Gatherer, window time.Duration) *Collector {
+	return &Collector{gatherer: gatherer, window: window}
+}
+
+// Start snapshots the metrics every interval until ctx is cancelled
+func (c *Collector) Start(ctx context.Context, interval time.Duration) {
+	c.take()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.take()
+		}
+	}
+}
+
+func (c *Collector) take() {
+	snap, err := c.gather()
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snapshots = append(c.snapshots, snap)
+	// Keep the newest snapshot that is at least a window old as the baseline
+	for len(c.snapshots) > 1 && snap.at.Sub(c.snapshots[1].at) >= c.window {
+		c.snapshots = c.snapshots[1:]
+	}
+}
+
+func (c *Collector) gather() (snapshot, error) {
+	families, err := c.gatherer.Gather()
+	if err != nil {
+		return snapshot{}, err
+	}
+	snap := snapshot{at: time.Now(), families: make(map[string]*dto.MetricFamily, len(families))}
+	for _, f := range families {
+		snap.families[f.GetName()] = f
+	}
+	return snap, nil
+}
+
+// Stats compares the current metrics with the start of the window
+func (c *Collector) Stats() (*Stats, error) {
+	now, err := c.gather()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	base := snapshot{at: now.at, families: map[string]*dto.MetricFamily{}}
+	if len(c.snapshots) > 0 {
+		base = c.snapshots[0]
+	}
+	c.mu.Unlock()
+
+	elapsed := now.at.Sub(base.at).Seconds()
+	perSecond := func(v float64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return v / elapsed
+	}
+
+	stats := &Stats{
+		Since:     base.at,
+		Window:    elapsed,
+		Routes:    []RouteStats{},
+		Upstreams: []UpstreamStats{},
+		RateLimit: []RateLimitStats{},
+	}
+
+	requests := counterDelta(base, now, namespace+"_requests_total", "route")
+	errors := counterDeltaWhere(base, now, namespace+"_requests_total", "route", func(labels map[string]string) bool {
+		status, _ := strconv.Atoi(labels["status"])
+		return status >= 500
+	})
+	routeLatency := histogramDelta(base, now, namespace+"_request_duration_seconds", "route")
+	for _, route := range sortedKeys(requests) {
+		rs := RouteStats{
+			Route:             route,
+			Requests:          requests[route],
+			RequestsPerSecond: perSecond(requests[route]),
+			Latency:           routeLatency[route].latency(),
+		}
+		if rs.Requests > 0 {
+			rs.ErrorRate = errors[route] / rs.Requests
+		}
+		stats.Routes = append(stats.Routes, rs)
+	}
+
+	responses := counterDelta(base, now, namespace+"_upstream_responses_total", "service")
+	failures := counterDelta(base, now, namespace+"_upstream_errors_total", "service")
+	serverErrors := counterDeltaWhere(base, now, namespace+"_upstream_responses_total", "service", func(labels map[string]string) bool {
+		status, _ := strconv.Atoi(labels["status"])
+		return status >= 500
+	})
+	upstreamLatency := histogramDelta(base, now, namespace+"_upstream_duration_seconds", "service")
+	for _, svc := range sortedKeys(responses, failures) {
+		total := responses[svc] + failures[svc]
+		stats.Upstreams = append(stats.Upstreams, UpstreamStats{
+			Service:           svc,
+			Requests:          total,
+			RequestsPerSecond: perSecond(total),
+			Errors:            failures[svc],
+			ServerErrors:      serverErrors[svc],
+			Latency:           upstreamLatency[svc].latency(),
+		})
+	}
+
+	rejections := counterDelta(base, now, namespace+"_rate_limit_rejections_total", "route")
+	for _, route := range sortedKeys(rejections) {
+		stats.RateLimit = append(stats.RateLimit, RateLimitStats{Route: route, Rejections: rejections[route]})
+	}
+
+	return stats, nil
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func counterDelta(base, now snapshot, name, by string) map[string]float64 {
+	return counterDeltaWhere(base, now, name, by, nil)
+}
+
+// counterDeltaWhere sums a counter's growth by one label, optionally only
+// over series matching keep
+func counterDeltaWhere(base, now snapshot, name, by string, keep func(map[string]string) bool) map[string]float64 {
+	sum := func(snap snapshot) map[string]float64 {
+		totals := make(map[string]float64)
+		if f, ok := snap.families[name]; ok {
+			for _, m := range f.GetMetric() {
+				labels := labelMap(m)
+				if keep == nil || keep(labels) {
+					totals[labels[by]] += m.GetCounter().GetValue()
+				}
+			}
+		}
+		return totals
+	}
+
+	before, after := sum(base), sum(now)
+	for key, value := range after {
+		after[key] = value - before[key]
+		// Series that vanished and came back restart from zero
+		if after[key] < 0 {
+			after[key] = value
+		}
+		if after[key] == 0 {
+			delete(after, key)
+		}
+	}
+	return after
+}
+
+// buckets maps each upper bound to the cumulative count at or below it
+type buckets map[float64]float64
+
+func histogramDelta(base, now snapshot, name, by string) map[string]buckets {
+	sum := func(snap snapshot) map[string]buckets {
+		totals := make(map[string]buckets)
+		if f, ok := snap.families[name]; ok {
+			for _, m := range f.GetMetric() {
+				key := labelMap(m)[by]
+				if totals[key] == nil {
+					totals[key] = make(buckets)
+				}
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					totals[key][b.GetUpperBound()] += float64(b.GetCumulativeCount())
+				}
+				totals[key][math.Inf(1)] += float64(h.GetSampleCount())
+			}
+		}
+		return totals
+	}
+
+	before, after := sum(base), sum(now)
+	for key, counts := range after {
+		for bound, count := range counts {
+			if delta := count - before[key][bound]; delta >= 0 {
+				counts[bound] = delta
+			}
+		}
+	}
+	return after
+}
+
+func (b buckets) latency() Latency {
+	return Latency{
+		P50: b.quantile(0.50) * 1000,
+		P95: b.quantile(0.95) * 1000,
+		P99: b.quantile(0.99) * 1000,
+	}
+}
+
+// quantile interpolates linearly within the bucket holding the rank, as
+// Prometheus' histogram_quantile does
+func (b buckets) quantile(q float64) float64 {
+	total := b[math.Inf(1)]
+	if total <= 0 {
+		return 0
+	}
+
+	bounds := make([]float64, 0, len(b))
+	for bound := range b {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	rank := q * total
+	lower, below := 0.0, 0.0
+	for _, bound := range bounds {
+		count := b[bound]
+		if count >= rank {
+			if math.IsInf(bound, 1) {
+				return lower
+			}
+			if count == below {
+				return bound
+			}
+			return lower + (bound-lower)*(rank-below)/(count-below)
+		}
+		lower, below = bound, count
+	}
+	return lower
+}
+
+func sortedKeys(maps ...map[string]float64) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gateway"
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests handled by the gateway.",
+	}, []string{"route", "method", "status"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "End-to-end request latency observed by the gateway.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	UpstreamResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_responses_total",
+		Help:      "Responses received from backend services by status code.",
+	}, []string{"service", "status"})
+
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_duration_seconds",
+		Help:      "Time until response headers were received from a backend service.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service"})
+
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed upstream calls, including requests rejected by an open circuit breaker.",
+	}, []string{"service", "reason"})
+
+	UpstreamCancellations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_cancellations_total",
+		Help:      "Upstream calls abandoned because the client went away. These aren't counted as upstream errors.",
+	}, []string{"service"})
+
+	IPAccessDenied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ip_access_denied_total",
+		Help:      "Requests rejected by IP or country rules, by scope (global or route path).",
+	}, []string{"scope"})
+
+	SplitRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "traffic_split_requests_total",
+		Help:      "Requests routed by a traffic split, by the service chosen.",
+	}, []string{"route", "service"})
+
+	MirrorRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirror_requests_total",
+		Help:      "Requests copied to shadow services, by outcome (sent, error, dropped, skipped).",
+	}, []string{"service", "outcome"})
+
+	OutlierEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outlier_ejections_total",
+		Help:      "Instances ejected from load balancing, by reason (error_rate, latency, consecutive_errors).",
+	}, []string{"service", "reason"})
+
+	OutlierEjected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outlier_ejected_instances",
+		Help:      "Instances currently ejected from load balancing.",
+	}, []string{"service"})
+
+	ZoneFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "zone_failovers_total",
+		Help:      "Requests of zone-aware services sent outside the gateway's zone.",
+	}, []string{"service"})
+
+	MessagesPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_published_total",
+		Help:      "Request bodies handed to message brokers, by outcome (published, retried, dead_lettered, dropped, rejected).",
+	}, []string{"broker", "topic", "outcome"})
+
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Upstream requests retried after a failed attempt.",
+	}, []string{"service"})
+
+	UpstreamConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_connections_total",
+		Help:      "Upstream connections obtained from the pool, by whether an idle connection was reused.",
+	}, []string{"service", "reused"})
+
+	UpstreamInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_inflight_requests",
+		Help:      "Upstream requests currently waiting for response headers.",
+	}, []string{"service"})
+
+	BulkheadActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bulkhead_active_requests",
+		Help:      "Requests holding a bulkhead slot, by service.",
+	}, []string{"service"})
+
+	BulkheadQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bulkhead_queued_requests",
+		Help:      "Requests waiting for a bulkhead slot, by service.",
+	}, []string{"service"})
+
+	BulkheadRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bulkhead_rejections_total",
+		Help:      "Requests rejected by a full bulkhead, by reason (queue_full or queue_timeout).",
+	}, []string{"service", "reason"})
+
+	LoadShedPressure = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_shed_pressure",
+		Help:      "Overload pressure from 0 (healthy) to 1 (only critical routes served).",
+	})
+
+	LoadShedCPU = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_shed_cpu_utilization",
+		Help:      "Share of available CPU used by the gateway at the last sample.",
+	})
+
+	LoadShedRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "load_shed_rejections_total",
+		Help:      "Requests shed while the gateway was overloaded, by route priority.",
+	}, []string{"priority"})
+
+	PriorityAdmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "priority_admitted_total",
+		Help:      "Proxied requests admitted by the priority queue, by priority.",
+	}, []string{"priority"})
+
+	PriorityQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "priority_queued_requests",
+		Help:      "Requests waiting in the priority queue, by priority.",
+	}, []string{"priority"})
+
+	PriorityQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "priority_queue_wait_seconds",
+		Help:      "Time requests waited in the priority queue, by priority.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"priority"})
+
+	PriorityQueueRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "priority_queue_rejections_total",
+		Help:      "Requests turned away by the priority queue, by priority and reason (queue_full, evicted or queue_timeout).",
+	}, []string{"priority", "reason"})
+
+	ExperimentAssignments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "experiment_assignments_total",
+		Help:      "Requests assigned to an experiment variant, by experiment and variant.",
+	}, []string{"experiment", "variant"})
+
+	SPIFFESVIDExpiry = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spiffe_svid_expiry_timestamp_seconds",
+		Help:      "Unix time at which the gateway's current SPIFFE SVID expires.",
+	})
+
+	ClientCertRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_cert_rejections_total",
+		Help:      "Requests rejected on routes requiring a client certificate, by reason (missing or not_allowed).",
+	}, []string{"reason"})
+
+	LogRecordsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_records_dropped_total",
+		Help:      "Log records not written, by level and reason (sampled, backpressure or buffer_full).",
+	}, []string{"level", "reason"})
+
+	DedupReplays = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dedup_replays_total",
+		Help:      "Duplicate requests answered with the response of the first, by route.",
+	}, []string{"route"})
+
+	ScheduleActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "schedule_active",
+		Help:      "Whether a schedule's window is open (1) or not (0), by schedule.",
+	}, []string{"schedule"})
+
+	ClientInFlightRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_in_flight_rejections_total",
+		Help:      "Requests rejected because their client had too many in flight, by key (ip or user).",
+	}, []string{"key_by"})
+
+	ExternalAuthzDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "external_authz_decisions_total",
+		Help:      "External authorization checks by outcome (allow, deny or error).",
+	}, []string{"decision"})
+
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter.",
+	}, []string{"route"})
+
+	RateLimitFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_fallbacks_total",
+		Help:      "Rate limit checks handled by the failure mode because Redis was unavailable.",
+	}, []string{"mode"})
+
+	RateLimitLeased = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_leased_total",
+		Help:      "Rate limit checks answered from a token lease without calling Redis.",
+	})
+
+	AnalyticsRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "analytics_records_total",
+		Help:      "Usage analytics records by outcome (written, dropped, failed).",
+	}, []string{"outcome"})
+
+	EventDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_deliveries_total",
+		Help:      "Gateway events sent to webhooks and Redis, by outcome (delivered, retried, failed, dropped).",
+	}, []string{"destination", "outcome"})
+
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state per service (0=closed, 1=half-open, 2=open).",
+	}, []string{"service"})
+)
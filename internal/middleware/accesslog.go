@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"time"
+
+	"api-gateway/internal/accesslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog records every completed request to the access log. Entries are
+// keyed by the matched route pattern so sampling can be set per route.
+func AccessLog(al *accesslog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = path
+		}
+
+		al.Log(accesslog.Entry{
+			Time:      start,
+			RequestID: c.GetString("request_id"),
+			ClientIP:  c.ClientIP(),
+			User:      c.GetString("username"),
+			Method:    c.Request.Method,
+			Path:      path,
+			Query:     query,
+			Route:     route,
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     c.Writer.Size(),
+			Latency:   time.Since(start),
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+
+			Experiment: c.GetString("experiment"),
+			Variant:    c.GetString("experiment_variant"),
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"time"
+
+	"api-gateway/internal/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Analytics records a usage record for every request to a route once it
+// completed. The consumer is the authenticated caller, or anonymous.
+func Analytics(recorder *analytics.Recorder, route, service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		consumer := c.GetString("user_id")
+		if consumer == "" {
+			consumer = "anonymous"
+		}
+		requestBytes := c.Request.ContentLength
+		if requestBytes < 0 {
+			requestBytes = 0
+		}
+		responseBytes := int64(c.Writer.Size())
+		if responseBytes < 0 {
+			responseBytes = 0
+		}
+
+		recorder.Record(analytics.Record{
+			Time:          start.UTC(),
+			Consumer:      consumer,
+			Tenant:        c.GetString("tenant_id"),
+			ClientIP:      c.ClientIP(),
+			Method:        c.Request.Method,
+			Route:         route,
+			Service:       service,
+			Status:        c.Writer.Status(),
+			RequestBytes:  requestBytes,
+			ResponseBytes: responseBytes,
+			LatencyMS:     float64(time.Since(start).Microseconds()) / 1000,
+			Experiment:    c.GetString("experiment"),
+			Variant:       c.GetString("experiment_variant"),
+		})
+	}
+}
@@ -1,54 +1,95 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"api-gateway/internal/service"
 	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTAuth(secret string) gin.HandlerFunc {
+// ClaimsValidator checks the claims of a verified token, e.g. that it is
+// bound to a tenant or device. An error rejects the request with a 403.
+type ClaimsValidator func(c *gin.Context, claims *utils.Claims) error
+
+// JWTAuth accepts the gateway's own tokens and, when external is set, RS/ES
+// tokens from trusted issuers. Tokens must then pass every validator.
+func JWTAuth(keys *service.SigningKeys, revocation *service.TokenRevocation, external *service.ExternalTokens, validators ...ClaimsValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Authorization header required"))
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format. Use: Bearer <token>"})
-			c.Abort()
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid authorization format. Use: Bearer <token>"))
 			return
 		}
 
 		tokenString := parts[1]
-		claims, err := utils.ValidateToken(tokenString, secret)
+		claims, err := validateToken(c, tokenString, keys, external)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or expired token"))
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
-		c.Set("role", claims.Role)
+		revoked, err := revocation.IsRevoked(c.Request.Context(), claims)
+		if err != nil {
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Unable to verify token"))
+			return
+		}
+		if revoked {
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Token has been revoked"))
+			return
+		}
 
+		if !acceptClaims(c, claims, validators) {
+			return
+		}
+
+		setClaims(c, claims)
 		c.Next()
 	}
 }
 
+// acceptClaims runs the validators, rejecting the request with a 403 on the
+// first failure
+func acceptClaims(c *gin.Context, claims *utils.Claims, validators []ClaimsValidator) bool {
+	for _, validate := range validators {
+		if err := validate(c, claims); err != nil {
+			utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Token claims not accepted").
+				WithDetail("reason", err.Error()))
+			return false
+		}
+	}
+	return true
+}
+
+// setClaims sets user information in context
+func setClaims(c *gin.Context, claims *utils.Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("email", claims.Email)
+	c.Set("role", claims.Role)
+	c.Set("permissions", claims.Permissions)
+	c.Set("scopes", claims.Scopes())
+	c.Set("claims", claims)
+	if claims.TenantID != "" {
+		c.Set("tenant_id", claims.TenantID)
+	}
+}
+
 func RoleAuth(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("role")
 		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role information not found"})
-			c.Abort()
+			utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Role information not found"))
 			return
 		}
 
@@ -60,7 +101,67 @@ func RoleAuth(requiredRoles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		c.Abort()
+		utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Insufficient permissions"))
+	}
+}
+
+func validateToken(c *gin.Context, tokenString string, keys *service.SigningKeys, external *service.ExternalTokens) (*utils.Claims, error) {
+	// Tokens issued before a rotation are signed with a retired key; those
+	// without a kid are tried against every key still in use
+	var claims *utils.Claims
+	var err error
+	policy := keys.Policy()
+	for _, key := range keys.Verification(utils.TokenKeyID(tokenString)) {
+		claims, err = utils.ValidateToken(tokenString, key, policy)
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
+		}
+	}
+	if err == nil || external == nil {
+		return claims, err
+	}
+
+	// Tokens none of the gateway's keys verify may come from a trusted
+	// issuer, which signs with its own RS/ES keys
+	if errors.Is(err, jwt.ErrTokenUnverifiable) || errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		return external.Validate(c.Request.Context(), tokenString)
+	}
+	return nil, err
+}
+
+// RequirePermission allows the request only if the caller's token grants
+// every listed permission. API keys are checked against their scopes.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("permissions")
+		for _, required := range permissions {
+			if !service.HasPermission(granted, required) {
+				utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Insufficient permissions").
+					WithDetail("permission", required))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects scoped tokens that lack any of the listed scopes.
+// Callers without scopes are not restricted.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("scopes")
+		if len(granted) == 0 {
+			c.Next()
+			return
+		}
+
+		for _, required := range scopes {
+			if !service.HasPermission(granted, required) {
+				utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Insufficient scope").
+					WithDetail("scope", required))
+				return
+			}
+		}
+		c.Next()
 	}
 }
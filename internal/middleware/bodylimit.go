@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit rejects requests whose body exceeds limit bytes. Declared
+// lengths are checked up front; chunked bodies fail when the reader
+// crosses the limit. A limit of zero disables the check.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			utils.AbortWithError(c, utils.NewError(http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Request body too large").
+				WithDetail("limit", limit))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
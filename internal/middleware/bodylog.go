@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLog logs the request and response bodies of sampled requests to
+// route, redacted and cut off at the configured size. Bodies are copied
+// as they stream through, so nothing is buffered beyond the cut-off.
+func BodyLog(bodies *service.BodyLogger, route string, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := bodies.Capture(route)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		reqBody := &cappedBuffer{limit: limit}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &teeReadCloser{Reader: io.TeeReader(c.Request.Body, reqBody), Closer: c.Request.Body}
+		}
+		reqHeader := c.Request.Header.Clone()
+
+		w := &bodyCaptureWriter{ResponseWriter: c.Writer, body: &cappedBuffer{limit: limit}}
+		c.Writer = w
+
+		c.Next()
+
+		redactor := bodies.Redactor()
+		log.WithContext(c).Infow("Request bodies captured",
+			"route", route,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", w.Status(),
+			"request_headers", redactor.Headers(reqHeader),
+			"request_body", renderBody(redactor, reqBody, reqHeader.Get("Content-Type"), reqHeader.Get("Content-Encoding")),
+			"request_truncated", reqBody.truncated(),
+			"response_headers", redactor.Headers(w.Header()),
+			"response_body", renderBody(redactor, w.body, w.Header().Get("Content-Type"), w.encoding),
+			"response_truncated", w.body.truncated(),
+		)
+	}
+}
+
+func renderBody(redactor *service.Redactor, body *cappedBuffer, contentType, encoding string) string {
+	if body.total == 0 {
+		return ""
+	}
+	if encoding != "" && encoding != "identity" {
+		return "[" + encoding + " encoded body omitted]"
+	}
+	return redactor.Body(body.buf, contentType)
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the rest
+type cappedBuffer struct {
+	buf   []byte
+	limit int
+	total int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.limit - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b.total > int64(len(b.buf))
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyCaptureWriter copies the response body into a capped buffer. The
+// content encoding is noted at the first write, before the compressing
+// writer underneath may set its own.
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body     *cappedBuffer
+	encoding string
+	started  bool
+}
+
+func (w *bodyCaptureWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.encoding = w.Header().Get("Content-Encoding")
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bodyCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
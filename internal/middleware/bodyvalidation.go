@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"api-gateway/internal/schema"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateBody rejects request bodies whose media type is not one of
+// contentTypes with 415. JSON bodies are checked against bodySchema, if
+// any: malformed JSON is rejected with 400 and schema violations with 400
+// listing them. With a schema, POST, PUT and PATCH requests must carry a
+// body and encoded bodies are refused, as they can't be inspected.
+func ValidateBody(contentTypes []string, bodySchema *schema.Schema) gin.HandlerFunc {
+	if len(contentTypes) == 0 && bodySchema != nil {
+		contentTypes = []string{"application/json", "application/*+json"}
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			switch c.Request.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+				if bodySchema != nil {
+					utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeValidation, "Request body is required"))
+					return
+				}
+			}
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || (len(contentTypes) > 0 && !acceptsMediaType(contentTypes, mediaType)) {
+			utils.AbortWithError(c, utils.NewError(http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia, "Unsupported content type").
+				WithDetail("accepted", contentTypes))
+			return
+		}
+		if bodySchema == nil || !isJSONMediaType(mediaType) {
+			c.Next()
+			return
+		}
+		if encoding := c.GetHeader("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+			utils.AbortWithError(c, utils.NewError(http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia, "Encoded request bodies are not accepted on this route"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.AbortWithError(c, utils.NewError(http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Request body too large").
+					WithDetail("limit", tooLarge.Limit))
+			} else {
+				utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Failed to read request body"))
+			}
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		violations, err := bodySchema.Validate(body)
+		if err != nil {
+			utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Malformed JSON body").
+				WithDetail("error", err.Error()))
+			return
+		}
+		if len(violations) > 0 {
+			utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeValidation, "Request body does not match the schema").
+				WithDetail("errors", violations))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// acceptsMediaType matches mediaType against type/subtype patterns, where
+// the subtype may be * or, as in application/*+json, a suffix
+func acceptsMediaType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		pattern, _, _ = mime.ParseMediaType(pattern)
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		prefix, subtype, _ := strings.Cut(pattern, "/")
+		if !strings.HasPrefix(mediaType, prefix+"/") {
+			continue
+		}
+		if subtype == "*" || strings.HasPrefix(subtype, "*+") && strings.HasSuffix(mediaType, subtype[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
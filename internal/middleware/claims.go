@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimRules returns a validator enforcing the configured claim rules
+func ClaimRules(rules []config.ClaimRule) ClaimsValidator {
+	return func(c *gin.Context, claims *utils.Claims) error {
+		for _, rule := range rules {
+			// The gateway's own tokens carry empty strings for unset fields
+			value, ok := claims.Claim(rule.Claim)
+			if !ok || value == "" {
+				if rule.Optional {
+					continue
+				}
+				return fmt.Errorf("claim %s is required", rule.Claim)
+			}
+
+			values := claimStrings(value)
+			if len(rule.Values) > 0 && !containsAny(values, rule.Values) {
+				return fmt.Errorf("claim %s is not accepted", rule.Claim)
+			}
+			if rule.Header != "" {
+				if header := c.GetHeader(rule.Header); header != "" && !containsAny(values, []string{header}) {
+					return fmt.Errorf("claim %s does not match %s", rule.Claim, rule.Header)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// claimStrings renders a claim as strings, one per element for arrays
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func containsAny(values, accepted []string) bool {
+	for _, want := range accepted {
+		for _, value := range values {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
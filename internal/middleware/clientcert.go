@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers carrying the verified client certificate to upstream services
+const (
+	ClientCertHeaderPrefix      = "X-Client-Cert-"
+	ClientCertSubjectHeader     = "X-Client-Cert-Subject"
+	ClientCertIssuerHeader      = "X-Client-Cert-Issuer"
+	ClientCertSANHeader         = "X-Client-Cert-SAN"
+	ClientCertSerialHeader      = "X-Client-Cert-Serial"
+	ClientCertFingerprintHeader = "X-Client-Cert-Fingerprint"
+)
+
+// ClientCert passes the client certificate verified during the handshake
+// on to the context and the upstream service. Headers a client sent under
+// the X-Client-Cert- prefix are always removed first. With rules requiring
+// a certificate, requests without one are rejected with a 401 and those
+// whose certificate matches neither the subjects nor the SANs with a 403.
+func ClientCert(rules *config.ClientCertConfig) gin.HandlerFunc {
+	required := rules != nil && rules.Required
+	return func(c *gin.Context) {
+		header := c.Request.Header
+		for name := range header {
+			if strings.HasPrefix(name, ClientCertHeaderPrefix) {
+				header.Del(name)
+			}
+		}
+
+		cert := verifiedClientCert(c.Request)
+		if cert == nil {
+			if required {
+				metrics.ClientCertRejections.WithLabelValues("missing").Inc()
+				utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Client certificate required"))
+				return
+			}
+			c.Next()
+			return
+		}
+		if required && !clientCertAllowed(cert, rules) {
+			metrics.ClientCertRejections.WithLabelValues("not_allowed").Inc()
+			utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Client certificate not allowed"))
+			return
+		}
+
+		sum := sha256.Sum256(cert.Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		sans := clientCertSANs(cert)
+
+		c.Set("client_cert_subject", cert.Subject.String())
+		c.Set("client_cert_cn", cert.Subject.CommonName)
+		c.Set("client_cert_sans", sans)
+		c.Set("client_cert_fingerprint", fingerprint)
+
+		header.Set(ClientCertSubjectHeader, cert.Subject.String())
+		header.Set(ClientCertIssuerHeader, cert.Issuer.String())
+		header.Set(ClientCertSerialHeader, cert.SerialNumber.Text(16))
+		header.Set(ClientCertFingerprintHeader, fingerprint)
+		if len(sans) > 0 {
+			header.Set(ClientCertSANHeader, strings.Join(sans, ","))
+		}
+
+		c.Next()
+	}
+}
+
+// verifiedClientCert returns the leaf of the client's verified chain, or
+// nil if the client presented no certificate or the listener has no CAs
+// to verify it against
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// clientCertSANs lists the subject alternative names in the form
+// DNS:name, URI:uri, email:address or IP:address
+func clientCertSANs(cert *x509.Certificate) []string {
+	var sans []string
+	for _, name := range cert.DNSNames {
+		sans = append(sans, "DNS:"+name)
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, "URI:"+uri.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		sans = append(sans, "email:"+email)
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, "IP:"+ip.String())
+	}
+	return sans
+}
+
+// clientCertAllowed matches the certificate against the route's subjects
+// and SANs; without either list any verified certificate is allowed
+func clientCertAllowed(cert *x509.Certificate, rules *config.ClientCertConfig) bool {
+	if len(rules.Subjects) == 0 && len(rules.SANs) == 0 {
+		return true
+	}
+	for _, subject := range rules.Subjects {
+		if subject == cert.Subject.CommonName || subject == cert.Subject.String() {
+			return true
+		}
+	}
+	for _, san := range clientCertSANs(cert) {
+		_, value, _ := strings.Cut(san, ":")
+		for _, allowed := range rules.SANs {
+			if allowed == value {
+				return true
+			}
+		}
+	}
+	return false
+}
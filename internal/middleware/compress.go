@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"api-gateway/internal/config"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	gzipWriters   = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }}
+)
+
+// Compression compresses responses with brotli or gzip, whichever the
+// client prefers. Bodies are buffered up to MinSize before deciding, so
+// small responses go out unchanged. Responses that are already encoded or
+// whose content type isn't allowed are passed through.
+func Compression(cfg config.CompressionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Enabled || c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &compressWriter{ResponseWriter: original, cfg: cfg, encoding: encoding, status: original.Status()}
+		c.Writer = w
+		defer func() { c.Writer = original }()
+
+		c.Next()
+		w.finish()
+	}
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header,
+// honouring q-values and preferring br on ties
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if (name != "br" && name != "gzip") || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the status and the first MinSize bytes until it
+// knows whether the response is worth compressing
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      config.CompressionConfig
+	encoding string
+
+	status        int
+	headerWritten bool
+	decided       bool
+	buf           []byte
+	encoder       io.WriteCloser
+	flusher       interface{ Flush() error }
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.headerWritten = true
+}
+
+func (w *compressWriter) Status() int {
+	if w.decided {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *compressWriter) Written() bool {
+	return w.headerWritten || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.headerWritten = true
+	if !w.decided {
+		if !w.eligible() {
+			w.start(false)
+		} else {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) >= w.cfg.MinSize {
+				w.start(true)
+			}
+			return len(p), nil
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush means the handler is streaming, so compression starts without
+// waiting for MinSize
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(w.eligible())
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) eligible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || w.status < http.StatusOK ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	// Content-Range counts the bytes of the body as sent, so byte ranges
+	// go out unchanged
+	if w.status == http.StatusPartialContent || header.Get("Content-Range") != "" {
+		return false
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < w.cfg.MinSize {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	// Event streams go out uncompressed so no proxy along the way holds
+	// events back waiting for a full compressed block
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, allowed := range w.cfg.ContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) start(compress bool) {
+	w.decided = true
+	header := w.Header()
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// Ranges the backend serves are of the uncompressed body
+		header.Del("Accept-Ranges")
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if !compress {
+		if w.headerWritten {
+			w.ResponseWriter.WriteHeaderNow()
+		}
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+		return
+	}
+
+	w.ResponseWriter.WriteHeaderNow()
+	switch w.encoding {
+	case "br":
+		bw := brotliWriters.Get().(*brotli.Writer)
+		bw.Reset(w.ResponseWriter)
+		w.encoder, w.flusher = bw, bw
+	default:
+		gw := gzipWriters.Get().(*gzip.Writer)
+		gw.Reset(w.ResponseWriter)
+		w.encoder, w.flusher = gw, gw
+	}
+	if len(w.buf) > 0 {
+		w.encoder.Write(w.buf)
+	}
+	w.buf = nil
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift
+// the write deadline for streams
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends whatever is still buffered and closes the encoder
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.start(false)
+		return
+	}
+	if w.encoder == nil {
+		return
+	}
+
+	w.encoder.Close()
+	switch enc := w.encoder.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(enc)
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	}
+	w.encoder = nil
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dedupPollInterval is how often a duplicate checks on the request it
+// waits for
+const dedupPollInterval = 100 * time.Millisecond
+
+// Dedup answers a POST, PUT, PATCH or DELETE repeating the method, URL and
+// body of one the same caller sent on route within window with the first
+// response, marked X-Deduplicated. A duplicate arriving while the first is
+// still running waits for its response. Like idempotency keys, server
+// errors aren't kept and duplicates go through while Redis is unavailable.
+func Dedup(store *service.IdempotencyStore, cfg config.DedupConfig, window time.Duration, route string, log *logger.Logger) gin.HandlerFunc {
+	if window <= 0 {
+		window = cfg.Window
+	}
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		body, ok := bufferBody(c)
+		if !ok {
+			return
+		}
+
+		ctx := context.Background()
+		fingerprint := requestFingerprint(c.Request, body)
+		key := dedupScope(c, route, fingerprint)
+
+		// Duplicates of a request still running wait for it, then replay
+		// its response or, if it kept none, compete for the lock again
+		for {
+			stored, err := store.Get(ctx, key)
+			if err != nil {
+				log.WithContext(c).Warnw("Dedup lookup failed", "route", route, "error", err)
+				c.Next()
+				return
+			}
+			if stored != nil {
+				replayDuplicate(c, route, stored)
+				return
+			}
+
+			locked, err := store.Lock(ctx, key, cfg.LockTimeout)
+			if err != nil {
+				log.WithContext(c).Warnw("Dedup lock failed", "route", route, "error", err)
+				c.Next()
+				return
+			}
+			if locked {
+				break
+			}
+			if err := awaitRelease(c.Request.Context(), store, key); err != nil {
+				utils.AbortWithError(c, utils.NewError(http.StatusConflict, utils.CodeConflict, "An identical request is still in progress"))
+				return
+			}
+		}
+		// The first request may have saved its response and released the
+		// lock since the lookup
+		if stored, err := store.Get(ctx, key); err == nil && stored != nil {
+			if err := store.Unlock(ctx, key); err != nil {
+				log.WithContext(c).Warnw("Dedup unlock failed", "route", route, "error", err)
+			}
+			replayDuplicate(c, route, stored)
+			return
+		}
+
+		w := &idempotencyWriter{ResponseWriter: c.Writer, body: &cappedBuffer{limit: cfg.MaxSize}}
+		c.Writer = w
+
+		c.Next()
+
+		w.snapshot()
+		if !w.Written() || w.Status() >= http.StatusInternalServerError || w.body.truncated() {
+			if err := store.Unlock(ctx, key); err != nil {
+				log.WithContext(c).Warnw("Dedup unlock failed", "route", route, "error", err)
+			}
+			return
+		}
+
+		resp := &service.IdempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      w.Status(),
+			Header:      w.header,
+			Body:        w.body.buf,
+		}
+		if err := store.Save(ctx, key, resp, window); err != nil {
+			log.WithContext(c).Warnw("Failed to store deduplicated response", "route", route, "error", err)
+		}
+	}
+}
+
+// awaitRelease waits until no request holds key. Saving a response
+// releases the lock in the same transaction, so a response is stored by
+// then if the request kept one.
+func awaitRelease(ctx context.Context, store *service.IdempotencyStore, key string) error {
+	ticker := time.NewTicker(dedupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		locked, err := store.Locked(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+	}
+}
+
+// dedupScope keys a request by route, caller and content, so only a
+// caller's own repeats are answered from its earlier responses
+func dedupScope(c *gin.Context, route, fingerprint string) string {
+	caller := c.GetString("user_id")
+	if caller == "" {
+		caller = "ip:" + c.ClientIP()
+	}
+	sum := sha256.Sum256([]byte("dedup\n" + route + "\n" + c.GetString("tenant_id") + "\n" + caller + "\n" + fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+func replayDuplicate(c *gin.Context, route string, stored *service.IdempotentResponse) {
+	metrics.DedupReplays.WithLabelValues(route).Inc()
+	for name, values := range stored.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("X-Deduplicated", "true")
+	c.Status(stored.Status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(stored.Body)
+	c.Abort()
+}
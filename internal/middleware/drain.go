@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackInFlight counts the request as in flight until it completes so a
+// drain can wait for it
+func TrackInFlight(drainer *service.Drainer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		drainer.Acquire()
+		defer drainer.Release()
+		c.Next()
+	}
+}
+
+// RejectWhileDraining refuses requests once drain mode has started
+func RejectWhileDraining(drainer *service.Drainer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if drainer.Draining() {
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Gateway is draining"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentHeader carries a request's experiment and variant, as
+// name=variant, to the upstream service and back to the client
+const ExperimentHeader = "X-Experiment"
+
+// Experiment assigns the caller to a variant of the experiment the route
+// is in and hands its service to the proxy as experiment_service. Only
+// authenticated callers take part; the others get the route's own
+// service.
+func Experiment(experiments *service.Experiments, routePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Never trust an assignment sent by the client
+		c.Request.Header.Del(ExperimentHeader)
+
+		exp := experiments.ForRoute(routePath)
+		userID := c.GetString("user_id")
+		if exp == nil || userID == "" {
+			c.Next()
+			return
+		}
+
+		variant := exp.Assign(userID)
+		c.Set("experiment", exp.Name)
+		c.Set("experiment_variant", variant.Name)
+		c.Set("experiment_service", variant.Service)
+
+		tag := exp.Name + "=" + variant.Name
+		c.Header(ExperimentHeader, tag)
+		c.Request.Header.Set(ExperimentHeader, tag)
+		metrics.ExperimentAssignments.WithLabelValues(exp.Name, variant.Name).Inc()
+
+		c.Next()
+	}
+}
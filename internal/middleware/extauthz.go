@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExternalAuthz asks the external decision point whether the caller may
+// make this request. When the decision point fails, the failure mode
+// decides: open lets the request through, closed rejects it with 503.
+func ExternalAuthz(authz *service.ExternalAuthz, route string, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input := service.AuthzInput{
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Route:    route,
+			ClientIP: c.ClientIP(),
+			Subject:  authzSubject(c),
+		}
+		if names := authz.Headers(); len(names) > 0 {
+			input.Headers = make(map[string]string, len(names))
+			for _, name := range names {
+				if value := c.GetHeader(name); value != "" {
+					input.Headers[strings.ToLower(name)] = value
+				}
+			}
+		}
+
+		decision, err := authz.Check(c.Request.Context(), input)
+		if err != nil {
+			metrics.ExternalAuthzDecisions.WithLabelValues("error").Inc()
+			log.WithContext(c).Errorw("External authorization failed", "route", route, "error", err)
+			if authz.FailOpen() {
+				c.Next()
+				return
+			}
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Authorization service unavailable"))
+			return
+		}
+
+		if !decision.Allow {
+			metrics.ExternalAuthzDecisions.WithLabelValues("deny").Inc()
+			apiErr := utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Access denied by policy")
+			if decision.Reason != "" {
+				apiErr = apiErr.WithDetail("reason", decision.Reason)
+			}
+			utils.AbortWithError(c, apiErr)
+			return
+		}
+
+		metrics.ExternalAuthzDecisions.WithLabelValues("allow").Inc()
+		c.Next()
+	}
+}
+
+// authzSubject collects what the auth middleware learned about the caller
+func authzSubject(c *gin.Context) map[string]interface{} {
+	subject := make(map[string]interface{})
+	for _, key := range []string{"user_id", "username", "email", "role", "permissions", "scopes", "api_key_id", "client_cert_subject", "client_cert_sans"} {
+		if value, ok := c.Get(key); ok {
+			subject[key] = value
+		}
+	}
+	if claims, ok := c.Get("claims"); ok {
+		subject["claims"] = claims
+	}
+	if len(subject) == 0 {
+		return nil
+	}
+	return subject
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// Idempotency replays the stored response when a POST, PUT or PATCH
+// repeats an idempotency key already used by the same caller on route.
+// Reusing a key for a different request is rejected with 422 and a
+// duplicate arriving while the first is still running with 409. Server
+// errors aren't stored, so those requests can be retried. Keys are not
+// enforced while Redis is unavailable.
+func Idempotency(store *service.IdempotencyStore, cfg config.IdempotencyConfig, route string, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+		idempotencyKey := c.GetHeader(cfg.Header)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, cfg.Header+" is too long").
+				WithDetail("max_length", maxIdempotencyKeyLength))
+			return
+		}
+
+		body, ok := bufferBody(c)
+		if !ok {
+			return
+		}
+
+		ctx := context.Background()
+		key := idempotencyScope(c, route, idempotencyKey)
+		fingerprint := requestFingerprint(c.Request, body)
+
+		stored, err := store.Get(ctx, key)
+		if err != nil {
+			log.WithContext(c).Warnw("Idempotency lookup failed", "route", route, "error", err)
+			c.Next()
+			return
+		}
+		if stored != nil {
+			replayResponse(c, cfg.Header, stored, fingerprint)
+			return
+		}
+
+		locked, err := store.Lock(ctx, key, cfg.LockTimeout)
+		if err != nil {
+			log.WithContext(c).Warnw("Idempotency lock failed", "route", route, "error", err)
+			c.Next()
+			return
+		}
+		if !locked {
+			utils.AbortWithError(c, utils.NewError(http.StatusConflict, utils.CodeConflict, "A request with this "+cfg.Header+" is still in progress"))
+			return
+		}
+		// The first request may have saved its response and released the
+		// lock since the lookup above
+		if stored, err := store.Get(ctx, key); err == nil && stored != nil {
+			if err := store.Unlock(ctx, key); err != nil {
+				log.WithContext(c).Warnw("Idempotency unlock failed", "route", route, "error", err)
+			}
+			replayResponse(c, cfg.Header, stored, fingerprint)
+			return
+		}
+
+		w := &idempotencyWriter{ResponseWriter: c.Writer, body: &cappedBuffer{limit: cfg.MaxSize}}
+		c.Writer = w
+
+		c.Next()
+
+		// Responses written further out, such as the 504 of an expired
+		// budget, never reach the writer and aren't stored either
+		w.snapshot()
+		if !w.Written() || w.Status() >= http.StatusInternalServerError || w.body.truncated() {
+			if err := store.Unlock(ctx, key); err != nil {
+				log.WithContext(c).Warnw("Idempotency unlock failed", "route", route, "error", err)
+			}
+			return
+		}
+
+		resp := &service.IdempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      w.Status(),
+			Header:      w.header,
+			Body:        w.body.buf,
+		}
+		if err := store.Save(ctx, key, resp, cfg.TTL); err != nil {
+			log.WithContext(c).Warnw("Failed to store idempotent response", "route", route, "error", err)
+		}
+	}
+}
+
+// bufferBody reads the request body and puts it back for the handlers
+// further on. It aborts the request and reports false if the body can't be
+// read.
+func bufferBody(c *gin.Context) ([]byte, bool) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.AbortWithError(c, utils.NewError(http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Request body too large").
+				WithDetail("limit", tooLarge.Limit))
+		} else {
+			utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Failed to read request body"))
+		}
+		return nil, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// idempotencyScope keys the stored response by route and caller, so one
+// client can't replay another's response by guessing its key
+func idempotencyScope(c *gin.Context, route, idempotencyKey string) string {
+	caller := c.GetString("user_id")
+	if caller == "" {
+		caller = "ip:" + c.ClientIP()
+	}
+	sum := sha256.Sum256([]byte(route + "\n" + c.GetString("tenant_id") + "\n" + caller + "\n" + idempotencyKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(c *gin.Context, header string, stored *service.IdempotentResponse, fingerprint string) {
+	if stored.Fingerprint != fingerprint {
+		utils.AbortWithError(c, utils.NewError(http.StatusUnprocessableEntity, utils.CodeIdempotencyReuse, header+" was already used for a different request"))
+		return
+	}
+
+	for name, values := range stored.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(stored.Status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(stored.Body)
+	c.Abort()
+}
+
+// idempotencyWriter keeps a copy of the response. Headers are copied when
+// the response starts, before outer writers such as compression add
+// their own.
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body   *cappedBuffer
+	header http.Header
+}
+
+func (w *idempotencyWriter) snapshot() {
+	if w.header != nil {
+		return
+	}
+	w.header = w.Header().Clone()
+	w.header.Del("X-Request-ID")
+}
+
+func (w *idempotencyWriter) WriteHeaderNow() {
+	w.snapshot()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *idempotencyWriter) Write(p []byte) (int, error) {
+	w.snapshot()
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.snapshot()
+	w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *idempotencyWriter) Flush() {
+	w.snapshot()
+	w.ResponseWriter.Flush()
+}
+
+func (w *idempotencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers carrying the authenticated caller to upstream services
+const (
+	UserIDHeader    = "X-User-ID"
+	UserRoleHeader  = "X-User-Role"
+	UserEmailHeader = "X-User-Email"
+)
+
+// Identity passes the caller authenticated by the route's chain on to the
+// upstream service, so it doesn't have to parse the token again. Headers
+// a client sent under the same names are always removed first; on public
+// routes they stay empty.
+func Identity(cfg config.IdentityConfig, keys *service.SigningKeys, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Request.Header
+		header.Del(UserIDHeader)
+		header.Del(UserRoleHeader)
+		header.Del(UserEmailHeader)
+		if cfg.InternalTokenHeader != "" {
+			header.Del(cfg.InternalTokenHeader)
+		}
+
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		if cfg.Headers {
+			setIfPresent(c, UserIDHeader, userID)
+			setIfPresent(c, UserRoleHeader, c.GetString("role"))
+			setIfPresent(c, UserEmailHeader, c.GetString("email"))
+		}
+
+		if cfg.InternalToken {
+			token, err := utils.GenerateInternalToken(callerClaims(c), keys.Current(), cfg.InternalTokenExpiry)
+			if err != nil {
+				log.WithContext(c).Errorw("Failed to issue internal token", "error", err)
+			} else {
+				header.Set(cfg.InternalTokenHeader, token)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func setIfPresent(c *gin.Context, name, value string) {
+	if value != "" {
+		c.Request.Header.Set(name, value)
+	}
+}
+
+// callerClaims describes the caller for the internal token, whichever
+// way they authenticated
+func callerClaims(c *gin.Context) utils.Claims {
+	if value, ok := c.Get("claims"); ok {
+		if claims, ok := value.(*utils.Claims); ok {
+			return *claims
+		}
+	}
+	return utils.Claims{
+		UserID:      c.GetString("user_id"),
+		Username:    c.GetString("username"),
+		Role:        c.GetString("role"),
+		TenantID:    c.GetString("tenant_id"),
+		Permissions: c.GetStringSlice("permissions"),
+		Scope:       strings.Join(c.GetStringSlice("scopes"), " "),
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInFlight rejects a request while its client already has limit
+// requests in flight, keyed by ip or user. The slot is held until the
+// response completes, streams included. Requests without a key, e.g.
+// anonymous ones keyed by user, pass. A limit of zero disables the check.
+func ClientInFlight(inFlight *service.ClientInFlight, keyBy string, limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		var id string
+		switch keyBy {
+		case KeyByIP:
+			id = c.ClientIP()
+		case KeyByUser:
+			id = c.GetString("user_id")
+		}
+		if id == "" {
+			c.Next()
+			return
+		}
+
+		key := keyBy + "=" + id
+		if !inFlight.Acquire(key, limit) {
+			metrics.ClientInFlightRejections.WithLabelValues(keyBy).Inc()
+			c.Header("Retry-After", "1")
+			utils.AbortWithError(c, utils.NewError(http.StatusTooManyRequests, utils.CodeRateLimited, "Too many concurrent requests"))
+			return
+		}
+		defer inFlight.Release(key)
+		c.Next()
+	}
+}
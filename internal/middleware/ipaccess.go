@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPAccess rejects clients that the filter's rules for scope don't allow.
+// scope is service.GlobalScope or a route path.
+func IPAccess(filter *service.IPFilter, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !filter.Allowed(scope, net.ParseIP(c.ClientIP())) {
+			metrics.IPAccessDenied.WithLabelValues(scope).Inc()
+			utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Access denied"))
+			return
+		}
+		c.Next()
+	}
+}
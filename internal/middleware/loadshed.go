@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoadShed rejects a share of the route's requests while the gateway is
+// overloaded and queues them while it is at capacity, by priority. The
+// priority of the caller's API key, tenant or role, from rolePriorities,
+// replaces the route's unless the route is critical, so it runs after
+// authentication.
+func LoadShed(shedder *service.LoadShedder, routePriority string, rolePriorities map[string]string) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(shedder.RetryAfter().Seconds()))
+
+	return func(c *gin.Context) {
+		consumer := c.GetString("priority")
+		if consumer == "" {
+			consumer = rolePriorities[c.GetString("role")]
+		}
+		priority := service.Priority(routePriority, consumer)
+
+		if shedder.Shed(priority) {
+			metrics.LoadShedRejections.WithLabelValues(priority).Inc()
+			c.Header("Retry-After", retryAfter)
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Gateway is overloaded"))
+			return
+		}
+
+		release, err := shedder.Admit(c.Request.Context(), priority)
+		if err != nil {
+			c.Header("Retry-After", retryAfter)
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Gateway is overloaded"))
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/rand"
 	"time"
 
 	"api-gateway/pkg/logger"
@@ -22,7 +23,8 @@ func RequestLogger(log *logger.Logger) gin.HandlerFunc {
 		clientIP := c.ClientIP()
 		userAgent := c.Request.UserAgent()
 
-		log.Infow("Request processed",
+		fields := []interface{}{
+			"request_id", c.GetString("request_id"),
 			"method", method,
 			"path", path,
 			"query", query,
@@ -30,18 +32,27 @@ func RequestLogger(log *logger.Logger) gin.HandlerFunc {
 			"latency", latency.String(),
 			"client_ip", clientIP,
 			"user_agent", userAgent,
-		)
+		}
+		if experiment := c.GetString("experiment"); experiment != "" {
+			fields = append(fields, "experiment", experiment, "variant", c.GetString("experiment_variant"))
+		}
+		log.Infow("Request processed", fields...)
 	}
 }
 
+// RequestID assigns each request an ID, reusing the client's X-Request-ID
+// if present. The ID is returned to the client, forwarded upstream and
+// carried in the request context for log correlation.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
-		if requestID == "" {
+		if requestID == "" || len(requestID) > 128 {
 			requestID = generateRequestID()
 		}
 		c.Set("request_id", requestID)
 		c.Header("X-Request-ID", requestID)
+		c.Request.Header.Set("X-Request-ID", requestID)
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
 		c.Next()
 	}
 }
@@ -53,8 +64,9 @@ func generateRequestID() string {
 func randString(n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
 	b := make([]byte, n)
+	rand.Read(b)
 	for i := range b {
-		b[i] = letters[time.Now().UnixNano()%int64(len(letters))]
+		b[i] = letters[int(b[i])%len(letters)]
 	}
 	return string(b)
 }
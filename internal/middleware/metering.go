@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"api-gateway/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metering bills every request made with an API key or on behalf of a
+// tenant units units. Requests the gateway or the upstream failed with a
+// server error are not billed.
+func Metering(meter *service.Meter, units int) gin.HandlerFunc {
+	if units <= 0 {
+		units = 1
+	}
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		apiKeyID, tenantID := c.GetString("api_key_id"), c.GetString("tenant_id")
+		if (apiKeyID == "" && tenantID == "") || c.Writer.Status() >= 500 {
+			return
+		}
+
+		requestBytes := c.Request.ContentLength
+		if requestBytes < 0 {
+			requestBytes = 0
+		}
+		responseBytes := int64(c.Writer.Size())
+		if responseBytes < 0 {
+			responseBytes = 0
+		}
+		meter.Record(apiKeyID, tenantID, int64(units), requestBytes, responseBytes)
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quota counts the request against every quota rule that applies to the
+// caller and rejects it with 429 once a quota is used up. Quotas are not
+// enforced while Redis is unavailable.
+func Quota(quotas *service.Quotas) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.Background()
+		overrides, _ := c.Get("quota_overrides")
+		keyOverrides, _ := overrides.(map[string]int64)
+		tenantOverrides, _ := c.Get("tenant_quotas")
+		tenantLimits, _ := tenantOverrides.(map[string]int64)
+
+		var tightest *service.QuotaResult
+		for _, rule := range quotas.Rules() {
+			var subject string
+			switch rule.KeyBy {
+			case "api_key":
+				subject = c.GetString("api_key_id")
+			case "user":
+				subject = c.GetString("user_id")
+			case "tenant":
+				subject = c.GetString("tenant_id")
+			}
+			if subject == "" {
+				continue
+			}
+
+			limit := rule.Limit
+			if override := keyOverrides[rule.Name]; override > 0 && rule.KeyBy == "api_key" {
+				limit = override
+			}
+			if override := tenantLimits[rule.Name]; override > 0 && rule.KeyBy == "tenant" {
+				limit = override
+			}
+
+			result, err := quotas.Consume(ctx, rule, subject, limit)
+			if err != nil {
+				continue
+			}
+
+			if !result.Allowed {
+				setQuotaHeaders(c, result)
+				c.Header("Retry-After", strconv.FormatInt(int64(time.Until(result.ResetsAt).Seconds())+1, 10))
+				utils.AbortWithError(c, utils.NewError(http.StatusTooManyRequests, utils.CodeQuotaExceeded, "Quota exceeded").
+					WithDetail("quota", result.Rule))
+				return
+			}
+
+			if tightest == nil || result.Limit-result.Used < tightest.Limit-tightest.Used {
+				tightest = result
+			}
+		}
+
+		if tightest != nil {
+			setQuotaHeaders(c, tightest)
+		}
+
+		c.Next()
+	}
+}
+
+func setQuotaHeaders(c *gin.Context, result *service.QuotaResult) {
+	remaining := result.Limit - result.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("X-Quota-Limit", strconv.FormatInt(result.Limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(result.ResetsAt.Unix(), 10))
+}
@@ -2,92 +2,165 @@ package middleware
 
 import (
 	"context"
-	"fmt"
-	"math"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"api-gateway/internal/config"
-	"api-gateway/pkg/storage"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RateLimiter(redisClient *storage.RedisClient, cfg config.RateLimitConfig) gin.HandlerFunc {
+// Rate limit key dimensions
+const (
+	KeyByIP     = "ip"
+	KeyByUser   = "user"
+	KeyByAPIKey = "api_key"
+	KeyByRoute  = "route"
+	KeyByTenant = "tenant"
+)
+
+// RateLimiter enforces every configured dimension independently; a request
+// is rejected as soon as one of them runs out of tokens. Dimensions whose key
+// can't be derived for a request (e.g. "user" on an anonymous call) are
+// skipped. Concurrency dimensions hold their slot until the request
+// completes. An open schedule window may replace a dimension's requests;
+// API key and tenant overrides still apply on top.
+func RateLimiter(limiter *ratelimit.Limiter, cfg config.RateLimitConfig, schedules *service.Schedules) gin.HandlerFunc {
+	dimensions := cfg.EffectiveDimensions()
+
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		key := fmt.Sprintf("ratelimit:%s", ip)
+		if limiter.Exempted(exemptionSubjects(c)...) {
+			c.Next()
+			return
+		}
 
 		ctx := context.Background()
-		pipe := redisClient.TxPipeline()
-
-		// Get current bucket state
-		bucketState := pipe.HGetAll(ctx, key)
-		pipe.Exec(ctx)
-
-		bucketData, err := bucketState.Result()
-		if err != nil || len(bucketData) == 0 {
-			// New user - initialize bucket
-			initialTokens := cfg.Requests - 1
-			pipe = redisClient.TxPipeline()
-			pipe.HSet(ctx, key, "tokens", initialTokens)
-			pipe.HSet(ctx, key, "timestamp", time.Now().Unix())
-			pipe.Expire(ctx, key, cfg.Window*2)
-			_, err := pipe.Exec(ctx)
+
+		var tightest *ratelimit.Result
+		var held []*ratelimit.Result
+		defer func() {
+			for _, result := range held {
+				result.Release()
+			}
+		}()
+
+		for _, dim := range dimensions {
+			id, ok := rateLimitKey(c, dim.KeyBy)
+			if !ok {
+				continue
+			}
+
+			requests := dim.Requests
+			if scheduled, ok := schedules.RateLimit(dim.Name); ok {
+				requests = scheduled
+			}
+			if override := c.GetInt("rate_limit_override"); override > 0 && keysBy(dim.KeyBy, KeyByAPIKey) {
+				requests = override
+			}
+			if override := c.GetInt("tenant_rate_limit"); override > 0 && keysBy(dim.KeyBy, KeyByTenant) {
+				requests = override
+			}
+
+			result, err := limiter.Take(ctx, ratelimit.KeyPrefix+dim.Name+":"+id, ratelimit.Policy{
+				Algorithm:     dim.Algorithm,
+				Limit:         requests,
+				Window:        dim.Window,
+				FailureMode:   cfg.FailureMode,
+				LeaseFraction: cfg.LeaseFraction,
+				LeaseTTL:      cfg.LeaseTTL,
+			})
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limiter error"})
-				c.Abort()
+				utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Rate limiter unavailable"))
 				return
 			}
-			c.Next()
-			return
+
+			if !result.Allowed {
+				c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				c.Header("X-RateLimit-Remaining", "0")
+				c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt, 10))
+				c.Header("Retry-After", strconv.Itoa(result.RetryAfter))
+				metrics.RateLimitRejections.WithLabelValues(c.FullPath()).Inc()
+				utils.AbortWithError(c, utils.NewError(http.StatusTooManyRequests, utils.CodeRateLimited, "Rate limit exceeded. Please try again later."))
+				return
+			}
+
+			held = append(held, result)
+			if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = result
+			}
 		}
 
-		// Parse bucket data
-		tokensStr := bucketData["tokens"]
-		timestampStr := bucketData["timestamp"]
-
-		tokens, _ := strconv.ParseFloat(tokensStr, 64)
-		lastTimestamp, _ := strconv.ParseInt(timestampStr, 10, 64)
-
-		// Calculate token refill
-		now := time.Now().Unix()
-		elapsed := now - lastTimestamp
-		refillRate := float64(cfg.Requests) / cfg.Window.Seconds()
-		tokensToAdd := float64(elapsed) * refillRate
-		tokens = math.Min(float64(cfg.Requests), tokens+tokensToAdd)
-
-		if tokens < 1 {
-			retryAfter := int(math.Ceil((1 - tokens) / refillRate))
-			c.Header("X-RateLimit-Limit", strconv.Itoa(cfg.Requests))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(now+int64(retryAfter), 10))
-			c.Header("Retry-After", strconv.Itoa(retryAfter))
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
-			return
+		// Set rate limit headers for the most restrictive dimension
+		if tightest != nil {
+			c.Header("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(tightest.ResetAt, 10))
 		}
 
-		// Consume token
-		tokens -= 1
-		pipe = redisClient.TxPipeline()
-		pipe.HSet(ctx, key, "tokens", tokens)
-		pipe.HSet(ctx, key, "timestamp", now)
-		_, err = pipe.Exec(ctx)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limiter error"})
-			c.Abort()
-			return
+		c.Next()
+	}
+}
+
+// rateLimitKey builds a composite bucket key from the requested dimensions.
+// API keys are identified by the ID APIKeyAuth sets, never the raw header,
+// so secrets stay out of Redis and unauthenticated keys get no bucket.
+func rateLimitKey(c *gin.Context, keyBy []string) (string, bool) {
+	if len(keyBy) == 0 {
+		keyBy = []string{KeyByIP}
+	}
+
+	parts := make([]string, 0, len(keyBy))
+	for _, dim := range keyBy {
+		var value string
+		switch dim {
+		case KeyByIP:
+			value = c.ClientIP()
+		case KeyByUser:
+			value = c.GetString("user_id")
+		case KeyByAPIKey:
+			value = c.GetString("api_key_id")
+		case KeyByRoute:
+			value = c.FullPath()
+		case KeyByTenant:
+			value = c.GetString("tenant_id")
 		}
+		if value == "" {
+			return "", false
+		}
+		parts = append(parts, dim+"="+value)
+	}
 
-		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", strconv.Itoa(cfg.Requests))
-		c.Header("X-RateLimit-Remaining", strconv.Itoa(int(tokens)))
-		c.Header("X-RateLimit-Reset", strconv.FormatInt(now+int64(cfg.Window.Seconds()), 10))
+	return strings.Join(parts, ":"), true
+}
 
-		c.Next()
+// exemptionSubjects lists the subjects of a request an exemption may name,
+// e.g. ip=10.0.0.1
+func exemptionSubjects(c *gin.Context) []string {
+	values := map[string]string{
+		KeyByIP:     c.ClientIP(),
+		KeyByUser:   c.GetString("user_id"),
+		KeyByAPIKey: c.GetString("api_key_id"),
+		KeyByTenant: c.GetString("tenant_id"),
+	}
+	subjects := make([]string, 0, len(values))
+	for name, value := range values {
+		if value != "" {
+			subjects = append(subjects, name+"="+value)
+		}
+	}
+	return subjects
+}
+
+func keysBy(keyBy []string, key string) bool {
+	for _, dim := range keyBy {
+		if dim == key {
+			return true
+		}
 	}
+	return false
 }
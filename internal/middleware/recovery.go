@@ -6,6 +6,7 @@ import (
 	"runtime/debug"
 
 	"api-gateway/pkg/logger"
+	"api-gateway/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,17 +16,14 @@ func Recovery(log *logger.Logger) gin.HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 				stack := string(debug.Stack())
-				log.Errorw("Panic recovered",
+				log.WithContext(c.Request.Context()).Errorw("Panic recovered",
 					"error", fmt.Sprintf("%v", err),
 					"stack", stack,
 					"path", c.Request.URL.Path,
 					"method", c.Request.Method,
 				)
 
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Internal server error",
-				})
-				c.Abort()
+				utils.AbortWithError(c, utils.NewError(http.StatusInternalServerError, utils.CodeInternal, "Internal server error"))
 			}
 		}()
 		c.Next()
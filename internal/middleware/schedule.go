@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scheduled applies the open schedule windows to a proxied route: a
+// maintenance window rejects its requests until it closes, and a service
+// override is passed on to the proxy as scheduled_service
+func Scheduled(schedules *service.Schedules, routePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if active, until := schedules.Maintenance(); active {
+			retryAfter := int((time.Until(until) + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Scheduled maintenance in progress"))
+			return
+		}
+		if serviceName, ok := schedules.Service(routePath); ok {
+			c.Set("scheduled_service", serviceName)
+		}
+		c.Next()
+	}
+}
@@ -1,32 +1,87 @@
 package middleware
 
 import (
+	"net/http"
+
+	"api-gateway/internal/config"
+
 	"github.com/gin-gonic/gin"
 )
 
-func SecurityHeaders() gin.HandlerFunc {
+// SecurityHeaders sets the configured security headers on every response.
+// By default these prevent MIME sniffing and clickjacking, force HTTPS
+// and restrict content sources, referrers and browser features.
+func SecurityHeaders(headers map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Prevent MIME type sniffing
-		c.Header("X-Content-Type-Options", "nosniff")
+		for name, value := range headers {
+			c.Header(name, value)
+		}
 
-		// Prevent clickjacking
-		c.Header("X-Frame-Options", "DENY")
+		c.Next()
+	}
+}
 
-		// XSS protection
-		c.Header("X-XSS-Protection", "1; mode=block")
+// ResponseHeaders applies a header policy to the response just before its
+// headers are sent, so it also covers headers copied from the upstream
+func ResponseHeaders(policy config.HeaderPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &headerPolicyWriter{ResponseWriter: c.Writer, policy: policy}
+		c.Writer = w
 
-		// HSTS - Force HTTPS
-		c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		c.Next()
 
-		// Content Security Policy
-		c.Header("Content-Security-Policy", "default-src 'self'")
+		// Responses without a body are sent by gin after the handlers
+		// return, bypassing this writer
+		if !w.Written() {
+			w.apply()
+		}
+	}
+}
 
-		// Referrer Policy
-		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+type headerPolicyWriter struct {
+	gin.ResponseWriter
+	policy  config.HeaderPolicy
+	applied bool
+}
 
-		// Permissions Policy
-		c.Header("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
+func (w *headerPolicyWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
 
-		c.Next()
+	header := w.Header()
+	for _, name := range w.policy.Remove {
+		header.Del(name)
 	}
+	for name, value := range w.policy.Set {
+		header.Set(name, value)
+	}
+	for name, value := range w.policy.Add {
+		header.Add(name, value)
+	}
+}
+
+func (w *headerPolicyWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerPolicyWriter) Write(p []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *headerPolicyWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *headerPolicyWriter) Flush() {
+	w.apply()
+	w.ResponseWriter.Flush()
+}
+
+func (w *headerPolicyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
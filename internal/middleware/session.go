@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionAuth authenticates requests carrying a session cookie and no
+// Authorization header; all others go to next. Unsafe methods must echo
+// the session's CSRF token in csrfHeader, since the browser sends the
+// cookie along with cross-site requests. Session claims must pass the same
+// validators as bearer tokens.
+func SessionAuth(sessions *service.SessionStore, revocation *service.TokenRevocation, cfg config.SessionConfig, csrfHeader string, next gin.HandlerFunc, validators ...ClaimsValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := c.Cookie(cfg.CookieName)
+		if err != nil || id == "" || c.GetHeader("Authorization") != "" {
+			next(c)
+			return
+		}
+
+		session, err := sessions.Get(c.Request.Context(), id)
+		if errors.Is(err, service.ErrSessionNotFound) {
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Session expired or ended"))
+			return
+		}
+		if err != nil {
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Unable to verify session"))
+			return
+		}
+
+		revoked, err := revocation.IsRevoked(c.Request.Context(), &session.Claims)
+		if err != nil {
+			utils.AbortWithError(c, utils.NewError(http.StatusServiceUnavailable, utils.CodeUnavailable, "Unable to verify session"))
+			return
+		}
+		if revoked {
+			utils.AbortWithError(c, utils.NewError(http.StatusUnauthorized, utils.CodeUnauthorized, "Session has been revoked"))
+			return
+		}
+
+		if !safeMethod(c.Request.Method) && !validCSRFToken(c, csrfHeader, session.CSRFToken) {
+			return
+		}
+		if !acceptClaims(c, &session.Claims, validators) {
+			return
+		}
+
+		setClaims(c, &session.Claims)
+		c.Set("session", session)
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/models"
+	"api-gateway/internal/service"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader carries the resolved tenant to upstream services
+const TenantHeader = "X-Tenant-ID"
+
+// ResolveTenant attributes the request to a tenant. A tenant bound to the
+// caller's credentials wins; a host or header naming a different tenant is
+// rejected so callers can't reach into other tenants. The tenant ID is
+// always set by the gateway upstream, never passed through from clients.
+func ResolveTenant(tenants *service.TenantStore, cfg config.TenancyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(cfg.Header)
+		c.Request.Header.Del(TenantHeader)
+
+		credential := c.GetString("tenant_id")
+		var requested *models.Tenant
+		if tenant, ok := tenants.LookupHost(c.Request.Host, cfg.BaseDomain); ok {
+			requested = tenant
+		} else if header != "" {
+			tenant, ok := tenants.Lookup(header)
+			if !ok {
+				utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Unknown tenant"))
+				return
+			}
+			requested = tenant
+		}
+
+		tenant := requested
+		if credential != "" {
+			if requested != nil && requested.TenantID != credential {
+				utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Credentials belong to another tenant"))
+				return
+			}
+			var ok bool
+			if tenant, ok = tenants.Lookup(credential); !ok {
+				utils.AbortWithError(c, utils.NewError(http.StatusForbidden, utils.CodeForbidden, "Unknown tenant"))
+				return
+			}
+		}
+
+		if tenant == nil {
+			if cfg.Required {
+				utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Tenant required"))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Set("tenant_id", tenant.TenantID)
+		c.Set("tenant", tenant)
+		if tenant.RateLimit > 0 {
+			c.Set("tenant_rate_limit", tenant.RateLimit)
+		}
+		// A priority of the caller's API key wins over the tenant's
+		if tenant.Priority != "" && c.GetString("priority") == "" {
+			c.Set("priority", tenant.Priority)
+		}
+		if len(tenant.Quotas) > 0 {
+			c.Set("tenant_quotas", tenant.Quotas)
+		}
+		c.Request.Header.Set(TenantHeader, tenant.TenantID)
+
+		c.Next()
+	}
+}
+
+// TenantRouteAccess rejects requests from tenants that aren't allowed on
+// route. Requests without a tenant pass.
+func TenantRouteAccess(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("tenant")
+		tenant, _ := value.(*models.Tenant)
+		if !ok || tenant == nil || len(tenant.Routes) == 0 {
+			c.Next()
+			return
+		}
+
+		for _, allowed := range tenant.Routes {
+			if allowed == route {
+				c.Next()
+				return
+			}
+		}
+		utils.AbortWithError(c, utils.NewError(http.StatusNotFound, utils.CodeNotFound, "Route not found"))
+	}
+}
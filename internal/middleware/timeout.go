@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds the rest of the handler chain to d. The deadline travels
+// on the request context so the proxy can pass it upstream; if it passes
+// before anything was written the client gets a 504. A zero d disables
+// the budget.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			utils.AbortWithError(c, utils.NewError(http.StatusGatewayTimeout, utils.CodeGatewayTimeout, "Upstream request timed out").
+				WithDetail("timeout", d.String()))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sniffLength is how much of a file http.DetectContentType looks at
+const sniffLength = 512
+
+// Upload enforces policy on multipart request bodies without buffering
+// them: parts are inspected as the body streams to the service, and the
+// first violation aborts the upstream request with a 413 or 415 for the
+// client. File types are detected from the content, not the declared
+// Content-Type.
+func Upload(policy config.UploadConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+			c.Next()
+			return
+		}
+		if params["boundary"] == "" {
+			utils.AbortWithError(c, utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Multipart body without boundary"))
+			return
+		}
+
+		c.Request.Body = newUploadBody(c.Request.Body, params["boundary"], policy)
+		c.Next()
+	}
+}
+
+// uploadBody passes the body through while a multipart reader walks the
+// same bytes from a pipe. Reads fail with the policy violation once one is
+// found, so the violation surfaces as the upstream request's error.
+type uploadBody struct {
+	io.ReadCloser
+	pipe *io.PipeWriter
+	done chan struct{}
+
+	mu    sync.Mutex
+	fault error
+}
+
+func newUploadBody(body io.ReadCloser, boundary string, policy config.UploadConfig) *uploadBody {
+	pr, pw := io.Pipe()
+	b := &uploadBody{ReadCloser: body, pipe: pw, done: make(chan struct{})}
+	go b.inspect(pr, boundary, policy)
+	return b
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	if err := b.violation(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.pipe.Write(p[:n])
+	}
+	switch {
+	case err == io.EOF:
+		// The last part is only checked once the closing boundary arrives
+		b.pipe.Close()
+		<-b.done
+	case err != nil:
+		b.pipe.CloseWithError(err)
+		return n, err
+	}
+	if fault := b.violation(); fault != nil {
+		return n, fault
+	}
+	return n, err
+}
+
+func (b *uploadBody) Close() error {
+	b.pipe.CloseWithError(io.ErrClosedPipe)
+	return b.ReadCloser.Close()
+}
+
+func (b *uploadBody) violation() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fault
+}
+
+func (b *uploadBody) fail(err *utils.APIError) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fault == nil {
+		b.fault = err
+	}
+}
+
+// inspect checks every part against policy. It keeps draining the pipe
+// after a violation or the end of the form so Read never blocks on it.
+func (b *uploadBody) inspect(pr *io.PipeReader, boundary string, policy config.UploadConfig) {
+	defer close(b.done)
+	defer io.Copy(io.Discard, pr)
+
+	form := multipart.NewReader(pr, boundary)
+	for parts := 1; ; parts++ {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.ErrClosedPipe) {
+				b.fail(utils.NewError(http.StatusBadRequest, utils.CodeBadRequest, "Malformed multipart body"))
+			}
+			return
+		}
+		if policy.MaxParts > 0 && parts > policy.MaxParts {
+			b.fail(utils.NewError(http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Too many parts in upload").
+				WithDetail("max_parts", policy.MaxParts))
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(part, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return
+		}
+		if len(policy.AllowedTypes) > 0 {
+			detected, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+			if !acceptsMediaType(policy.AllowedTypes, detected) {
+				b.fail(utils.NewError(http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia, "File type not allowed").
+					WithDetail("file", part.FileName()).
+					WithDetail("detected", detected).
+					WithDetail("allowed", policy.AllowedTypes))
+				return
+			}
+		}
+		if policy.MaxFileSize > 0 {
+			rest, _ := io.Copy(io.Discard, io.LimitReader(part, policy.MaxFileSize-int64(n)+1))
+			if int64(n)+rest > policy.MaxFileSize {
+				b.fail(utils.NewError(http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Uploaded file too large").
+					WithDetail("file", part.FileName()).
+					WithDetail("max_file_size", policy.MaxFileSize))
+				return
+			}
+		}
+	}
+}
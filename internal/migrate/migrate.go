@@ -0,0 +1,97 @@
+// Package migrate applies versioned changes to a database schema, such as
+// indexes, tables and data fixes, and records which ones have run.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"api-gateway/pkg/logger"
+)
+
+// Migration is one change to the schema. Migrations run in ID order, each
+// at most once per database. Several gateways may start at the same time,
+// so Up must be safe to run twice.
+type Migration struct {
+	ID          int
+	Description string
+	Up          func(ctx context.Context) error
+}
+
+// Status is a migration and when it was applied, if it was
+type Status struct {
+	ID          int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// History records which migrations a database has applied
+type History interface {
+	Applied(ctx context.Context) (map[int]time.Time, error)
+	Record(ctx context.Context, m Migration) error
+}
+
+// Runner applies pending migrations to a database
+type Runner struct {
+	migrations []Migration
+	history    History
+	logger     *logger.Logger
+}
+
+func NewRunner(migrations []Migration, history History, log *logger.Logger) *Runner {
+	migrations = append([]Migration(nil), migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].ID < migrations[j].ID })
+	return &Runner{
+		migrations: migrations,
+		history:    history,
+		logger:     log,
+	}
+}
+
+// Status lists every migration with its applied time
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.history.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{ID: m.ID, Description: m.Description}
+		if at, ok := applied[m.ID]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Run applies the pending migrations in order and returns how many ran. It
+// stops at the first failure, leaving later migrations pending.
+func (r *Runner) Run(ctx context.Context) (int, error) {
+	applied, err := r.history.Applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.migrations {
+		if _, ok := applied[m.ID]; ok {
+			continue
+		}
+
+		start := time.Now()
+		if err := m.Up(ctx); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.ID, m.Description, err)
+		}
+		if err := r.history.Record(ctx, m); err != nil {
+			return count, fmt.Errorf("record migration %d: %w", m.ID, err)
+		}
+
+		r.logger.Infow("Applied migration", "id", m.ID, "description", m.Description, "duration", time.Since(start))
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoRunner returns the runner for the MongoDB schema. Applied
+// migrations are recorded in the schema_migrations collection.
+func NewMongoRunner(db *mongo.Database, log *logger.Logger) *Runner {
+	return NewRunner(mongoMigrations(db), mongoHistory{db.Collection("schema_migrations")}, log)
+}
+
+// mongoMigrations lists every MongoDB migration. Append new ones with the
+// next ID; never edit or renumber one that has been released.
+//
+// Indexes that depend on config, like the audit and analytics retention
+// TTLs, are kept in sync by their stores at startup instead.
+func mongoMigrations(db *mongo.Database) []Migration {
+	return []Migration{
+		{
+			ID:          1,
+			Description: "unique usernames and emails",
+			Up: func(ctx context.Context) error {
+				return createIndexes(ctx, db.Collection("users"),
+					mongo.IndexModel{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}},
+				)
+			},
+		},
+		{
+			ID:          2,
+			Description: "refresh and user token lookups, expired tokens removed",
+			Up: func(ctx context.Context) error {
+				for _, name := range []string{"refresh_tokens", "user_tokens"} {
+					err := createIndexes(ctx, db.Collection(name),
+						mongo.IndexModel{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+						mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}},
+						mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+					)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID:          3,
+			Description: "API key and signing client lookups",
+			Up: func(ctx context.Context) error {
+				err := createIndexes(ctx, db.Collection("api_keys"),
+					mongo.IndexModel{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				)
+				if err != nil {
+					return err
+				}
+				return createIndexes(ctx, db.Collection("signing_clients"),
+					mongo.IndexModel{Keys: bson.D{{Key: "client_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				)
+			},
+		},
+		{
+			ID:          4,
+			Description: "quota usage reports",
+			Up: func(ctx context.Context) error {
+				return createIndexes(ctx, db.Collection("quota_usage"),
+					mongo.IndexModel{Keys: bson.D{{Key: "period_start", Value: -1}, {Key: "subject", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "subject", Value: 1}, {Key: "period_start", Value: -1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "rule", Value: 1}, {Key: "period_start", Value: -1}}},
+				)
+			},
+		},
+	}
+}
+
+// createIndexes creates indexes, which is a no-op for ones that exist
+func createIndexes(ctx context.Context, coll *mongo.Collection, models ...mongo.IndexModel) error {
+	_, err := coll.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+type mongoHistory struct {
+	collection *mongo.Collection
+}
+
+type mongoRecord struct {
+	ID          int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+func (h mongoHistory) Applied(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := h.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []mongoRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.ID] = record.AppliedAt
+	}
+	return applied, nil
+}
+
+func (h mongoHistory) Record(ctx context.Context, m Migration) error {
+	_, err := h.collection.UpdateOne(ctx,
+		bson.M{"_id": m.ID},
+		bson.M{"$setOnInsert": bson.M{"description": m.Description, "applied_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewPostgresRunner returns the runner for the PostgreSQL schema. Applied
+// migrations are recorded in the schema_migrations table.
+func NewPostgresRunner(pool *pgxpool.Pool, log *logger.Logger) *Runner {
+	return NewRunner(postgresMigrations(pool), postgresHistory{pool}, log)
+}
+
+// postgresMigrations lists every PostgreSQL migration. Append new ones with
+// the next ID; never edit or renumber one that has been released.
+func postgresMigrations(pool *pgxpool.Pool) []Migration {
+	return []Migration{
+		{
+			ID:          1,
+			Description: "users and their external identities",
+			Up: execSQL(pool, `
+				CREATE TABLE IF NOT EXISTS users (
+					id             TEXT PRIMARY KEY,
+					username       TEXT NOT NULL UNIQUE,
+					email          TEXT NOT NULL UNIQUE,
+					password       TEXT NOT NULL,
+					role           TEXT NOT NULL,
+					tenant_id      TEXT NOT NULL DEFAULT '',
+					active         BOOLEAN NOT NULL,
+					email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+					deleted_at     TIMESTAMPTZ,
+					created_at     TIMESTAMPTZ NOT NULL,
+					updated_at     TIMESTAMPTZ NOT NULL
+				);
+				CREATE TABLE IF NOT EXISTS user_identities (
+					user_id  TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+					provider TEXT NOT NULL,
+					subject  TEXT NOT NULL,
+					PRIMARY KEY (provider, subject)
+				);
+				CREATE INDEX IF NOT EXISTS user_identities_user_id ON user_identities (user_id);`),
+		},
+		{
+			ID:          2,
+			Description: "refresh and user tokens",
+			Up: execSQL(pool, `
+				CREATE TABLE IF NOT EXISTS refresh_tokens (
+					id         TEXT PRIMARY KEY,
+					token_hash TEXT NOT NULL UNIQUE,
+					user_id    TEXT NOT NULL,
+					scopes     TEXT[],
+					revoked    BOOLEAN NOT NULL DEFAULT FALSE,
+					expires_at TIMESTAMPTZ NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS refresh_tokens_user_id ON refresh_tokens (user_id);
+				CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at ON refresh_tokens (expires_at);
+				CREATE TABLE IF NOT EXISTS user_tokens (
+					id         TEXT PRIMARY KEY,
+					token_hash TEXT NOT NULL UNIQUE,
+					user_id    TEXT NOT NULL,
+					purpose    TEXT NOT NULL,
+					used       BOOLEAN NOT NULL DEFAULT FALSE,
+					expires_at TIMESTAMPTZ NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS user_tokens_expires_at ON user_tokens (expires_at);`),
+		},
+		{
+			ID:          3,
+			Description: "roles and registered services",
+			Up: execSQL(pool, `
+				CREATE TABLE IF NOT EXISTS roles (
+					name        TEXT PRIMARY KEY,
+					description TEXT NOT NULL DEFAULT '',
+					permissions TEXT[] NOT NULL,
+					created_at  TIMESTAMPTZ NOT NULL,
+					updated_at  TIMESTAMPTZ NOT NULL
+				);
+				CREATE TABLE IF NOT EXISTS registered_services (
+					name       TEXT PRIMARY KEY,
+					definition JSONB NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL,
+					updated_at TIMESTAMPTZ NOT NULL
+				);`),
+		},
+		{
+			ID:          4,
+			Description: "audit events",
+			Up: execSQL(pool, `
+				CREATE TABLE IF NOT EXISTS audit_events (
+					id         TEXT PRIMARY KEY,
+					time       TIMESTAMPTZ NOT NULL,
+					action     TEXT NOT NULL,
+					success    BOOLEAN NOT NULL,
+					actor_id   TEXT NOT NULL DEFAULT '',
+					actor      TEXT NOT NULL DEFAULT '',
+					target     TEXT NOT NULL DEFAULT '',
+					client_ip  TEXT NOT NULL DEFAULT '',
+					request_id TEXT NOT NULL DEFAULT '',
+					details    JSONB
+				);
+				CREATE INDEX IF NOT EXISTS audit_events_time ON audit_events (time DESC);
+				CREATE INDEX IF NOT EXISTS audit_events_actor_id ON audit_events (actor_id, time DESC);
+				CREATE INDEX IF NOT EXISTS audit_events_actor ON audit_events (actor, time DESC);
+				CREATE INDEX IF NOT EXISTS audit_events_target ON audit_events (target, time DESC);
+				CREATE INDEX IF NOT EXISTS audit_events_action ON audit_events (action, time DESC);`),
+		},
+	}
+}
+
+// execSQL returns an Up running statements in a transaction
+func execSQL(pool *pgxpool.Pool, statements string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		if _, err := tx.Exec(ctx, statements); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+}
+
+type postgresHistory struct {
+	pool *pgxpool.Pool
+}
+
+func (h postgresHistory) Applied(ctx context.Context) (map[int]time.Time, error) {
+	_, err := h.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			id          INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at  TIMESTAMPTZ NOT NULL
+		)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.pool.Query(ctx, `SELECT id, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		applied[id] = at
+	}
+	return applied, rows.Err()
+}
+
+func (h postgresHistory) Record(ctx context.Context, m Migration) error {
+	_, err := h.pool.Exec(ctx,
+		`INSERT INTO schema_migrations (id, description, applied_at) VALUES ($1, $2, now())
+		 ON CONFLICT (id) DO NOTHING`,
+		m.ID, m.Description)
+	return err
+}
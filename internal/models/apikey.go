@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey is a credential for machine clients. Only the key hash is stored;
+// the plaintext key is returned once when created or rotated.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	TenantID   string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	RateLimit  int                `bson:"rate_limit" json:"rate_limit"`
+	Priority   string             `bson:"priority,omitempty" json:"priority,omitempty"`
+	Quotas     map[string]int64   `bson:"quotas,omitempty" json:"quotas,omitempty"`
+	Active     bool               `bson:"active" json:"active"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	RotatedAt  *time.Time         `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" binding:"required,min=3,max=100"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit" binding:"omitempty,min=1"`
+	TenantID  string   `json:"tenant_id"`
+	Priority  string   `json:"priority" binding:"omitempty,oneof=low normal high"`
+
+	// Quotas overrides quota rule limits for this key, by rule name
+	Quotas map[string]int64 `json:"quotas"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditUserRegister         = "user.register"
+	AuditLogin                = "auth.login"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditTokensRevoked        = "auth.tokens_revoked"
+	AuditEmailVerified        = "user.email_verify"
+	AuditPasswordReset        = "user.password_reset"
+	AuditProfileUpdated       = "user.profile_update"
+	AuditPasswordChanged      = "user.password_change"
+	AuditUserDeleted          = "user.delete"
+	AuditRoleSaved            = "role.save"
+	AuditRoleDeleted          = "role.delete"
+	AuditRoleAssigned         = "user.role_assign"
+	AuditServiceRegister      = "service.register"
+	AuditServiceUnregister    = "service.unregister"
+	AuditAPIKeyCreated        = "api_key.create"
+	AuditAPIKeyRotated        = "api_key.rotate"
+	AuditAPIKeyRevoked        = "api_key.revoke"
+	AuditSigningClientCreated = "signing_client.create"
+	AuditSigningClientRevoked = "signing_client.revoke"
+	AuditTenantCreated        = "tenant.create"
+	AuditTenantUpdated        = "tenant.update"
+	AuditTenantDeleted        = "tenant.delete"
+	AuditQuotaReset           = "quota.reset"
+	AuditBodyLogUpdated       = "body_log.update"
+	AuditSplitUpdated         = "split.update"
+	AuditExperimentUpdated    = "experiment.update"
+	AuditExperimentDeleted    = "experiment.delete"
+	AuditIPAccessAdded        = "ip_access.add"
+	AuditIPAccessRemoved      = "ip_access.remove"
+	AuditJWTKeyRotated        = "jwt_key.rotate"
+	AuditJWTKeyDeleted        = "jwt_key.delete"
+	AuditRateLimitReset       = "rate_limit.reset"
+	AuditRateLimitExempted    = "rate_limit.exempt"
+	AuditRateLimitUnexempted  = "rate_limit.unexempt"
+)
+
+// AuditEvent records a security relevant operation. Events are only ever
+// inserted; they are removed once the retention has passed and by nothing
+// else.
+type AuditEvent struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Time      time.Time              `bson:"time" json:"time"`
+	Action    string                 `bson:"action" json:"action"`
+	Success   bool                   `bson:"success" json:"success"`
+	ActorID   string                 `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	Actor     string                 `bson:"actor,omitempty" json:"actor,omitempty"`
+	Target    string                 `bson:"target,omitempty" json:"target,omitempty"`
+	ClientIP  string                 `bson:"client_ip" json:"client_ip"`
+	RequestID string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+}
+
+type AuditQuery struct {
+	User   string    `form:"user"`
+	Action string    `form:"action"`
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int64     `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
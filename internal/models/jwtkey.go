@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// JWTKey is a key the gateway signs its tokens with, identified by the kid
+// header of those tokens. Secret is the HMAC secret or, for asymmetric
+// algorithms, the PEM encoded private key. Like signing client secrets it
+// has to be kept in the clear and is never returned by the admin API.
+type JWTKey struct {
+	ID          string    `bson:"_id" json:"kid"`
+	Algorithm   string    `bson:"algorithm" json:"algorithm"`
+	Secret      string    `bson:"secret" json:"-"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+	ActivatesAt time.Time `bson:"activates_at" json:"activates_at"`
+}
+
+// RotateJWTKeyRequest schedules the next signing key; without ActivateAt
+// it signs from now on
+type RotateJWTKeyRequest struct {
+	ActivateAt *time.Time `json:"activate_at"`
+}
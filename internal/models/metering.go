@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// MeterUsage is the billable usage of one API key or tenant in one hour.
+// Requests without an API key have an empty APIKeyID and are billed to
+// their tenant.
+type MeterUsage struct {
+	ID            string    `bson:"_id" json:"-"`
+	Hour          time.Time `bson:"hour" json:"hour"`
+	APIKeyID      string    `bson:"api_key_id" json:"api_key_id"`
+	TenantID      string    `bson:"tenant_id" json:"tenant_id"`
+	Requests      int64     `bson:"requests" json:"requests"`
+	Units         int64     `bson:"units" json:"units"`
+	RequestBytes  int64     `bson:"request_bytes" json:"request_bytes"`
+	ResponseBytes int64     `bson:"response_bytes" json:"response_bytes"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// MeterExportQuery selects the hours starting in [From, To)
+type MeterExportQuery struct {
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	APIKey string    `form:"api_key"`
+	Tenant string    `form:"tenant"`
+	Format string    `form:"format" binding:"omitempty,oneof=json csv"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// QuotaUsage is the request count of one subject under one quota rule for
+// one period. Counters live in Redis and are persisted here periodically.
+type QuotaUsage struct {
+	ID          string    `bson:"_id" json:"-"`
+	Rule        string    `bson:"rule" json:"rule"`
+	Subject     string    `bson:"subject" json:"subject"`
+	Period      string    `bson:"period" json:"period"`
+	PeriodStart time.Time `bson:"period_start" json:"period_start"`
+	ResetsAt    time.Time `bson:"resets_at" json:"resets_at"`
+	Count       int64     `bson:"count" json:"count"`
+	Limit       int64     `bson:"limit" json:"limit"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type QuotaReportQuery struct {
+	Subject string    `form:"subject"`
+	Rule    string    `form:"rule"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit   int64     `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// RegisteredService is a service added through the admin API. Services
+// from the config file are not stored.
+type RegisteredService struct {
+	Name          string              `bson:"_id" json:"name"`
+	URLs          []string            `bson:"urls" json:"urls"`
+	HealthURL     string              `bson:"health_url,omitempty" json:"health_url,omitempty"`
+	OpenAPIURL    string              `bson:"openapi_url,omitempty" json:"openapi_url,omitempty"`
+	Protocol      string              `bson:"protocol,omitempty" json:"protocol,omitempty"`
+	LoadBalancing string              `bson:"load_balancing,omitempty" json:"load_balancing,omitempty"`
+	HashKey       string              `bson:"hash_key,omitempty" json:"hash_key,omitempty"`
+	SlowStart     time.Duration       `bson:"slow_start,omitempty" json:"slow_start,omitempty"`
+	Zones         map[string][]string `bson:"zones,omitempty" json:"zones,omitempty"`
+	ZoneAware     bool                `bson:"zone_aware" json:"zone_aware"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time           `bson:"updated_at" json:"updated_at"`
+}
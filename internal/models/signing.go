@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningClient is a webhook style client that signs its requests with a
+// shared HMAC secret. The secret has to be kept in the clear to verify
+// signatures, so it is never returned after the client is created.
+type SigningClient struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID   string             `bson:"client_id" json:"client_id"`
+	Name       string             `bson:"name" json:"name"`
+	Secret     string             `bson:"secret" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	Active     bool               `bson:"active" json:"active"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+type CreateSigningClientRequest struct {
+	Name   string   `json:"name" binding:"required,min=3,max=100"`
+	Scopes []string `json:"scopes"`
+}
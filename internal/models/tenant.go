@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tenant is a customer hosted on the gateway. Requests are attributed to a
+// tenant by the caller's credentials, the host or a header, and are then
+// limited to the tenant's routes and services.
+type Tenant struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	Name     string             `bson:"name" json:"name"`
+
+	// Domains are hosts that belong to the tenant besides its subdomain
+	Domains []string `bson:"domains" json:"domains"`
+
+	// RateLimit overrides the request limit of rate limit dimensions keyed
+	// by tenant, and Quotas the limit of tenant quota rules by rule name
+	RateLimit int              `bson:"rate_limit" json:"rate_limit"`
+	Quotas    map[string]int64 `bson:"quotas,omitempty" json:"quotas,omitempty"`
+
+	// Priority is the load shedding priority of the tenant's requests
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`
+
+	// Routes restricts the tenant to these route paths; empty allows all
+	Routes []string `bson:"routes" json:"routes"`
+
+	// Services maps route services to the tenant's own deployment of them
+	Services map[string]string `bson:"services,omitempty" json:"services,omitempty"`
+
+	Active    bool      `bson:"active" json:"active"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type CreateTenantRequest struct {
+	TenantID string `json:"tenant_id" binding:"required,min=2,max=63"`
+	UpdateTenantRequest
+}
+
+type UpdateTenantRequest struct {
+	Name      string            `json:"name" binding:"required,min=2,max=100"`
+	Domains   []string          `json:"domains"`
+	RateLimit int               `json:"rate_limit" binding:"omitempty,min=1"`
+	Priority  string            `json:"priority" binding:"omitempty,oneof=low normal high"`
+	Quotas    map[string]int64  `json:"quotas"`
+	Routes    []string          `json:"routes"`
+	Services  map[string]string `json:"services"`
+	Active    *bool             `json:"active"`
+}
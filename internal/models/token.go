@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a long-lived opaque credential. Only its hash is stored.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Scopes    []string           `bson:"scopes,omitempty"`
+	Revoked   bool               `bson:"revoked"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// UserToken purposes
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a single use token sent to the user by email. Only its hash
+// is stored.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	Used      bool               `bson:"used"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ScopedTokenRequest mints an access token restricted to the given scopes
+type ScopedTokenRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+
+	// ExpiresIn is the lifetime in seconds, capped at the normal token expiry
+	ExpiresIn int `json:"expires_in" binding:"omitempty,min=1"`
+}
@@ -12,14 +12,36 @@ type User struct {
 	Email     string             `bson:"email" json:"email"`
 	Password  string             `bson:"password" json:"-"`
 	Role      string             `bson:"role" json:"role"`
+	TenantID  string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
 	Active    bool               `bson:"active" json:"active"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// EmailVerified is set once the user confirms their email address
+	EmailVerified bool `bson:"email_verified" json:"email_verified"`
+
+	// DeletedAt is set when the user deletes their account. Deleted
+	// accounts are kept inactive so their username and email stay taken.
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"-"`
+
+	// Identities links the account to external identity providers
+	Identities []ExternalIdentity `bson:"identities,omitempty" json:"-"`
+}
+
+type ExternalIdentity struct {
+	Provider string `bson:"provider"`
+	Subject  string `bson:"subject"`
 }
 
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+
+	// Scopes optionally restricts the issued tokens
+	Scopes []string `json:"scopes"`
+
+	// Mode "session" sets a session cookie instead of returning tokens
+	Mode string `json:"mode" binding:"omitempty,oneof=token session"`
 }
 
 type RegisterRequest struct {
@@ -28,6 +50,34 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// UpdateProfileRequest changes the given fields; at least one is required
+type UpdateProfileRequest struct {
+	Username string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email    string `json:"email" binding:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6,nefield=CurrentPassword"`
+}
+
+type DeleteProfileRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
 type TokenResponse struct {
 	Token     string    `json:"token"`
 	ExpiresAt time.Time `json:"expires_at"`
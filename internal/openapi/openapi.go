@@ -0,0 +1,236 @@
+// Package openapi builds the gateway's unified OpenAPI document from its own
+// route table and the specs published by backend services.
+package openapi
+
+import (
+	"sort"
+	"strings"
+
+	"api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Document is a decoded OpenAPI 3 document
+type Document = map[string]interface{}
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Merge combines the gateway's own endpoints with the backend specs keyed
+// by service name. Backend paths are rewritten to where the routes expose
+// them and their components are prefixed with the service name so schemas
+// from different services can't collide.
+func Merge(title, version string, gateway gin.RoutesInfo, routes []config.RouteConfig, specs map[string]Document) Document {
+	paths := make(map[string]interface{})
+	components := map[string]interface{}{
+		"securitySchemes": map[string]interface{}{
+			"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		},
+	}
+
+	for _, r := range gateway {
+		addOperation(paths, openAPIPath(r.Path), strings.ToLower(r.Method), gatewayOperation(r))
+	}
+
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec := prefixRefs(specs[name], name).(Document)
+		mergeComponents(components, spec, name)
+
+		specPaths, _ := spec["paths"].(map[string]interface{})
+		for _, route := range routes {
+			if route.Service != name {
+				continue
+			}
+			for path, item := range specPaths {
+				gatewayPath, ok := exposedPath(route, path)
+				if !ok {
+					continue
+				}
+				ops, _ := item.(map[string]interface{})
+				for _, method := range methods {
+					op, ok := ops[method].(map[string]interface{})
+					if !ok || !routeAllows(route, method) {
+						continue
+					}
+					addOperation(paths, gatewayPath, method, backendOperation(op, ops, name, route))
+				}
+			}
+		}
+	}
+
+	return Document{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": title, "version": version},
+		"paths":      paths,
+		"components": components,
+	}
+}
+
+func addOperation(paths map[string]interface{}, path, method string, op map[string]interface{}) {
+	item, ok := paths[path].(map[string]interface{})
+	if !ok {
+		item = make(map[string]interface{})
+		paths[path] = item
+	}
+	item[method] = op
+}
+
+// gatewayOperation describes one of the gateway's own handlers
+func gatewayOperation(r gin.RouteInfo) map[string]interface{} {
+	tag := "gateway"
+	switch {
+	case strings.HasPrefix(r.Path, "/api/v1/admin"):
+		tag = "admin"
+	case strings.HasPrefix(r.Path, "/api/v1/auth"):
+		tag = "auth"
+	}
+
+	op := map[string]interface{}{
+		"tags":      []string{tag},
+		"summary":   r.Method + " " + r.Path,
+		"responses": map[string]interface{}{"default": map[string]interface{}{"description": "Gateway response envelope"}},
+	}
+	if params := pathParameters(r.Path); len(params) > 0 {
+		op["parameters"] = params
+	}
+	if tag == "admin" || r.Path == "/api/v1/profile" || r.Path == "/api/v1/auth/token" {
+		op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return op
+}
+
+// backendOperation copies a service operation, folding in path level
+// parameters and marking routes that need authentication
+func backendOperation(op, item map[string]interface{}, service string, route config.RouteConfig) map[string]interface{} {
+	out := make(map[string]interface{}, len(op)+2)
+	for k, v := range op {
+		out[k] = v
+	}
+	if shared, ok := item["parameters"].([]interface{}); ok {
+		own, _ := out["parameters"].([]interface{})
+		out["parameters"] = append(append([]interface{}(nil), shared...), own...)
+	}
+	if _, ok := out["tags"]; !ok {
+		out["tags"] = []string{service}
+	}
+	if id, ok := out["operationId"].(string); ok {
+		out["operationId"] = service + "_" + id
+	}
+	// Clients authenticate against the gateway, not the service
+	delete(out, "security")
+	if route.AuthRequired {
+		out["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return out
+}
+
+// exposedPath maps a service path to the gateway path that reaches it.
+// Without strip_prefix only service paths under the route path are
+// reachable.
+func exposedPath(route config.RouteConfig, path string) (string, bool) {
+	base := strings.TrimSuffix(route.Path, "/")
+	if route.StripPrefix {
+		if path == "/" {
+			return base, true
+		}
+		return base + path, true
+	}
+	if path == base || strings.HasPrefix(path, base+"/") {
+		return path, true
+	}
+	return "", false
+}
+
+func routeAllows(route config.RouteConfig, method string) bool {
+	if len(route.Methods) == 0 {
+		return true
+	}
+	for _, m := range route.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath converts gin's :param and *param segments to {param}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParameters(path string) []interface{} {
+	var params []interface{}
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, map[string]interface{}{
+				"name":     s[1:],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return params
+}
+
+// prefixRefs rewrites local component references to the prefixed names
+func prefixRefs(node interface{}, service string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			if ref, ok := child.(string); ok && k == "$ref" {
+				out[k] = prefixRef(ref, service)
+				continue
+			}
+			out[k] = prefixRefs(child, service)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = prefixRefs(child, service)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func prefixRef(ref, service string) string {
+	if !strings.HasPrefix(ref, "#/components/") {
+		return ref
+	}
+	i := strings.LastIndex(ref, "/")
+	return ref[:i+1] + service + "." + ref[i+1:]
+}
+
+func mergeComponents(into map[string]interface{}, spec Document, service string) {
+	components, _ := spec["components"].(map[string]interface{})
+	for kind, entries := range components {
+		items, ok := entries.(map[string]interface{})
+		if !ok || kind == "securitySchemes" {
+			continue
+		}
+		target, ok := into[kind].(map[string]interface{})
+		if !ok {
+			target = make(map[string]interface{})
+			into[kind] = target
+		}
+		for name, item := range items {
+			target[service+"."+name] = item
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// KeyPrefix starts every bucket key: ratelimit:<dimension>:<subject>,
+	// the subject being dim=value pairs joined by colons
+	KeyPrefix = "ratelimit:"
+
+	// MaxBuckets caps how many buckets Buckets returns
+	MaxBuckets = 500
+
+	scanCount     = 200
+	exemptionsKey = "ratelimit-exemptions"
+)
+
+// Bucket is the current state of one rate limit key
+type Bucket struct {
+	Key       string            `json:"key"`
+	Dimension string            `json:"dimension"`
+	Subject   map[string]string `json:"subject"`
+	Algorithm string            `json:"algorithm"`
+	Limit     int               `json:"limit"`
+	Remaining int               `json:"remaining"`
+	ResetAt   int64             `json:"reset_at"`
+}
+
+// Filter selects buckets by dimension and subject values, e.g.
+// {"ip": "10.0.0.1"}. A bucket matches when its subject has every value.
+type Filter struct {
+	Dimension string
+	Subject   map[string]string
+}
+
+// Exemption lets a subject such as ip=10.0.0.1 bypass every limit until
+// it expires
+type Exemption struct {
+	Subject   string    `json:"subject"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Buckets returns the buckets matching filter with their remaining
+// requests under policies, keyed by dimension name. Keys are enumerated
+// with SCAN, so Redis isn't blocked; buckets of dimensions no longer
+// configured are skipped.
+func (l *Limiter) Buckets(ctx context.Context, filter Filter, policies map[string]Policy) ([]Bucket, error) {
+	buckets := []Bucket{}
+	err := l.scan(ctx, filter, func(key, dimension string, subject map[string]string) (bool, error) {
+		policy, ok := policies[dimension]
+		if !ok {
+			return true, nil
+		}
+		bucket, found, err := l.readBucket(ctx, key, policy)
+		var replyErr redis.Error
+		if errors.As(err, &replyErr) {
+			// The dimension's algorithm changed since the key was written
+			return true, nil
+		}
+		if err != nil || !found {
+			return err == nil, err
+		}
+		bucket.Dimension = dimension
+		bucket.Subject = subject
+		buckets = append(buckets, bucket)
+		return len(buckets) < MaxBuckets, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key < buckets[j].Key })
+	return buckets, nil
+}
+
+// Reset deletes the buckets matching filter, in Redis, in the local
+// fallback and in this gateway's leases, and returns how many Redis keys were removed
+func (l *Limiter) Reset(ctx context.Context, filter Filter) (int, error) {
+	var keys []string
+	err := l.scan(ctx, filter, func(key, _ string, _ map[string]string) (bool, error) {
+		keys = append(keys, key)
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += scanCount {
+		end := start + scanCount
+		if end > len(keys) {
+			end = len(keys)
+		}
+		n, err := l.redis.redis.Del(ctx, keys[start:end]...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+
+	match := func(key string) bool {
+		dimension, subject, ok := ParseKey(key)
+		return ok && filter.matches(dimension, subject)
+	}
+	l.local.Reset(match)
+	l.leases.Reset(match)
+	return deleted, nil
+}
+
+// scan calls fn for every key matching filter until fn returns false
+func (l *Limiter) scan(ctx context.Context, filter Filter, fn func(key, dimension string, subject map[string]string) (bool, error)) error {
+	pattern := KeyPrefix + "*"
+	if filter.Dimension != "" {
+		pattern = KeyPrefix + globEscape(filter.Dimension) + ":*"
+	}
+	// One subject value narrows the scan; all are checked exactly below
+	for name, value := range filter.Subject {
+		pattern += globEscape(name+"="+value) + "*"
+		break
+	}
+
+	iter := l.redis.redis.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		dimension, subject, ok := ParseKey(key)
+		if !ok || !filter.matches(dimension, subject) {
+			continue
+		}
+		more, err := fn(key, dimension, subject)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return iter.Err()
+}
+
+func (f Filter) matches(dimension string, subject map[string]string) bool {
+	if f.Dimension != "" && f.Dimension != dimension {
+		return false
+	}
+	for name, value := range f.Subject {
+		if subject[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseKey splits a bucket key into its dimension and subject
+func ParseKey(key string) (string, map[string]string, bool) {
+	rest, ok := strings.CutPrefix(key, KeyPrefix)
+	if !ok {
+		return "", nil, false
+	}
+	dimension, rest, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", nil, false
+	}
+
+	// Values may contain colons themselves (IPv6 addresses, API key user
+	// IDs), so a segment not starting with name= continues the previous
+	// value
+	subject := make(map[string]string)
+	var last string
+	for _, part := range strings.Split(rest, ":") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || !isSubjectName(name) {
+			if last == "" {
+				return "", nil, false
+			}
+			subject[last] += ":" + part
+			continue
+		}
+		subject[name] = value
+		last = name
+	}
+	return dimension, subject, len(subject) > 0
+}
+
+func isSubjectName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func globEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// readBucket works out the remaining requests of a bucket without taking
+// from it, mirroring the algorithm's script
+func (l *Limiter) readBucket(ctx context.Context, key string, p Policy) (Bucket, bool, error) {
+	rdb := l.redis.redis
+	bucket := Bucket{Key: key, Algorithm: p.Algorithm, Limit: p.Limit, Remaining: p.Limit}
+	if bucket.Algorithm == "" {
+		bucket.Algorithm = AlgorithmTokenBucket
+	}
+	now := time.Now().UnixMilli()
+	window := p.Window.Milliseconds()
+	if window <= 0 {
+		return bucket, false, nil
+	}
+
+	switch bucket.Algorithm {
+	case AlgorithmSlidingLog, AlgorithmConcurrency:
+		entries, err := rdb.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Min: strconv.FormatInt(now-window, 10),
+			Max: "+inf",
+		}).Result()
+		if err != nil {
+			return bucket, false, err
+		}
+		bucket.Remaining = max(0, p.Limit-len(entries))
+		bucket.ResetAt = now / 1000
+		if len(entries) > 0 {
+			bucket.ResetAt = (int64(entries[0].Score) + window) / 1000
+		}
+		return bucket, true, nil
+	}
+
+	state, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil || len(state) == 0 {
+		return bucket, false, err
+	}
+	number := func(field string) float64 {
+		v, _ := strconv.ParseFloat(state[field], 64)
+		return v
+	}
+
+	limit := float64(p.Limit)
+	switch bucket.Algorithm {
+	case AlgorithmTokenBucket:
+		rate := limit / float64(window)
+		tokens := math.Min(limit, number("tokens")+float64(now-int64(number("timestamp")))*rate)
+		bucket.Remaining = int(tokens)
+		bucket.ResetAt = (now + int64((limit-tokens)/rate)) / 1000
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow:
+		current := now / window
+		count, previous := number("count"), number("previous")
+		switch int64(number("window")) {
+		case current:
+		case current - 1:
+			count, previous = 0, count
+		default:
+			count, previous = 0, 0
+		}
+		used := count
+		if bucket.Algorithm == AlgorithmSlidingWindow {
+			used += previous * float64(window-(now-current*window)) / float64(window)
+		}
+		bucket.Remaining = max(0, int(limit-used))
+		bucket.ResetAt = (current + 1) * window / 1000
+	}
+	return bucket, true, nil
+}
+
+// Exempt lets subject bypass every limit until until, on all instances
+// once they refreshed their exemptions
+func (l *Limiter) Exempt(ctx context.Context, subject string, until time.Time) error {
+	if err := l.redis.redis.HSet(ctx, exemptionsKey, subject, until.Unix()).Err(); err != nil {
+		return err
+	}
+	return l.RefreshExemptions(ctx)
+}
+
+// RemoveExemption ends an exemption early. It reports false if there was
+// none.
+func (l *Limiter) RemoveExemption(ctx context.Context, subject string) (bool, error) {
+	n, err := l.redis.redis.HDel(ctx, exemptionsKey, subject).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, l.RefreshExemptions(ctx)
+}
+
+// Exemptions lists the exemptions in force
+func (l *Limiter) Exemptions() []Exemption {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now()
+	exemptions := []Exemption{}
+	for subject, until := range l.exemptions {
+		if now.Before(until) {
+			exemptions = append(exemptions, Exemption{Subject: subject, ExpiresAt: until})
+		}
+	}
+	sort.Slice(exemptions, func(i, j int) bool { return exemptions[i].Subject < exemptions[j].Subject })
+	return exemptions
+}
+
+// Exempted reports whether any of subjects is exempt from rate limits
+func (l *Limiter) Exempted(subjects ...string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.exemptions) == 0 {
+		return false
+	}
+	now := time.Now()
+	for _, subject := range subjects {
+		if until, ok := l.exemptions[subject]; ok && now.Before(until) {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshExemptions reloads the exemptions from Redis, dropping expired
+// ones
+func (l *Limiter) RefreshExemptions(ctx context.Context) error {
+	stored, err := l.redis.redis.HGetAll(ctx, exemptionsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	exemptions := make(map[string]time.Time, len(stored))
+	var expired []string
+	for subject, value := range stored {
+		unix, _ := strconv.ParseInt(value, 10, 64)
+		until := time.Unix(unix, 0)
+		if !now.Before(until) {
+			expired = append(expired, subject)
+			continue
+		}
+		exemptions[subject] = until
+	}
+	if len(expired) > 0 {
+		l.redis.redis.HDel(ctx, exemptionsKey, expired...)
+	}
+
+	l.mu.Lock()
+	l.exemptions = exemptions
+	l.mu.Unlock()
+	return nil
+}
+
+// Start picks up exemptions made on other instances every interval until
+// ctx is cancelled. The last known exemptions stay in force while Redis
+// is unreachable.
+func (l *Limiter) Start(ctx context.Context, interval time.Duration) {
+	if err := l.RefreshExemptions(ctx); err != nil {
+		l.logger.Warnw("Failed to load rate limit exemptions", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.RefreshExemptions(ctx); err != nil {
+				l.logger.Warnw("Failed to refresh rate limit exemptions", "error", err)
+			}
+		}
+	}
+}
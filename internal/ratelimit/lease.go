@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"api-gateway/internal/metrics"
+)
+
+// lease is the tokens of one key held by this gateway
+type lease struct {
+	mu sync.Mutex
+
+	tokens  int
+	expires time.Time
+	// size is how many tokens the next lease asks for. It doubles while
+	// leases run out before expiring and halves when they expire unspent.
+	size int
+	// remaining is what the bucket in Redis held after the last lease
+	remaining int
+	// deniedUntil answers requests locally after Redis denied one
+	deniedUntil time.Time
+}
+
+// Leases takes tokens of busy token buckets from Redis in batches and
+// hands them out in process, so most requests on a key don't call Redis.
+// Limits stay global: a leased token has been taken from the bucket every
+// gateway shares, so nothing is admitted beyond the limit. Tokens still
+// leased when their lease expires are dropped; a key may therefore admit
+// up to LeaseFraction of its limit fewer requests per gateway.
+type Leases struct {
+	redis *Redis
+
+	mu        sync.Mutex
+	leases    map[string]*lease
+	lastSweep time.Time
+}
+
+func NewLeases(r *Redis) *Leases {
+	return &Leases{
+		redis:     r,
+		leases:    make(map[string]*lease),
+		lastSweep: time.Now(),
+	}
+}
+
+// Applies reports whether p is leased: a token bucket with a lease fraction
+func (ls *Leases) Applies(p Policy) bool {
+	return p.LeaseFraction > 0 && p.LeaseTTL > 0 && (p.Algorithm == "" || p.Algorithm == AlgorithmTokenBucket)
+}
+
+// Take admits a request from key's lease, leasing more tokens from Redis
+// once it is spent or expired
+func (ls *Leases) Take(ctx context.Context, key string, p Policy) (*Result, error) {
+	l := ls.get(key)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.tokens > 0 && now.Before(l.expires) {
+		l.tokens--
+		metrics.RateLimitLeased.Inc()
+		return &Result{
+			Allowed:   true,
+			Limit:     p.Limit,
+			Remaining: l.remaining + l.tokens,
+			ResetAt:   now.Unix() + int64(p.Window.Seconds()),
+		}, nil
+	}
+	if now.Before(l.deniedUntil) {
+		metrics.RateLimitLeased.Inc()
+		retryIn := l.deniedUntil.Sub(now)
+		retryAfter := int((retryIn + time.Second - 1) / time.Second)
+		return &Result{
+			Limit:      p.Limit,
+			RetryAfter: retryAfter,
+			ResetAt:    now.Unix() + int64(retryAfter),
+			retryIn:    retryIn,
+		}, nil
+	}
+
+	maxSize := int(float64(p.Limit) * p.LeaseFraction)
+	switch {
+	case l.tokens > 0 || l.expires.IsZero():
+		l.size /= 2
+	case now.Before(l.expires.Add(p.LeaseTTL)):
+		// The last lease ran out before, or just after, expiring
+		l.size *= 2
+	}
+	l.size = max(1, min(l.size, maxSize))
+
+	granted, result, err := ls.redis.Lease(ctx, key, p, l.size)
+	if err != nil {
+		return nil, err
+	}
+	if granted == 0 {
+		l.tokens, l.expires = 0, time.Time{}
+		l.deniedUntil = now.Add(result.retryIn)
+		return result, nil
+	}
+
+	l.tokens = granted - 1
+	l.expires = now.Add(p.LeaseTTL)
+	l.remaining = result.Remaining
+	result.Remaining += l.tokens
+	return result, nil
+}
+
+func (ls *Leases) get(key string) *lease {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.sweep(time.Now())
+	l, ok := ls.leases[key]
+	if !ok {
+		l = &lease{}
+		ls.leases[key] = l
+	}
+	return l
+}
+
+// sweep drops leases that have expired, forgetting their size
+func (ls *Leases) sweep(now time.Time) {
+	if now.Sub(ls.lastSweep) < localSweepInterval {
+		return
+	}
+	ls.lastSweep = now
+
+	for key, l := range ls.leases {
+		if !l.mu.TryLock() {
+			continue
+		}
+		if now.After(l.expires) && now.After(l.deniedUntil) {
+			delete(ls.leases, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Reset drops the leases whose keys match, giving up their tokens
+func (ls *Leases) Reset(match func(key string) bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for key := range ls.leases {
+		if match(key) {
+			delete(ls.leases, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"api-gateway/internal/events"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+)
+
+// Failure modes decide what happens to requests while Redis is unavailable
+const (
+	// FailLocal enforces limits with in-process state
+	FailLocal = "local"
+	// FailOpen admits every request
+	FailOpen = "open"
+	// FailClosed rejects every request
+	FailClosed = "closed"
+)
+
+// Limiter checks policies against Redis and degrades according to the
+// failure mode when Redis can't be reached. It is long lived so fallback
+// state survives config reloads.
+type Limiter struct {
+	redis  *Redis
+	leases *Leases
+	local  *Local
+	events *events.Notifier
+	logger *logger.Logger
+
+	mu         sync.RWMutex
+	exemptions map[string]time.Time
+}
+
+func NewLimiter(redisClient *storage.RedisClient, notifier *events.Notifier, log *logger.Logger) *Limiter {
+	r := NewRedis(redisClient)
+	return &Limiter{
+		redis:  r,
+		leases: NewLeases(r),
+		local:  NewLocal(),
+		events: notifier,
+		logger: log,
+	}
+}
+
+// Take counts a request against key. A bucket running out is announced
+// as an event, at most once per throttle period per key.
+func (l *Limiter) Take(ctx context.Context, key string, p Policy) (*Result, error) {
+	result, err := l.take(ctx, key, p)
+	if err == nil && !result.Allowed {
+		dimension, subject, _ := ParseKey(key)
+		l.events.EmitThrottled(events.RateLimitExceeded, key, map[string]interface{}{
+			"dimension": dimension,
+			"subject":   subject,
+			"limit":     p.Limit,
+			"window":    p.Window.String(),
+		})
+	}
+	return result, err
+}
+
+func (l *Limiter) take(ctx context.Context, key string, p Policy) (*Result, error) {
+	var result *Result
+	var err error
+	if l.leases.Applies(p) {
+		result, err = l.leases.Take(ctx, key, p)
+	} else {
+		result, err = l.redis.Take(ctx, key, p)
+	}
+	if err == nil {
+		return result, nil
+	}
+
+	metrics.RateLimitFallbacks.WithLabelValues(p.FailureMode).Inc()
+	switch p.FailureMode {
+	case FailOpen:
+		return &Result{
+			Allowed:   true,
+			Limit:     p.Limit,
+			Remaining: p.Limit,
+			ResetAt:   time.Now().Unix() + int64(p.Window.Seconds()),
+		}, nil
+	case FailClosed:
+		return nil, err
+	default:
+		return l.local.Take(ctx, key, p)
+	}
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const localSweepInterval = time.Minute
+
+type localBucket struct {
+	tokens   float64
+	last     time.Time
+	capacity int
+	window   time.Duration
+}
+
+// Local keeps limiter state in process memory. It backs the Redis limiter
+// during an outage; limits are then enforced per gateway instance rather
+// than cluster wide. Every rate algorithm is approximated with a token
+// bucket.
+type Local struct {
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	inFlight  map[string]int
+	lastSweep time.Time
+}
+
+func NewLocal() *Local {
+	return &Local{
+		buckets:   make(map[string]*localBucket),
+		inFlight:  make(map[string]int),
+		lastSweep: time.Now(),
+	}
+}
+
+func (b *Local) Take(ctx context.Context, key string, p Policy) (*Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p.Algorithm == AlgorithmConcurrency {
+		return b.acquire(key, p.Limit), nil
+	}
+	return b.takeToken(key, p.Limit, p.Window), nil
+}
+
+func (b *Local) acquire(key string, limit int) *Result {
+	result := &Result{Limit: limit, ResetAt: time.Now().Unix()}
+	if b.inFlight[key] >= limit {
+		result.RetryAfter = 1
+		return result
+	}
+
+	b.inFlight[key]++
+	result.Allowed = true
+	result.Remaining = limit - b.inFlight[key]
+
+	var once sync.Once
+	result.release = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.inFlight[key]--; b.inFlight[key] <= 0 {
+				delete(b.inFlight, key)
+			}
+		})
+	}
+	return result
+}
+
+func (b *Local) takeToken(key string, limit int, window time.Duration) *Result {
+	now := time.Now()
+	b.sweep(now)
+
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &localBucket{tokens: float64(limit), last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.capacity, bucket.window = limit, window
+
+	rate := float64(limit) / window.Seconds()
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	result := &Result{
+		Limit:   limit,
+		ResetAt: now.Unix() + int64(window.Seconds()),
+	}
+	if bucket.tokens < 1 {
+		result.RetryAfter = int(math.Ceil((1 - bucket.tokens) / rate))
+		result.ResetAt = now.Unix() + int64(result.RetryAfter)
+		return result
+	}
+
+	bucket.tokens--
+	result.Allowed = true
+	result.Remaining = int(bucket.tokens)
+	return result
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// indistinguishable from new ones
+func (b *Local) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < localSweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, bucket := range b.buckets {
+		if now.Sub(bucket.last) >= bucket.window {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+// Reset drops the buckets whose keys match. Concurrency slots are left to
+// their requests.
+func (b *Local) Reset(match func(key string) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key := range b.buckets {
+		if match(key) {
+			delete(b.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/pkg/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unavailableBackoff is how long Redis is skipped after a failed call, so an
+// outage doesn't add a connection timeout to every request
+const unavailableBackoff = 5 * time.Second
+
+var ErrUnavailable = errors.New("rate limit store unavailable")
+
+// Algorithms
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingLog    = "sliding_log"
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmFixedWindow   = "fixed_window"
+	AlgorithmConcurrency   = "concurrency"
+)
+
+// Policy describes one limit. For the concurrency algorithm Limit is the
+// number of requests allowed in flight and Window bounds how long a slot is
+// held if its gateway dies before releasing it. LeaseFraction and LeaseTTL
+// enable token leasing for token buckets, see Leases.
+type Policy struct {
+	Algorithm     string
+	Limit         int
+	Window        time.Duration
+	FailureMode   string
+	LeaseFraction float64
+	LeaseTTL      time.Duration
+}
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter int   // seconds until a request may be admitted, when denied
+	ResetAt    int64 // unix time
+
+	retryIn time.Duration
+	release func()
+}
+
+// Release frees a concurrency slot. It is a no-op for other algorithms.
+func (r *Result) Release() {
+	if r != nil && r.release != nil {
+		r.release()
+	}
+}
+
+// Redis runs every algorithm as a single atomic script, so concurrent
+// requests on any number of gateways can't over-admit
+type Redis struct {
+	redis     *storage.RedisClient
+	downUntil atomic.Int64
+}
+
+func NewRedis(redisClient *storage.RedisClient) *Redis {
+	return &Redis{redis: redisClient}
+}
+
+// Take checks the policy for key and, if allowed, records the request.
+// After a Redis failure it returns ErrUnavailable for a short while without
+// trying Redis again.
+func (r *Redis) Take(ctx context.Context, key string, p Policy) (*Result, error) {
+	script, args, err := r.script(p)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := r.run(ctx, script, key, args)
+	if err != nil {
+		return nil, err
+	}
+	result := newResult(values, p)
+
+	if p.Algorithm == AlgorithmConcurrency && result.Allowed {
+		member := args[2].(string)
+		result.release = func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			r.redis.ZRem(ctx, key, member)
+		}
+	}
+	return result, nil
+}
+
+// Lease takes up to n tokens at once from key's token bucket and returns
+// how many it got, with the result of the request that asked for them
+func (r *Redis) Lease(ctx context.Context, key string, p Policy, n int) (int, *Result, error) {
+	values, err := r.run(ctx, tokenBucketScript, key, []interface{}{p.Limit, p.Window.Milliseconds(), n})
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(values[0]), newResult(values, p), nil
+}
+
+// run runs a limit script, returning ErrUnavailable without trying Redis
+// for a while after a failure
+func (r *Redis) run(ctx context.Context, script *redis.Script, key string, args []interface{}) ([]int64, error) {
+	if time.Now().UnixNano() < r.downUntil.Load() {
+		return nil, ErrUnavailable
+	}
+
+	values, err := script.Run(ctx, r.redis, []string{key}, args...).Int64Slice()
+	if err != nil {
+		r.downUntil.Store(time.Now().Add(unavailableBackoff).UnixNano())
+		return nil, errors.Join(ErrUnavailable, err)
+	}
+	return values, nil
+}
+
+// newResult reads the {allowed, remaining, retry_after_ms} a script returns
+func newResult(values []int64, p Policy) *Result {
+	now := time.Now().Unix()
+	result := &Result{
+		Allowed:   values[0] >= 1,
+		Limit:     p.Limit,
+		Remaining: int(values[1]),
+		ResetAt:   now + int64(p.Window.Seconds()),
+	}
+	if !result.Allowed {
+		result.retryIn = time.Duration(values[2]) * time.Millisecond
+		result.RetryAfter = int((values[2] + 999) / 1000)
+		result.ResetAt = now + int64(result.RetryAfter)
+	}
+	return result
+}
+
+func (r *Redis) script(p Policy) (*redis.Script, []interface{}, error) {
+	window := p.Window.Milliseconds()
+	switch p.Algorithm {
+	case "", AlgorithmTokenBucket:
+		return tokenBucketScript, []interface{}{p.Limit, window}, nil
+	case AlgorithmFixedWindow:
+		return fixedWindowScript, []interface{}{p.Limit, window}, nil
+	case AlgorithmSlidingWindow:
+		return slidingWindowScript, []interface{}{p.Limit, window}, nil
+	case AlgorithmSlidingLog:
+		return slidingLogScript, []interface{}{p.Limit, window, newMember()}, nil
+	case AlgorithmConcurrency:
+		return concurrencyScript, []interface{}{p.Limit, window, newMember()}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit algorithm %q", p.Algorithm)
+	}
+}
+
+// newMember returns a unique sorted set member for one request
+func newMember() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
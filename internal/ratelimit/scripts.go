@@ -0,0 +1,176 @@
+package ratelimit
+
+import "github.com/redis/go-redis/v9"
+
+// Every script takes KEYS[1] as the limit key and ARGV[1] limit, ARGV[2]
+// window in milliseconds, and returns {allowed, remaining, retry_after_ms}.
+// Redis server time is used so gateways with skewed clocks agree.
+
+// tokenBucketScript refills and takes from the bucket in one step. ARGV[3]
+// optionally asks for more than one token, to lease them; as many as the
+// bucket holds are taken and their number returned in place of allowed.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local requested = tonumber(ARGV[3]) or 1
+local rate = capacity / window
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'timestamp')
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil or last == nil or last > now then
+	tokens = capacity
+	last = now
+end
+
+tokens = math.min(capacity, tokens + (now - last) * rate)
+
+local granted = 0
+local retry = 0
+if tokens >= 1 then
+	granted = math.min(requested, math.floor(tokens))
+	tokens = tokens - granted
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'timestamp', now)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+
+return {granted, math.floor(tokens), retry}
+`)
+
+// fixedWindowScript counts requests per aligned window. Rejected requests
+// aren't counted.
+var fixedWindowScript = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local current = math.floor(now / window)
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'count')
+local count = tonumber(state[2]) or 0
+if tonumber(state[1]) ~= current then
+	count = 0
+end
+
+if count >= limit then
+	return {0, 0, (current + 1) * window - now}
+end
+
+count = count + 1
+redis.call('HSET', KEYS[1], 'window', current, 'count', count)
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {1, limit - count, 0}
+`)
+
+// slidingWindowScript approximates a sliding window from the counts of the
+// current and previous fixed windows, weighting the previous one by how much
+// of it still overlaps the sliding window
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local current = math.floor(now / window)
+local elapsed = now - current * window
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'count', 'previous')
+local stored = tonumber(state[1])
+local count = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+if stored == current - 1 then
+	previous = count
+	count = 0
+elseif stored ~= current then
+	previous = 0
+	count = 0
+end
+
+local weight = (window - elapsed) / window
+local estimate = previous * weight + count
+
+if estimate + 1 > limit then
+	local retry = window - elapsed
+	if count + 1 <= limit and previous > 0 then
+		-- wait until enough of the previous window has slid out
+		local needed = window * (1 - (limit - count - 1) / previous)
+		retry = math.max(1, math.ceil(needed - elapsed))
+	end
+	return {0, 0, retry}
+end
+
+count = count + 1
+redis.call('HSET', KEYS[1], 'window', current, 'count', count, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+
+return {1, math.floor(limit - estimate - 1), 0}
+`)
+
+// slidingLogScript keeps the timestamp of every admitted request in the
+// window. It is exact but stores one entry per request. ARGV[3] is a unique
+// member for this request.
+var slidingLogScript = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+if count >= limit then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	local retry = window
+	if oldest[2] then
+		retry = math.max(1, tonumber(oldest[2]) + window - now)
+	end
+	return {0, 0, retry}
+end
+
+redis.call('ZADD', KEYS[1], now, ARGV[3])
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {1, limit - count - 1, 0}
+`)
+
+// concurrencyScript holds a slot per in-flight request. Slots older than
+// the window are treated as leaked by a dead gateway and dropped. ARGV[3]
+// is the slot member, removed again when the request completes.
+var concurrencyScript = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+if count >= limit then
+	return {0, 0, 1000}
+end
+
+redis.call('ZADD', KEYS[1], now, ARGV[3])
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {1, limit - count - 1, 0}
+`)
@@ -0,0 +1,154 @@
+// Package schedule parses cron expressions and tells whether a time falls
+// within the windows they open.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Schedule timezones must load in images without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// MaxWindow bounds the window a schedule may keep open after each start
+const MaxWindow = 7 * 24 * time.Hour
+
+// Cron is a standard five field cron expression: minute, hour, day of
+// month, month and day of week. Fields take *, numbers, names (jan, mon),
+// ranges, lists and steps such as */15 or 1-5.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// Like cron, a day matches either restricted day field when both are
+	restrictedDom, restrictedDow bool
+}
+
+type field struct {
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField    = field{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+func Parse(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	c := &Cron{
+		restrictedDom: fields[2] != "*",
+		restrictedDow: fields[4] != "*",
+	}
+	var err error
+	if c.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parse turns one field into a bit set of the values it matches
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		if rangeExpr != "*" {
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = f.value(lowExpr); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highExpr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q", rangeExpr)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(expr string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(expr, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(expr)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", expr, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Matches reports whether the expression fires in t's minute
+func (c *Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.restrictedDom && c.restrictedDow {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Active reports whether t falls within window after a time the
+// expression fires, and when that window closes. Windows opened before it
+// closes extend it.
+func (c *Cron) Active(t time.Time, window time.Duration) (bool, time.Time) {
+	window = min(window, MaxWindow)
+	minute := t.Truncate(time.Minute)
+	for start := minute; t.Sub(start) < window; start = start.Add(-time.Minute) {
+		if c.Matches(start) {
+			return true, start.Add(window)
+		}
+	}
+	return false, time.Time{}
+}
@@ -0,0 +1,665 @@
+// Package schema validates JSON documents against a JSON Schema. It
+// supports the commonly used subset of draft 2020-12: type, enum, const,
+// the numeric, string, array and object constraints, the applicators
+// allOf, anyOf, oneOf and not, and $ref to "#" or to $defs/definitions of
+// the same document.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxErrors bounds the errors reported for one document
+const maxErrors = 20
+
+// Schema is a compiled JSON schema
+type Schema struct {
+	root *node
+}
+
+// Error is a violation at Path, a JSON pointer into the document
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e Error) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+type node struct {
+	boolean *bool
+	ref     string
+	root    *node
+	defs    map[string]*node
+
+	types    []string
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	items       *node
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	properties           map[string]*node
+	required             []string
+	additionalProperties *node
+	minProperties        *int
+	maxProperties        *int
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+}
+
+// Load compiles the schema in a JSON file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Compile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Compile compiles a schema document
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	root := &node{}
+	if err := root.compile(doc, root, ""); err != nil {
+		return nil, err
+	}
+	if err := root.resolve(""); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate checks a JSON document. It returns nil when the document
+// conforms and an error for malformed JSON.
+func (s *Schema) Validate(data []byte) ([]Error, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+
+	var errs []Error
+	s.root.validate(doc, "", &errs)
+	return errs, nil
+}
+
+func (n *node) compile(doc interface{}, root *node, at string) error {
+	n.root = root
+	if b, ok := doc.(bool); ok {
+		n.boolean = &b
+		return nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or a boolean", location(at))
+	}
+
+	sub := func(key string, value interface{}) (*node, error) {
+		child := &node{}
+		return child, child.compile(value, root, at+"/"+key)
+	}
+	list := func(key string, value interface{}) ([]*node, error) {
+		values, ok := value.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%s/%s: must be a non-empty array", location(at), key)
+		}
+		nodes := make([]*node, len(values))
+		for i, v := range values {
+			child, err := sub(key+"/"+strconv.Itoa(i), v)
+			if err != nil {
+				return nil, err
+			}
+			nodes[i] = child
+		}
+		return nodes, nil
+	}
+
+	for key, value := range m {
+		var err error
+		switch key {
+		case "$ref":
+			ref, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s/$ref: must be a string", location(at))
+			}
+			n.ref = ref
+		case "$defs", "definitions":
+			defs, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s/%s: must be an object", location(at), key)
+			}
+			if n.defs == nil {
+				n.defs = make(map[string]*node)
+			}
+			for name, def := range defs {
+				if n.defs[key+"/"+name], err = sub(key+"/"+name, def); err != nil {
+					return err
+				}
+			}
+		case "type":
+			switch t := value.(type) {
+			case string:
+				n.types = []string{t}
+			case []interface{}:
+				for _, v := range t {
+					s, ok := v.(string)
+					if !ok {
+						return fmt.Errorf("%s/type: must be a string or an array of strings", location(at))
+					}
+					n.types = append(n.types, s)
+				}
+			default:
+				return fmt.Errorf("%s/type: must be a string or an array of strings", location(at))
+			}
+			for _, t := range n.types {
+				switch t {
+				case "null", "boolean", "object", "array", "number", "integer", "string":
+				default:
+					return fmt.Errorf("%s/type: unknown type %q", location(at), t)
+				}
+			}
+		case "enum":
+			values, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s/enum: must be an array", location(at))
+			}
+			n.enum = values
+		case "const":
+			n.constant, n.hasConst = value, true
+		case "minimum":
+			n.minimum, err = number(at, key, value)
+		case "maximum":
+			n.maximum, err = number(at, key, value)
+		case "exclusiveMinimum":
+			n.exclusiveMinimum, err = number(at, key, value)
+		case "exclusiveMaximum":
+			n.exclusiveMaximum, err = number(at, key, value)
+		case "multipleOf":
+			if n.multipleOf, err = number(at, key, value); err == nil && *n.multipleOf <= 0 {
+				err = fmt.Errorf("%s/multipleOf: must be positive", location(at))
+			}
+		case "minLength":
+			n.minLength, err = count(at, key, value)
+		case "maxLength":
+			n.maxLength, err = count(at, key, value)
+		case "minItems":
+			n.minItems, err = count(at, key, value)
+		case "maxItems":
+			n.maxItems, err = count(at, key, value)
+		case "minProperties":
+			n.minProperties, err = count(at, key, value)
+		case "maxProperties":
+			n.maxProperties, err = count(at, key, value)
+		case "pattern":
+			p, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s/pattern: must be a string", location(at))
+			}
+			if n.pattern, err = regexp.Compile(p); err != nil {
+				err = fmt.Errorf("%s/pattern: %w", location(at), err)
+			}
+		case "format":
+			n.format, _ = value.(string)
+		case "items":
+			n.items, err = sub(key, value)
+		case "uniqueItems":
+			n.uniqueItems, _ = value.(bool)
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s/properties: must be an object", location(at))
+			}
+			n.properties = make(map[string]*node, len(props))
+			for name, prop := range props {
+				if n.properties[name], err = sub("properties/"+name, prop); err != nil {
+					return err
+				}
+			}
+		case "required":
+			names, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s/required: must be an array of strings", location(at))
+			}
+			for _, name := range names {
+				s, ok := name.(string)
+				if !ok {
+					return fmt.Errorf("%s/required: must be an array of strings", location(at))
+				}
+				n.required = append(n.required, s)
+			}
+		case "additionalProperties":
+			n.additionalProperties, err = sub(key, value)
+		case "allOf":
+			n.allOf, err = list(key, value)
+		case "anyOf":
+			n.anyOf, err = list(key, value)
+		case "oneOf":
+			n.oneOf, err = list(key, value)
+		case "not":
+			n.not, err = sub(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve checks every $ref points into the document's $defs or
+// definitions, or at its root
+func (n *node) resolve(at string) error {
+	if n == nil || n.boolean != nil {
+		return nil
+	}
+	if n.ref != "" {
+		if _, err := n.target(); err != nil {
+			return fmt.Errorf("%s/$ref: %w", location(at), err)
+		}
+	}
+
+	children := map[string]*node{"items": n.items, "additionalProperties": n.additionalProperties, "not": n.not}
+	for name, def := range n.defs {
+		children[name] = def
+	}
+	for name, prop := range n.properties {
+		children["properties/"+name] = prop
+	}
+	for key, nodes := range map[string][]*node{"allOf": n.allOf, "anyOf": n.anyOf, "oneOf": n.oneOf} {
+		for i, child := range nodes {
+			children[key+"/"+strconv.Itoa(i)] = child
+		}
+	}
+	for key, child := range children {
+		if err := child.resolve(at + "/" + key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *node) target() (*node, error) {
+	if n.ref == "#" {
+		return n.root, nil
+	}
+	if name := strings.TrimPrefix(n.ref, "#/"); name != n.ref {
+		if def, ok := n.root.defs[name]; ok {
+			return def, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported or unknown reference %q", n.ref)
+}
+
+func (n *node) validate(value interface{}, path string, errs *[]Error) {
+	if len(*errs) >= maxErrors {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		if len(*errs) < maxErrors {
+			*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if n.boolean != nil {
+		if !*n.boolean {
+			fail("is not allowed")
+		}
+		return
+	}
+	if n.ref != "" {
+		target, _ := n.target()
+		target.validate(value, path, errs)
+	}
+
+	if len(n.types) > 0 && !matchesType(value, n.types) {
+		fail("must be of type %s", strings.Join(n.types, " or "))
+		return
+	}
+	if n.hasConst && !equal(value, n.constant) {
+		fail("must be %s", encode(n.constant))
+	}
+	if len(n.enum) > 0 {
+		found := false
+		for _, allowed := range n.enum {
+			if equal(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", encode(n.enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		n.validateNumber(f, fail)
+	case string:
+		n.validateString(v, fail)
+	case []interface{}:
+		n.validateArray(v, path, errs, fail)
+	case map[string]interface{}:
+		n.validateObject(v, path, errs, fail)
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(value, path, errs)
+	}
+	if len(n.anyOf) > 0 && n.matching(n.anyOf, value, path) == 0 {
+		fail("must match at least one schema of anyOf")
+	}
+	if len(n.oneOf) > 0 {
+		if matched := n.matching(n.oneOf, value, path); matched != 1 {
+			fail("must match exactly one schema of oneOf, matched %d", matched)
+		}
+	}
+	if n.not != nil && n.not.valid(value, path) {
+		fail("must not match the schema in not")
+	}
+}
+
+func (n *node) validateNumber(f float64, fail func(string, ...interface{})) {
+	if n.minimum != nil && f < *n.minimum {
+		fail("must be at least %v", *n.minimum)
+	}
+	if n.maximum != nil && f > *n.maximum {
+		fail("must be at most %v", *n.maximum)
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		fail("must be greater than %v", *n.exclusiveMinimum)
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		fail("must be less than %v", *n.exclusiveMaximum)
+	}
+	if n.multipleOf != nil {
+		if q := f / *n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("must be a multiple of %v", *n.multipleOf)
+		}
+	}
+}
+
+func (n *node) validateString(s string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		fail("must be at least %d characters long", *n.minLength)
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		fail("must be at most %d characters long", *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		fail("must match the pattern %s", n.pattern)
+	}
+	if n.format != "" && !validFormat(n.format, s) {
+		fail("must be a valid %s", n.format)
+	}
+}
+
+func (n *node) validateArray(items []interface{}, path string, errs *[]Error, fail func(string, ...interface{})) {
+	if n.minItems != nil && len(items) < *n.minItems {
+		fail("must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(items) > *n.maxItems {
+		fail("must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if equal(items[i], items[j]) {
+					fail("items %d and %d must be unique", j, i)
+				}
+			}
+		}
+	}
+	if n.items != nil {
+		for i, item := range items {
+			n.items.validate(item, path+"/"+strconv.Itoa(i), errs)
+		}
+	}
+}
+
+func (n *node) validateObject(obj map[string]interface{}, path string, errs *[]Error, fail func(string, ...interface{})) {
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		fail("must have at least %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		fail("must have at most %d properties", *n.maxProperties)
+	}
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, Error{Path: path + "/" + escape(name), Message: "is required"})
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := obj[name]
+		if prop, ok := n.properties[name]; ok {
+			prop.validate(value, path+"/"+escape(name), errs)
+		} else if n.additionalProperties != nil {
+			n.additionalProperties.validate(value, path+"/"+escape(name), errs)
+		}
+	}
+}
+
+// matching counts the schemas value conforms to
+func (n *node) matching(schemas []*node, value interface{}, path string) int {
+	matched := 0
+	for _, sub := range schemas {
+		if sub.valid(value, path) {
+			matched++
+		}
+	}
+	return matched
+}
+
+func (n *node) valid(value interface{}, path string) bool {
+	var errs []Error
+	n.validate(value, path, &errs)
+	return len(errs) == 0
+}
+
+func matchesType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if f, err := v.Float64(); t == "integer" && err == nil && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equal compares JSON values, numbers by value
+func equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*$`)
+)
+
+// validFormat checks the well-known formats; unknown formats are only
+// annotations and always pass
+func validFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "hostname":
+		return len(s) <= 253 && hostnamePattern.MatchString(s)
+	case "ipv4":
+		return ipVersion(s) == 4
+	case "ipv6":
+		return ipVersion(s) == 6
+	}
+	return true
+}
+
+func number(at, key string, value interface{}) (*float64, error) {
+	f, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: must be a number", location(at), key)
+	}
+	return &f, nil
+}
+
+func count(at, key string, value interface{}) (*int, error) {
+	f, ok := value.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s/%s: must be a non-negative integer", location(at), key)
+	}
+	i := int(f)
+	return &i, nil
+}
+
+func encode(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// escape encodes a property name as a JSON pointer token
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func location(at string) string {
+	return "#" + at
+}
+
+func ipVersion(s string) int {
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil:
+		return 0
+	case strings.Contains(s, ":"):
+		return 6
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	apiKeyPrefix   = "gw_"
+	apiKeyCacheTTL = 30 * time.Second
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type cachedAPIKey struct {
+	key      *models.APIKey
+	cachedAt time.Time
+}
+
+// APIKeyStore manages API keys in Mongo. Lookups are cached briefly so key
+// authentication doesn't hit the database on every request; revocations
+// made on this instance take effect immediately.
+type APIKeyStore struct {
+	collection *mongo.Collection
+	cache      map[string]cachedAPIKey
+	mu         sync.RWMutex
+}
+
+func NewAPIKeyStore(mongoClient *storage.MongoClient) *APIKeyStore {
+	return &APIKeyStore{
+		collection: mongoClient.Database.Collection("api_keys"),
+		cache:      make(map[string]cachedAPIKey),
+	}
+}
+
+// Create stores a new key and returns it together with the plaintext secret
+func (s *APIKeyStore) Create(ctx context.Context, req models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	plaintext, hash, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      req.Name,
+		Prefix:    plaintext[:len(apiKeyPrefix)+6],
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		Priority:  req.Priority,
+		Quotas:    req.Quotas,
+		TenantID:  req.TenantID,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	if _, err := s.collection.InsertOne(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *APIKeyStore) List(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]models.APIKey, 0)
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Rotate replaces the secret of an existing key, invalidating the old one
+func (s *APIKeyStore) Rotate(ctx context.Context, id primitive.ObjectID) (*models.APIKey, string, error) {
+	plaintext, hash, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	var key models.APIKey
+	err = s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "active": true},
+		bson.M{"$set": bson.M{
+			"key_hash":   hash,
+			"prefix":     plaintext[:len(apiKeyPrefix)+6],
+			"rotated_at": now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.flushCache()
+	return &key, plaintext, nil
+}
+
+func (s *APIKeyStore) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"active": false}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	s.flushCache()
+	return nil
+}
+
+// Authenticate resolves a plaintext key to an active API key
+func (s *APIKeyStore) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	hash := utils.HashToken(plaintext)
+
+	s.mu.RLock()
+	cached, ok := s.cache[hash]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.cachedAt) < apiKeyCacheTTL {
+		return cached.key, nil
+	}
+
+	var key models.APIKey
+	err := s.collection.FindOne(ctx, bson.M{"key_hash": hash, "active": true}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.collection.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"last_used_at": now}})
+
+	s.mu.Lock()
+	s.cache[hash] = cachedAPIKey{key: &key, cachedAt: now}
+	s.mu.Unlock()
+
+	return &key, nil
+}
+
+func (s *APIKeyStore) flushCache() {
+	s.mu.Lock()
+	s.cache = make(map[string]cachedAPIKey)
+	s.mu.Unlock()
+}
+
+func newAPIKeySecret() (string, string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext := apiKeyPrefix + token
+	return plaintext, utils.HashToken(plaintext), nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/models"
+	"api-gateway/internal/store"
+	"api-gateway/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog writes audit events to the audit store. Events are removed by
+// the store's expiry once the configured retention has passed.
+type AuditLog struct {
+	store  store.AuditStore
+	config config.AuditConfig
+	logger *logger.Logger
+}
+
+func NewAuditLog(audit store.AuditStore, cfg config.AuditConfig, log *logger.Logger) *AuditLog {
+	return &AuditLog{
+		store:  audit,
+		config: cfg,
+		logger: log,
+	}
+}
+
+// Retention is how long events are kept, zero while auditing is off
+func (a *AuditLog) Retention() time.Duration {
+	if !a.config.Enabled {
+		return 0
+	}
+	return a.config.Retention
+}
+
+// Record stores an event. Failures are logged rather than returned so an
+// unavailable audit store never fails the operation being audited.
+func (a *AuditLog) Record(event models.AuditEvent) {
+	if !a.config.Enabled {
+		return
+	}
+
+	event.ID = primitive.NewObjectID()
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.store.InsertAuditEvent(ctx, &event); err != nil {
+		a.logger.Errorw("Failed to write audit event", "action", event.Action, "actor", event.Actor, "error", err)
+	}
+}
+
+// Query returns matching events, newest first
+func (a *AuditLog) Query(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	return a.store.QueryAuditEvents(ctx, q)
+}
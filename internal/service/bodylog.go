@@ -0,0 +1,105 @@
+package service
+
+import (
+	"math/rand"
+	"sync"
+
+	"api-gateway/internal/config"
+)
+
+// BodyLogSettings is the runtime state of body capture. Routes maps route
+// paths to an explicit on or off; other routes follow Enabled.
+type BodyLogSettings struct {
+	Enabled    bool            `json:"enabled"`
+	SampleRate float64         `json:"sample_rate"`
+	MaxSize    int             `json:"max_size"`
+	Routes     map[string]bool `json:"routes"`
+}
+
+// BodyLogger decides which requests get their bodies captured and redacts
+// them. Capture can be switched on and off at runtime, globally or per
+// route; route switches last until the next config reload.
+type BodyLogger struct {
+	redactor *Redactor
+
+	mu       sync.RWMutex
+	settings BodyLogSettings
+}
+
+func NewBodyLogger(cfg config.BodyLogConfig) *BodyLogger {
+	return &BodyLogger{
+		redactor: NewRedactor(cfg.RedactFields, cfg.RedactHeaders),
+		settings: BodyLogSettings{
+			Enabled:    cfg.Enabled,
+			SampleRate: cfg.SampleRate,
+			MaxSize:    cfg.MaxSize,
+			Routes:     make(map[string]bool),
+		},
+	}
+}
+
+func (b *BodyLogger) Redactor() *Redactor {
+	return b.redactor
+}
+
+// SetRoutes replaces the route switches with those in config
+func (b *BodyLogger) SetRoutes(routes []config.RouteConfig) {
+	switches := make(map[string]bool)
+	for _, route := range routes {
+		if route.LogBodies != nil {
+			switches[route.Path] = *route.LogBodies
+		}
+	}
+
+	b.mu.Lock()
+	b.settings.Routes = switches
+	b.mu.Unlock()
+}
+
+// Update switches capture on or off for a route, or globally when route
+// is empty. A nil sampleRate keeps the current rate.
+func (b *BodyLogger) Update(route string, enabled bool, sampleRate *float64) BodyLogSettings {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if route == "" {
+		b.settings.Enabled = enabled
+	} else {
+		routes := make(map[string]bool, len(b.settings.Routes)+1)
+		for path, on := range b.settings.Routes {
+			routes[path] = on
+		}
+		routes[route] = enabled
+		b.settings.Routes = routes
+	}
+	if sampleRate != nil {
+		b.settings.SampleRate = *sampleRate
+	}
+	return b.settings
+}
+
+func (b *BodyLogger) Settings() BodyLogSettings {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.settings
+}
+
+// Capture reports whether the bodies of a request to route should be
+// captured, and if so how many bytes of each to keep
+func (b *BodyLogger) Capture(route string) (int, bool) {
+	b.mu.RLock()
+	settings := b.settings
+	b.mu.RUnlock()
+
+	enabled, ok := settings.Routes[route]
+	if !ok {
+		enabled = settings.Enabled
+	}
+	if !enabled || settings.SampleRate <= 0 {
+		return 0, false
+	}
+	if settings.SampleRate < 1 && rand.Float64() >= settings.SampleRate {
+		return 0, false
+	}
+	return settings.MaxSize, true
+}
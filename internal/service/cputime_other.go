@@ -0,0 +1,10 @@
+//go:build !unix
+
+package service
+
+import "time"
+
+// processCPUTime is unavailable here, so CPU is left out of load shedding
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/logger"
+)
+
+// DiscoveryProvider streams the instance URLs of a service. Watch blocks,
+// calling update whenever the instance set changes, until ctx is cancelled
+// or an error occurs.
+type DiscoveryProvider interface {
+	Watch(ctx context.Context, target config.ServiceDiscoveryConfig, update func([]string)) error
+}
+
+// Discovery keeps registry instances in sync with external service
+// discovery for every service that declares a discovery block.
+type Discovery struct {
+	registry  *Registry
+	providers map[string]DiscoveryProvider
+	logger    *logger.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func NewDiscovery(registry *Registry, cfg config.DiscoveryConfig, log *logger.Logger) *Discovery {
+	return &Discovery{
+		registry: registry,
+		providers: map[string]DiscoveryProvider{
+			"consul": NewConsulProvider(cfg.ConsulAddr, cfg.ConsulToken),
+			"etcd":   NewEtcdProvider(cfg.EtcdEndpoints),
+			"dns":    NewDNSProvider(cfg.DNSInterval),
+		},
+		logger: log,
+	}
+}
+
+// Start (re)starts watchers for the given services, stopping any watchers
+// from a previous call
+func (d *Discovery) Start(ctx context.Context, services []config.ServiceConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cancel != nil {
+		d.cancel()
+	}
+	ctx, d.cancel = context.WithCancel(ctx)
+
+	for _, svc := range services {
+		if svc.Discovery == nil {
+			continue
+		}
+
+		provider, ok := d.providers[svc.Discovery.Provider]
+		if !ok {
+			d.logger.Errorw("Unknown discovery provider", "service", svc.Name, "provider", svc.Discovery.Provider)
+			continue
+		}
+
+		target := *svc.Discovery
+		if target.Name == "" {
+			target.Name = svc.Name
+		}
+		target.URLs = svc.URLs
+		go d.watch(ctx, provider, svc.Name, target)
+	}
+}
+
+// watch runs a provider watch, restarting it with backoff on failure
+func (d *Discovery) watch(ctx context.Context, provider DiscoveryProvider, serviceName string, target config.ServiceDiscoveryConfig) {
+	delay := time.Second
+	for {
+		err := provider.Watch(ctx, target, func(urls []string) {
+			sort.Strings(urls)
+			if err := d.registry.SetURLs(serviceName, urls); err != nil {
+				return
+			}
+			d.logger.Infow("Service instances updated from discovery",
+				"service", serviceName,
+				"provider", target.Provider,
+				"instances", len(urls),
+			)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		d.logger.Warnw("Discovery watch failed, retrying",
+			"service", serviceName,
+			"provider", target.Provider,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
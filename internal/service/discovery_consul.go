@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// ConsulProvider discovers passing instances through the Consul health API
+// using blocking queries, so changes are picked up as soon as they happen.
+type ConsulProvider struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+func NewConsulProvider(addr, token string) *ConsulProvider {
+	return &ConsulProvider{
+		addr:  strings.TrimSuffix(addr, "/"),
+		token: token,
+		// Must outlast the blocking query wait time
+		client: &http.Client{Timeout: 6 * time.Minute},
+	}
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func (p *ConsulProvider) Watch(ctx context.Context, target config.ServiceDiscoveryConfig, update func([]string)) error {
+	scheme := target.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	var index uint64
+	for {
+		query := url.Values{}
+		query.Set("passing", "true")
+		query.Set("wait", "5m")
+		query.Set("index", strconv.FormatUint(index, 10))
+		if target.Tag != "" {
+			query.Set("tag", target.Tag)
+		}
+
+		endpoint := fmt.Sprintf("%s/v1/health/service/%s?%s", p.addr, url.PathEscape(target.Name), query.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		if p.token != "" {
+			req.Header.Set("X-Consul-Token", p.token)
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		var entries []consulServiceEntry
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("consul returned status %d", resp.StatusCode)
+		}
+		if err != nil {
+			return err
+		}
+
+		newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+		if newIndex == index {
+			// Wait timed out without changes
+			continue
+		}
+		// Consul docs: reset the index if it goes backwards
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		urls := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			host := entry.Service.Address
+			if host == "" {
+				host = entry.Node.Address
+			}
+			urls = append(urls, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+		}
+		update(urls)
+	}
+}
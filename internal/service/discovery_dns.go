@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// DNSProvider resolves the hostnames in a service's URLs and re-resolves
+// them every interval. Each A/AAAA record becomes an instance on the URL's
+// port. A hostname of the form _service._proto.name is looked up as SRV
+// records instead, each target contributing its own port.
+type DNSProvider struct {
+	interval time.Duration
+	resolver *net.Resolver
+}
+
+func NewDNSProvider(interval time.Duration) *DNSProvider {
+	return &DNSProvider{
+		interval: interval,
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (p *DNSProvider) Watch(ctx context.Context, target config.ServiceDiscoveryConfig, update func([]string)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	var last []string
+	for {
+		// A failed lookup keeps the last known instances until the
+		// watcher has been restarted and resolves again
+		urls, err := p.resolve(ctx, target.URLs)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(urls, last) {
+			update(urls)
+			last = urls
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve expands every URL into one URL per resolved address
+func (p *DNSProvider) resolve(ctx context.Context, rawURLs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var urls []string
+	add := func(u url.URL, ip, port string) {
+		u.Host = ip
+		if strings.Contains(ip, ":") {
+			u.Host = "[" + ip + "]"
+		}
+		if port != "" {
+			u.Host = net.JoinHostPort(ip, port)
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			urls = append(urls, s)
+		}
+	}
+
+	for _, raw := range rawURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		host := u.Hostname()
+		if net.ParseIP(host) != nil {
+			add(*u, host, u.Port())
+			continue
+		}
+
+		if strings.HasPrefix(host, "_") {
+			_, records, err := p.resolver.LookupSRV(ctx, "", "", host)
+			if err != nil {
+				return nil, fmt.Errorf("resolve %s: %w", host, err)
+			}
+			for _, srv := range records {
+				ips, err := p.lookupIPs(ctx, strings.TrimSuffix(srv.Target, "."))
+				if err != nil {
+					return nil, err
+				}
+				for _, ip := range ips {
+					add(*u, ip, strconv.Itoa(int(srv.Port)))
+				}
+			}
+			continue
+		}
+
+		ips, err := p.lookupIPs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			add(*u, ip, u.Port())
+		}
+	}
+
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func (p *DNSProvider) lookupIPs(ctx context.Context, host string) ([]string, error) {
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	return ips, nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// EtcdProvider discovers instances stored under a key prefix in etcd, one
+// instance URL per key. It reads the prefix once through the v3 JSON
+// gateway and then follows changes on a /v3/watch stream from the next
+// revision, so no etcd client library is required.
+type EtcdProvider struct {
+	endpoints []string
+	client    *http.Client
+	// stream carries watches, which stay open until ctx ends
+	stream *http.Client
+}
+
+func NewEtcdProvider(endpoints []string) *EtcdProvider {
+	return &EtcdProvider{
+		endpoints: endpoints,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		stream:    &http.Client{},
+	}
+}
+
+// etcdIdleTimeout drops a watch stream that has sent nothing, not even a
+// progress notification, for this long
+const etcdIdleTimeout = 15 * time.Minute
+
+type etcdKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Canceled        bool   `json:"canceled"`
+		CancelReason    string `json:"cancel_reason"`
+		CompactRevision string `json:"compact_revision"`
+		Events          []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Watch reports the snapshot and then every change from the watch stream.
+// Any stream failure returns an error, and the retry starts over with a
+// fresh snapshot.
+func (p *EtcdProvider) Watch(ctx context.Context, target config.ServiceDiscoveryConfig, update func([]string)) error {
+	values, revision, endpoint, err := p.fetch(ctx, target.Prefix)
+	if err != nil {
+		return err
+	}
+	last := etcdURLs(values)
+	update(last)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":             base64.StdEncoding.EncodeToString([]byte(target.Prefix)),
+			"range_end":       base64.StdEncoding.EncodeToString(prefixEnd(target.Prefix)),
+			"start_revision":  strconv.FormatInt(revision+1, 10),
+			"progress_notify": true,
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/watch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.stream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd watch returned status %d", resp.StatusCode)
+	}
+
+	idle := time.AfterFunc(etcdIdleTimeout, cancel)
+	defer idle.Stop()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg etcdWatchResponse
+		if err := decoder.Decode(&msg); err != nil {
+			if ctx.Err() != nil && idle.Stop() {
+				return ctx.Err()
+			}
+			return fmt.Errorf("etcd watch stream: %w", err)
+		}
+		idle.Reset(etcdIdleTimeout)
+
+		switch {
+		case msg.Error != nil:
+			return fmt.Errorf("etcd watch: %s", msg.Error.Message)
+		case msg.Result == nil:
+			continue
+		case msg.Result.CompactRevision != "" && msg.Result.CompactRevision != "0":
+			return fmt.Errorf("etcd watch: revision %d compacted", revision+1)
+		case msg.Result.Canceled:
+			return fmt.Errorf("etcd watch canceled: %s", msg.Result.CancelReason)
+		}
+
+		for _, event := range msg.Result.Events {
+			if event.Type == "DELETE" {
+				delete(values, event.Kv.Key)
+			} else {
+				values[event.Kv.Key] = event.Kv.Value
+			}
+		}
+		if urls := etcdURLs(values); !reflect.DeepEqual(urls, last) {
+			update(urls)
+			last = urls
+		}
+	}
+}
+
+// fetch reads the prefix from the first endpoint that answers, returning
+// the base64 values by key, the store revision and the endpoint used
+func (p *EtcdProvider) fetch(ctx context.Context, prefix string) (map[string]string, int64, string, error) {
+	body, _ := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixEnd(prefix)),
+	})
+
+	var lastErr error
+	for _, endpoint := range p.endpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/kv/range", bytes.NewReader(body))
+		if err != nil {
+			return nil, 0, "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var result etcdRangeResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("etcd returned status %d", resp.StatusCode)
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		revision, err := strconv.ParseInt(result.Header.Revision, 10, 64)
+		if err != nil {
+			lastErr = fmt.Errorf("etcd returned invalid revision %q", result.Header.Revision)
+			continue
+		}
+
+		values := make(map[string]string, len(result.Kvs))
+		for _, kv := range result.Kvs {
+			values[kv.Key] = kv.Value
+		}
+		return values, revision, endpoint, nil
+	}
+
+	return nil, 0, "", lastErr
+}
+
+// etcdURLs decodes the instance URLs from base64 values, sorted
+func etcdURLs(values map[string]string) []string {
+	urls := make([]string, 0, len(values))
+	for _, v := range values {
+		value, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(strings.TrimSpace(string(value))) == 0 {
+			continue
+		}
+		urls = append(urls, strings.TrimSpace(string(value)))
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// prefixEnd returns the range end that matches every key with the prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Drainer tracks in-flight proxied requests and whether the gateway is
+// draining ahead of a shutdown or upgrade
+type Drainer struct {
+	draining atomic.Bool
+	inFlight atomic.Int64
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Begin switches the gateway into drain mode. It reports false if it was
+// already draining.
+func (d *Drainer) Begin() bool {
+	return d.draining.CompareAndSwap(false, true)
+}
+
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+func (d *Drainer) Acquire() {
+	d.inFlight.Add(1)
+}
+
+func (d *Drainer) Release() {
+	d.inFlight.Add(-1)
+}
+
+func (d *Drainer) InFlight() int64 {
+	return d.inFlight.Load()
+}
+
+// Wait blocks until no proxied requests are in flight or ctx is done
+func (d *Drainer) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for d.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"api-gateway/internal/config"
+)
+
+var (
+	ErrExperimentNotFound = errors.New("experiment not found")
+	ErrExperimentInConfig = errors.New("experiment is defined in the config file")
+)
+
+// Experiment is an A/B experiment in force. Source is config for the
+// experiments of the config file and admin for those added at runtime.
+type Experiment struct {
+	config.ExperimentConfig
+	Source string `json:"source"`
+
+	total int
+}
+
+// Experiment sources
+const (
+	ExperimentFromConfig = "config"
+	ExperimentFromAdmin  = "admin"
+)
+
+func newExperiment(cfg config.ExperimentConfig, source string) (*Experiment, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	e := &Experiment{ExperimentConfig: cfg, Source: source}
+	for _, v := range cfg.Variants {
+		e.total += v.Weight
+	}
+	return e, nil
+}
+
+// Assign returns the variant of the consumer with the given key. A key
+// keeps its variant while the weights stay the same, and the experiment
+// name is hashed in so experiments assign consumers independently.
+func (e *Experiment) Assign(key string) config.ExperimentVariant {
+	n := int(crc32.ChecksumIEEE([]byte(e.Name+":"+key)) % uint32(e.total))
+	for _, v := range e.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// Experiments holds the experiments in force by name. Those added through
+// the admin API outlive config reloads but not restarts.
+type Experiments struct {
+	mu          sync.RWMutex
+	experiments map[string]*Experiment
+	routes      map[string]*Experiment
+}
+
+func NewExperiments() *Experiments {
+	return &Experiments{
+		experiments: make(map[string]*Experiment),
+		routes:      make(map[string]*Experiment),
+	}
+}
+
+// ForRoute returns the experiment a route path is in, if any
+func (x *Experiments) ForRoute(route string) *Experiment {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.routes[route]
+}
+
+// Replace swaps the experiments of the config file, e.g. on reload.
+// Runtime experiments sharing a name or route with them are dropped.
+func (x *Experiments) Replace(cfgs []config.ExperimentConfig) error {
+	experiments := make(map[string]*Experiment)
+	routes := make(map[string]*Experiment)
+	for _, cfg := range cfgs {
+		e, err := newExperiment(cfg, ExperimentFromConfig)
+		if err != nil {
+			return fmt.Errorf("experiment %q: %w", cfg.Name, err)
+		}
+		experiments[e.Name] = e
+		for _, route := range e.Routes {
+			routes[route] = e
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for name, e := range x.experiments {
+		if e.Source != ExperimentFromAdmin || experiments[name] != nil || claimsAny(routes, e.Routes) {
+			continue
+		}
+		experiments[name] = e
+		for _, route := range e.Routes {
+			routes[route] = e
+		}
+	}
+	x.experiments = experiments
+	x.routes = routes
+	return nil
+}
+
+// Put adds or replaces a runtime experiment. Experiments from the config
+// file can't be changed here, and a route can only be in one experiment.
+func (x *Experiments) Put(cfg config.ExperimentConfig) (*Experiment, error) {
+	e, err := newExperiment(cfg, ExperimentFromAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if current, ok := x.experiments[e.Name]; ok && current.Source == ExperimentFromConfig {
+		return nil, ErrExperimentInConfig
+	}
+	for _, route := range e.Routes {
+		if other, ok := x.routes[route]; ok && other.Name != e.Name {
+			return nil, fmt.Errorf("route %q is already in experiment %q", route, other.Name)
+		}
+	}
+
+	x.remove(e.Name)
+	x.experiments[e.Name] = e
+	for _, route := range e.Routes {
+		x.routes[route] = e
+	}
+	return e, nil
+}
+
+// Delete ends a runtime experiment
+func (x *Experiments) Delete(name string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e, ok := x.experiments[name]
+	if !ok {
+		return ErrExperimentNotFound
+	}
+	if e.Source == ExperimentFromConfig {
+		return ErrExperimentInConfig
+	}
+	x.remove(name)
+	return nil
+}
+
+// List returns the experiments sorted by name
+func (x *Experiments) List() []*Experiment {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	experiments := make([]*Experiment, 0, len(x.experiments))
+	for _, e := range x.experiments {
+		experiments = append(experiments, e)
+	}
+	sort.Slice(experiments, func(i, j int) bool { return experiments[i].Name < experiments[j].Name })
+	return experiments
+}
+
+// remove drops an experiment and its routes. Callers hold x.mu.
+func (x *Experiments) remove(name string) {
+	e, ok := x.experiments[name]
+	if !ok {
+		return
+	}
+	delete(x.experiments, name)
+	for _, route := range e.Routes {
+		if x.routes[route] == e {
+			delete(x.routes, route)
+		}
+	}
+}
+
+func claimsAny(routes map[string]*Experiment, paths []string) bool {
+	for _, path := range paths {
+		if _, ok := routes[path]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// maxAuthzCacheEntries bounds the decision cache; expired entries are
+// swept when it fills up and it is cleared if that isn't enough
+const maxAuthzCacheEntries = 10000
+
+var errAuthzNotConfigured = errors.New("external authorization is not configured")
+
+// AuthzInput describes a request to the external decision point
+type AuthzInput struct {
+	Method   string                 `json:"method"`
+	Path     string                 `json:"path"`
+	Route    string                 `json:"route"`
+	ClientIP string                 `json:"client_ip"`
+	Subject  map[string]interface{} `json:"subject,omitempty"`
+	Headers  map[string]string      `json:"headers,omitempty"`
+}
+
+type AuthzDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExternalAuthz asks an HTTP webhook or an OPA server whether a request
+// may proceed. Decisions can be cached for identical inputs.
+type ExternalAuthz struct {
+	config config.ExternalAuthzConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	decision  AuthzDecision
+	expiresAt time.Time
+}
+
+func NewExternalAuthz(cfg config.ExternalAuthzConfig) *ExternalAuthz {
+	return &ExternalAuthz{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cache:  make(map[string]cachedDecision),
+	}
+}
+
+func (a *ExternalAuthz) FailOpen() bool {
+	return a.config.FailureMode == "open"
+}
+
+// Headers are the request headers passed to the decision point
+func (a *ExternalAuthz) Headers() []string {
+	return a.config.Headers
+}
+
+// Check returns the decision for input, from the cache when possible
+func (a *ExternalAuthz) Check(ctx context.Context, input AuthzInput) (AuthzDecision, error) {
+	if a.config.URL == "" {
+		return AuthzDecision{}, errAuthzNotConfigured
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return AuthzDecision{}, err
+	}
+
+	sum := sha256.Sum256(payload)
+	key := hex.EncodeToString(sum[:])
+	if decision, ok := a.cached(key); ok {
+		return decision, nil
+	}
+
+	var decision AuthzDecision
+	if a.config.Provider == "opa" {
+		decision, err = a.queryOPA(ctx, payload)
+	} else {
+		decision, err = a.callWebhook(ctx, payload)
+	}
+	if err != nil {
+		return AuthzDecision{}, err
+	}
+
+	a.store(key, decision)
+	return decision, nil
+}
+
+// callWebhook posts the input and expects {"allow": bool, "reason": ...}.
+// A 401 or 403 is a denial without a body.
+func (a *ExternalAuthz) callWebhook(ctx context.Context, payload []byte) (AuthzDecision, error) {
+	resp, err := a.post(ctx, payload)
+	if err != nil {
+		return AuthzDecision{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return AuthzDecision{Allow: false}, nil
+	case resp.StatusCode != http.StatusOK:
+		return AuthzDecision{}, fmt.Errorf("authz webhook returned %d", resp.StatusCode)
+	}
+
+	var decision AuthzDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return AuthzDecision{}, fmt.Errorf("decode authz webhook response: %w", err)
+	}
+	return decision, nil
+}
+
+// queryOPA evaluates a policy through OPA's data API. The URL names the
+// rule, e.g. http://opa:8181/v1/data/gateway/authz, which may evaluate to
+// a boolean or to {"allow": bool, "reason": string}. An undefined result
+// denies.
+func (a *ExternalAuthz) queryOPA(ctx context.Context, payload []byte) (AuthzDecision, error) {
+	body := append(append([]byte(`{"input":`), payload...), '}')
+	resp, err := a.post(ctx, body)
+	if err != nil {
+		return AuthzDecision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return AuthzDecision{}, fmt.Errorf("opa returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return AuthzDecision{}, fmt.Errorf("decode opa response: %w", err)
+	}
+
+	var allow bool
+	if err := json.Unmarshal(result.Result, &allow); err == nil {
+		return AuthzDecision{Allow: allow}, nil
+	}
+	var decision AuthzDecision
+	if err := json.Unmarshal(result.Result, &decision); err == nil {
+		return decision, nil
+	}
+	return AuthzDecision{Allow: false, Reason: "policy result is undefined"}, nil
+}
+
+func (a *ExternalAuthz) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.client.Do(req)
+}
+
+func (a *ExternalAuthz) cached(key string) (AuthzDecision, bool) {
+	if a.config.CacheTTL <= 0 {
+		return AuthzDecision{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return AuthzDecision{}, false
+	}
+	return entry.decision, true
+}
+
+func (a *ExternalAuthz) store(key string, decision AuthzDecision) {
+	if a.config.CacheTTL <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if len(a.cache) >= maxAuthzCacheEntries {
+		for k, entry := range a.cache {
+			if now.After(entry.expiresAt) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= maxAuthzCacheEntries {
+			a.cache = make(map[string]cachedDecision)
+		}
+	}
+	a.cache[key] = cachedDecision{decision: decision, expiresAt: now.Add(a.config.CacheTTL)}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/spiffe"
+	"api-gateway/pkg/logger"
+)
+
+// HealthChecker periodically probes every instance of every registered
+// service and records the result in the Registry.
+type HealthChecker struct {
+	registry *Registry
+	config   config.HealthCheckConfig
+	client   *http.Client
+	svids    *spiffe.Source
+	logger   *logger.Logger
+
+	// spiffeClients probe instances authenticated by SVID, by SPIFFE ID
+	mu            sync.Mutex
+	spiffeClients map[string]*http.Client
+}
+
+func NewHealthChecker(registry *Registry, cfg config.HealthCheckConfig, svids *spiffe.Source, log *logger.Logger) *HealthChecker {
+	return &HealthChecker{
+		registry: registry,
+		config:   cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		svids:         svids,
+		logger:        log,
+		spiffeClients: make(map[string]*http.Client),
+	}
+}
+
+// Start runs health checks until the context is cancelled
+func (hc *HealthChecker) Start(ctx context.Context) {
+	ticker := time.NewTicker(hc.config.Interval)
+	defer ticker.Stop()
+
+	hc.checkAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hc.checkAll(ctx)
+		}
+	}
+}
+
+func (hc *HealthChecker) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, svc := range hc.registry.List() {
+		if svc.HealthURL == "" {
+			continue
+		}
+
+		for _, instanceURL := range svc.InstanceURLs() {
+			wg.Add(1)
+			go func(svc *Service, instanceURL string) {
+				defer wg.Done()
+				hc.checkInstance(ctx, svc, instanceURL)
+			}(svc, instanceURL)
+		}
+	}
+
+	wg.Wait()
+}
+
+func (hc *HealthChecker) checkInstance(ctx context.Context, svc *Service, instanceURL string) {
+	probeErr := hc.probe(ctx, hc.clientFor(svc), healthCheckURL(instanceURL, svc.HealthURL))
+	healthy := probeErr == nil
+	wasHealthy := svc.IsHealthy(instanceURL)
+
+	if err := hc.registry.SetInstanceHealth(svc.Name, instanceURL, probeErr); err != nil {
+		// Service was unregistered while the check was in flight
+		return
+	}
+
+	if healthy != wasHealthy {
+		hc.logger.Infow("Instance health changed",
+			"service", svc.Name,
+			"url", instanceURL,
+			"healthy", healthy,
+			"error", probeErr,
+		)
+
+		data := map[string]interface{}{"service": svc.Name, "url": instanceURL}
+		if healthy {
+			hc.registry.events.Emit(events.InstanceHealthy, data)
+		} else {
+			data["error"] = probeErr.Error()
+			hc.registry.events.Emit(events.InstanceUnhealthy, data)
+		}
+	}
+}
+
+// clientFor returns the client presenting the gateway's SVID to services
+// with a SPIFFE ID, and the plain one to the others
+func (hc *HealthChecker) clientFor(svc *Service) *http.Client {
+	if svc.Upstream == nil || svc.Upstream.SPIFFEID == "" || hc.svids == nil {
+		return hc.client
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	client, ok := hc.spiffeClients[svc.Upstream.SPIFFEID]
+	if !ok {
+		client = &http.Client{
+			Timeout:   hc.config.Timeout,
+			Transport: &http.Transport{TLSClientConfig: hc.svids.ClientTLS(svc.Upstream.SPIFFEID)},
+		}
+		hc.spiffeClients[svc.Upstream.SPIFFEID] = client
+	}
+	return client
+}
+
+func (hc *HealthChecker) probe(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// healthCheckURL joins an instance base URL with the service health path.
+// Absolute health URLs are used as-is.
+func healthCheckURL(instanceURL, healthURL string) string {
+	if strings.HasPrefix(healthURL, "http://") || strings.HasPrefix(healthURL, "https://") {
+		return healthURL
+	}
+	return strings.TrimSuffix(instanceURL, "/") + "/" + strings.TrimPrefix(healthURL, "/")
+}
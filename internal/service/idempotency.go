@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-gateway/pkg/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotentResponse is a stored response, replayed for requests repeating
+// its idempotency key. Fingerprint identifies the request that produced it.
+type IdempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// IdempotencyStore keeps responses and in-flight markers in Redis
+type IdempotencyStore struct {
+	redis *storage.RedisClient
+}
+
+func NewIdempotencyStore(redisClient *storage.RedisClient) *IdempotencyStore {
+	return &IdempotencyStore{redis: redisClient}
+}
+
+// Get returns the response stored under key, or nil if there is none
+func (s *IdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	data, err := s.redis.Get(ctx, "idempotency:response:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Lock marks key as in flight for at most ttl. It reports false if another
+// request holds it.
+func (s *IdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.redis.SetNX(ctx, "idempotency:lock:"+key, 1, ttl).Result()
+}
+
+// Locked reports whether a request holds key
+func (s *IdempotencyStore) Locked(ctx context.Context, key string) (bool, error) {
+	n, err := s.redis.Exists(ctx, "idempotency:lock:"+key).Result()
+	return n > 0, err
+}
+
+func (s *IdempotencyStore) Unlock(ctx context.Context, key string) error {
+	return s.redis.Del(ctx, "idempotency:lock:"+key).Err()
+}
+
+// Save stores resp under key for ttl and releases the lock
+func (s *IdempotencyStore) Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, "idempotency:response:"+key, data, ttl)
+	pipe.Del(ctx, "idempotency:lock:"+key)
+	_, err = pipe.Exec(ctx)
+	return err
+}
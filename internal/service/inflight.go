@@ -0,0 +1,38 @@
+package service
+
+import "sync"
+
+// ClientInFlight counts the requests each client has in flight on this
+// gateway, so one client can't hold an unbounded share of its connections
+type ClientInFlight struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewClientInFlight() *ClientInFlight {
+	return &ClientInFlight{counts: make(map[string]int)}
+}
+
+// Acquire takes one of key's limit slots, reporting false if all are held
+func (f *ClientInFlight) Acquire(key string, limit int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.counts[key] >= limit {
+		return false
+	}
+	f.counts[key]++
+	return true
+}
+
+// Release returns a slot taken by Acquire
+func (f *ClientInFlight) Release(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.counts[key] <= 1 {
+		delete(f.counts, key)
+		return
+	}
+	f.counts[key]--
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GlobalScope is the scope of rules that apply to every request. Other
+// scopes are route paths.
+const GlobalScope = "global"
+
+const ipFilterScopesKey = "ipfilter:scopes"
+
+var (
+	ErrInvalidIPEntry = errors.New("entry must be an IP address, a CIDR range or a two-letter country code")
+	ErrGeoIPDisabled  = errors.New("country entries need a GeoIP database")
+)
+
+type ipRules struct {
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	allowCountries map[string]bool
+	denyCountries  map[string]bool
+}
+
+func (r *ipRules) empty() bool {
+	return len(r.allow) == 0 && len(r.deny) == 0 && len(r.allowCountries) == 0 && len(r.denyCountries) == 0
+}
+
+func (r *ipRules) add(list, value string) error {
+	if ipNet, err := ParseIPNet(value); err == nil {
+		if list == "allow" {
+			r.allow = append(r.allow, ipNet)
+		} else {
+			r.deny = append(r.deny, ipNet)
+		}
+		return nil
+	}
+
+	if len(value) != 2 {
+		return ErrInvalidIPEntry
+	}
+	country := strings.ToUpper(value)
+	if list == "allow" {
+		r.allowCountries[country] = true
+	} else {
+		r.denyCountries[country] = true
+	}
+	return nil
+}
+
+// IPAccessEntries lists the values of one scope, as shown by the admin API
+type IPAccessEntries struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// IPFilter decides whether a client address may reach the gateway or a
+// route. Rules come from config and from entries added at runtime, which
+// live in Redis so every gateway instance applies them.
+type IPFilter struct {
+	redis  *storage.RedisClient
+	geoip  *maxminddb.Reader
+	logger *logger.Logger
+
+	mu      sync.RWMutex
+	static  map[string]*ipRules
+	dynamic map[string]*ipRules
+	entries map[string]IPAccessEntries
+}
+
+// NewIPFilter opens the GeoIP database if one is configured
+func NewIPFilter(redisClient *storage.RedisClient, geoIPDatabase string, log *logger.Logger) (*IPFilter, error) {
+	f := &IPFilter{
+		redis:   redisClient,
+		logger:  log,
+		static:  make(map[string]*ipRules),
+		dynamic: make(map[string]*ipRules),
+		entries: make(map[string]IPAccessEntries),
+	}
+	if geoIPDatabase != "" {
+		reader, err := maxminddb.Open(geoIPDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("open geoip database: %w", err)
+		}
+		f.geoip = reader
+	}
+	return f, nil
+}
+
+func (f *IPFilter) Close() error {
+	if f.geoip != nil {
+		return f.geoip.Close()
+	}
+	return nil
+}
+
+// SetRules replaces the rules from config, e.g. on reload
+func (f *IPFilter) SetRules(global config.AccessRules, routes []config.RouteConfig) error {
+	static := make(map[string]*ipRules)
+	compile := func(scope string, rules config.AccessRules) error {
+		compiled, err := compileAccessRules(rules)
+		if err != nil {
+			return fmt.Errorf("%s: %w", scope, err)
+		}
+		if !compiled.empty() {
+			static[scope] = compiled
+		}
+		return nil
+	}
+
+	if err := compile(GlobalScope, global); err != nil {
+		return err
+	}
+	for _, route := range routes {
+		if route.Access == nil {
+			continue
+		}
+		if err := compile(route.Path, *route.Access); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.static = static
+	return nil
+}
+
+func compileAccessRules(rules config.AccessRules) (*ipRules, error) {
+	compiled := newIPRules()
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{"allow", rules.Allow}, {"deny", rules.Deny},
+		{"allow", rules.AllowCountries}, {"deny", rules.DenyCountries},
+	} {
+		for _, value := range list.values {
+			if err := compiled.add(list.name, value); err != nil {
+				return nil, fmt.Errorf("%q: %w", value, err)
+			}
+		}
+	}
+	return compiled, nil
+}
+
+func newIPRules() *ipRules {
+	return &ipRules{allowCountries: make(map[string]bool), denyCountries: make(map[string]bool)}
+}
+
+// Allowed reports whether ip passes the rules of scope. Deny entries win;
+// when a scope has allow entries the address must match one of them.
+func (f *IPFilter) Allowed(scope string, ip net.IP) bool {
+	f.mu.RLock()
+	rulesets := []*ipRules{f.static[scope], f.dynamic[scope]}
+	f.mu.RUnlock()
+
+	var country string
+	countryLooked := false
+	lookup := func() string {
+		if !countryLooked {
+			country = f.country(ip)
+			countryLooked = true
+		}
+		return country
+	}
+
+	restricted, allowed := false, false
+	for _, rules := range rulesets {
+		if rules == nil {
+			continue
+		}
+		if containsIP(rules.deny, ip) {
+			return false
+		}
+		if len(rules.denyCountries) > 0 && rules.denyCountries[lookup()] {
+			return false
+		}
+		if len(rules.allow) > 0 || len(rules.allowCountries) > 0 {
+			restricted = true
+			if containsIP(rules.allow, ip) || (len(rules.allowCountries) > 0 && rules.allowCountries[lookup()]) {
+				allowed = true
+			}
+		}
+	}
+	return !restricted || allowed
+}
+
+func (f *IPFilter) country(ip net.IP) string {
+	if f.geoip == nil || ip == nil {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := f.geoip.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPNet accepts a CIDR range or a single address
+func ParseIPNet(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		return ipNet, err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, ErrInvalidIPEntry
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// AddEntry adds a runtime entry to the allow or deny list of scope
+func (f *IPFilter) AddEntry(ctx context.Context, scope, list, value string) error {
+	if err := f.checkEntry(value); err != nil {
+		return err
+	}
+	pipe := f.redis.TxPipeline()
+	pipe.SAdd(ctx, ipFilterScopesKey, scope)
+	pipe.SAdd(ctx, ipFilterKey(scope, list), value)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	return f.Refresh(ctx)
+}
+
+// RemoveEntry removes a runtime entry. Entries from config can't be removed.
+func (f *IPFilter) RemoveEntry(ctx context.Context, scope, list, value string) error {
+	if err := f.redis.SRem(ctx, ipFilterKey(scope, list), value).Err(); err != nil {
+		return err
+	}
+	return f.Refresh(ctx)
+}
+
+func (f *IPFilter) checkEntry(value string) error {
+	if _, err := ParseIPNet(value); err == nil {
+		return nil
+	}
+	if len(value) != 2 {
+		return ErrInvalidIPEntry
+	}
+	if f.geoip == nil {
+		return ErrGeoIPDisabled
+	}
+	return nil
+}
+
+// Entries returns the runtime entries per scope
+func (f *IPFilter) Entries() map[string]IPAccessEntries {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries := make(map[string]IPAccessEntries, len(f.entries))
+	for scope, e := range f.entries {
+		entries[scope] = e
+	}
+	return entries
+}
+
+// Refresh reloads the runtime entries from Redis
+func (f *IPFilter) Refresh(ctx context.Context) error {
+	scopes, err := f.redis.SMembers(ctx, ipFilterScopesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	dynamic := make(map[string]*ipRules, len(scopes))
+	entries := make(map[string]IPAccessEntries, len(scopes))
+	for _, scope := range scopes {
+		allow, err := f.redis.SMembers(ctx, ipFilterKey(scope, "allow")).Result()
+		if err != nil {
+			return err
+		}
+		deny, err := f.redis.SMembers(ctx, ipFilterKey(scope, "deny")).Result()
+		if err != nil {
+			return err
+		}
+		if len(allow) == 0 && len(deny) == 0 {
+			continue
+		}
+
+		compiled, err := compileAccessRules(config.AccessRules{Allow: allow, Deny: deny})
+		if err != nil {
+			f.logger.Warnw("Ignoring invalid IP access entries", "scope", scope, "error", err)
+			continue
+		}
+		dynamic[scope] = compiled
+		entries[scope] = IPAccessEntries{Allow: allow, Deny: deny}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dynamic = dynamic
+	f.entries = entries
+	return nil
+}
+
+// Start picks up entries added by other gateway instances every interval
+// until ctx is cancelled. The last known entries stay in force while Redis
+// is unreachable.
+func (f *IPFilter) Start(ctx context.Context, interval time.Duration) {
+	if err := f.Refresh(ctx); err != nil {
+		f.logger.Warnw("Failed to load IP access entries", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Refresh(ctx); err != nil {
+				f.logger.Warnw("Failed to refresh IP access entries", "error", err)
+			}
+		}
+	}
+}
+
+func ipFilterKey(scope, list string) string {
+	return "ipfilter:" + scope + ":" + list
+}
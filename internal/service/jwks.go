@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefetch bounds how often an unknown kid can force a refetch
+	jwksMinRefetch = time.Minute
+)
+
+var ErrUntrustedIssuer = errors.New("token issuer is not trusted")
+
+// JWKS caches the signing keys published by an issuer. Keys are refreshed
+// periodically and whenever a token references an unknown key id, which
+// picks up key rotation without a restart.
+type JWKS struct {
+	issuer string
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewJWKS(issuer, url string) *JWKS {
+	return &JWKS{
+		issuer: issuer,
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]crypto.PublicKey),
+	}
+}
+
+// Key returns the public key with the given id
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > jwksRefreshInterval
+	if ok && !stale {
+		return key, nil
+	}
+
+	if stale || time.Since(j.fetchedAt) > jwksMinRefetch {
+		if err := j.refresh(ctx); err != nil {
+			// Keep serving cached keys if the issuer is briefly unreachable
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		if key, ok = j.keys[kid]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (j *JWKS) refresh(ctx context.Context) error {
+	if j.url == "" {
+		url, err := j.discoverURL(ctx)
+		if err != nil {
+			return err
+		}
+		j.url = url
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := j.getJSON(ctx, j.url, &set); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func (j *JWKS) discoverURL(ctx context.Context) (string, error) {
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimSuffix(j.issuer, "/") + "/.well-known/openid-configuration"
+	if err := j.getJSON(ctx, wellKnown, &doc); err != nil {
+		return "", fmt.Errorf("discover jwks_uri: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("discover jwks_uri: issuer publishes no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+func (j *JWKS) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ExternalTokens validates tokens signed by trusted external issuers
+type ExternalTokens struct {
+	issuers map[string]*trustedIssuer
+	leeway  time.Duration
+}
+
+type trustedIssuer struct {
+	config config.TrustedIssuerConfig
+	jwks   *JWKS
+}
+
+func NewExternalTokens(issuers []config.TrustedIssuerConfig, leeway time.Duration) *ExternalTokens {
+	trusted := make(map[string]*trustedIssuer, len(issuers))
+	for _, ic := range issuers {
+		trusted[ic.Issuer] = &trustedIssuer{
+			config: ic,
+			jwks:   NewJWKS(ic.Issuer, ic.JWKSURL),
+		}
+	}
+	return &ExternalTokens{issuers: trusted, leeway: leeway}
+}
+
+// Validate verifies an externally issued token and maps its claims onto the
+// gateway's claims
+func (e *ExternalTokens) Validate(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	unverified := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, unverified); err != nil {
+		return nil, err
+	}
+	iss, _ := unverified.GetIssuer()
+	issuer, ok := e.issuers[iss]
+	if !ok {
+		return nil, ErrUntrustedIssuer
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return issuer.jwks.Key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(iss),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(e.leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := issuer.checkAudience(claims); err != nil {
+		return nil, err
+	}
+	return issuer.mapClaims(claims), nil
+}
+
+func (t *trustedIssuer) checkAudience(claims jwt.MapClaims) error {
+	if len(t.config.Audiences) == 0 {
+		return nil
+	}
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return err
+	}
+	for _, want := range t.config.Audiences {
+		for _, got := range aud {
+			if got == want {
+				return nil
+			}
+		}
+	}
+	return errors.New("token audience is not accepted")
+}
+
+func (t *trustedIssuer) mapClaims(claims jwt.MapClaims) *utils.Claims {
+	usernameClaim := t.config.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	tenantClaim := t.config.TenantClaim
+	if tenantClaim == "" {
+		tenantClaim = "tenant_id"
+	}
+
+	sub, _ := claims.GetSubject()
+	exp, _ := claims.GetExpirationTime()
+	iat, _ := claims.GetIssuedAt()
+	aud, _ := claims.GetAudience()
+	jti, _ := claims["jti"].(string)
+
+	return &utils.Claims{
+		UserID:   sub,
+		Username: stringClaim(claims, usernameClaim),
+		Email:    stringClaim(claims, "email"),
+		Role:     mapRole(claims, t.config.RoleClaim, t.config.RoleMap, t.config.DefaultRole),
+		TenantID: stringClaim(claims, tenantClaim),
+
+		Permissions: stringsClaim(claims, "permissions"),
+		Scope:       stringClaim(claims, "scope"),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    t.config.Issuer,
+			Subject:   sub,
+			Audience:  aud,
+			ExpiresAt: exp,
+			IssuedAt:  iat,
+		},
+		Raw: claims,
+	}
+}
+
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	items, _ := claims[name].([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSigningKeyNotFound   = errors.New("signing key not found")
+	ErrSigningKeyConfigured = errors.New("configured signing keys can't be deleted")
+	ErrSigningKeyInUse      = errors.New("the current signing key can't be deleted")
+	ErrSigningKeysNotStored = errors.New("rotating signing keys requires the mongo storage backend")
+)
+
+// Signing key states
+const (
+	KeySigning = "signing"
+	KeyPending = "pending"
+	KeyRetired = "retired"
+	KeyExpired = "expired"
+)
+
+// SigningKeyStatus describes a signing key for the admin API
+type SigningKeyStatus struct {
+	models.JWTKey
+	Source    string     `json:"source"`
+	Status    string     `json:"status"`
+	RetiresAt *time.Time `json:"retires_at,omitempty"`
+
+	key utils.SigningKey
+}
+
+type signingKey struct {
+	models.JWTKey
+	key    utils.SigningKey
+	source string
+}
+
+// SigningKeys holds the keys the gateway signs its tokens with. The newest
+// activated key signs; a key replaced by a newer one keeps verifying
+// tokens for grace, the lifetime of the tokens it signed. The configured
+// key is the oldest. Keys rotated in through the admin API use the
+// configured key's algorithm; they are kept in Mongo and refreshed
+// periodically, so all instances switch keys at the same time. Without
+// Mongo only the configured keys are used.
+type SigningKeys struct {
+	collection *mongo.Collection
+	grace      time.Duration
+	logger     *logger.Logger
+
+	mu         sync.RWMutex
+	configured []signingKey
+	stored     []signingKey
+	policy     utils.TokenPolicy
+}
+
+// NewSigningKeys creates the signing keys; mongoClient may be nil
+func NewSigningKeys(mongoClient *storage.MongoClient, key utils.SigningKey, policy utils.TokenPolicy, grace time.Duration, log *logger.Logger) *SigningKeys {
+	s := &SigningKeys{
+		grace:      grace,
+		logger:     log,
+		configured: []signingKey{configuredKey(key, time.Time{})},
+		policy:     policy,
+	}
+	if mongoClient != nil {
+		s.collection = mongoClient.Database.Collection("jwt_keys")
+	}
+	return s
+}
+
+func configuredKey(key utils.SigningKey, activatesAt time.Time) signingKey {
+	return signingKey{
+		JWTKey: models.JWTKey{
+			ID:          key.ID,
+			Algorithm:   key.Algorithm,
+			ActivatesAt: activatesAt,
+		},
+		key:    key,
+		source: "config",
+	}
+}
+
+// keys returns all keys, oldest first; s.mu must be held
+func (s *SigningKeys) keys() []signingKey {
+	keys := make([]signingKey, 0, len(s.configured)+len(s.stored))
+	keys = append(keys, s.configured...)
+	keys = append(keys, s.stored...)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+	return keys
+}
+
+// statuses works out which key signs and until when the others verify;
+// s.mu must be held
+func (s *SigningKeys) statuses(now time.Time) []SigningKeyStatus {
+	keys := s.keys()
+	statuses := make([]SigningKeyStatus, len(keys))
+	var successor *time.Time
+	for i := len(keys) - 1; i >= 0; i-- {
+		status := SigningKeyStatus{JWTKey: keys[i].JWTKey, Source: keys[i].source, key: keys[i].key}
+		switch {
+		case now.Before(keys[i].ActivatesAt):
+			status.Status = KeyPending
+		case successor == nil:
+			status.Status = KeySigning
+		default:
+			retiresAt := successor.Add(s.grace)
+			status.RetiresAt = &retiresAt
+			status.Status = KeyRetired
+			if !now.Before(retiresAt) {
+				status.Status = KeyExpired
+			}
+		}
+		if status.Status != KeyPending {
+			activatesAt := keys[i].ActivatesAt
+			successor = &activatesAt
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// Current returns the key new tokens are signed with
+func (s *SigningKeys) Current() utils.SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, status := range s.statuses(time.Now()) {
+		if status.Status == KeySigning {
+			return status.key
+		}
+	}
+	return s.configured[0].key
+}
+
+// Verification returns the keys a token with the given kid may be signed
+// with. Tokens without a kid, or with one the gateway didn't
+// issue, are tried against every key still in use, newest first. Pending
+// keys already verify, so instances whose clocks run ahead don't issue
+// tokens others reject.
+func (s *SigningKeys) Verification(kid string) []utils.SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []utils.SigningKey
+	statuses := s.statuses(time.Now())
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if status.Status == KeyExpired {
+			continue
+		}
+		if status.ID == kid {
+			return []utils.SigningKey{status.key}
+		}
+		keys = append(keys, status.key)
+	}
+	return keys
+}
+
+// Rotate makes key the configured signing key, e.g. after the secrets
+// provider returned a new JWT_SECRET. It reports false if it already was.
+func (s *SigningKeys) Rotate(key utils.SigningKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configured[len(s.configured)-1].ID == key.ID {
+		return false
+	}
+	s.configured = append(s.configured, configuredKey(key, time.Now()))
+	return true
+}
+
+// Policy returns the issuer, audience and leeway tokens are issued and
+// verified with
+func (s *SigningKeys) Policy() utils.TokenPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// SetPolicy replaces the token policy, e.g. on reload
+func (s *SigningKeys) SetPolicy(policy utils.TokenPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// List describes every key, newest first
+func (s *SigningKeys) List() []SigningKeyStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := s.statuses(time.Now())
+	for i, j := 0, len(statuses)-1; i < j; i, j = i+1, j-1 {
+		statuses[i], statuses[j] = statuses[j], statuses[i]
+	}
+	return statuses
+}
+
+// Create stores a new key that starts signing at activatesAt
+func (s *SigningKeys) Create(ctx context.Context, activatesAt time.Time) (*models.JWTKey, error) {
+	if s.collection == nil {
+		return nil, ErrSigningKeysNotStored
+	}
+
+	id, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	algorithm := s.configured[len(s.configured)-1].Algorithm
+	s.mu.RUnlock()
+
+	var secret string
+	if algorithm == utils.AlgHS256 {
+		secret, err = utils.GenerateOpaqueToken()
+	} else {
+		secret, err = utils.GeneratePrivateKeyPEM(algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.JWTKey{
+		ID:          id[:16],
+		Algorithm:   algorithm,
+		Secret:      secret,
+		CreatedAt:   time.Now(),
+		ActivatesAt: activatesAt,
+	}
+	stored, err := storedKey(*key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.collection.InsertOne(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.stored = append(s.stored, stored)
+	s.mu.Unlock()
+	return key, nil
+}
+
+func storedKey(key models.JWTKey) (signingKey, error) {
+	parsed, err := utils.NewSigningKey(key.ID, key.Algorithm, key.Secret)
+	if err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{JWTKey: key, key: parsed, source: "admin"}, nil
+}
+
+// Delete removes a key at once, invalidating the tokens signed with it
+func (s *SigningKeys) Delete(ctx context.Context, kid string) error {
+	s.mu.RLock()
+	var found *SigningKeyStatus
+	for _, status := range s.statuses(time.Now()) {
+		if status.ID == kid {
+			status := status
+			found = &status
+			break
+		}
+	}
+	s.mu.RUnlock()
+
+	switch {
+	case found == nil:
+		return ErrSigningKeyNotFound
+	case found.Source == "config":
+		return ErrSigningKeyConfigured
+	case found.Status == KeySigning:
+		return ErrSigningKeyInUse
+	}
+
+	if _, err := s.collection.DeleteOne(ctx, bson.M{"_id": kid}); err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+// JWKS returns the keys still in use as a JSON Web Key Set. HS256 keys
+// are the shared secrets themselves, for trusted downstream services only.
+func (s *SigningKeys) JWKS() map[string]interface{} {
+	return s.jwks(false)
+}
+
+// PublicJWKS returns the public halves of the asymmetric keys still in
+// use, which anyone may see
+func (s *SigningKeys) PublicJWKS() map[string]interface{} {
+	return s.jwks(true)
+}
+
+func (s *SigningKeys) jwks(public bool) map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []map[string]string{}
+	for _, status := range s.statuses(time.Now()) {
+		if status.Status == KeyExpired || (public && !status.key.Asymmetric()) {
+			continue
+		}
+		keys = append(keys, status.key.JWK())
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// Refresh reloads the stored keys
+func (s *SigningKeys) Refresh(ctx context.Context) error {
+	if s.collection == nil {
+		return nil
+	}
+
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var keys []models.JWTKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return err
+	}
+
+	stored := make([]signingKey, 0, len(keys))
+	for _, key := range keys {
+		parsed, err := storedKey(key)
+		if err != nil {
+			s.logger.Warnw("Skipping unusable signing key", "kid", key.ID, "error", err)
+			continue
+		}
+		stored = append(stored, parsed)
+	}
+	s.mu.Lock()
+	s.stored = stored
+	s.mu.Unlock()
+	return nil
+}
+
+// Start refreshes the stored keys every interval until ctx is cancelled.
+// They should be loaded once with Refresh before serving, so a restarted
+// instance doesn't sign with an outdated key.
+func (s *SigningKeys) Start(ctx context.Context, interval time.Duration) {
+	if s.collection == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Warnw("Failed to refresh signing keys", "error", err)
+			}
+		}
+	}
+}
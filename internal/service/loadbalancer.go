@@ -2,32 +2,187 @@ package service
 
 import (
 	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+
+	"api-gateway/internal/metrics"
+)
+
+// Load balancing strategies
+const (
+	StrategyRoundRobin     = "round_robin"
+	StrategyConsistentHash = "consistent_hash"
 )
 
+// virtualNodes is the number of ring points per instance; more points give a
+// more even key distribution
+const virtualNodes = 100
+
+type hashRing struct {
+	members string
+	points  []uint32
+	owners  map[uint32]string
+}
+
 type LoadBalancer struct {
+	// zone is the gateway's own zone, preferred by zone-aware services
+	zone     string
 	counters map[string]int
+	rings    map[string]*hashRing
 	mu       sync.Mutex
 }
 
-func NewLoadBalancer() *LoadBalancer {
+func NewLoadBalancer(zone string) *LoadBalancer {
 	return &LoadBalancer{
+		zone:     zone,
 		counters: make(map[string]int),
+		rings:    make(map[string]*hashRing),
+	}
+}
+
+// Select picks an instance for the service using its configured strategy.
+// key is the affinity key for consistent hashing and ignored otherwise.
+// Instances in exclude, and those turned away while recovering from an
+// ejection or warming up, are skipped unless nothing else is available.
+// Zone-aware services only leave the gateway's zone once every local
+// instance is unhealthy or excluded.
+func (lb *LoadBalancer) Select(service *Service, key string, exclude map[string]bool) (string, error) {
+	urls := lb.candidates(service, exclude)
+	if service.Strategy == StrategyConsistentHash && key != "" {
+		return lb.consistentHash(service, urls, key, exclude)
+	}
+
+	attempts := len(exclude) + 1
+	if service.Ramping() {
+		attempts += len(service.InstanceURLs())
+	}
+
+	var url string
+	for i := 0; i < attempts; i++ {
+		next, err := lb.roundRobin(service.Name, urls)
+		if err != nil {
+			return "", err
+		}
+		url = next
+		if !exclude[url] && service.Admits(url) {
+			break
+		}
+	}
+	return url, nil
+}
+
+// candidates returns the healthy instances to balance over. For zone-aware
+// services these are the ones in the gateway's zone while any of them is
+// still available.
+func (lb *LoadBalancer) candidates(service *Service, exclude map[string]bool) []string {
+	urls := service.HealthyURLs()
+	if !service.ZoneAware || lb.zone == "" {
+		return urls
+	}
+
+	local := make([]string, 0, len(urls))
+	available := false
+	for _, url := range urls {
+		if service.Zones[url] == lb.zone {
+			local = append(local, url)
+			available = available || !exclude[url]
+		}
+	}
+	if available {
+		return local
 	}
+	if len(urls) > 0 {
+		metrics.ZoneFailovers.WithLabelValues(service.Name).Inc()
+	}
+	return urls
 }
 
-// RoundRobin returns the next URL using round-robin algorithm
-func (lb *LoadBalancer) RoundRobin(service *Service) (string, error) {
-	if len(service.URLs) == 0 {
+// roundRobin returns the next of urls using round-robin algorithm
+func (lb *LoadBalancer) roundRobin(serviceName string, urls []string) (string, error) {
+	if len(urls) == 0 {
 		return "", errors.New("no URLs available for service")
 	}
 
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	counter := lb.counters[service.Name]
-	url := service.URLs[counter%len(service.URLs)]
-	lb.counters[service.Name] = (counter + 1) % len(service.URLs)
+	counter := lb.counters[serviceName]
+	url := urls[counter%len(urls)]
+	lb.counters[serviceName] = (counter + 1) % len(urls)
 
 	return url, nil
 }
+
+// consistentHash maps key onto a hash ring of urls, so the same key keeps
+// reaching the same instance. When an instance is added or removed only
+// the keys owned by it move.
+func (lb *LoadBalancer) consistentHash(service *Service, urls []string, key string, exclude map[string]bool) (string, error) {
+	if len(urls) == 0 {
+		return "", errors.New("no URLs available for service")
+	}
+
+	ring := lb.ring(service.Name, urls)
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(ring.points), func(i int) bool { return ring.points[i] >= hash })
+
+	// Walk clockwise to the first instance that isn't excluded. A ramping
+	// instance is asked once, however many points it owns.
+	var admitted map[string]bool
+	for i := 0; i < len(ring.points); i++ {
+		owner := ring.owners[ring.points[(start+i)%len(ring.points)]]
+		if exclude[owner] {
+			continue
+		}
+		ok, asked := admitted[owner]
+		if !asked {
+			ok = service.Admits(owner)
+			if admitted == nil {
+				admitted = make(map[string]bool)
+			}
+			admitted[owner] = ok
+		}
+		if ok {
+			return owner, nil
+		}
+	}
+	return ring.owners[ring.points[start%len(ring.points)]], nil
+}
+
+// ring returns the cached ring for the service, rebuilding it when the set
+// of healthy instances changed
+func (lb *LoadBalancer) ring(serviceName string, urls []string) *hashRing {
+	sorted := append([]string(nil), urls...)
+	sort.Strings(sorted)
+	members := strings.Join(sorted, ",")
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if ring, ok := lb.rings[serviceName]; ok && ring.members == members {
+		return ring
+	}
+
+	ring := &hashRing{
+		members: members,
+		points:  make([]uint32, 0, len(sorted)*virtualNodes),
+		owners:  make(map[uint32]string, len(sorted)*virtualNodes),
+	}
+	for _, url := range sorted {
+		for v := 0; v < virtualNodes; v++ {
+			point := crc32.ChecksumIEEE([]byte(url + "#" + strconv.Itoa(v)))
+			if _, taken := ring.owners[point]; taken {
+				continue
+			}
+			ring.points = append(ring.points, point)
+			ring.owners[point] = url
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+
+	lb.rings[serviceName] = ring
+	return ring
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+)
+
+// Route priorities, lowest first. Critical routes are never shed.
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityHigh     = "high"
+	PriorityCritical = "critical"
+)
+
+// minLatencySamples is how many upstream responses an interval needs
+// before its p99 counts as a signal
+const minLatencySamples = 20
+
+// LoadShedder estimates how overloaded the gateway is from CPU use,
+// goroutine count and p99 upstream latency, and sheds low priority
+// requests first as the pressure grows
+type LoadShedder struct {
+	config config.LoadSheddingConfig
+	queue  *priorityQueue
+
+	// pressure is 0 when healthy and 1 when everything but critical
+	// traffic is shed, stored as float64 bits
+	pressure atomic.Uint64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+
+	lastCPU    time.Duration
+	lastSample time.Time
+}
+
+func NewLoadShedder(cfg config.LoadSheddingConfig) *LoadShedder {
+	l := &LoadShedder{config: cfg, lastSample: time.Now()}
+	if cfg.Enabled && cfg.MaxInFlight > 0 {
+		l.queue = newPriorityQueue(cfg.MaxInFlight, cfg.MaxQueue, cfg.QueueTimeout)
+	}
+	l.lastCPU, _ = processCPUTime()
+	return l
+}
+
+// Observe records the time an upstream took to respond
+func (l *LoadShedder) Observe(d time.Duration) {
+	if !l.config.Enabled {
+		return
+	}
+	l.mu.Lock()
+	l.latencies = append(l.latencies, d)
+	l.mu.Unlock()
+}
+
+func (l *LoadShedder) Pressure() float64 {
+	return math.Float64frombits(l.pressure.Load())
+}
+
+func (l *LoadShedder) RetryAfter() time.Duration {
+	return l.config.RetryAfter
+}
+
+// Shed reports whether a request of the given priority should be rejected.
+// Each priority has its own third of the pressure range, so low priority
+// traffic is fully shed before normal traffic starts to be.
+func (l *LoadShedder) Shed(priority string) bool {
+	pressure := l.Pressure()
+	if pressure <= 0 {
+		return false
+	}
+
+	var tier float64
+	switch priority {
+	case PriorityCritical:
+		return false
+	case PriorityLow:
+		tier = 0
+	case PriorityHigh:
+		tier = 2
+	default:
+		tier = 1
+	}
+
+	probability := math.Min(1, math.Max(0, pressure*3-tier))
+	return probability > 0 && rand.Float64() < probability
+}
+
+// Admit takes one of the slots of proxied requests, waiting in the
+// priority queue while all are held. The returned release must be called
+// once the request is done.
+func (l *LoadShedder) Admit(ctx context.Context, priority string) (func(), error) {
+	if l.queue == nil {
+		return func() {}, nil
+	}
+	return l.queue.acquire(ctx, priority)
+}
+
+// Priority is the priority of a request to a route of routePriority by a
+// consumer of priority consumer. The consumer's priority wins unless the
+// route is critical.
+func Priority(routePriority, consumer string) string {
+	if routePriority == "" {
+		routePriority = PriorityNormal
+	}
+	if consumer == "" || routePriority == PriorityCritical {
+		return routePriority
+	}
+	return consumer
+}
+
+// Start samples the signals every interval until ctx is cancelled
+func (l *LoadShedder) Start(ctx context.Context, interval time.Duration) {
+	if !l.config.Enabled || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sample()
+		}
+	}
+}
+
+func (l *LoadShedder) sample() {
+	now := time.Now()
+
+	l.mu.Lock()
+	latencies := l.latencies
+	l.latencies = nil
+	l.mu.Unlock()
+
+	var pressure float64
+
+	if cpu, ok := processCPUTime(); ok {
+		elapsed := now.Sub(l.lastSample)
+		if elapsed > 0 && l.config.CPUThreshold < 1 {
+			used := float64(cpu-l.lastCPU) / (float64(elapsed) * float64(runtime.NumCPU()))
+			metrics.LoadShedCPU.Set(used)
+			pressure = math.Max(pressure, (used-l.config.CPUThreshold)/(1-l.config.CPUThreshold))
+		}
+		l.lastCPU = cpu
+	}
+	l.lastSample = now
+
+	if limit := l.config.MaxGoroutines; limit > 0 {
+		pressure = math.Max(pressure, float64(runtime.NumGoroutine()-limit)/float64(limit))
+	}
+
+	if threshold := l.config.LatencyThreshold; threshold > 0 && len(latencies) >= minLatencySamples {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		p99 := latencies[(len(latencies)*99)/100]
+		pressure = math.Max(pressure, float64(p99-threshold)/float64(threshold))
+	}
+
+	pressure = math.Min(1, math.Max(0, pressure))
+	l.pressure.Store(math.Float64bits(pressure))
+	metrics.LoadShedPressure.Set(pressure)
+}
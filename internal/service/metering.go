@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Meter counts billable usage per API key and tenant per hour. Counts are
+// kept in memory and added to Mongo on every flush, so each instance only
+// writes what it counted since. Once an hour is closed one instance posts
+// its totals to the webhook.
+type Meter struct {
+	usage   *mongo.Collection
+	periods *mongo.Collection
+	config  config.MeteringConfig
+	client  *http.Client
+	logger  *logger.Logger
+
+	mu      sync.Mutex
+	pending map[string]models.MeterUsage
+}
+
+func NewMeter(mongoClient *storage.MongoClient, cfg config.MeteringConfig, log *logger.Logger) *Meter {
+	return &Meter{
+		usage:   mongoClient.Database.Collection("metering"),
+		periods: mongoClient.Database.Collection("metering_periods"),
+		config:  cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		logger:  log,
+		pending: make(map[string]models.MeterUsage),
+	}
+}
+
+func (m *Meter) EnsureIndexes(ctx context.Context) error {
+	_, err := m.usage.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hour", Value: 1}, {Key: "api_key_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "hour", Value: 1}}},
+	})
+	return err
+}
+
+// Record counts one billable request
+func (m *Meter) Record(apiKeyID, tenantID string, units, requestBytes, responseBytes int64) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	id := hour.Format(time.RFC3339) + "|" + apiKeyID + "|" + tenantID
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage, ok := m.pending[id]
+	if !ok {
+		usage = models.MeterUsage{ID: id, Hour: hour, APIKeyID: apiKeyID, TenantID: tenantID}
+	}
+	usage.Requests++
+	usage.Units += units
+	usage.RequestBytes += requestBytes
+	usage.ResponseBytes += responseBytes
+	m.pending[id] = usage
+}
+
+// Start flushes the counts and closes finished hours every FlushInterval
+// until ctx is cancelled
+func (m *Meter) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			m.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			m.Flush(ctx)
+			m.closePeriod(ctx, time.Now().UTC().Add(-m.config.CloseDelay).Truncate(time.Hour).Add(-time.Hour))
+		}
+	}
+}
+
+// Flush adds the counts since the last flush to Mongo. Counts that fail
+// to be written are kept for the next flush.
+func (m *Meter) Flush(ctx context.Context) {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string]models.MeterUsage)
+	m.mu.Unlock()
+
+	for id, usage := range pending {
+		_, err := m.usage.UpdateOne(ctx,
+			bson.M{"_id": id},
+			bson.M{
+				"$inc": bson.M{
+					"requests":       usage.Requests,
+					"units":          usage.Units,
+					"request_bytes":  usage.RequestBytes,
+					"response_bytes": usage.ResponseBytes,
+				},
+				"$set": bson.M{"updated_at": time.Now()},
+				"$setOnInsert": bson.M{
+					"hour":       usage.Hour,
+					"api_key_id": usage.APIKeyID,
+					"tenant_id":  usage.TenantID,
+				},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			m.logger.Warnw("Failed to persist metering usage", "id", id, "error", err)
+			m.restore(usage)
+		}
+	}
+}
+
+func (m *Meter) restore(usage models.MeterUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.pending[usage.ID]
+	if ok {
+		usage.Requests += current.Requests
+		usage.Units += current.Units
+		usage.RequestBytes += current.RequestBytes
+		usage.ResponseBytes += current.ResponseBytes
+	}
+	m.pending[usage.ID] = usage
+}
+
+// Export returns the usage of the hours starting in [from, to), oldest
+// first
+func (m *Meter) Export(ctx context.Context, query models.MeterExportQuery) ([]models.MeterUsage, error) {
+	filter := bson.M{"hour": bson.M{"$gte": query.From, "$lt": query.To}}
+	if query.APIKey != "" {
+		filter["api_key_id"] = query.APIKey
+	}
+	if query.Tenant != "" {
+		filter["tenant_id"] = query.Tenant
+	}
+
+	cursor, err := m.usage.Find(ctx, filter, options.Find().SetSort(bson.D{
+		{Key: "hour", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "api_key_id", Value: 1},
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]models.MeterUsage, 0)
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// closePeriod posts the totals of hour to the webhook. The first instance
+// to record the hour as closed sends it; if delivery fails the record is
+// removed so the next tick tries again.
+func (m *Meter) closePeriod(ctx context.Context, hour time.Time) {
+	if m.config.WebhookURL == "" {
+		return
+	}
+
+	id := hour.Format(time.RFC3339)
+	_, err := m.periods.InsertOne(ctx, bson.M{"_id": id, "closed_at": time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return
+	}
+	if err != nil {
+		m.logger.Warnw("Failed to close metering period", "hour", id, "error", err)
+		return
+	}
+
+	if err := m.sendPeriod(ctx, hour); err != nil {
+		m.logger.Warnw("Failed to send metering period", "hour", id, "error", err)
+		if _, err := m.periods.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			m.logger.Warnw("Failed to reopen metering period", "hour", id, "error", err)
+		}
+	}
+}
+
+func (m *Meter) sendPeriod(ctx context.Context, hour time.Time) error {
+	usage, err := m.Export(ctx, models.MeterExportQuery{From: hour, To: hour.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event":        "metering.period_closed",
+		"period_start": hour,
+		"period_end":   hour.Add(time.Hour),
+		"usage":        usage,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(events.SignatureHeader, events.Sign(m.config.WebhookSecret, body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("metering webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
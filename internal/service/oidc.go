@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Identity is the user as reported by an external identity provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+	Claims        map[string]interface{}
+}
+
+// OIDCProvider runs the authorization code flow (with PKCE) against one
+// identity provider and fetches the user from its userinfo endpoint
+type OIDCProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+}
+
+type OIDCProviders struct {
+	providers map[string]*OIDCProvider
+}
+
+func NewOIDCProviders(cfg config.OIDCConfig) *OIDCProviders {
+	providers := make(map[string]*OIDCProvider, len(cfg.Providers))
+	for _, pc := range cfg.Providers {
+		p := &OIDCProvider{
+			config: pc,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+
+		switch pc.Type {
+		case "google":
+			p.config.Issuer = "https://accounts.google.com"
+		case "github":
+			p.authURL = "https://github.com/login/oauth/authorize"
+			p.tokenURL = "https://github.com/login/oauth/access_token"
+			p.userInfoURL = "https://api.github.com/user"
+		}
+		if len(p.config.Scopes) == 0 {
+			p.config.Scopes = defaultScopes(pc.Type)
+		}
+
+		providers[pc.Name] = p
+	}
+	return &OIDCProviders{providers: providers}
+}
+
+func defaultScopes(providerType string) []string {
+	if providerType == "github" {
+		return []string{"read:user", "user:email"}
+	}
+	return []string{"openid", "email", "profile"}
+}
+
+func (o *OIDCProviders) Get(name string) (*OIDCProvider, error) {
+	p, ok := o.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+func (p *OIDCProvider) Config() config.OIDCProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, verifier, redirectURI string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return p.authURL + "?" + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for an access token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, redirectURI string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s", token.Error)
+	}
+	return token.AccessToken, nil
+}
+
+// UserInfo fetches the authenticated user. The access token comes straight
+// from the token endpoint over TLS, so the response is trusted as is.
+func (p *OIDCProvider) UserInfo(ctx context.Context, accessToken string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims, err := p.getClaims(ctx, p.userInfoURL, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: p.config.Name,
+		Claims:   claims,
+		Email:    stringClaim(claims, "email"),
+		Name:     stringClaim(claims, "name"),
+	}
+
+	if p.config.Type == "github" {
+		// GitHub has no sub claim and only returns the public email
+		if id, ok := claims["id"].(float64); ok {
+			identity.Subject = fmt.Sprintf("%.0f", id)
+		}
+		identity.Username = stringClaim(claims, "login")
+		identity.Email, identity.EmailVerified = p.githubPrimaryEmail(ctx, accessToken)
+	} else {
+		identity.Subject = stringClaim(claims, "sub")
+		identity.Username = stringClaim(claims, "preferred_username")
+		identity.EmailVerified, _ = claims["email_verified"].(bool)
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("identity provider returned no subject")
+	}
+	return identity, nil
+}
+
+func (p *OIDCProvider) githubPrimaryEmail(ctx context.Context, accessToken string) (string, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/user/emails", nil)
+	if err != nil {
+		return "", false
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.doJSON(req, &emails); err != nil {
+		return "", false
+	}
+	for _, e := range emails {
+		if e.Primary {
+			return e.Email, e.Verified
+		}
+	}
+	return "", false
+}
+
+func (p *OIDCProvider) getClaims(ctx context.Context, endpoint, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	claims := make(map[string]interface{})
+	if err := p.doJSON(req, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// discover loads the endpoints from the issuer's OpenID configuration once
+func (p *OIDCProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.authURL != "" {
+		return nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := p.doJSON(req, &doc); err != nil {
+		return fmt.Errorf("oidc discovery for %s: %w", p.config.Name, err)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserInfoEndpoint == "" {
+		return fmt.Errorf("oidc discovery for %s: incomplete provider metadata", p.config.Name)
+	}
+
+	p.authURL = doc.AuthorizationEndpoint
+	p.tokenURL = doc.TokenEndpoint
+	p.userInfoURL = doc.UserInfoEndpoint
+	return nil
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	v, _ := claims[name].(string)
+	return v
+}
+
+// Role maps the configured claim to a gateway role
+func (p *OIDCProvider) Role(identity *Identity) string {
+	return mapRole(identity.Claims, p.config.RoleClaim, p.config.RoleMap, p.config.DefaultRole)
+}
+
+// mapRole returns the gateway role for the first value of claim found in
+// roleMap, or defaultRole ("user" when empty)
+func mapRole(claims map[string]interface{}, claim string, roleMap map[string]string, defaultRole string) string {
+	role := defaultRole
+	if role == "" {
+		role = "user"
+	}
+	if claim == "" {
+		return role
+	}
+
+	var values []string
+	switch v := claims[claim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	// Config keys are lower-cased when loaded, so match case-insensitively
+	for _, v := range values {
+		if mapped, ok := roleMap[strings.ToLower(v)]; ok {
+			return mapped
+		}
+	}
+	return role
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/openapi"
+	"api-gateway/pkg/logger"
+)
+
+// openAPICacheTTL is how long a fetched service spec is reused
+const openAPICacheTTL = 5 * time.Minute
+
+// OpenAPISpecs fetches and caches the specs services publish at their
+// openapi_url
+type OpenAPISpecs struct {
+	registry *Registry
+	client   *http.Client
+	logger   *logger.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedSpec
+}
+
+type cachedSpec struct {
+	doc       openapi.Document
+	fetchedAt time.Time
+}
+
+func NewOpenAPISpecs(registry *Registry, log *logger.Logger) *OpenAPISpecs {
+	return &OpenAPISpecs{
+		registry: registry,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		logger:   log,
+		cache:    make(map[string]cachedSpec),
+	}
+}
+
+// All returns the spec of every registered service that publishes one.
+// Services that can't be reached keep their last spec; services never
+// fetched successfully are left out.
+func (o *OpenAPISpecs) All(ctx context.Context) map[string]openapi.Document {
+	specs := make(map[string]openapi.Document)
+	for _, svc := range o.registry.List() {
+		if svc.OpenAPIURL == "" {
+			continue
+		}
+		if doc, ok := o.get(ctx, svc); ok {
+			specs[svc.Name] = doc
+		}
+	}
+	return specs
+}
+
+func (o *OpenAPISpecs) get(ctx context.Context, svc *Service) (openapi.Document, bool) {
+	o.mu.Lock()
+	cached, ok := o.cache[svc.Name]
+	o.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < openAPICacheTTL {
+		return cached.doc, true
+	}
+
+	doc, err := o.fetch(ctx, svc)
+	if err != nil {
+		o.logger.Warnw("Failed to fetch service OpenAPI spec", "service", svc.Name, "error", err)
+		return cached.doc, ok
+	}
+
+	o.mu.Lock()
+	o.cache[svc.Name] = cachedSpec{doc: doc, fetchedAt: time.Now()}
+	o.mu.Unlock()
+	return doc, true
+}
+
+func (o *OpenAPISpecs) fetch(ctx context.Context, svc *Service) (openapi.Document, error) {
+	urls := svc.HealthyURLs()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no healthy instances")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthCheckURL(urls[0], svc.OpenAPIURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spec endpoint returned %d", resp.StatusCode)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported spec version %q, only OpenAPI 3 is merged", version)
+	}
+	return doc, nil
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+)
+
+// maxOutlierEvents is how many ejection events are kept for the admin API
+const maxOutlierEvents = 100
+
+// Ejection reasons
+const (
+	EjectErrorRate         = "error_rate"
+	EjectLatency           = "latency"
+	EjectConsecutiveErrors = "consecutive_errors"
+)
+
+var ErrInstanceNotEjected = errors.New("instance is not ejected")
+
+// OutlierEvent is an instance leaving or re-entering load balancing
+type OutlierEvent struct {
+	Service string     `json:"service"`
+	URL     string     `json:"url"`
+	Action  string     `json:"action"`
+	Reason  string     `json:"reason,omitempty"`
+	At      time.Time  `json:"at"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// InstanceEjection is an ejected or recovering instance of a service
+type InstanceEjection struct {
+	Service string `json:"service"`
+	Ejection
+	Weight float64 `json:"weight"`
+}
+
+// OutlierDetector tracks the error rate and latency of every instance
+// from the requests proxied to it and ejects those that stand out
+type OutlierDetector struct {
+	registry *Registry
+	config   config.OutlierDetectionConfig
+	logger   *logger.Logger
+
+	mu     sync.Mutex
+	stats  map[instanceKey]*instanceStats
+	counts map[instanceKey]int
+	// ejected holds instances until their readmission has been reported
+	ejected map[instanceKey]time.Time
+	events  []OutlierEvent
+}
+
+type instanceKey struct {
+	service string
+	url     string
+}
+
+type instanceStats struct {
+	requests    int
+	errors      int
+	latency     time.Duration
+	consecutive int
+}
+
+func NewOutlierDetector(registry *Registry, cfg config.OutlierDetectionConfig, log *logger.Logger) *OutlierDetector {
+	return &OutlierDetector{
+		registry: registry,
+		config:   cfg,
+		logger:   log,
+		stats:    make(map[instanceKey]*instanceStats),
+		counts:   make(map[instanceKey]int),
+		ejected:  make(map[instanceKey]time.Time),
+	}
+}
+
+// Observe records the outcome of a request to an instance of svc
+func (d *OutlierDetector) Observe(svc *Service, url string, failed bool, latency time.Duration) {
+	if !d.config.Enabled {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := instanceKey{service: svc.Name, url: url}
+	st, ok := d.stats[key]
+	if !ok {
+		st = &instanceStats{}
+		d.stats[key] = st
+	}
+	st.requests++
+	st.latency += latency
+	if !failed {
+		st.consecutive = 0
+		return
+	}
+	st.errors++
+	st.consecutive++
+
+	if d.config.ConsecutiveErrors > 0 && st.consecutive >= d.config.ConsecutiveErrors {
+		d.eject(svc, url, EjectConsecutiveErrors)
+	}
+}
+
+// Start evaluates the collected stats every interval until ctx is cancelled
+func (d *OutlierDetector) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.evaluate()
+		}
+	}
+}
+
+func (d *OutlierDetector) evaluate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Each interval starts a fresh window; only the error streaks carry over
+	window := d.stats
+	d.stats = make(map[instanceKey]*instanceStats, len(window))
+	byService := make(map[string][]instanceKey)
+	for key, st := range window {
+		if st.consecutive > 0 {
+			d.stats[key] = &instanceStats{consecutive: st.consecutive}
+		}
+		if st.requests >= d.config.MinRequests {
+			byService[key.service] = append(byService[key.service], key)
+		}
+	}
+
+	for name, keys := range byService {
+		svc, err := d.registry.Get(name)
+		if err != nil {
+			continue
+		}
+
+		means := make([]float64, len(keys))
+		for i, key := range keys {
+			st := window[key]
+			means[i] = float64(st.latency) / float64(st.requests)
+		}
+		median := medianOf(means)
+
+		for i, key := range keys {
+			st := window[key]
+			switch {
+			case float64(st.errors)/float64(st.requests) >= d.config.ErrorRate:
+				d.eject(svc, key.url, EjectErrorRate)
+			case d.config.LatencyFactor > 0 && len(keys) >= 3 && means[i] > d.config.LatencyFactor*median:
+				d.eject(svc, key.url, EjectLatency)
+			case d.counts[key] > 0 && svc.IsHealthy(key.url):
+				// A clean interval shortens the next ejection
+				d.counts[key]--
+			}
+		}
+	}
+
+	d.reportReadmissions()
+}
+
+// eject takes url out of rotation unless that would eject too much of the
+// service; d.mu must be held
+func (d *OutlierDetector) eject(svc *Service, url, reason string) {
+	now := time.Now()
+	total := len(svc.InstanceURLs())
+	ejected := 0
+	for _, e := range svc.Ejections() {
+		if e.URL == url && now.Before(e.Until) {
+			return
+		}
+		if now.Before(e.Until) {
+			ejected++
+		}
+	}
+	if ejected+1 >= total || (ejected+1)*100 > total*d.config.MaxEjectionPercent {
+		d.logger.Warnw("Outlier not ejected, too many instances already ejected",
+			"service", svc.Name,
+			"instance", url,
+			"reason", reason,
+		)
+		return
+	}
+
+	key := instanceKey{service: svc.Name, url: url}
+	d.counts[key]++
+	duration := d.config.BaseEjectionTime * time.Duration(d.counts[key])
+	if duration > d.config.MaxEjectionTime {
+		duration = d.config.MaxEjectionTime
+	}
+
+	e := Ejection{
+		URL:         url,
+		Reason:      reason,
+		EjectedAt:   now,
+		Until:       now.Add(duration),
+		RecoveredAt: now.Add(duration + d.config.RecoveryWindow),
+	}
+	svc.Eject(e)
+	delete(d.stats, key)
+	d.ejected[key] = e.Until
+
+	metrics.OutlierEjections.WithLabelValues(svc.Name, reason).Inc()
+	metrics.OutlierEjected.WithLabelValues(svc.Name).Inc()
+	d.record(OutlierEvent{Service: svc.Name, URL: url, Action: "ejected", Reason: reason, At: now, Until: &e.Until})
+	d.logger.Warnw("Outlier instance ejected",
+		"service", svc.Name,
+		"instance", url,
+		"reason", reason,
+		"duration", duration.String(),
+	)
+}
+
+// reportReadmissions records the instances whose ejection has run out;
+// d.mu must be held
+func (d *OutlierDetector) reportReadmissions() {
+	now := time.Now()
+	for key, until := range d.ejected {
+		if now.Before(until) {
+			continue
+		}
+		delete(d.ejected, key)
+		metrics.OutlierEjected.WithLabelValues(key.service).Dec()
+		d.record(OutlierEvent{Service: key.service, URL: key.url, Action: "readmitted", At: until})
+		d.logger.Infow("Outlier instance readmitted", "service", key.service, "instance", key.url)
+	}
+}
+
+func (d *OutlierDetector) record(event OutlierEvent) {
+	d.events = append(d.events, event)
+	if len(d.events) > maxOutlierEvents {
+		d.events = d.events[len(d.events)-maxOutlierEvents:]
+	}
+}
+
+// Readmit ends an ejection early, e.g. once the instance has been fixed
+func (d *OutlierDetector) Readmit(serviceName, url string) error {
+	svc, err := d.registry.Get(serviceName)
+	if err != nil {
+		return err
+	}
+	if !svc.Readmit(url) {
+		return ErrInstanceNotEjected
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := instanceKey{service: serviceName, url: url}
+	if _, ok := d.ejected[key]; ok {
+		delete(d.ejected, key)
+		metrics.OutlierEjected.WithLabelValues(serviceName).Dec()
+	}
+	d.counts[key] = 0
+	d.record(OutlierEvent{Service: serviceName, URL: url, Action: "readmitted", Reason: "manual", At: time.Now()})
+	return nil
+}
+
+// Ejections lists the ejected and recovering instances of all services
+func (d *OutlierDetector) Ejections() []InstanceEjection {
+	now := time.Now()
+	ejections := []InstanceEjection{}
+	for _, svc := range d.registry.List() {
+		for _, e := range svc.Ejections() {
+			ejections = append(ejections, InstanceEjection{Service: svc.Name, Ejection: e, Weight: e.Weight(now)})
+		}
+	}
+	sort.Slice(ejections, func(i, j int) bool {
+		return ejections[i].EjectedAt.After(ejections[j].EjectedAt)
+	})
+	return ejections
+}
+
+// Events returns the recent ejections and readmissions, newest first
+func (d *OutlierDetector) Events() []OutlierEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	events := make([]OutlierEvent, len(d.events))
+	for i, event := range d.events {
+		events[len(events)-1-i] = event
+	}
+	return events
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
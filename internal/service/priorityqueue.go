@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"api-gateway/internal/metrics"
+)
+
+var (
+	ErrPriorityQueueFull    = errors.New("priority queue is full")
+	ErrPriorityQueueTimeout = errors.New("timed out waiting in the priority queue")
+)
+
+func priorityRank(priority string) int {
+	switch priority {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	case PriorityCritical:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// priorityQueue caps the requests served at once. Once all slots are
+// held, requests wait for one and a freed slot goes to the oldest waiter
+// of the highest priority. Critical requests never wait.
+type priorityQueue struct {
+	maxInFlight int
+	maxQueue    int
+	timeout     time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	waiting  [3][]*queueWaiter
+}
+
+type queueWaiter struct {
+	// ready receives nil once the waiter holds a slot, or the error it
+	// was turned away with
+	ready chan error
+}
+
+func newPriorityQueue(maxInFlight, maxQueue int, timeout time.Duration) *priorityQueue {
+	return &priorityQueue{maxInFlight: maxInFlight, maxQueue: maxQueue, timeout: timeout}
+}
+
+// acquire takes a slot for a request of priority, queueing while none is
+// free. A full queue makes room by turning away its lowest priority
+// waiter, newest first, if that is below priority. The returned release
+// must be called once the request is done.
+func (q *priorityQueue) acquire(ctx context.Context, priority string) (func(), error) {
+	rank := priorityRank(priority)
+
+	q.mu.Lock()
+	if q.inFlight < q.maxInFlight || rank == priorityRank(PriorityCritical) {
+		q.inFlight++
+		q.mu.Unlock()
+		metrics.PriorityAdmitted.WithLabelValues(priority).Inc()
+		return q.holding(), nil
+	}
+	if q.queued >= q.maxQueue && !q.evictBelow(rank) {
+		q.mu.Unlock()
+		metrics.PriorityQueueRejections.WithLabelValues(priority, "queue_full").Inc()
+		return nil, ErrPriorityQueueFull
+	}
+	w := &queueWaiter{ready: make(chan error, 1)}
+	q.waiting[rank] = append(q.waiting[rank], w)
+	q.queued++
+	q.mu.Unlock()
+
+	metrics.PriorityQueued.WithLabelValues(priority).Inc()
+	defer metrics.PriorityQueued.WithLabelValues(priority).Dec()
+	start := time.Now()
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case err = <-w.ready:
+	case <-timer.C:
+		err = ErrPriorityQueueTimeout
+	case <-ctx.Done():
+		err = ErrPriorityQueueTimeout
+	}
+	if err == ErrPriorityQueueTimeout && !q.remove(rank, w) {
+		// The slot was handed over while timing out
+		err = <-w.ready
+	}
+	metrics.PriorityQueueWait.WithLabelValues(priority).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		reason := "queue_timeout"
+		if err == ErrPriorityQueueFull {
+			reason = "evicted"
+		}
+		metrics.PriorityQueueRejections.WithLabelValues(priority, reason).Inc()
+		return nil, err
+	}
+	metrics.PriorityAdmitted.WithLabelValues(priority).Inc()
+	return q.holding(), nil
+}
+
+// evictBelow turns away the newest waiter of the lowest priority under
+// rank. It reports false if there is none.
+func (q *priorityQueue) evictBelow(rank int) bool {
+	for r := 0; r < rank; r++ {
+		if n := len(q.waiting[r]); n > 0 {
+			w := q.waiting[r][n-1]
+			q.waiting[r] = q.waiting[r][:n-1]
+			q.queued--
+			w.ready <- ErrPriorityQueueFull
+			return true
+		}
+	}
+	return false
+}
+
+// remove takes w out of the queue, reporting false if it already left
+func (q *priorityQueue) remove(rank int, w *queueWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, waiter := range q.waiting[rank] {
+		if waiter == w {
+			q.waiting[rank] = append(q.waiting[rank][:i], q.waiting[rank][i+1:]...)
+			q.queued--
+			return true
+		}
+	}
+	return false
+}
+
+func (q *priorityQueue) holding() func() {
+	var once sync.Once
+	return func() {
+		once.Do(q.release)
+	}
+}
+
+// release hands the slot to the next waiter, or frees it. Slots taken
+// by critical requests beyond the cap are freed.
+func (q *priorityQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.inFlight > q.maxInFlight {
+		q.inFlight--
+		return
+	}
+	for r := len(q.waiting) - 1; r >= 0; r-- {
+		if len(q.waiting[r]) > 0 {
+			w := q.waiting[r][0]
+			q.waiting[r] = q.waiting[r][1:]
+			q.queued--
+			w.ready <- nil
+			return
+		}
+	}
+	q.inFlight--
+}
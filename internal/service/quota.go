@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultQuotaReportLimit = 100
+
+var (
+	ErrQuotaRuleNotFound      = errors.New("quota rule not found")
+	ErrQuotaReportUnavailable = errors.New("quota reports require the mongo storage backend")
+)
+
+// consumeQuotaScript counts a request unless the limit is already reached.
+// KEYS[1] counter, ARGV[1] limit, ARGV[2] unix expiry. Returns {allowed, count}.
+var consumeQuotaScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count >= tonumber(ARGV[1]) then
+	return {0, count}
+end
+count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('EXPIREAT', KEYS[1], ARGV[2])
+end
+return {1, count}
+`)
+
+// raiseQuotaScript restores a counter from its persisted value if Redis lost
+// it. KEYS[1] counter, ARGV[1] persisted count, ARGV[2] unix expiry.
+var raiseQuotaScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > count then
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('EXPIREAT', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// QuotaResult is the state of a quota after a request was counted
+type QuotaResult struct {
+	Allowed  bool
+	Rule     string
+	Limit    int64
+	Used     int64
+	ResetsAt time.Time
+}
+
+// Quotas enforces long-period usage limits. Counters live in Redis for
+// speed and are persisted to Mongo periodically, which keeps a usage
+// history and restores counters if Redis loses them. Without Mongo the
+// counters live in Redis only.
+type Quotas struct {
+	redis      *storage.RedisClient
+	collection *mongo.Collection
+	logger     *logger.Logger
+
+	mu      sync.Mutex
+	rules   []config.QuotaRule
+	touched map[string]models.QuotaUsage
+}
+
+// NewQuotas creates the quotas; mongoClient may be nil
+func NewQuotas(redisClient *storage.RedisClient, mongoClient *storage.MongoClient, rules []config.QuotaRule, log *logger.Logger) *Quotas {
+	q := &Quotas{
+		redis:   redisClient,
+		logger:  log,
+		rules:   rules,
+		touched: make(map[string]models.QuotaUsage),
+	}
+	if mongoClient != nil {
+		q.collection = mongoClient.Database.Collection("quota_usage")
+	}
+	return q
+}
+
+// SetRules replaces the rules on config reload. Usage already counted is
+// kept.
+func (q *Quotas) SetRules(rules []config.QuotaRule) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rules = rules
+}
+
+func (q *Quotas) Rules() []config.QuotaRule {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.rules
+}
+
+func (q *Quotas) rule(name string) (config.QuotaRule, bool) {
+	for _, rule := range q.Rules() {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return config.QuotaRule{}, false
+}
+
+// Consume counts one request for subject under rule
+func (q *Quotas) Consume(ctx context.Context, rule config.QuotaRule, subject string, limit int64) (*QuotaResult, error) {
+	usage := quotaPeriod(rule, subject, time.Now().UTC())
+	usage.Limit = limit
+
+	values, err := consumeQuotaScript.Run(ctx, q.redis, []string{usage.ID}, limit, quotaExpiry(usage)).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if q.collection != nil {
+		q.mu.Lock()
+		q.touched[usage.ID] = usage
+		q.mu.Unlock()
+	}
+
+	return &QuotaResult{
+		Allowed:  values[0] == 1,
+		Rule:     rule.Name,
+		Limit:    limit,
+		Used:     values[1],
+		ResetsAt: usage.ResetsAt,
+	}, nil
+}
+
+// Usage returns the current period usage of subject under every rule
+func (q *Quotas) Usage(ctx context.Context, subject string) ([]models.QuotaUsage, error) {
+	now := time.Now().UTC()
+	rules := q.Rules()
+	usages := make([]models.QuotaUsage, 0, len(rules))
+
+	for _, rule := range rules {
+		usage := quotaPeriod(rule, subject, now)
+		usage.Limit = rule.Limit
+		count, err := q.redis.Get(ctx, usage.ID).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		usage.Count = count
+		usage.UpdatedAt = now
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// Reset clears the current period usage of subject under the named rule
+func (q *Quotas) Reset(ctx context.Context, ruleName, subject string) error {
+	rule, ok := q.rule(ruleName)
+	if !ok {
+		return ErrQuotaRuleNotFound
+	}
+
+	usage := quotaPeriod(rule, subject, time.Now().UTC())
+	if err := q.redis.Del(ctx, usage.ID).Err(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	delete(q.touched, usage.ID)
+	q.mu.Unlock()
+
+	if q.collection == nil {
+		return nil
+	}
+	_, err := q.collection.UpdateOne(ctx,
+		bson.M{"_id": usage.ID},
+		bson.M{"$set": bson.M{"count": 0, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// Report returns persisted usage, most recent periods first
+func (q *Quotas) Report(ctx context.Context, query models.QuotaReportQuery) ([]models.QuotaUsage, error) {
+	if q.collection == nil {
+		return nil, ErrQuotaReportUnavailable
+	}
+
+	filter := bson.M{}
+	if query.Subject != "" {
+		filter["subject"] = query.Subject
+	}
+	if query.Rule != "" {
+		filter["rule"] = query.Rule
+	}
+	periodRange := bson.M{}
+	if !query.From.IsZero() {
+		periodRange["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		periodRange["$lte"] = query.To
+	}
+	if len(periodRange) > 0 {
+		filter["period_start"] = periodRange
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultQuotaReportLimit
+	}
+
+	cursor, err := q.collection.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "period_start", Value: -1}, {Key: "subject", Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]models.QuotaUsage, 0)
+	if err := cursor.All(ctx, &usages); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// Start persists counters every interval until ctx is cancelled
+func (q *Quotas) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || q.collection == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			q.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			q.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the counters touched since the last flush to Mongo. Several
+// gateways may flush the same counter, so the stored count only grows.
+func (q *Quotas) Flush(ctx context.Context) {
+	q.mu.Lock()
+	touched := q.touched
+	q.touched = make(map[string]models.QuotaUsage)
+	q.mu.Unlock()
+
+	for key, usage := range touched {
+		count, err := q.redis.Get(ctx, key).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			q.logger.Warnw("Failed to read quota counter", "key", key, "error", err)
+			continue
+		}
+
+		var stored models.QuotaUsage
+		err = q.collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": key},
+			bson.M{
+				"$max": bson.M{"count": count},
+				"$set": bson.M{"limit": usage.Limit, "updated_at": time.Now()},
+				"$setOnInsert": bson.M{
+					"rule":         usage.Rule,
+					"subject":      usage.Subject,
+					"period":       usage.Period,
+					"period_start": usage.PeriodStart,
+					"resets_at":    usage.ResetsAt,
+				},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&stored)
+		if err != nil {
+			q.logger.Warnw("Failed to persist quota usage", "key", key, "error", err)
+			continue
+		}
+
+		if stored.Count > count {
+			raiseQuotaScript.Run(ctx, q.redis, []string{key}, stored.Count, quotaExpiry(usage))
+		}
+	}
+}
+
+// quotaPeriod identifies the period containing now. The ID doubles as the
+// Redis key.
+func quotaPeriod(rule config.QuotaRule, subject string, now time.Time) models.QuotaUsage {
+	var start, end time.Time
+	var period string
+	if rule.Period == "daily" {
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 1)
+		period = start.Format("2006-01-02")
+	} else {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+		period = start.Format("2006-01")
+	}
+
+	return models.QuotaUsage{
+		ID:          "quota:" + rule.Name + ":" + subject + ":" + period,
+		Rule:        rule.Name,
+		Subject:     subject,
+		Period:      period,
+		PeriodStart: start,
+		ResetsAt:    end,
+	}
+}
+
+// quotaExpiry keeps counters a day past their period so late flushes still
+// see them
+func quotaExpiry(usage models.QuotaUsage) int64 {
+	return usage.ResetsAt.Add(24 * time.Hour).Unix()
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// cardNumber matches 13 to 19 digits, optionally grouped by spaces or dashes
+var cardNumber = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// Redactor masks sensitive values before bodies and headers are logged.
+// Field names match case-insensitively at any depth of a JSON document or
+// as form keys; anything that looks like a card number is masked too.
+type Redactor struct {
+	fields  map[string]bool
+	headers map[string]bool
+}
+
+func NewRedactor(fields, headers []string) *Redactor {
+	r := &Redactor{
+		fields:  make(map[string]bool, len(fields)),
+		headers: make(map[string]bool, len(headers)),
+	}
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return r
+}
+
+// Headers flattens h, masking the configured headers
+func (r *Redactor) Headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if r.headers[name] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// Body renders a captured body for the log. Bodies that aren't text are
+// summarised rather than logged.
+func (r *Redactor) Body(body []byte, contentType string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err == nil {
+			out, _ := json.Marshal(r.value(doc))
+			return string(out)
+		}
+		// Truncated or invalid documents fall back to plain text masking
+	case mediaType == "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for key, values := range form {
+				for i := range values {
+					if r.fields[strings.ToLower(key)] {
+						values[i] = redacted
+					} else {
+						values[i] = maskCards(values[i])
+					}
+				}
+			}
+			// Logged unescaped, which is easier to read
+			if out, err := url.QueryUnescape(form.Encode()); err == nil {
+				return out
+			}
+			return form.Encode()
+		}
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "xml"), strings.HasSuffix(mediaType, "+xml"):
+	default:
+		return "[" + mediaType + " body omitted]"
+	}
+	return maskCards(string(body))
+}
+
+func (r *Redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.fields[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = r.value(field)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = r.value(v[i])
+		}
+		return v
+	case string:
+		return maskCards(v)
+	case json.Number:
+		if cardNumber.MatchString(v.String()) && luhn(v.String()) {
+			return redacted
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func maskCards(s string) string {
+	return cardNumber.ReplaceAllStringFunc(s, func(match string) string {
+		if !luhn(match) {
+			return match
+		}
+		return redacted
+	})
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum used by
+// payment card numbers, which keeps most other long numbers unmasked
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
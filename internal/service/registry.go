@@ -2,45 +2,401 @@ package service
 
 import (
 	"errors"
+	"math/rand"
 	"sync"
+	"time"
 
 	"api-gateway/internal/config"
+	"api-gateway/internal/events"
+)
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
 )
 
 type Service struct {
 	Name      string
 	URLs      []string
 	HealthURL string
+	Protocol  string
+	Strategy  string
+	HashKey   string
 	Active    bool
+
+	// OpenAPIURL is where the service publishes its OpenAPI 3 spec
+	OpenAPIURL string
+
+	// Upstream holds the connection pool settings, nil for the defaults
+	Upstream *config.UpstreamConfig
+
+	// Bulkhead holds the concurrency limit, nil for the defaults
+	Bulkhead *config.BulkheadConfig
+
+	// SlowStart is the warmup window of newly added instances
+	SlowStart time.Duration
+
+	// Zones maps instance URLs to their zone. ZoneAware services prefer
+	// the instances in the gateway's zone.
+	Zones     map[string]string
+	ZoneAware bool
+
+	mu        sync.RWMutex
+	unhealthy map[string]bool
+	checks    map[string]InstanceCheck
+	ejections map[string]Ejection
+	warmups   map[string]time.Time
+	lastError *UpstreamError
+}
+
+// Ejection keeps an outlier instance out of rotation until Until. After
+// that it gets a growing share of its traffic back until RecoveredAt.
+type Ejection struct {
+	URL         string    `json:"url"`
+	Reason      string    `json:"reason"`
+	EjectedAt   time.Time `json:"ejected_at"`
+	Until       time.Time `json:"until"`
+	RecoveredAt time.Time `json:"recovered_at"`
+}
+
+// Weight is the share of its traffic the instance receives at now
+func (e Ejection) Weight(now time.Time) float64 {
+	if now.Before(e.Until) {
+		return 0
+	}
+	return rampWeight(e.Until, e.RecoveredAt, now)
+}
+
+// rampWeight grows linearly from a tenth at start, so the instance sees
+// some traffic right away, to full weight at end
+func rampWeight(start, end, now time.Time) float64 {
+	if !now.Before(end) {
+		return 1
+	}
+	if now.Before(start) {
+		return 0.1
+	}
+	return 0.1 + 0.9*float64(now.Sub(start))/float64(end.Sub(start))
+}
+
+// InstanceCheck is the outcome of an instance's last health check
+type InstanceCheck struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// InstanceStatus describes one instance for the service health report
+type InstanceStatus struct {
+	URL       string     `json:"url"`
+	Zone      string     `json:"zone,omitempty"`
+	Healthy   bool       `json:"healthy"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+
+	// WarmingUntil is set while a new instance is still in slow start
+	WarmingUntil *time.Time `json:"warming_until,omitempty"`
+}
+
+// UpstreamError is the last error seen while proxying to a service
+type UpstreamError struct {
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// InstanceURLs returns all instance URLs regardless of health
+func (s *Service) InstanceURLs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.URLs
+}
+
+// HealthyURLs returns the instance URLs that have not failed their last health check
+func (s *Service) HealthyURLs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.unhealthy) == 0 && len(s.ejections) == 0 {
+		return s.URLs
+	}
+
+	now := time.Now()
+	urls := make([]string, 0, len(s.URLs))
+	for _, u := range s.URLs {
+		if !s.unhealthy[u] && !s.ejected(u, now) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// ejected reports whether url is ejected at now; s.mu must be held
+func (s *Service) ejected(url string, now time.Time) bool {
+	e, ok := s.ejections[url]
+	return ok && now.Before(e.Until)
+}
+
+// Eject takes an instance out of rotation as described by e
+func (s *Service) Eject(e Ejection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ejections[e.URL] = e
+}
+
+// Readmit ends an instance's ejection and recovery at once. It reports
+// false if the instance wasn't ejected.
+func (s *Service) Readmit(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ejections[url]; !ok {
+		return false
+	}
+	delete(s.ejections, url)
+	return true
+}
+
+// Ejections returns the instances that are ejected or still recovering
+func (s *Service) Ejections() []Ejection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	ejections := make([]Ejection, 0, len(s.ejections))
+	for url, e := range s.ejections {
+		if !now.Before(e.RecoveredAt) {
+			delete(s.ejections, url)
+			continue
+		}
+		ejections = append(ejections, e)
+	}
+	return ejections
+}
+
+// Admits decides whether a request may go to url, letting through only a
+// share of the traffic of instances recovering from an ejection or
+// warming up
+func (s *Service) Admits(url string) bool {
+	s.mu.RLock()
+	e, ejected := s.ejections[url]
+	warm, warming := s.warmups[url]
+	s.mu.RUnlock()
+
+	if !ejected && !warming {
+		return true
+	}
+
+	now := time.Now()
+	weight := 1.0
+	if ejected {
+		weight = e.Weight(now)
+	}
+	if warming {
+		weight *= rampWeight(warm.Add(-s.SlowStart), warm, now)
+	}
+	return rand.Float64() < weight
+}
+
+// Ramping reports whether any instance gets less than its full share of
+// traffic, because it is ejected, recovering or warming up
+func (s *Service) Ramping() bool {
+	s.mu.RLock()
+	ramping := len(s.ejections) > 0 || len(s.warmups) > 0
+	s.mu.RUnlock()
+	if !ramping {
+		return false
+	}
+
+	// Drop warmups that have run out so the check stays cheap
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for url, warm := range s.warmups {
+		if !now.Before(warm) {
+			delete(s.warmups, url)
+		}
+	}
+	return len(s.ejections) > 0 || len(s.warmups) > 0
+}
+
+// warmUp puts the instances of urls that aren't in previous into slow
+// start. Without previous instances there is nobody to take the traffic
+// off the new ones, so they start at full weight. s.mu must be held.
+func (s *Service) warmUp(previous, urls []string) {
+	if s.SlowStart <= 0 || len(previous) == 0 {
+		return
+	}
+	until := time.Now().Add(s.SlowStart)
+	for _, url := range urls {
+		if !containsString(previous, url) {
+			s.warmups[url] = until
+		}
+	}
+}
+
+func (s *Service) IsHealthy(url string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.unhealthy[url]
+}
+
+func (s *Service) setHealthy(url string, checkErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	check := InstanceCheck{Healthy: checkErr == nil, CheckedAt: time.Now()}
+	if checkErr != nil {
+		check.Error = checkErr.Error()
+	}
+	s.checks[url] = check
+
+	if check.Healthy {
+		delete(s.unhealthy, url)
+	} else {
+		s.unhealthy[url] = true
+	}
+}
+
+// Instances reports every instance with its last health check, if any
+func (s *Service) Instances() []InstanceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	instances := make([]InstanceStatus, 0, len(s.URLs))
+	for _, u := range s.URLs {
+		status := InstanceStatus{URL: u, Zone: s.Zones[u], Healthy: !s.unhealthy[u]}
+		if warm, ok := s.warmups[u]; ok && time.Now().Before(warm) {
+			status.WarmingUntil = &warm
+		}
+		if check, ok := s.checks[u]; ok {
+			checkedAt := check.CheckedAt
+			status.CheckedAt = &checkedAt
+			status.Error = check.Error
+		}
+		instances = append(instances, status)
+	}
+	return instances
+}
+
+// RecordError remembers a failed upstream request for the health report
+func (s *Service) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = &UpstreamError{Message: err.Error(), At: time.Now()}
+}
+
+func (s *Service) LastError() *UpstreamError {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastError
 }
 
 type Registry struct {
 	services map[string]*Service
+	events   *events.Notifier
 	mu       sync.RWMutex
 }
 
-func NewRegistry(services []config.ServiceConfig) *Registry {
+func NewRegistry(services []config.ServiceConfig, notifier *events.Notifier) *Registry {
 	r := &Registry{
 		services: make(map[string]*Service),
 	}
 
 	// Register services from config
 	for _, svc := range services {
-		r.Register(svc.Name, svc.URLs, svc.HealthURL)
+		r.Register(svc)
 	}
 
+	// Only services added later are announced
+	r.events = notifier
 	return r
 }
 
-func (r *Registry) Register(name string, urls []string, healthURL string) {
+func (r *Registry) Register(cfg config.ServiceConfig) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.services[name] = &Service{
-		Name:      name,
-		URLs:      urls,
-		HealthURL: healthURL,
-		Active:    true,
+	protocol := cfg.Protocol
+	if protocol == "" {
+		protocol = ProtocolHTTP
+	}
+
+	strategy := cfg.LoadBalancing
+	if strategy == "" {
+		strategy = StrategyRoundRobin
+	}
+
+	// Discovered instances survive re-registration (e.g. on config reload)
+	// until the discovery watcher reports again
+	urls := cfg.URLs
+	existing, exists := r.services[cfg.Name]
+	if exists && cfg.Discovery != nil {
+		urls = existing.InstanceURLs()
+	}
+
+	zones := make(map[string]string)
+	for zone, zoneURLs := range cfg.Zones {
+		for _, url := range zoneURLs {
+			zones[url] = zone
+		}
+	}
+
+	svc := &Service{
+		Name:       cfg.Name,
+		URLs:       urls,
+		HealthURL:  cfg.HealthURL,
+		Protocol:   protocol,
+		Strategy:   strategy,
+		HashKey:    cfg.HashKey,
+		Active:     true,
+		Upstream:   cfg.Upstream,
+		Bulkhead:   cfg.Bulkhead,
+		OpenAPIURL: cfg.OpenAPIURL,
+		SlowStart:  cfg.SlowStart,
+		Zones:      zones,
+		ZoneAware:  cfg.ZoneAware,
+		unhealthy:  make(map[string]bool),
+		checks:     make(map[string]InstanceCheck),
+		ejections:  make(map[string]Ejection),
+		warmups:    make(map[string]time.Time),
+	}
+
+	// A running service keeps its admin state, last error and the health,
+	// ejections and warmups of the instances it still has; instances it
+	// didn't have yet start warming up. A service seen for the first time
+	// starts with every instance at full weight.
+	if exists {
+		svc.Active = existing.Active
+		existing.mu.RLock()
+		svc.lastError = existing.lastError
+		for url, down := range existing.unhealthy {
+			if containsString(urls, url) {
+				svc.unhealthy[url] = down
+			}
+		}
+		for url, check := range existing.checks {
+			if containsString(urls, url) {
+				svc.checks[url] = check
+			}
+		}
+		for url, e := range existing.ejections {
+			if containsString(urls, url) {
+				svc.ejections[url] = e
+			}
+		}
+		for url, warm := range existing.warmups {
+			if containsString(urls, url) {
+				svc.warmups[url] = warm
+			}
+		}
+		previous := existing.URLs
+		existing.mu.RUnlock()
+		svc.warmUp(previous, urls)
+	}
+	r.services[cfg.Name] = svc
+
+	if !exists {
+		r.events.Emit(events.ServiceRegistered, map[string]interface{}{"service": cfg.Name, "urls": urls})
 	}
 }
 
@@ -53,6 +409,7 @@ func (r *Registry) Unregister(name string) error {
 	}
 
 	delete(r.services, name)
+	r.events.Emit(events.ServiceUnregistered, map[string]interface{}{"service": name})
 	return nil
 }
 
@@ -96,3 +453,65 @@ func (r *Registry) SetActive(name string, active bool) error {
 	svc.Active = active
 	return nil
 }
+
+// SetURLs replaces the instance list of a service, e.g. after a discovery update
+func (r *Registry) SetURLs(name string, urls []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	svc, exists := r.services[name]
+	if !exists {
+		return errors.New("service not found")
+	}
+
+	svc.mu.Lock()
+	svc.warmUp(svc.URLs, urls)
+	svc.URLs = urls
+	for url := range svc.warmups {
+		if !containsString(urls, url) {
+			delete(svc.warmups, url)
+		}
+	}
+	for url := range svc.unhealthy {
+		if !containsString(urls, url) {
+			delete(svc.unhealthy, url)
+		}
+	}
+	for url := range svc.checks {
+		if !containsString(urls, url) {
+			delete(svc.checks, url)
+		}
+	}
+	for url := range svc.ejections {
+		if !containsString(urls, url) {
+			delete(svc.ejections, url)
+		}
+	}
+	svc.mu.Unlock()
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetInstanceHealth records a health check of a single instance URL. A nil
+// checkErr marks it healthy.
+func (r *Registry) SetInstanceHealth(name, url string, checkErr error) error {
+	r.mu.RLock()
+	svc, exists := r.services[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return errors.New("service not found")
+	}
+
+	svc.setHealthy(url, checkErr)
+	return nil
+}
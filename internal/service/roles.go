@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/internal/store"
+)
+
+const roleCacheTTL = 30 * time.Second
+
+var ErrRoleNotFound = errors.New("role not found")
+
+// RoleStore manages roles and their permissions, caching them briefly.
+// The admin role is built in and grants every permission unless it is
+// stored explicitly.
+type RoleStore struct {
+	users store.UserStore
+
+	mu       sync.RWMutex
+	cache    map[string][]string
+	cachedAt time.Time
+}
+
+func NewRoleStore(users store.UserStore) *RoleStore {
+	return &RoleStore{
+		users: users,
+	}
+}
+
+func (s *RoleStore) List(ctx context.Context) ([]models.Role, error) {
+	return s.users.ListRoles(ctx)
+}
+
+// Save creates or replaces a role
+func (s *RoleStore) Save(ctx context.Context, req models.RoleRequest) (*models.Role, error) {
+	role, err := s.users.SaveRole(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.flushCache()
+	return role, nil
+}
+
+func (s *RoleStore) Delete(ctx context.Context, name string) error {
+	err := s.users.DeleteRole(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.flushCache()
+	return nil
+}
+
+// Exists reports whether a role can be assigned to users
+func (s *RoleStore) Exists(ctx context.Context, name string) (bool, error) {
+	perms, err := s.all(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := perms[name]
+	return ok || name == "admin" || name == "user", nil
+}
+
+// Permissions returns the permissions granted to a role
+func (s *RoleStore) Permissions(ctx context.Context, role string) ([]string, error) {
+	perms, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := perms[role]; ok {
+		return p, nil
+	}
+	if role == "admin" {
+		return []string{"*"}, nil
+	}
+	return []string{}, nil
+}
+
+func (s *RoleStore) all(ctx context.Context) (map[string][]string, error) {
+	s.mu.RLock()
+	cache, fresh := s.cache, time.Since(s.cachedAt) < roleCacheTTL
+	s.mu.RUnlock()
+	if cache != nil && fresh {
+		return cache, nil
+	}
+
+	roles, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache = make(map[string][]string, len(roles))
+	for _, role := range roles {
+		cache[role.Name] = role.Permissions
+	}
+
+	s.mu.Lock()
+	s.cache, s.cachedAt = cache, time.Now()
+	s.mu.Unlock()
+	return cache, nil
+}
+
+func (s *RoleStore) flushCache() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+// HasPermission reports whether granted covers required. "*" grants
+// everything and "orders:*" every permission on orders.
+func HasPermission(granted []string, required string) bool {
+	for _, p := range granted {
+		if p == "*" || p == required {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(required, prefix) {
+			return true
+		}
+	}
+	return false
+}
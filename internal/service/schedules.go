@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/schedule"
+)
+
+// Schedules applies the policies of config schedules while their windows
+// are open. Windows are evaluated every interval of Start, so request
+// handling only reads the result.
+type Schedules struct {
+	mu        sync.Mutex
+	schedules []scheduled
+
+	active atomic.Pointer[scheduledPolicies]
+}
+
+type scheduled struct {
+	config   config.ScheduleConfig
+	cron     *schedule.Cron
+	location *time.Location
+}
+
+// scheduledPolicies are the policies of the open windows
+type scheduledPolicies struct {
+	rateLimits  map[string]int
+	services    map[string]string
+	maintenance time.Time
+	until       map[string]time.Time
+}
+
+// ScheduleStatus describes a schedule for the admin API
+type ScheduleStatus struct {
+	Name        string                      `json:"name"`
+	Cron        string                      `json:"cron"`
+	Duration    string                      `json:"duration"`
+	Timezone    string                      `json:"timezone"`
+	RateLimits  []config.ScheduledRateLimit `json:"rate_limits,omitempty"`
+	Services    []config.ScheduledService   `json:"services,omitempty"`
+	Maintenance bool                        `json:"maintenance"`
+	Active      bool                        `json:"active"`
+	ActiveUntil *time.Time                  `json:"active_until,omitempty"`
+}
+
+func NewSchedules(cfgs []config.ScheduleConfig) *Schedules {
+	s := &Schedules{}
+	s.SetSchedules(cfgs)
+	return s
+}
+
+// SetSchedules replaces the schedules and applies them right away.
+// Validation has already parsed them; invalid ones are skipped.
+func (s *Schedules) SetSchedules(cfgs []config.ScheduleConfig) {
+	schedules := make([]scheduled, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		cron, err := schedule.Parse(cfg.Cron)
+		if err != nil {
+			continue
+		}
+		location := time.UTC
+		if cfg.Timezone != "" {
+			if location, err = time.LoadLocation(cfg.Timezone); err != nil {
+				continue
+			}
+		}
+		schedules = append(schedules, scheduled{config: cfg, cron: cron, location: location})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, old := range s.schedules {
+		metrics.ScheduleActive.DeleteLabelValues(old.config.Name)
+	}
+	s.schedules = schedules
+	s.evaluate(time.Now())
+}
+
+// Start re-evaluates the windows every interval until ctx is cancelled
+func (s *Schedules) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			s.evaluate(now)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// evaluate works out the policies in force at now. Callers hold s.mu.
+func (s *Schedules) evaluate(now time.Time) {
+	policies := &scheduledPolicies{
+		rateLimits: make(map[string]int),
+		services:   make(map[string]string),
+		until:      make(map[string]time.Time),
+	}
+	for _, sc := range s.schedules {
+		active, until := sc.cron.Active(now.In(sc.location), sc.config.Duration)
+		if !active {
+			metrics.ScheduleActive.WithLabelValues(sc.config.Name).Set(0)
+			continue
+		}
+		metrics.ScheduleActive.WithLabelValues(sc.config.Name).Set(1)
+		policies.until[sc.config.Name] = until
+
+		// The first schedule listed wins
+		for _, limit := range sc.config.RateLimits {
+			if _, ok := policies.rateLimits[limit.Dimension]; !ok {
+				policies.rateLimits[limit.Dimension] = limit.Requests
+			}
+		}
+		for _, override := range sc.config.Services {
+			if _, ok := policies.services[override.Route]; !ok {
+				policies.services[override.Route] = override.Service
+			}
+		}
+		if sc.config.Maintenance && until.After(policies.maintenance) {
+			policies.maintenance = until
+		}
+	}
+	s.active.Store(policies)
+}
+
+// RateLimit is the requests a scheduled window sets for the dimension
+func (s *Schedules) RateLimit(dimension string) (int, bool) {
+	requests, ok := s.active.Load().rateLimits[dimension]
+	return requests, ok
+}
+
+// Service is the service a scheduled window sends the route to
+func (s *Schedules) Service(routePath string) (string, bool) {
+	service, ok := s.active.Load().services[routePath]
+	return service, ok
+}
+
+// Maintenance reports whether a maintenance window is open, and until when
+func (s *Schedules) Maintenance() (bool, time.Time) {
+	until := s.active.Load().maintenance
+	return time.Now().Before(until), until
+}
+
+func (s *Schedules) List() []ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.active.Load()
+	statuses := make([]ScheduleStatus, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		status := ScheduleStatus{
+			Name:        sc.config.Name,
+			Cron:        sc.config.Cron,
+			Duration:    sc.config.Duration.String(),
+			Timezone:    sc.location.String(),
+			RateLimits:  sc.config.RateLimits,
+			Services:    sc.config.Services,
+			Maintenance: sc.config.Maintenance,
+		}
+		if until, ok := active.until[sc.config.Name]; ok {
+			status.Active = true
+			status.ActiveUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSessionNotFound is returned for unknown, expired or ended sessions
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a browser login. It carries the same claims as an access
+// token and the CSRF token unsafe requests must echo.
+type Session struct {
+	Claims    utils.Claims `json:"claims"`
+	CSRFToken string       `json:"csrf_token"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// SessionStore keeps sessions in Redis under the hash of their ID, so the
+// cookie value can't be read back from Redis. Sessions end after TTL, or
+// after IdleTimeout without requests.
+type SessionStore struct {
+	redis  *storage.RedisClient
+	config config.SessionConfig
+}
+
+func NewSessionStore(redisClient *storage.RedisClient, cfg config.SessionConfig) *SessionStore {
+	return &SessionStore{
+		redis:  redisClient,
+		config: cfg,
+	}
+}
+
+// Create starts a session for claims and returns its ID, the cookie value
+func (s *SessionStore) Create(ctx context.Context, claims utils.Claims) (string, *Session, error) {
+	id, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrfToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Sessions are revoked along with the user's tokens, which compares
+	// against the issue time
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(s.config.TTL))
+	session := &Session{Claims: claims, CSRFToken: csrfToken, CreatedAt: now, ExpiresAt: now.Add(s.config.TTL)}
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := sessionKey(id)
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, key, payload, s.idleTTL(session, now))
+	pipe.SAdd(ctx, userSessionsKey(claims.UserID), key)
+	pipe.Expire(ctx, userSessionsKey(claims.UserID), s.config.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", nil, err
+	}
+	return id, session, nil
+}
+
+// Get returns the session and extends its idle timeout
+func (s *SessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	key := sessionKey(id)
+	payload, err := s.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !now.Before(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	if err := s.redis.Expire(ctx, key, s.idleTTL(&session, now)).Err(); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Delete ends a session
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	return s.redis.Del(ctx, sessionKey(id)).Err()
+}
+
+// DeleteUser ends every session of a user
+func (s *SessionStore) DeleteUser(ctx context.Context, userID string) error {
+	keys, err := s.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	return s.redis.Del(ctx, append(keys, userSessionsKey(userID))...).Err()
+}
+
+// idleTTL is how long the session lives without another request
+func (s *SessionStore) idleTTL(session *Session, now time.Time) time.Duration {
+	ttl := session.ExpiresAt.Sub(now)
+	if s.config.IdleTimeout > 0 && s.config.IdleTimeout < ttl {
+		ttl = s.config.IdleTimeout
+	}
+	return ttl
+}
+
+func sessionKey(id string) string {
+	return "session:" + utils.HashToken(id)
+}
+
+func userSessionsKey(userID string) string {
+	return "sessions:user:" + userID
+}
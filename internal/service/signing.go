@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/pkg/storage"
+	"api-gateway/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	signingClientPrefix   = "sc_"
+	signingClientCacheTTL = 30 * time.Second
+)
+
+var ErrSigningClientNotFound = errors.New("signing client not found")
+
+type cachedSigningClient struct {
+	client   *models.SigningClient
+	cachedAt time.Time
+}
+
+// SigningClientStore keeps the secrets of HMAC signing clients in Mongo and
+// the nonces they have used in Redis. Like API keys, lookups are cached
+// briefly and revocations on this instance take effect immediately.
+type SigningClientStore struct {
+	collection *mongo.Collection
+	redis      *storage.RedisClient
+	cache      map[string]cachedSigningClient
+	mu         sync.RWMutex
+}
+
+func NewSigningClientStore(mongoClient *storage.MongoClient, redisClient *storage.RedisClient) *SigningClientStore {
+	return &SigningClientStore{
+		collection: mongoClient.Database.Collection("signing_clients"),
+		redis:      redisClient,
+		cache:      make(map[string]cachedSigningClient),
+	}
+}
+
+// Create stores a new client and returns it together with its secret
+func (s *SigningClientStore) Create(ctx context.Context, req models.CreateSigningClientRequest) (*models.SigningClient, string, error) {
+	id, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &models.SigningClient{
+		ID:        primitive.NewObjectID(),
+		ClientID:  signingClientPrefix + id[:16],
+		Name:      req.Name,
+		Secret:    secret,
+		Scopes:    req.Scopes,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if client.Scopes == nil {
+		client.Scopes = []string{}
+	}
+
+	if _, err := s.collection.InsertOne(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+func (s *SigningClientStore) List(ctx context.Context) ([]models.SigningClient, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]models.SigningClient, 0)
+	if err := cursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (s *SigningClientStore) Revoke(ctx context.Context, clientID string) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"client_id": clientID, "active": true},
+		bson.M{"$set": bson.M{"active": false}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSigningClientNotFound
+	}
+
+	s.mu.Lock()
+	delete(s.cache, clientID)
+	s.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the active client with the given client ID
+func (s *SigningClientStore) Lookup(ctx context.Context, clientID string) (*models.SigningClient, error) {
+	s.mu.RLock()
+	cached, ok := s.cache[clientID]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.cachedAt) < signingClientCacheTTL {
+		return cached.client, nil
+	}
+
+	var client models.SigningClient
+	err := s.collection.FindOne(ctx, bson.M{"client_id": clientID, "active": true}).Decode(&client)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSigningClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.collection.UpdateOne(ctx, bson.M{"_id": client.ID}, bson.M{"$set": bson.M{"last_used_at": now}})
+
+	s.mu.Lock()
+	s.cache[clientID] = cachedSigningClient{client: &client, cachedAt: now}
+	s.mu.Unlock()
+
+	return &client, nil
+}
+
+// ClaimNonce records a nonce for the client and reports whether it was
+// unused. The nonce is remembered for ttl, which must cover the accepted
+// clock skew in both directions.
+func (s *SigningClientStore) ClaimNonce(ctx context.Context, clientID, nonce string, ttl time.Duration) (bool, error) {
+	return s.redis.SetNX(ctx, "hmac:nonce:"+clientID+":"+nonce, 1, ttl).Result()
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sync"
+
+	"api-gateway/internal/config"
+)
+
+// TrafficSplit divides a route's traffic between services by weight, e.g.
+// to send a small share to a canary. Header and Cookie let testers pick a
+// backend explicitly by naming its service.
+type TrafficSplit struct {
+	Backends []config.SplitBackend `json:"backends"`
+	Header   string                `json:"header,omitempty"`
+	Cookie   string                `json:"cookie,omitempty"`
+	HashKey  string                `json:"hash_key,omitempty"`
+
+	total int
+}
+
+func newTrafficSplit(cfg config.TrafficSplitConfig) (*TrafficSplit, error) {
+	split := &TrafficSplit{
+		Backends: append([]config.SplitBackend(nil), cfg.Backends...),
+		Header:   cfg.Header,
+		Cookie:   cfg.Cookie,
+		HashKey:  cfg.HashKey,
+	}
+	for _, b := range split.Backends {
+		if b.Service == "" || b.Weight < 0 {
+			return nil, fmt.Errorf("invalid backend %q with weight %d", b.Service, b.Weight)
+		}
+		split.total += b.Weight
+	}
+	if split.total <= 0 {
+		return nil, errors.New("at least one backend needs a positive weight")
+	}
+	return split, nil
+}
+
+// Pick returns the service for a request. override is the backend the
+// client asked for through the split's header or cookie and wins when it
+// names one of the backends. A non-empty key always maps to the same
+// backend while the weights stay the same.
+func (s *TrafficSplit) Pick(override, key string) string {
+	if override != "" {
+		for _, b := range s.Backends {
+			if b.Service == override {
+				return b.Service
+			}
+		}
+	}
+
+	var n int
+	if key != "" {
+		n = int(crc32.ChecksumIEEE([]byte(key)) % uint32(s.total))
+	} else {
+		n = rand.Intn(s.total)
+	}
+	for _, b := range s.Backends {
+		if n < b.Weight {
+			return b.Service
+		}
+		n -= b.Weight
+	}
+	return s.Backends[len(s.Backends)-1].Service
+}
+
+// TrafficSplits holds the active split per route path. Like transforms it
+// outlives router rebuilds, so weight changes from the admin API apply
+// immediately.
+type TrafficSplits struct {
+	mu     sync.RWMutex
+	splits map[string]*TrafficSplit
+}
+
+func NewTrafficSplits() *TrafficSplits {
+	return &TrafficSplits{splits: make(map[string]*TrafficSplit)}
+}
+
+func (t *TrafficSplits) Get(route string) *TrafficSplit {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.splits[route]
+}
+
+// SetWeights changes the weights of a route's existing split. Backends not
+// listed keep their weight and unknown services are rejected.
+func (t *TrafficSplits) SetWeights(route string, weights map[string]int) (*TrafficSplit, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, ok := t.splits[route]
+	if !ok {
+		return nil, fmt.Errorf("route %q has no traffic split", route)
+	}
+
+	cfg := config.TrafficSplitConfig{Header: current.Header, Cookie: current.Cookie, HashKey: current.HashKey}
+	for _, b := range current.Backends {
+		if w, ok := weights[b.Service]; ok {
+			b.Weight = w
+		}
+		cfg.Backends = append(cfg.Backends, b)
+	}
+	for name := range weights {
+		if !current.hasBackend(name) {
+			return nil, fmt.Errorf("service %q is not a backend of route %q", name, route)
+		}
+	}
+
+	split, err := newTrafficSplit(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.splits[route] = split
+	return split, nil
+}
+
+// Replace swaps all splits at once, e.g. on config reload
+func (t *TrafficSplits) Replace(routes []config.RouteConfig) error {
+	splits := make(map[string]*TrafficSplit)
+	for _, route := range routes {
+		if route.Split == nil {
+			continue
+		}
+		split, err := newTrafficSplit(*route.Split)
+		if err != nil {
+			return fmt.Errorf("route %q: %w", route.Path, err)
+		}
+		splits[route.Path] = split
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.splits = splits
+	return nil
+}
+
+func (t *TrafficSplits) List() map[string]*TrafficSplit {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	splits := make(map[string]*TrafficSplit, len(t.splits))
+	for route, split := range t.splits {
+		splits[route] = split
+	}
+	return splits
+}
+
+func (s *TrafficSplit) hasBackend(name string) bool {
+	for _, b := range s.Backends {
+		if b.Service == name {
+			return true
+		}
+	}
+	return false
+}
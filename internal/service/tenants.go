@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrTenantExists    = errors.New("tenant already exists")
+	ErrInvalidTenantID = errors.New("tenant ID must be lowercase letters, digits and dashes")
+)
+
+// Tenant IDs double as subdomains, so they follow DNS label rules
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+type tenantSnapshot struct {
+	byID     map[string]*models.Tenant
+	byDomain map[string]*models.Tenant
+}
+
+// TenantStore keeps tenants in Mongo. Request handling only reads an in
+// memory snapshot, which is rebuilt after every change on this instance
+// and refreshed periodically to pick up changes made on others.
+type TenantStore struct {
+	collection *mongo.Collection
+	snapshot   atomic.Pointer[tenantSnapshot]
+	logger     *logger.Logger
+}
+
+func NewTenantStore(mongoClient *storage.MongoClient, log *logger.Logger) *TenantStore {
+	s := &TenantStore{
+		collection: mongoClient.Database.Collection("tenants"),
+		logger:     log,
+	}
+	s.snapshot.Store(&tenantSnapshot{
+		byID:     make(map[string]*models.Tenant),
+		byDomain: make(map[string]*models.Tenant),
+	})
+	return s
+}
+
+func (s *TenantStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *TenantStore) Create(ctx context.Context, req models.CreateTenantRequest) (*models.Tenant, error) {
+	if !tenantIDPattern.MatchString(req.TenantID) {
+		return nil, ErrInvalidTenantID
+	}
+
+	now := time.Now()
+	tenant := &models.Tenant{
+		ID:        primitive.NewObjectID(),
+		TenantID:  req.TenantID,
+		Active:    true,
+		CreatedAt: now,
+	}
+	applyTenantUpdate(tenant, req.UpdateTenantRequest, now)
+
+	_, err := s.collection.InsertOne(ctx, tenant)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrTenantExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.refreshAfterChange(ctx)
+	return tenant, nil
+}
+
+func (s *TenantStore) List(ctx context.Context) ([]models.Tenant, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"tenant_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]models.Tenant, 0)
+	if err := cursor.All(ctx, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (s *TenantStore) Get(ctx context.Context, tenantID string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	err := s.collection.FindOne(ctx, bson.M{"tenant_id": tenantID}).Decode(&tenant)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Update replaces the settings of a tenant; the tenant ID can't change
+func (s *TenantStore) Update(ctx context.Context, tenantID string, req models.UpdateTenantRequest) (*models.Tenant, error) {
+	tenant, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	applyTenantUpdate(tenant, req, time.Now())
+
+	if _, err := s.collection.ReplaceOne(ctx, bson.M{"_id": tenant.ID}, tenant); err != nil {
+		return nil, err
+	}
+
+	s.refreshAfterChange(ctx)
+	return tenant, nil
+}
+
+func (s *TenantStore) Delete(ctx context.Context, tenantID string) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"tenant_id": tenantID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrTenantNotFound
+	}
+
+	s.refreshAfterChange(ctx)
+	return nil
+}
+
+// Lookup returns the active tenant with the given ID from the snapshot
+func (s *TenantStore) Lookup(tenantID string) (*models.Tenant, bool) {
+	tenant, ok := s.snapshot.Load().byID[tenantID]
+	return tenant, ok
+}
+
+// LookupHost returns the active tenant a request host belongs to: one
+// that lists the host as a domain, or whose ID is the host's subdomain of
+// baseDomain
+func (s *TenantStore) LookupHost(host, baseDomain string) (*models.Tenant, bool) {
+	host = strings.ToLower(host)
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+
+	snapshot := s.snapshot.Load()
+	if tenant, ok := snapshot.byDomain[host]; ok {
+		return tenant, true
+	}
+	if baseDomain == "" {
+		return nil, false
+	}
+	sub, ok := strings.CutSuffix(host, "."+strings.ToLower(baseDomain))
+	if !ok || strings.Contains(sub, ".") {
+		return nil, false
+	}
+	tenant, ok := snapshot.byID[sub]
+	return tenant, ok
+}
+
+// Refresh reloads the snapshot of active tenants from Mongo
+func (s *TenantStore) Refresh(ctx context.Context) error {
+	cursor, err := s.collection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var tenants []models.Tenant
+	if err := cursor.All(ctx, &tenants); err != nil {
+		return err
+	}
+
+	snapshot := &tenantSnapshot{
+		byID:     make(map[string]*models.Tenant, len(tenants)),
+		byDomain: make(map[string]*models.Tenant),
+	}
+	for i := range tenants {
+		tenant := &tenants[i]
+		snapshot.byID[tenant.TenantID] = tenant
+		for _, domain := range tenant.Domains {
+			snapshot.byDomain[strings.ToLower(domain)] = tenant
+		}
+	}
+	s.snapshot.Store(snapshot)
+	return nil
+}
+
+func (s *TenantStore) Start(ctx context.Context, interval time.Duration) {
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.Warnw("Failed to load tenants", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Warnw("Failed to refresh tenants", "error", err)
+			}
+		}
+	}
+}
+
+func (s *TenantStore) refreshAfterChange(ctx context.Context) {
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.Warnw("Failed to refresh tenants", "error", err)
+	}
+}
+
+func applyTenantUpdate(tenant *models.Tenant, req models.UpdateTenantRequest, now time.Time) {
+	tenant.Name = req.Name
+	tenant.Domains = req.Domains
+	tenant.RateLimit = req.RateLimit
+	tenant.Priority = req.Priority
+	tenant.Quotas = req.Quotas
+	tenant.Routes = req.Routes
+	tenant.Services = req.Services
+	if req.Active != nil {
+		tenant.Active = *req.Active
+	}
+	if tenant.Domains == nil {
+		tenant.Domains = []string{}
+	}
+	if tenant.Routes == nil {
+		tenant.Routes = []string{}
+	}
+	tenant.UpdatedAt = now
+}
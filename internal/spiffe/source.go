@@ -0,0 +1,175 @@
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/logger"
+)
+
+var ErrNoSVID = errors.New("no SVID fetched from the workload API yet")
+
+// ParseID splits a SPIFFE ID such as spiffe://example.org/orders into its
+// trust domain and path. A bare trust domain has an empty path.
+func ParseID(id string) (trustDomain, path string, err error) {
+	u, err := url.Parse(id)
+	if err != nil || u.Scheme != "spiffe" || u.Host == "" || u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q", id)
+	}
+	return u.Host, u.Path, nil
+}
+
+// Source keeps the gateway's current SVID, which the workload API rotates
+// before it expires
+type Source struct {
+	addr   string
+	logger *logger.Logger
+
+	mu    sync.RWMutex
+	svid  *SVID
+	ready chan struct{}
+}
+
+func NewSource(addr string, log *logger.Logger) *Source {
+	return &Source{addr: addr, logger: log, ready: make(chan struct{})}
+}
+
+// Start watches the workload API until the context is cancelled,
+// reconnecting with backoff when the stream breaks
+func (s *Source) Start(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := watchX509SVIDs(ctx, s.addr, s.update)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warnw("SPIFFE workload API stream ended", "address", s.addr, "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (s *Source) update(svid *SVID) {
+	s.mu.Lock()
+	first := s.svid == nil
+	s.svid = svid
+	s.mu.Unlock()
+
+	leaf := svid.Certificates[0]
+	metrics.SPIFFESVIDExpiry.Set(float64(leaf.NotAfter.Unix()))
+	s.logger.Infow("SPIFFE SVID updated", "spiffe_id", svid.ID, "expires", leaf.NotAfter)
+	if first {
+		close(s.ready)
+	}
+}
+
+// WaitReady blocks until the first SVID arrives or ctx is done
+func (s *Source) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for SVID from %s: %w", s.addr, ctx.Err())
+	}
+}
+
+// SVID returns the current SVID, or nil before the first one arrives
+func (s *Source) SVID() *SVID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.svid
+}
+
+// ClientTLS returns the TLS config for dialing a server expected to
+// present an SVID for id. A bare trust domain such as spiffe://example.org
+// accepts any workload in it. Every handshake uses the SVID current at the
+// time, so rotations need no new config.
+func (s *Source) ClientTLS(id string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			svid := s.SVID()
+			if svid == nil {
+				return nil, ErrNoSVID
+			}
+			cert := &tls.Certificate{PrivateKey: svid.PrivateKey, Leaf: svid.Certificates[0]}
+			for _, c := range svid.Certificates {
+				cert.Certificate = append(cert.Certificate, c.Raw)
+			}
+			return cert, nil
+		},
+		// Servers are authenticated by SPIFFE ID against the trust bundle
+		// below rather than by hostname
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return s.verify(raw, id)
+		},
+	}
+}
+
+// verify checks that the peer's chain leads to its trust domain's bundle
+// and that its SPIFFE ID is the expected one
+func (s *Source) verify(raw [][]byte, expected string) error {
+	svid := s.SVID()
+	if svid == nil {
+		return ErrNoSVID
+	}
+	if len(raw) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(raw))
+	for _, der := range raw {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+	if len(leaf.URIs) != 1 {
+		return errors.New("server certificate must have exactly one URI SAN")
+	}
+	peerID := leaf.URIs[0].String()
+	peerDomain, _, err := ParseID(peerID)
+	if err != nil {
+		return err
+	}
+
+	bundle, ok := svid.Bundles[peerDomain]
+	if !ok {
+		return fmt.Errorf("no trust bundle for %q", peerDomain)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("server SVID %s: %w", peerID, err)
+	}
+
+	expectedDomain, expectedPath, err := ParseID(expected)
+	if err != nil {
+		return err
+	}
+	if peerID != expected && !(expectedPath == "" && peerDomain == expectedDomain) {
+		return fmt.Errorf("server SVID %s is not %s", peerID, expected)
+	}
+	return nil
+}
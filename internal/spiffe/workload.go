@@ -0,0 +1,259 @@
+// Package spiffe fetches the gateway's X.509 SVID from the SPIFFE Workload
+// API, e.g. a SPIRE agent, and verifies the SVIDs of upstream servers
+// against the trust bundles it serves.
+package spiffe
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+
+	// The Workload API rejects calls without this header, so that other
+	// gRPC clients can't reach it by accident
+	securityHeader = "workload.spiffe.io"
+)
+
+// SVID is the gateway's X.509 SVID together with the bundles of its own
+// and federated trust domains, keyed by trust domain name
+type SVID struct {
+	ID           string
+	Certificates []*x509.Certificate
+	PrivateKey   crypto.Signer
+	Bundles      map[string]*x509.CertPool
+}
+
+// dialTarget turns a SPIFFE_ENDPOINT_SOCKET address, unix:///path or
+// tcp://ip:port, into a gRPC target
+func dialTarget(addr string) (string, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return addr, nil
+	case strings.HasPrefix(addr, "tcp://"):
+		return "passthrough:///" + strings.TrimPrefix(addr, "tcp://"), nil
+	default:
+		return "", fmt.Errorf("workload API address %q must start with unix:// or tcp://", addr)
+	}
+}
+
+// watchX509SVIDs streams the SVIDs issued to the gateway, calling update
+// with each, until the stream or ctx ends
+func watchX509SVIDs(ctx context.Context, addr string, update func(*SVID)) error {
+	target, err := dialTarget(addr)
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, securityHeader, "true")
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+	// X509SVIDRequest has no fields
+	if err := stream.SendMsg(&[]byte{}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var msg []byte
+		if err := stream.RecvMsg(&msg); err != nil {
+			return err
+		}
+		svid, err := parseX509SVIDResponse(msg)
+		if err != nil {
+			return err
+		}
+		update(svid)
+	}
+}
+
+// rawCodec passes messages through as bytes; they are encoded and decoded
+// with protowire instead of generated code
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *msg, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*msg = append((*msg)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// parseX509SVIDResponse decodes an X509SVIDResponse, keeping its first
+// SVID, which is the workload's default one:
+//
+//	message X509SVIDResponse {
+//	  repeated X509SVID svids = 1;
+//	  repeated bytes crl = 2;
+//	  map<string, bytes> federated_bundles = 3;
+//	}
+//	message X509SVID {
+//	  string spiffe_id = 1;
+//	  bytes x509_svid = 2;     // ASN.1 DER certificates, leaf first
+//	  bytes x509_svid_key = 3; // PKCS#8 DER private key
+//	  bytes bundle = 4;        // ASN.1 DER CA certificates
+//	}
+func parseX509SVIDResponse(msg []byte) (*SVID, error) {
+	var svid *SVID
+	bundles := make(map[string]*x509.CertPool)
+
+	err := eachField(msg, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			if svid != nil {
+				return nil
+			}
+			var err error
+			svid, err = parseX509SVID(value)
+			return err
+		case 3:
+			var trustDomain string
+			var bundle *x509.CertPool
+			err := eachField(value, func(num protowire.Number, value []byte) error {
+				var err error
+				switch num {
+				case 1:
+					trustDomain = strings.TrimPrefix(string(value), "spiffe://")
+				case 2:
+					bundle, err = certPool(value)
+				}
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("federated bundle: %w", err)
+			}
+			if trustDomain != "" && bundle != nil {
+				bundles[trustDomain] = bundle
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if svid == nil {
+		return nil, errors.New("workload API returned no SVID")
+	}
+
+	for trustDomain, bundle := range bundles {
+		if _, ok := svid.Bundles[trustDomain]; !ok {
+			svid.Bundles[trustDomain] = bundle
+		}
+	}
+	return svid, nil
+}
+
+func parseX509SVID(msg []byte) (*SVID, error) {
+	svid := &SVID{Bundles: make(map[string]*x509.CertPool)}
+	var bundle *x509.CertPool
+
+	err := eachField(msg, func(num protowire.Number, value []byte) error {
+		var err error
+		switch num {
+		case 1:
+			svid.ID = string(value)
+		case 2:
+			svid.Certificates, err = x509.ParseCertificates(value)
+		case 3:
+			var key interface{}
+			if key, err = x509.ParsePKCS8PrivateKey(value); err == nil {
+				signer, ok := key.(crypto.Signer)
+				if !ok {
+					return fmt.Errorf("unsupported private key type %T", key)
+				}
+				svid.PrivateKey = signer
+			}
+		case 4:
+			bundle, err = certPool(value)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SVID: %w", err)
+	}
+	if len(svid.Certificates) == 0 || svid.PrivateKey == nil || bundle == nil {
+		return nil, errors.New("SVID: certificates, private key and bundle are required")
+	}
+
+	trustDomain, _, err := ParseID(svid.ID)
+	if err != nil {
+		return nil, fmt.Errorf("SVID: %w", err)
+	}
+	svid.Bundles[trustDomain] = bundle
+	return svid, nil
+}
+
+// eachField calls fn with the number and bytes of each length-delimited
+// field of msg, skipping the others
+func eachField(msg []byte, fn func(protowire.Number, []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			msg = msg[n:]
+			continue
+		}
+
+		value, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func certPool(der []byte) (*x509.CertPool, error) {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
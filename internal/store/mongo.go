@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway/internal/migrate"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps everything in MongoDB collections. Expired tokens and
+// audit events are removed by TTL indexes.
+type MongoStore struct {
+	client *storage.MongoClient
+
+	users         *mongo.Collection
+	refreshTokens *mongo.Collection
+	userTokens    *mongo.Collection
+	roles         *mongo.Collection
+	services      *mongo.Collection
+	audit         *mongo.Collection
+}
+
+func NewMongoStore(client *storage.MongoClient) *MongoStore {
+	db := client.Database
+	return &MongoStore{
+		client:        client,
+		users:         db.Collection("users"),
+		refreshTokens: db.Collection("refresh_tokens"),
+		userTokens:    db.Collection("user_tokens"),
+		roles:         db.Collection("roles"),
+		services:      db.Collection("registered_services"),
+		audit:         db.Collection("audit_events"),
+	}
+}
+
+func (s *MongoStore) Backend() string {
+	return "mongo"
+}
+
+func (s *MongoStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx, nil)
+}
+
+func (s *MongoStore) Migrations(log *logger.Logger) *migrate.Runner {
+	return migrate.NewMongoRunner(s.client.Database, log)
+}
+
+// mongoError maps driver errors to the store's
+func mongoError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoStore) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := s.users.InsertOne(ctx, user)
+	return mongoError(err)
+}
+
+func (s *MongoStore) findUser(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	if err := s.users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, mongoError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoStore) FindUser(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findUser(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoStore) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.findUser(ctx, bson.M{"username": username})
+}
+
+func (s *MongoStore) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findUser(ctx, bson.M{"email": email})
+}
+
+func (s *MongoStore) FindUserByIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error) {
+	return s.findUser(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": identity.Provider, "subject": identity.Subject}},
+	})
+}
+
+func (s *MongoStore) UserExists(ctx context.Context, username, email string, except primitive.ObjectID) (bool, error) {
+	var taken []bson.M
+	if username != "" {
+		taken = append(taken, bson.M{"username": username})
+	}
+	if email != "" {
+		taken = append(taken, bson.M{"email": email})
+	}
+	if len(taken) == 0 {
+		return false, nil
+	}
+
+	filter := bson.M{"$or": taken}
+	if !except.IsZero() {
+		filter["_id"] = bson.M{"$ne": except}
+	}
+	count, err := s.users.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (s *MongoStore) UpdateUser(ctx context.Context, id primitive.ObjectID, update UserUpdate) error {
+	set := bson.M{"updated_at": time.Now()}
+	if update.Username != nil {
+		set["username"] = *update.Username
+	}
+	if update.Email != nil {
+		set["email"] = *update.Email
+	}
+	if update.Password != nil {
+		set["password"] = *update.Password
+	}
+	if update.Role != nil {
+		set["role"] = *update.Role
+	}
+	if update.Active != nil {
+		set["active"] = *update.Active
+	}
+	if update.EmailVerified != nil {
+		set["email_verified"] = *update.EmailVerified
+	}
+	if update.DeletedAt != nil {
+		set["deleted_at"] = *update.DeletedAt
+	}
+
+	result, err := s.users.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) LinkIdentity(ctx context.Context, email string, identity models.ExternalIdentity) (*models.User, error) {
+	var user models.User
+	err := s.users.FindOneAndUpdate(ctx,
+		bson.M{"email": email},
+		bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoStore) VerifyEmail(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": id, "email_verified": false, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"email_verified": true, "active": true, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (s *MongoStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	_, err := s.refreshTokens.InsertOne(ctx, token)
+	return mongoError(err)
+}
+
+func (s *MongoStore) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := s.refreshTokens.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token); err != nil {
+		return nil, mongoError(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoStore) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := s.refreshTokens.FindOneAndUpdate(ctx,
+		bson.M{"token_hash": tokenHash, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	).Decode(&token)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := s.refreshTokens.UpdateOne(ctx,
+		bson.M{"token_hash": tokenHash},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *MongoStore) RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := s.refreshTokens.UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+func (s *MongoStore) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	_, err := s.userTokens.InsertOne(ctx, token)
+	return mongoError(err)
+}
+
+func (s *MongoStore) ConsumeUserToken(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := s.userTokens.FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": tokenHash,
+			"purpose":    purpose,
+			"used":       false,
+			"expires_at": bson.M{"$gt": time.Now()},
+		},
+		bson.M{"$set": bson.M{"used": true}},
+	).Decode(&token)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoStore) ListRoles(ctx context.Context) ([]models.Role, error) {
+	cursor, err := s.roles.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]models.Role, 0)
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (s *MongoStore) SaveRole(ctx context.Context, req models.RoleRequest) (*models.Role, error) {
+	now := time.Now()
+	var role models.Role
+	err := s.roles.FindOneAndUpdate(ctx,
+		bson.M{"_id": req.Name},
+		bson.M{
+			"$set": bson.M{
+				"description": req.Description,
+				"permissions": req.Permissions,
+				"updated_at":  now,
+			},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&role)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *MongoStore) DeleteRole(ctx context.Context, name string) error {
+	result, err := s.roles.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) ListServices(ctx context.Context) ([]models.RegisteredService, error) {
+	cursor, err := s.services.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]models.RegisteredService, 0)
+	if err := cursor.All(ctx, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (s *MongoStore) SaveService(ctx context.Context, svc *models.RegisteredService) error {
+	now := time.Now()
+	svc.UpdatedAt = now
+
+	// Replacing keeps the original creation time
+	var existing models.RegisteredService
+	err := s.services.FindOne(ctx, bson.M{"_id": svc.Name}).Decode(&existing)
+	switch {
+	case err == nil:
+		svc.CreatedAt = existing.CreatedAt
+	case errors.Is(err, mongo.ErrNoDocuments):
+		svc.CreatedAt = now
+	default:
+		return err
+	}
+
+	_, err = s.services.ReplaceOne(ctx, bson.M{"_id": svc.Name}, svc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) DeleteService(ctx context.Context, name string) error {
+	result, err := s.services.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *MongoStore) InsertAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	_, err := s.audit.InsertOne(ctx, event)
+	return err
+}
+
+func (s *MongoStore) QueryAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	filter := bson.M{}
+	if q.User != "" {
+		filter["$or"] = []bson.M{{"actor_id": q.User}, {"actor": q.User}, {"target": q.User}}
+	}
+	if q.Action != "" {
+		filter["action"] = q.Action
+	}
+	timeRange := bson.M{}
+	if !q.From.IsZero() {
+		timeRange["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		timeRange["$lte"] = q.To
+	}
+	if len(timeRange) > 0 {
+		filter["time"] = timeRange
+	}
+
+	cursor, err := s.audit.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(auditQueryLimit(q)))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.AuditEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Expire keeps the audit query and retention indexes in place; Mongo
+// removes expired documents itself. Changing the retention updates the
+// existing TTL index in place.
+func (s *MongoStore) Expire(ctx context.Context, auditRetention time.Duration) error {
+	_, err := s.audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "time", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if auditRetention <= 0 {
+		return nil
+	}
+	ttl := int32(auditRetention.Seconds())
+	_, err = s.audit.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "time", Value: 1}},
+		Options: options.Index().SetName("time_ttl").SetExpireAfterSeconds(ttl),
+	})
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.HasErrorCode(85) {
+		// IndexOptionsConflict: the retention changed, update the TTL in place
+		err = s.audit.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: s.audit.Name()},
+			{Key: "index", Value: bson.D{{Key: "name", Value: "time_ttl"}, {Key: "expireAfterSeconds", Value: ttl}}},
+		}).Err()
+	}
+	return err
+}
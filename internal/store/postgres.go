@@ -0,0 +1,421 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-gateway/internal/migrate"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/storage"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostgresStore keeps everything in PostgreSQL tables. IDs are the same
+// ObjectIDs the Mongo backend uses, stored as hex strings. Expired tokens
+// and audit events are deleted by Expire.
+type PostgresStore struct {
+	client *storage.PostgresClient
+}
+
+func NewPostgresStore(client *storage.PostgresClient) *PostgresStore {
+	return &PostgresStore{client: client}
+}
+
+func (s *PostgresStore) Backend() string {
+	return "postgres"
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+func (s *PostgresStore) Migrations(log *logger.Logger) *migrate.Runner {
+	return migrate.NewPostgresRunner(s.client.Pool, log)
+}
+
+// postgresError maps driver errors to the store's
+func postgresError(err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return ErrDuplicate
+	}
+	return err
+}
+
+// objectID parses an ID read from a table
+func objectID(hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid stored id %q: %w", hex, err)
+	}
+	return id, nil
+}
+
+const userColumns = `id, username, email, password, role, tenant_id, active, email_verified, deleted_at, created_at, updated_at`
+
+func (s *PostgresStore) CreateUser(ctx context.Context, user *models.User) error {
+	tx, err := s.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		user.ID.Hex(), user.Username, user.Email, user.Password, user.Role, user.TenantID,
+		user.Active, user.EmailVerified, user.DeletedAt, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return postgresError(err)
+	}
+	for _, identity := range user.Identities {
+		if err := insertIdentity(ctx, tx, user.ID, identity); err != nil {
+			return err
+		}
+	}
+	return postgresError(tx.Commit(ctx))
+}
+
+func insertIdentity(ctx context.Context, tx pgx.Tx, userID primitive.ObjectID, identity models.ExternalIdentity) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject) VALUES ($1, $2, $3)`,
+		userID.Hex(), identity.Provider, identity.Subject,
+	)
+	return postgresError(err)
+}
+
+// findUser loads the user matching where, which may use $1
+func (s *PostgresStore) findUser(ctx context.Context, where string, arg interface{}) (*models.User, error) {
+	var user models.User
+	var id string
+	err := s.client.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, arg).Scan(
+		&id, &user.Username, &user.Email, &user.Password, &user.Role, &user.TenantID,
+		&user.Active, &user.EmailVerified, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	if user.ID, err = objectID(id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.client.Query(ctx,
+		`SELECT provider, subject FROM user_identities WHERE user_id = $1 ORDER BY provider, subject`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var identity models.ExternalIdentity
+		if err := rows.Scan(&identity.Provider, &identity.Subject); err != nil {
+			return nil, err
+		}
+		user.Identities = append(user.Identities, identity)
+	}
+	return &user, rows.Err()
+}
+
+func (s *PostgresStore) FindUser(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findUser(ctx, `id = $1`, id.Hex())
+}
+
+func (s *PostgresStore) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.findUser(ctx, `username = $1`, username)
+}
+
+func (s *PostgresStore) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findUser(ctx, `email = $1`, email)
+}
+
+func (s *PostgresStore) FindUserByIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error) {
+	var userID string
+	err := s.client.QueryRow(ctx,
+		`SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`,
+		identity.Provider, identity.Subject,
+	).Scan(&userID)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	return s.findUser(ctx, `id = $1`, userID)
+}
+
+func (s *PostgresStore) UserExists(ctx context.Context, username, email string, except primitive.ObjectID) (bool, error) {
+	if username == "" && email == "" {
+		return false, nil
+	}
+
+	var exists bool
+	err := s.client.QueryRow(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE ((username = $1 AND $1 <> '') OR (email = $2 AND $2 <> '')) AND id <> $3
+		)`,
+		username, email, except.Hex(),
+	).Scan(&exists)
+	return exists, err
+}
+
+func (s *PostgresStore) UpdateUser(ctx context.Context, id primitive.ObjectID, update UserUpdate) error {
+	set := []string{"updated_at = $1"}
+	args := []interface{}{time.Now()}
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if update.Username != nil {
+		add("username", *update.Username)
+	}
+	if update.Email != nil {
+		add("email", *update.Email)
+	}
+	if update.Password != nil {
+		add("password", *update.Password)
+	}
+	if update.Role != nil {
+		add("role", *update.Role)
+	}
+	if update.Active != nil {
+		add("active", *update.Active)
+	}
+	if update.EmailVerified != nil {
+		add("email_verified", *update.EmailVerified)
+	}
+	if update.DeletedAt != nil {
+		add("deleted_at", *update.DeletedAt)
+	}
+
+	args = append(args, id.Hex())
+	tag, err := s.client.Exec(ctx,
+		fmt.Sprintf(`UPDATE users SET %s WHERE id = $%d`, strings.Join(set, ", "), len(args)),
+		args...,
+	)
+	if err != nil {
+		return postgresError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) LinkIdentity(ctx context.Context, email string, identity models.ExternalIdentity) (*models.User, error) {
+	tx, err := s.client.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var hex string
+	err = tx.QueryRow(ctx,
+		`UPDATE users SET updated_at = now() WHERE email = $1 RETURNING id`, email,
+	).Scan(&hex)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	userID, err := objectID(hex)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertIdentity(ctx, tx, userID, identity); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.FindUser(ctx, userID)
+}
+
+func (s *PostgresStore) VerifyEmail(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.client.Exec(ctx,
+		`UPDATE users SET email_verified = TRUE, active = TRUE, updated_at = now()
+		 WHERE id = $1 AND NOT email_verified AND deleted_at IS NULL`,
+		id.Hex(),
+	)
+	return err
+}
+
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	_, err := s.client.Exec(ctx,
+		`INSERT INTO refresh_tokens (id, token_hash, user_id, scopes, revoked, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID.Hex(), token.TokenHash, token.UserID.Hex(), token.Scopes, token.Revoked, token.ExpiresAt, token.CreatedAt,
+	)
+	return postgresError(err)
+}
+
+const refreshTokenColumns = `id, token_hash, user_id, scopes, revoked, expires_at, created_at`
+
+func scanRefreshToken(row pgx.Row) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var id, userID string
+	err := row.Scan(&id, &token.TokenHash, &userID, &token.Scopes, &token.Revoked, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	if token.ID, err = objectID(id); err != nil {
+		return nil, err
+	}
+	if token.UserID, err = objectID(userID); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *PostgresStore) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return scanRefreshToken(s.client.QueryRow(ctx,
+		`SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1`, tokenHash))
+}
+
+func (s *PostgresStore) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	// RETURNING shows the new row, but the token was unrevoked before
+	token, err := scanRefreshToken(s.client.QueryRow(ctx,
+		`UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1 AND NOT revoked
+		 RETURNING `+refreshTokenColumns, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+	token.Revoked = false
+	return token, nil
+}
+
+func (s *PostgresStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := s.client.Exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+func (s *PostgresStore) RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := s.client.Exec(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1`, userID.Hex())
+	return err
+}
+
+func (s *PostgresStore) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	_, err := s.client.Exec(ctx,
+		`INSERT INTO user_tokens (id, token_hash, user_id, purpose, used, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		token.ID.Hex(), token.TokenHash, token.UserID.Hex(), token.Purpose, token.Used, token.ExpiresAt, token.CreatedAt,
+	)
+	return postgresError(err)
+}
+
+func (s *PostgresStore) ConsumeUserToken(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	var id, userID string
+	err := s.client.QueryRow(ctx,
+		`UPDATE user_tokens SET used = TRUE
+		 WHERE token_hash = $1 AND purpose = $2 AND NOT used AND expires_at > now()
+		 RETURNING id, token_hash, user_id, purpose, expires_at, created_at`,
+		tokenHash, purpose,
+	).Scan(&id, &token.TokenHash, &userID, &token.Purpose, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		return nil, postgresError(err)
+	}
+	if token.ID, err = objectID(id); err != nil {
+		return nil, err
+	}
+	if token.UserID, err = objectID(userID); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *PostgresStore) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := s.client.Query(ctx,
+		`SELECT name, description, permissions, created_at, updated_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]models.Role, 0)
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Description, &role.Permissions, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *PostgresStore) SaveRole(ctx context.Context, req models.RoleRequest) (*models.Role, error) {
+	permissions := req.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	var role models.Role
+	err := s.client.QueryRow(ctx,
+		`INSERT INTO roles (name, description, permissions, created_at, updated_at)
+		 VALUES ($1, $2, $3, now(), now())
+		 ON CONFLICT (name) DO UPDATE
+		 SET description = EXCLUDED.description, permissions = EXCLUDED.permissions, updated_at = EXCLUDED.updated_at
+		 RETURNING name, description, permissions, created_at, updated_at`,
+		req.Name, req.Description, permissions,
+	).Scan(&role.Name, &role.Description, &role.Permissions, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *PostgresStore) DeleteRole(ctx context.Context, name string) error {
+	tag, err := s.client.Exec(ctx, `DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListServices(ctx context.Context) ([]models.RegisteredService, error) {
+	rows, err := s.client.Query(ctx,
+		`SELECT definition, created_at, updated_at FROM registered_services ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := make([]models.RegisteredService, 0)
+	for rows.Next() {
+		var svc models.RegisteredService
+		if err := rows.Scan(&svc, &svc.CreatedAt, &svc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+	return services, rows.Err()
+}
+
+func (s *PostgresStore) SaveService(ctx context.Context, svc *models.RegisteredService) error {
+	return s.client.QueryRow(ctx,
+		`INSERT INTO registered_services (name, definition, created_at, updated_at)
+		 VALUES ($1, $2, now(), now())
+		 ON CONFLICT (name) DO UPDATE
+		 SET definition = EXCLUDED.definition, updated_at = EXCLUDED.updated_at
+		 RETURNING created_at, updated_at`,
+		svc.Name, svc,
+	).Scan(&svc.CreatedAt, &svc.UpdatedAt)
+}
+
+func (s *PostgresStore) DeleteService(ctx context.Context, name string) error {
+	tag, err := s.client.Exec(ctx, `DELETE FROM registered_services WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-gateway/internal/models"
+)
+
+func (s *PostgresStore) InsertAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	_, err := s.client.Exec(ctx,
+		`INSERT INTO audit_events (id, time, action, success, actor_id, actor, target, client_ip, request_id, details)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		event.ID.Hex(), event.Time, event.Action, event.Success, event.ActorID, event.Actor,
+		event.Target, event.ClientIP, event.RequestID, event.Details,
+	)
+	return err
+}
+
+func (s *PostgresStore) QueryAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	var where []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if q.User != "" {
+		add("(actor_id = $%[1]d OR actor = $%[1]d OR target = $%[1]d)", q.User)
+	}
+	if q.Action != "" {
+		add("action = $%d", q.Action)
+	}
+	if !q.From.IsZero() {
+		add("time >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		add("time <= $%d", q.To)
+	}
+
+	query := `SELECT id, time, action, success, actor_id, actor, target, client_ip, request_id, details FROM audit_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, auditQueryLimit(q))
+	query += fmt.Sprintf(` ORDER BY time DESC LIMIT $%d`, len(args))
+
+	rows, err := s.client.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0)
+	for rows.Next() {
+		var event models.AuditEvent
+		var id string
+		err := rows.Scan(&id, &event.Time, &event.Action, &event.Success, &event.ActorID, &event.Actor,
+			&event.Target, &event.ClientIP, &event.RequestID, &event.Details)
+		if err != nil {
+			return nil, err
+		}
+		if event.ID, err = objectID(id); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Expire deletes expired refresh and email tokens and audit events older
+// than auditRetention
+func (s *PostgresStore) Expire(ctx context.Context, auditRetention time.Duration) error {
+	for _, table := range []string{"refresh_tokens", "user_tokens"} {
+		if _, err := s.client.Exec(ctx, `DELETE FROM `+table+` WHERE expires_at <= now()`); err != nil {
+			return fmt.Errorf("expire %s: %w", table, err)
+		}
+	}
+
+	if auditRetention <= 0 {
+		return nil
+	}
+	if _, err := s.client.Exec(ctx, `DELETE FROM audit_events WHERE time < $1`, time.Now().Add(-auditRetention)); err != nil {
+		return fmt.Errorf("expire audit events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package store persists the gateway's users, registered services and
+// audit events. Each backend, MongoDB or PostgreSQL, implements the same
+// interfaces so handlers don't depend on the database in use.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway/internal/migrate"
+	"api-gateway/internal/models"
+	"api-gateway/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no record matches
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicate is returned when a write would break a unique
+	// constraint, such as a taken username
+	ErrDuplicate = errors.New("duplicate")
+)
+
+// UserUpdate changes the fields that are set. UpdatedAt is always bumped.
+type UserUpdate struct {
+	Username      *string
+	Email         *string
+	Password      *string
+	Role          *string
+	Active        *bool
+	EmailVerified *bool
+	DeletedAt     *time.Time
+}
+
+// UserStore keeps users, their refresh and email tokens, and roles
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	FindUser(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindUserByUsername(ctx context.Context, username string) (*models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	FindUserByIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.User, error)
+
+	// UserExists reports whether another user than except has the
+	// username or email. Empty values are not looked up.
+	UserExists(ctx context.Context, username, email string, except primitive.ObjectID) (bool, error)
+
+	UpdateUser(ctx context.Context, id primitive.ObjectID, update UserUpdate) error
+
+	// LinkIdentity adds an external identity to the user with the email
+	// and returns the updated user
+	LinkIdentity(ctx context.Context, email string, identity models.ExternalIdentity) (*models.User, error)
+
+	// VerifyEmail marks the email of a user verified and activates the
+	// account, unless the account was deleted
+	VerifyEmail(ctx context.Context, id primitive.ObjectID) error
+
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+
+	// ConsumeRefreshToken atomically revokes an unrevoked token and
+	// returns it, so concurrent refreshes can't both succeed
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error
+
+	CreateUserToken(ctx context.Context, token *models.UserToken) error
+
+	// ConsumeUserToken atomically marks an unused, unexpired token for
+	// purpose as used and returns it
+	ConsumeUserToken(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error)
+
+	ListRoles(ctx context.Context) ([]models.Role, error)
+
+	// SaveRole creates or replaces a role
+	SaveRole(ctx context.Context, req models.RoleRequest) (*models.Role, error)
+
+	DeleteRole(ctx context.Context, name string) error
+}
+
+// ServiceStore keeps the services registered through the admin API, so
+// they survive restarts
+type ServiceStore interface {
+	ListServices(ctx context.Context) ([]models.RegisteredService, error)
+
+	// SaveService creates or replaces a service
+	SaveService(ctx context.Context, svc *models.RegisteredService) error
+
+	DeleteService(ctx context.Context, name string) error
+}
+
+// AuditStore keeps audit events
+type AuditStore interface {
+	InsertAuditEvent(ctx context.Context, event *models.AuditEvent) error
+
+	// QueryAuditEvents returns matching events, newest first
+	QueryAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error)
+}
+
+// Store is a storage backend
+type Store interface {
+	UserStore
+	ServiceStore
+	AuditStore
+
+	// Backend names the database, e.g. "mongo"
+	Backend() string
+
+	Ping(ctx context.Context) error
+
+	// Migrations returns the runner for the backend's schema
+	Migrations(log *logger.Logger) *migrate.Runner
+
+	// Expire removes expired tokens and audit events older than
+	// auditRetention; zero keeps audit events forever. It is run
+	// periodically.
+	Expire(ctx context.Context, auditRetention time.Duration) error
+}
+
+const defaultAuditQueryLimit = 100
+
+// auditQueryLimit is the number of events a query returns
+func auditQueryLimit(q models.AuditQuery) int64 {
+	if q.Limit <= 0 {
+		return defaultAuditQueryLimit
+	}
+	return q.Limit
+}
@@ -0,0 +1,82 @@
+package transform
+
+import "sync"
+
+// Definition is the source form of a route's rules, kept so the admin API
+// can show what is currently active
+type Definition struct {
+	Request  []string `json:"request"`
+	Response []string `json:"response"`
+}
+
+// Store holds the active pipeline per route. It outlives router rebuilds
+// so admin changes apply immediately without touching the route table.
+type Store struct {
+	mu          sync.RWMutex
+	pipelines   map[string]*Pipeline
+	definitions map[string]Definition
+}
+
+func NewStore() *Store {
+	return &Store{
+		pipelines:   make(map[string]*Pipeline),
+		definitions: make(map[string]Definition),
+	}
+}
+
+func (s *Store) Get(route string) *Pipeline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pipelines[route]
+}
+
+// Set compiles and activates the rules for a route. Empty rules remove it.
+func (s *Store) Set(route string, def Definition) error {
+	pipeline, err := Compile(def.Request, def.Response)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(def.Request) == 0 && len(def.Response) == 0 {
+		delete(s.pipelines, route)
+		delete(s.definitions, route)
+		return nil
+	}
+
+	s.pipelines[route] = pipeline
+	s.definitions[route] = def
+	return nil
+}
+
+// Replace swaps all definitions at once, e.g. on config reload
+func (s *Store) Replace(defs map[string]Definition) error {
+	pipelines := make(map[string]*Pipeline, len(defs))
+	for route, def := range defs {
+		pipeline, err := Compile(def.Request, def.Response)
+		if err != nil {
+			return err
+		}
+		pipelines[route] = pipeline
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pipelines = pipelines
+	s.definitions = defs
+	return nil
+}
+
+func (s *Store) List() map[string]Definition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	defs := make(map[string]Definition, len(s.definitions))
+	for route, def := range s.definitions {
+		defs[route] = def
+	}
+	return defs
+}
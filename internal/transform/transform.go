@@ -0,0 +1,202 @@
+package transform
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Context is the mutable view of a request or response that rules act on.
+// Lookup resolves template variables such as jwt.sub or header.X-Name.
+type Context struct {
+	Header http.Header
+	Path   string
+	Lookup func(name string) string
+}
+
+type Rule interface {
+	Apply(ctx *Context)
+}
+
+// Pipeline holds the ordered rules run before forwarding a request and
+// after receiving the upstream response
+type Pipeline struct {
+	Request  []Rule
+	Response []Rule
+}
+
+func (p *Pipeline) ApplyRequest(ctx *Context) {
+	for _, rule := range p.Request {
+		rule.Apply(ctx)
+	}
+}
+
+func (p *Pipeline) ApplyResponse(ctx *Context) {
+	for _, rule := range p.Response {
+		rule.Apply(ctx)
+	}
+}
+
+// Compile parses request and response rule definitions into a pipeline
+func Compile(request, response []string) (*Pipeline, error) {
+	p := &Pipeline{}
+
+	for _, def := range request {
+		rule, err := Parse(def)
+		if err != nil {
+			return nil, err
+		}
+		p.Request = append(p.Request, rule)
+	}
+
+	for _, def := range response {
+		rule, err := Parse(def)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rule.(pathRule); ok {
+			return nil, fmt.Errorf("rule %q: path rules only apply to requests", def)
+		}
+		p.Response = append(p.Response, rule)
+	}
+
+	return p, nil
+}
+
+// Parse turns a single rule definition into a Rule. Supported rules:
+//
+//	set_header <name> <value>
+//	add_header <name> <value>
+//	remove_header <name>
+//	strip_prefix <prefix>
+//	add_prefix <prefix>
+//	rewrite_path <regex> <replacement>
+//
+// Header values may reference variables as {{jwt.sub}}, {{header.X-Name}},
+// {{query.name}}, {{request_id}} or {{client_ip}}.
+func Parse(def string) (Rule, error) {
+	fields := strings.Fields(def)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty transformation rule")
+	}
+
+	op, args := fields[0], fields[1:]
+	switch op {
+	case "set_header", "add_header":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("rule %q: expected <name> <value>", def)
+		}
+		return headerRule{
+			name:   http.CanonicalHeaderKey(args[0]),
+			value:  strings.Join(args[1:], " "),
+			append: op == "add_header",
+		}, nil
+	case "remove_header":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("rule %q: expected <name>", def)
+		}
+		return removeHeaderRule{name: args[0]}, nil
+	case "strip_prefix":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("rule %q: expected <prefix>", def)
+		}
+		return stripPrefixRule{prefix: strings.TrimSuffix(args[0], "/")}, nil
+	case "add_prefix":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("rule %q: expected <prefix>", def)
+		}
+		return addPrefixRule{prefix: strings.TrimSuffix(args[0], "/")}, nil
+	case "rewrite_path":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("rule %q: expected <regex> <replacement>", def)
+		}
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", def, err)
+		}
+		return rewritePathRule{re: re, replacement: args[1]}, nil
+	default:
+		return nil, fmt.Errorf("rule %q: unknown operation %q", def, op)
+	}
+}
+
+var templateVar = regexp.MustCompile(`\{\{\s*([\w.\-]+)\s*\}\}`)
+
+// Expand replaces {{name}} variables in value with what lookup returns
+func Expand(value string, lookup func(string) string) string {
+	if lookup == nil || !strings.Contains(value, "{{") {
+		return value
+	}
+	return templateVar.ReplaceAllStringFunc(value, func(match string) string {
+		return lookup(templateVar.FindStringSubmatch(match)[1])
+	})
+}
+
+type headerRule struct {
+	name   string
+	value  string
+	append bool
+}
+
+func (r headerRule) Apply(ctx *Context) {
+	value := Expand(r.value, ctx.Lookup)
+	if r.append {
+		ctx.Header.Add(r.name, value)
+		return
+	}
+	ctx.Header.Set(r.name, value)
+}
+
+type removeHeaderRule struct {
+	name string
+}
+
+func (r removeHeaderRule) Apply(ctx *Context) {
+	ctx.Header.Del(r.name)
+}
+
+// pathRule marks rules that only make sense on requests
+type pathRule interface {
+	isPathRule()
+}
+
+type stripPrefixRule struct {
+	prefix string
+}
+
+func (stripPrefixRule) isPathRule() {}
+
+func (r stripPrefixRule) Apply(ctx *Context) {
+	if ctx.Path == r.prefix || strings.HasPrefix(ctx.Path, r.prefix+"/") {
+		ctx.Path = strings.TrimPrefix(ctx.Path, r.prefix)
+		if ctx.Path == "" {
+			ctx.Path = "/"
+		}
+	}
+}
+
+type addPrefixRule struct {
+	prefix string
+}
+
+func (addPrefixRule) isPathRule() {}
+
+func (r addPrefixRule) Apply(ctx *Context) {
+	if ctx.Path == "/" {
+		ctx.Path = r.prefix
+		return
+	}
+	ctx.Path = r.prefix + ctx.Path
+}
+
+type rewritePathRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+func (rewritePathRule) isPathRule() {}
+
+func (r rewritePathRule) Apply(ctx *Context) {
+	ctx.Path = r.re.ReplaceAllString(ctx.Path, r.replacement)
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bufio"
+	"sync/atomic"
+
+	"api-gateway/internal/metrics"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// overflowSampleEvery is the share of records OverflowSample keeps while
+// the buffer is under pressure
+const overflowSampleEvery = 10
+
+// asyncCore encodes records in the caller, so they can't change once
+// queued, and leaves the writing to an asyncWriter
+type asyncCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   *asyncWriter
+}
+
+func newAsyncCore(enc zapcore.Encoder, level zapcore.LevelEnabler, w *asyncWriter) *asyncCore {
+	return &asyncCore{LevelEnabler: level, enc: enc, w: w}
+}
+
+func (c *asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &asyncCore{LevelEnabler: c.LevelEnabler, enc: enc, w: c.w}
+}
+
+func (c *asyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *asyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	c.w.enqueue(ent.Level, buf)
+	// Panics and fatal errors end the process, so they go out at once
+	if ent.Level > zapcore.ErrorLevel {
+		return c.Sync()
+	}
+	return nil
+}
+
+func (c *asyncCore) Sync() error {
+	return c.w.sync()
+}
+
+// asyncWriter writes queued records from a single goroutine, batching
+// those that pile up into fewer writes
+type asyncWriter struct {
+	out      zapcore.WriteSyncer
+	queue    chan *buffer.Buffer
+	syncs    chan chan error
+	overflow string
+	pressure atomic.Uint64
+}
+
+func newAsyncWriter(out zapcore.WriteSyncer, size int, overflow string) *asyncWriter {
+	if size <= 0 {
+		size = 8192
+	}
+	w := &asyncWriter{
+		out:      out,
+		queue:    make(chan *buffer.Buffer, size),
+		syncs:    make(chan chan error),
+		overflow: overflow,
+	}
+	go w.run()
+	return w
+}
+
+func (w *asyncWriter) enqueue(level zapcore.Level, buf *buffer.Buffer) {
+	if level >= zapcore.WarnLevel || w.overflow == OverflowBlock {
+		w.queue <- buf
+		return
+	}
+	if w.overflow == OverflowSample && len(w.queue) >= cap(w.queue)/2 &&
+		w.pressure.Add(1)%overflowSampleEvery != 0 {
+		dropped(level, "backpressure")
+		buf.Free()
+		return
+	}
+	select {
+	case w.queue <- buf:
+	default:
+		dropped(level, "buffer_full")
+		buf.Free()
+	}
+}
+
+// sync waits until every record queued so far is written
+func (w *asyncWriter) sync() error {
+	done := make(chan error)
+	w.syncs <- done
+	return <-done
+}
+
+func (w *asyncWriter) run() {
+	bw := bufio.NewWriterSize(w.out, 64*1024)
+	write := func(buf *buffer.Buffer) {
+		bw.Write(buf.Bytes())
+		buf.Free()
+	}
+
+	for {
+		select {
+		case buf := <-w.queue:
+			write(buf)
+			if len(w.queue) == 0 {
+				bw.Flush()
+			}
+		case done := <-w.syncs:
+			for n := len(w.queue); n > 0; n-- {
+				write(<-w.queue)
+			}
+			err := bw.Flush()
+			if syncErr := w.out.Sync(); err == nil {
+				err = syncErr
+			}
+			done <- err
+		}
+	}
+}
+
+func dropped(level zapcore.Level, reason string) {
+	metrics.LogRecordsDropped.WithLabelValues(level.String(), reason).Inc()
+}
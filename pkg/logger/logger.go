@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"os"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -9,45 +11,91 @@ type Logger struct {
 	*zap.SugaredLogger
 }
 
+// Overflow policies for async logging, applied to debug and info records
+// while the buffer is full. Warnings and errors always wait for room.
+const (
+	// OverflowBlock makes the logging call wait for room
+	OverflowBlock = "block"
+	// OverflowDrop drops the record
+	OverflowDrop = "drop"
+	// OverflowSample keeps one record in ten once the buffer is half full
+	// and drops the rest, or all of them while it is full
+	OverflowSample = "sample"
+)
+
+// Options configure a logger. With Async records are written by a
+// background goroutine from a buffer of BufferSize records, so callers
+// don't wait on stdout. Sampling thins out repeated records by level.
+type Options struct {
+	Level      string
+	Async      bool
+	BufferSize int
+	Overflow   string
+	Sampling   map[string]Sampling
+}
+
+// Sampling keeps the first Initial records per second with the same
+// message, then every Thereafter-th. A zero Thereafter drops the rest.
+type Sampling struct {
+	Initial    int
+	Thereafter int
+}
+
 func NewLogger(level string) *Logger {
-	var zapLevel zapcore.Level
+	return New(Options{Level: level})
+}
+
+func New(opts Options) *Logger {
+	level := zap.NewAtomicLevelAt(ParseLevel(opts.Level))
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		TimeKey:        "timestamp",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "message",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	})
+	out := zapcore.Lock(os.Stdout)
+
+	var core zapcore.Core
+	if opts.Async {
+		core = newAsyncCore(encoder, level, newAsyncWriter(out, opts.BufferSize, opts.Overflow))
+	} else {
+		core = zapcore.NewCore(encoder, out, level)
+	}
+	if len(opts.Sampling) > 0 {
+		core = newSamplingCore(core, opts.Sampling)
+	}
+
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return &Logger{logger.Sugar()}
+}
+
+// ParseLevel maps debug, info, warn and error to their level, defaulting
+// to info
+func ParseLevel(level string) zapcore.Level {
 	switch level {
 	case "debug":
-		zapLevel = zapcore.DebugLevel
-	case "info":
-		zapLevel = zapcore.InfoLevel
+		return zapcore.DebugLevel
 	case "warn":
-		zapLevel = zapcore.WarnLevel
+		return zapcore.WarnLevel
 	case "error":
-		zapLevel = zapcore.ErrorLevel
+		return zapcore.ErrorLevel
 	default:
-		zapLevel = zapcore.InfoLevel
-	}
-
-	config := zap.Config{
-		Level:            zap.NewAtomicLevelAt(zapLevel),
-		Encoding:         "json",
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stderr"},
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "timestamp",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			MessageKey:     "message",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.LowercaseLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
+		return zapcore.InfoLevel
 	}
-
-	logger, _ := config.Build()
-	return &Logger{logger.Sugar()}
 }
 
+// Sync writes out buffered records
 func (l *Logger) Sync() {
 	l.SugaredLogger.Sync()
 }
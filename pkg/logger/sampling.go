@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// sampleBuckets bounds the memory sampling takes; messages sharing a
+// bucket are counted together
+const sampleBuckets = 4096
+
+// samplingCore drops records beyond the sampling rule of their level.
+// Unlike zap's sampler the rules differ by level.
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]*levelSampler
+}
+
+func newSamplingCore(core zapcore.Core, rules map[string]Sampling) *samplingCore {
+	samplers := make(map[zapcore.Level]*levelSampler, len(rules))
+	for level, rule := range rules {
+		samplers[ParseLevel(level)] = &levelSampler{
+			initial:    uint64(rule.Initial),
+			thereafter: uint64(rule.Thereafter),
+		}
+	}
+	return &samplingCore{Core: core, samplers: samplers}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{Core: c.Core.With(fields), samplers: c.samplers}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if s := c.samplers[ent.Level]; s != nil && !s.keep(ent) {
+		dropped(ent.Level, "sampled")
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+type levelSampler struct {
+	initial, thereafter uint64
+	counts              [sampleBuckets]sampleCounter
+}
+
+func (s *levelSampler) keep(ent zapcore.Entry) bool {
+	h := fnv.New32a()
+	h.Write([]byte(ent.Message))
+	n := s.counts[h.Sum32()%sampleBuckets].inc(ent.Time)
+	return n <= s.initial || s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// sampleCounter counts the records of one second
+type sampleCounter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+func (c *sampleCounter) inc(t time.Time) uint64 {
+	now := t.UnixNano()
+	resetAt := c.resetAt.Load()
+	if now < resetAt {
+		return c.n.Add(1)
+	}
+	c.n.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+int64(time.Second)) {
+		return c.n.Add(1)
+	}
+	return 1
+}
@@ -2,12 +2,19 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"api-gateway/internal/config"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 type MongoClient struct {
@@ -15,16 +22,41 @@ type MongoClient struct {
 	Database *mongo.Database
 }
 
+// NewMongoClient connects to MongoDB and checks that it answers
 func NewMongoClient(cfg config.MongoDBConfig) (*MongoClient, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	client, err := DialMongo(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Give the ping as long as server selection may take
+	timeout := 10 * time.Second
+	if cfg.ServerSelectionTimeout > timeout {
+		timeout = cfg.ServerSelectionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// DialMongo creates a client without checking that MongoDB is reachable;
+// it connects in the background
+func DialMongo(cfg config.MongoDBConfig) (*MongoClient, error) {
+	opts, err := mongoClientOptions(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := client.Ping(ctx, nil); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
 		return nil, err
 	}
 
@@ -34,6 +66,61 @@ func NewMongoClient(cfg config.MongoDBConfig) (*MongoClient, error) {
 	}, nil
 }
 
+// mongoClientOptions applies the configured options over those in the URI
+func mongoClientOptions(cfg config.MongoDBConfig) (*options.ClientOptions, error) {
+	opts := options.Client().ApplyURI(cfg.URI).SetRetryWrites(cfg.RetryWrites)
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(cfg.MaxPoolSize))
+	}
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(uint64(cfg.MinPoolSize))
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		pref, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(pref)
+	}
+
+	switch cfg.WriteConcern {
+	case "":
+	case "majority":
+		opts.SetWriteConcern(writeconcern.Majority())
+	default:
+		w, err := strconv.Atoi(cfg.WriteConcern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid write concern %q", cfg.WriteConcern)
+		}
+		opts.SetWriteConcern(&writeconcern.WriteConcern{W: w})
+	}
+
+	if cfg.TLS {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.TLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read TLS CA file: %w", err)
+			}
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in TLS CA file %s", cfg.TLSCAFile)
+			}
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	return opts, nil
+}
+
 func (m *MongoClient) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"api-gateway/internal/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresClient struct {
+	*pgxpool.Pool
+}
+
+// NewPostgresClient connects to PostgreSQL and checks that it answers
+func NewPostgresClient(cfg config.PostgresConfig) (*PostgresClient, error) {
+	client, err := DialPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// DialPostgres creates a connection pool without checking that PostgreSQL
+// is reachable; connections are opened on first use
+func DialPostgres(cfg config.PostgresConfig) (*PostgresClient, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.MaxConns = cfg.MaxConns
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresClient{Pool: pool}, nil
+}
+
+func (p *PostgresClient) Close() error {
+	p.Pool.Close()
+	return nil
+}
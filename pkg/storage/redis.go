@@ -13,21 +13,30 @@ type RedisClient struct {
 	*redis.Client
 }
 
+// NewRedisClient connects to Redis and checks that it answers
 func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	client := DialRedis(cfg)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, err
 	}
 
-	return &RedisClient{client}, nil
+	return client, nil
+}
+
+// DialRedis creates a client without checking that Redis is reachable;
+// it connects on first use
+func DialRedis(cfg config.RedisConfig) *RedisClient {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &RedisClient{client}
 }
 
 func (r *RedisClient) Close() error {
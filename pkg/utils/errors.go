@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the "code" field of error responses. Clients
+// should branch on these rather than on messages.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidation       = "VALIDATION_FAILED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	CodeConflict         = "CONFLICT"
+	CodeIdempotencyReuse = "IDEMPOTENCY_KEY_REUSED"
+	CodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeInternal         = "INTERNAL_ERROR"
+	CodeBadGateway       = "BAD_GATEWAY"
+	CodeUnavailable      = "SERVICE_UNAVAILABLE"
+	CodeGatewayTimeout   = "GATEWAY_TIMEOUT"
+)
+
+// problemJSON switches error bodies to RFC 7807 application/problem+json
+var problemJSON atomic.Bool
+
+// SetProblemJSON selects the error body format for all responses
+func SetProblemJSON(enabled bool) {
+	problemJSON.Store(enabled)
+}
+
+// APIError is the error model shared by handlers and middleware
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+func NewError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// WithDetail adds a machine readable detail, e.g. the limit that was hit
+func (e *APIError) WithDetail(key string, value interface{}) *APIError {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Problem is an RFC 7807 problem details body. Code, RequestID and the
+// error details are extension members.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// WriteError writes err in the configured format, or with the operator's
+// template for its status
+func WriteError(c *gin.Context, err *APIError) {
+	if writeTemplatedError(c, err) {
+		return
+	}
+	requestID := c.GetString("request_id")
+
+	if problemJSON.Load() {
+		c.Render(err.Status, problemRender{Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(err.Status),
+			Status:    err.Status,
+			Detail:    err.Message,
+			Instance:  c.Request.URL.Path,
+			Code:      err.Code,
+			RequestID: requestID,
+			Details:   err.Details,
+		}})
+		return
+	}
+
+	c.JSON(err.Status, Response{
+		Success:   false,
+		Error:     err.Message,
+		Code:      err.Code,
+		Details:   err.Details,
+		RequestID: requestID,
+	})
+}
+
+// AbortWithError writes err and stops the handler chain
+func AbortWithError(c *gin.Context, err *APIError) {
+	WriteError(c, err)
+	c.Abort()
+}
+
+// CodeForStatus is the default code of an HTTP status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway:
+		return CodeBadGateway
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeGatewayTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
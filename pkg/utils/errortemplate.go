@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ErrorTemplate is an operator supplied error body. A format left empty
+// keeps the built-in body.
+type ErrorTemplate struct {
+	JSON string
+	HTML string
+}
+
+// errorTemplates holds the templates by status ("404") and class ("4xx")
+var errorTemplates atomic.Pointer[map[string]ErrorTemplate]
+
+// SetErrorTemplates replaces the error templates for all responses
+func SetErrorTemplates(templates map[string]ErrorTemplate) {
+	errorTemplates.Store(&templates)
+}
+
+var errorTemplateVar = regexp.MustCompile(`\{\{\s*([\w.\-]+)\s*\}\}`)
+
+// writeTemplatedError writes err with the template for its status, if one
+// suits the client. It reports false if none does.
+func writeTemplatedError(c *gin.Context, err *APIError) bool {
+	templates := errorTemplates.Load()
+	if templates == nil {
+		return false
+	}
+	tmpl, ok := (*templates)[strconv.Itoa(err.Status)]
+	if !ok {
+		tmpl, ok = (*templates)[strconv.Itoa(err.Status/100)+"xx"]
+	}
+	if !ok {
+		return false
+	}
+
+	switch {
+	case tmpl.HTML != "" && c.NegotiateFormat(binding.MIMEJSON, binding.MIMEHTML) == binding.MIMEHTML:
+		c.Data(err.Status, "text/html; charset=utf-8", []byte(expandErrorTemplate(c, err, tmpl.HTML, html.EscapeString)))
+	case tmpl.JSON != "":
+		c.Data(err.Status, "application/json; charset=utf-8", []byte(expandErrorTemplate(c, err, tmpl.JSON, jsonEscape)))
+	default:
+		return false
+	}
+	return true
+}
+
+// expandErrorTemplate replaces {{variables}} in tmpl, escaping their
+// values for the body's format. Unknown variables expand to nothing.
+func expandErrorTemplate(c *gin.Context, err *APIError, tmpl string, escape func(string) string) string {
+	return errorTemplateVar.ReplaceAllStringFunc(tmpl, func(match string) string {
+		var value string
+		switch errorTemplateVar.FindStringSubmatch(match)[1] {
+		case "status":
+			value = strconv.Itoa(err.Status)
+		case "status_text":
+			value = http.StatusText(err.Status)
+		case "code":
+			value = err.Code
+		case "message":
+			value = err.Message
+		case "request_id":
+			value = c.GetString("request_id")
+		case "service":
+			value = c.GetString("service")
+		case "method":
+			value = c.Request.Method
+		case "path":
+			value = c.Request.URL.Path
+		}
+		return escape(value)
+	})
+}
+
+// jsonEscape escapes s for use inside a JSON string
+func jsonEscape(s string) string {
+	quoted, _ := json.Marshal(s)
+	return strings.TrimSuffix(strings.TrimPrefix(string(quoted), `"`), `"`)
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"api-gateway/internal/models"
@@ -14,26 +15,80 @@ type Claims struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Role     string `json:"role"`
+
+	// TenantID ties the token to a tenant on multi-tenant gateways
+	TenantID string `json:"tenant_id,omitempty"`
+
+	Permissions []string `json:"permissions,omitempty"`
+
+	// Scope is a space-separated OAuth scope list. Tokens without scopes
+	// are not restricted by route scope requirements.
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
+
+	// Raw holds every claim of a verified token, including those the
+	// gateway doesn't use, for claims validators
+	Raw map[string]interface{} `json:"-"`
+}
+
+// Claim returns a claim of the token by name
+func (c *Claims) Claim(name string) (interface{}, bool) {
+	value, ok := c.Raw[name]
+	return value, ok
+}
+
+// TokenPolicy holds the registered claims the gateway's own tokens are
+// issued with and must carry; an empty Issuer or Audience is neither set
+// nor required. Leeway tolerates clock skew when checking exp and nbf.
+type TokenPolicy struct {
+	Issuer   string
+	Audience []string
+	Leeway   time.Duration
+}
+
+// TokenGrant carries what an access token is allowed to do
+type TokenGrant struct {
+	Permissions []string
+	Scopes      []string
+}
+
+// Scopes returns the token's scopes
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
 }
 
-func GenerateToken(user *models.User, secret string, expiry time.Duration) (string, time.Time, error) {
+// GenerateToken signs a token for user with key, using its algorithm. The
+// key's ID, when set, names it in the kid header.
+func GenerateToken(user *models.User, grant TokenGrant, key SigningKey, policy TokenPolicy, expiry time.Duration) (string, time.Time, error) {
 	expiresAt := time.Now().Add(expiry)
 
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
 	claims := Claims{
 		UserID:   user.ID.Hex(),
 		Username: user.Username,
 		Email:    user.Email,
 		Role:     user.Role,
+		TenantID: user.TenantID,
+
+		Permissions: grant.Permissions,
+		Scope:       strings.Join(grant.Scopes, " "),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    policy.Issuer,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if len(policy.Audience) > 0 {
+		claims.Audience = jwt.ClaimStrings(policy.Audience)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := signToken(claims, key)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -41,15 +96,65 @@ func GenerateToken(user *models.User, secret string, expiry time.Duration) (stri
 	return tokenString, expiresAt, nil
 }
 
-func ValidateToken(tokenString, secret string) (*Claims, error) {
+// InternalTokenAudience marks the tokens the gateway forwards to upstream
+// services. The gateway itself doesn't accept them, so an upstream can't
+// replay one against other routes.
+const InternalTokenAudience = "gateway-internal"
+
+// GenerateInternalToken re-issues the caller's claims as a short-lived
+// token for upstream services
+func GenerateInternalToken(claims Claims, key SigningKey, expiry time.Duration) (string, error) {
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   claims.UserID,
+		Audience:  jwt.ClaimStrings{InternalTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+	return signToken(claims, key)
+}
+
+func signToken(claims Claims, key SigningKey) (string, error) {
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.Key)
+}
+
+// TokenKeyID returns the kid header of a token without verifying it
+func TokenKeyID(tokenString string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// ValidateToken verifies a token signed with key and checks it against
+// policy. Tokens signed with another algorithm fail with
+// jwt.ErrTokenUnverifiable.
+func ValidateToken(tokenString string, key SigningKey, policy TokenPolicy) (*Claims, error) {
 	claims := &Claims{}
 
+	options := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithLeeway(policy.Leeway)}
+	if policy.Issuer != "" {
+		options = append(options, jwt.WithIssuer(policy.Issuer))
+	}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(secret), nil
-	})
+		return key.verificationKey(), nil
+	}, options...)
 
 	if err != nil {
 		return nil, err
@@ -58,6 +163,32 @@ func ValidateToken(tokenString, secret string) (*Claims, error) {
 	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
+	for _, audience := range claims.Audience {
+		if audience == InternalTokenAudience {
+			return nil, errors.New("internal tokens are only valid upstream")
+		}
+	}
+	if len(policy.Audience) > 0 && !hasAudience(claims.Audience, policy.Audience) {
+		return nil, errors.New("token audience is not accepted")
+	}
+
+	// The token has already been verified, so this only decodes it again
+	raw := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, raw); err != nil {
+		return nil, err
+	}
+	claims.Raw = raw
 
 	return claims, nil
 }
+
+func hasAudience(got, accepted []string) bool {
+	for _, want := range accepted {
+		for _, aud := range got {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms the gateway can sign its tokens with
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is a key tokens are signed and verified with. Key is the
+// secret for HS256, an *rsa.PrivateKey for RS256 and an
+// ed25519.PrivateKey for EdDSA.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// NewSigningKey builds a key from its material: the secret for HS256, a
+// PEM encoded private key otherwise
+func NewSigningKey(id, algorithm, material string) (SigningKey, error) {
+	key := SigningKey{ID: id, Algorithm: algorithm}
+	switch algorithm {
+	case "", AlgHS256:
+		key.Algorithm = AlgHS256
+		key.Key = []byte(material)
+		return key, nil
+	case AlgRS256, AlgEdDSA:
+		private, err := ParsePrivateKeyPEM([]byte(material))
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if _, ok := private.(*rsa.PrivateKey); ok != (algorithm == AlgRS256) {
+			return SigningKey{}, fmt.Errorf("private key does not match %s", algorithm)
+		}
+		key.Key = private
+		return key, nil
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// KeyThumbprint derives a stable key ID from the key material, so every
+// instance configured with the same key names it alike. Asymmetric keys
+// are identified by their public half.
+func KeyThumbprint(key SigningKey) string {
+	var data []byte
+	switch k := key.Key.(type) {
+	case []byte:
+		data = k
+	case crypto.Signer:
+		data, _ = x509.MarshalPKIXPublicKey(k.Public())
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// verificationKey returns what jwt verifies signatures with: the secret
+// or the public key
+func (k SigningKey) verificationKey() interface{} {
+	if signer, ok := k.Key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.Key
+}
+
+// Asymmetric reports whether the key has a public half that can be
+// published
+func (k SigningKey) Asymmetric() bool {
+	return k.Algorithm == AlgRS256 || k.Algorithm == AlgEdDSA
+}
+
+// JWK describes the key as a JSON Web Key. For asymmetric keys that is
+// the public key only; for HS256 it contains the secret.
+func (k SigningKey) JWK() map[string]string {
+	jwk := map[string]string{
+		"kid": k.ID,
+		"alg": k.Algorithm,
+		"use": "sig",
+	}
+	switch key := k.verificationKey().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(key)
+	case []byte:
+		jwk["kty"] = "oct"
+		jwk["k"] = base64.RawURLEncoding.EncodeToString(key)
+	}
+	return jwk
+}
+
+// GeneratePrivateKeyPEM creates a private key for algorithm, PKCS #8 and
+// PEM encoded: RSA 2048 bit for RS256, Ed25519 for EdDSA
+func GeneratePrivateKeyPEM(algorithm string) (string, error) {
+	var private interface{}
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("can't generate a private key for %q", algorithm)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKeyPEM reads an RSA or Ed25519 private key in PKCS #8 or,
+// for RSA, PKCS #1 form
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
@@ -11,6 +11,13 @@ type Response struct {
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+
+	// Code and Details describe errors for programmatic handling
+	Code    string                 `json:"code,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+
+	// RequestID lets clients quote a failed request to support
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func SuccessResponse(c *gin.Context, statusCode int, message string, data interface{}) {
@@ -21,16 +28,24 @@ func SuccessResponse(c *gin.Context, statusCode int, message string, data interf
 	})
 }
 
+// ErrorResponse writes an error with the default code for statusCode
 func ErrorResponse(c *gin.Context, statusCode int, message string) {
-	c.JSON(statusCode, Response{
-		Success: false,
-		Error:   message,
-	})
+	WriteError(c, NewError(statusCode, CodeForStatus(statusCode), message))
 }
 
+// ValidationErrorResponse writes a binding error, listing the fields it
+// rejected under details.errors
 func ValidationErrorResponse(c *gin.Context, err error) {
-	c.JSON(http.StatusBadRequest, Response{
-		Success: false,
-		Error:   err.Error(),
-	})
+	switch fields := FieldErrors(err); {
+	case fields != nil:
+		WriteError(c, NewError(http.StatusBadRequest, CodeValidation, "Request validation failed").
+			WithDetail("errors", fields))
+	case isMissingBody(err):
+		WriteError(c, NewError(http.StatusBadRequest, CodeValidation, "Request body is required"))
+	case isMalformedJSON(err):
+		WriteError(c, NewError(http.StatusBadRequest, CodeBadRequest, "Malformed JSON body").
+			WithDetail("error", err.Error()))
+	default:
+		WriteError(c, NewError(http.StatusBadRequest, CodeValidation, err.Error()))
+	}
 }